@@ -0,0 +1,28 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// DefaultExpiration uses the default expiration that the cache was constructed with
+	DefaultExpiration time.Duration = 0
+	// NoExpiration means the item never expires
+	NoExpiration time.Duration = -1
+)
+
+// Cache is a key value cache of values of type T
+// Implementations may be process local or shared between replicas of the api server
+type Cache[T any] interface {
+	// Get gets the value for the key from the cache
+	// If the key is not in the cache, it returns false for the second return value
+	Get(ctx context.Context, key string) (T, bool, error)
+
+	// Set sets the value for the key in the cache, expiring it after the given ttl
+	// Use DefaultExpiration to use the default ttl of the cache
+	Set(ctx context.Context, key string, value T, ttl time.Duration) error
+
+	// Delete removes the key from the cache, it is not an error if the key is not in the cache
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/redis/go-redis/v9"
+	"testing"
+	"time"
+)
+
+func testCache(t *testing.T, c Cache[[]api.Incident]) {
+	t.Helper()
+	ctx := context.Background()
+	incidents := []api.Incident{
+		api.NewIncident("title", []string{"API"}, nil, time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC), nil, nil, "https://status.example.com/incidents/a", api.ImpactMajor, "https://status.example.com"),
+	}
+
+	_, found, err := c.Get(ctx, "key")
+	if err != nil || found {
+		t.Fatalf("expected a miss for an unset key, got found=%v err=%v", found, err)
+	}
+
+	if err := c.Set(ctx, "key", incidents, DefaultExpiration); err != nil {
+		t.Fatalf("unexpected error setting key: %v", err)
+	}
+	cached, found, err := c.Get(ctx, "key")
+	if err != nil || !found {
+		t.Fatalf("expected a hit after setting key, got found=%v err=%v", found, err)
+	}
+	if len(cached) != 1 || cached[0].DeepLink != incidents[0].DeepLink || !cached[0].StartTime.Equal(incidents[0].StartTime) || cached[0].Components[0] != "API" {
+		t.Fatalf("cached incidents do not match, got %v", cached)
+	}
+
+	if err := c.Delete(ctx, "key"); err != nil {
+		t.Fatalf("unexpected error deleting key: %v", err)
+	}
+	_, found, err = c.Get(ctx, "key")
+	if err != nil || found {
+		t.Fatalf("expected a miss after deleting key, got found=%v err=%v", found, err)
+	}
+}
+
+func TestInMemoryCache(t *testing.T) {
+	testCache(t, NewInMemoryCache[[]api.Incident](time.Minute, time.Minute))
+}
+
+func TestRedisCache(t *testing.T) {
+	miniRedis := miniredis.RunT(t)
+	c := NewRedisCache[[]api.Incident](redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}), "incidents", time.Minute)
+	testCache(t, c)
+
+	if err := c.Set(context.Background(), "key", nil, DefaultExpiration); err != nil {
+		t.Fatalf("unexpected error setting key: %v", err)
+	}
+	if ttl := miniRedis.TTL("incidents:key"); ttl != time.Minute {
+		t.Fatalf("expected the default expiration to be used, got %v", ttl)
+	}
+	if err := c.Set(context.Background(), "key", nil, NoExpiration); err != nil {
+		t.Fatalf("unexpected error setting key: %v", err)
+	}
+	if ttl := miniRedis.TTL("incidents:key"); ttl != 0 {
+		t.Fatalf("expected no expiration, got %v", ttl)
+	}
+}
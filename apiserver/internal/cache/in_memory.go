@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"github.com/patrickmn/go-cache"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// InMemoryCache is a process local cache backed by go-cache
+type InMemoryCache[T any] struct {
+	cache *cache.Cache
+}
+
+func NewInMemoryCache[T any](defaultExpiration time.Duration, cleanupInterval time.Duration) *InMemoryCache[T] {
+	return &InMemoryCache[T]{
+		cache: cache.New(defaultExpiration, cleanupInterval),
+	}
+}
+
+func (c *InMemoryCache[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var empty T
+	value, found := c.cache.Get(key)
+	if !found {
+		return empty, false, nil
+	}
+
+	valueCasted, ok := value.(T)
+	if !ok {
+		return empty, false, errors.Errorf("failed to cast cached value to %T", empty)
+	}
+	return valueCasted, true, nil
+}
+
+func (c *InMemoryCache[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	c.cache.Set(key, value, ttl)
+	return nil
+}
+
+func (c *InMemoryCache[T]) Delete(ctx context.Context, key string) error {
+	c.cache.Delete(key)
+	return nil
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// RedisCache is a cache backed by redis so that it can be shared between replicas of the api server
+// Values are serialized as json
+type RedisCache[T any] struct {
+	client            redis.UniversalClient
+	prefix            string
+	defaultExpiration time.Duration
+}
+
+// NewRedisCache creates a cache that stores its values in redis under keys prefixed by prefix
+// The prefix should be unique per cache so that caches sharing a redis instance don't collide
+func NewRedisCache[T any](client redis.UniversalClient, prefix string, defaultExpiration time.Duration) *RedisCache[T] {
+	return &RedisCache[T]{
+		client:            client,
+		prefix:            prefix,
+		defaultExpiration: defaultExpiration,
+	}
+}
+
+func (c *RedisCache[T]) key(key string) string {
+	return c.prefix + ":" + key
+}
+
+func (c *RedisCache[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var value T
+	marshalled, err := c.client.Get(ctx, c.key(key)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return value, false, nil
+		}
+		return value, false, errors.Wrap(err, "failed to get value from redis")
+	}
+
+	if err := json.Unmarshal(marshalled, &value); err != nil {
+		return value, false, errors.Wrap(err, "failed to unmarshal value from redis")
+	}
+	return value, true, nil
+}
+
+func (c *RedisCache[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	marshalled, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal value for redis")
+	}
+
+	if ttl == DefaultExpiration {
+		ttl = c.defaultExpiration
+	}
+	if ttl == NoExpiration {
+		// Redis treats a zero expiration as no expiration
+		ttl = 0
+	}
+	return errors.Wrap(c.client.Set(ctx, c.key(key), marshalled, ttl).Err(), "failed to set value in redis")
+}
+
+func (c *RedisCache[T]) Delete(ctx context.Context, key string) error {
+	return errors.Wrap(c.client.Del(ctx, c.key(key)).Err(), "failed to delete value from redis")
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// ApiKeyScope is what an api key is allowed to call, each scope includes the scopes below it, so admin keys can also write and read
+type ApiKeyScope string
+
+const (
+	ApiKeyScopeRead  ApiKeyScope = "read"
+	ApiKeyScopeWrite ApiKeyScope = "write"
+	ApiKeyScopeAdmin ApiKeyScope = "admin"
+)
+
+var apiKeyScopeRanks = map[ApiKeyScope]int{ApiKeyScopeRead: 1, ApiKeyScopeWrite: 2, ApiKeyScopeAdmin: 3}
+
+// Includes returns true if a key with the scope is allowed to call the endpoints that need the other scope
+func (s ApiKeyScope) Includes(other ApiKeyScope) bool {
+	return apiKeyScopeRanks[s] >= apiKeyScopeRanks[other]
+}
+
+// ApiKey is an api key that can be sent as an Authorization: Bearer <key> header
+// Only the sha256 of the key is configured, so that the keys themselves don't have to be stored in the environment
+type ApiKey struct {
+	// ID identifies the key in the logs, as the key itself is never logged
+	ID    string
+	Scope ApiKeyScope
+	// Hash is the sha256 of the key
+	Hash []byte
+}
+
+// NewApiKey returns the api key with the id and scope whose secret is key
+func NewApiKey(id string, scope ApiKeyScope, key string) ApiKey {
+	hash := sha256.Sum256([]byte(key))
+	return ApiKey{ID: id, Scope: scope, Hash: hash[:]}
+}
+
+// UnmarshalText parses an api key in the form id:scope:sha256, where the sha256 of the key is hex encoded
+func (k *ApiKey) UnmarshalText(text []byte) error {
+	parts := strings.Split(string(text), ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return errors.New("api keys must be in the form id:scope:sha256")
+	}
+	scope := ApiKeyScope(parts[1])
+	if _, ok := apiKeyScopeRanks[scope]; !ok {
+		return errors.Errorf("the scope of the api key %s must be read, write or admin", parts[0])
+	}
+	hash, err := hex.DecodeString(parts[2])
+	if err != nil || len(hash) != sha256.Size {
+		return errors.Errorf("the hash of the api key %s must be a hex encoded sha256", parts[0])
+	}
+	*k = ApiKey{ID: parts[0], Scope: scope, Hash: hash}
+	return nil
+}
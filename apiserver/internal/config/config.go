@@ -0,0 +1,268 @@
+package config
+
+import (
+	"github.com/kelseyhightower/envconfig"
+	"github.com/metoro-io/statusphere/common/hosts"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"net/netip"
+	"time"
+)
+
+const (
+	CacheBackendMemory = "memory"
+	CacheBackendRedis  = "redis"
+)
+
+const (
+	// IncidentCacheKeyStatusPage caches the incidents of each status page under a single key, filtered requests filter them in memory
+	IncidentCacheKeyStatusPage = "statusPage"
+	// IncidentCacheKeyStatusPageAndImpacts also caches the incidents of each status page for each impact filter that is requested
+	IncidentCacheKeyStatusPageAndImpacts = "statusPageAndImpacts"
+)
+
+type Config struct {
+	// LogLevel is the minimum level of the logs that are written, e.g. debug, info, warn or error
+	LogLevel    string            `envconfig:"LOG_LEVEL" default:"info"`
+	LogSampling LogSamplingConfig `envconfig:"LOG_SAMPLING"`
+	// CacheBackend is either memory (process local caches) or redis (caches shared between replicas)
+	CacheBackend  string `envconfig:"CACHE_BACKEND" default:"memory"`
+	RedisAddress  string `envconfig:"REDIS_ADDRESS"`
+	RedisPassword string `envconfig:"REDIS_PASSWORD"`
+	RedisDatabase int    `envconfig:"REDIS_DATABASE"`
+	// AdminApiKey is a bearer token that can call every endpoint, it is the api key with the id admin and the admin scope
+	// The admin endpoints are disabled if neither it nor any of the ApiKeys have the admin scope
+	AdminApiKey string `envconfig:"ADMIN_API_KEY"`
+	// ApiKeys are the api keys that can be sent as bearer tokens, each as id:scope:sha256, e.g. ci:write:<hex sha256 of the key>
+	// The write endpoints require a key with the write scope once any keys are configured here, so that existing deployments keep working
+	ApiKeys []ApiKey `envconfig:"API_KEYS"`
+	// RequireApiKeyForReads is whether the read endpoints require a key with the read scope, they are public by default
+	RequireApiKeyForReads bool `envconfig:"REQUIRE_API_KEY_FOR_READS"`
+	// Hosts are the hosts that status pages can be submitted from, the scraper reads the same variables so that it only indexes them
+	Hosts hosts.Config `envconfig:"HOSTS"`
+	// TrustedProxies are the CIDRs of the proxies in front of the api server, e.g. 10.0.0.0/8, whose X-Forwarded-For and X-Real-IP headers
+	// are believed for the ip of the client. It is empty by default so that no proxy is trusted and clients can't spoof their ip
+	TrustedProxies []netip.Prefix `envconfig:"TRUSTED_PROXIES"`
+	// CompressionMinSize is the size in bytes below which responses are not compressed
+	CompressionMinSize int `envconfig:"COMPRESSION_MIN_SIZE" default:"1024"`
+	// CompressionEncodings are the encodings that responses can be compressed with, in the order they're preferred in when a client accepts
+	// several of them as much, they can be br, gzip and deflate
+	CompressionEncodings []string           `envconfig:"COMPRESSION_ENCODINGS" default:"br,gzip,deflate"`
+	Cache                CacheConfig        `envconfig:"CACHE"`
+	CacheControl         CacheControlConfig `envconfig:"CACHE_CONTROL"`
+	Cors                 CorsConfig         `envconfig:"CORS"`
+	Incidents            IncidentsConfig    `envconfig:"INCIDENTS"`
+	RateLimit            RateLimitConfig    `envconfig:"RATE_LIMIT"`
+	Tracing              TracingConfig      `envconfig:"TRACING"`
+
+	// ShutdownGracePeriod is how long in flight requests are given to complete when the server is shut down
+	ShutdownGracePeriod time.Duration `envconfig:"SHUTDOWN_GRACE_PERIOD" default:"30s"`
+}
+
+// LogSamplingConfig samples repeated logs so that an error logged by every request, e.g. when the database is down, doesn't flood the logs
+// Logs are repeated if they have the same level and message, the first Initial of them are logged in each Tick and then every Thereafter-th
+type LogSamplingConfig struct {
+	// Initial is the number of repeated logs that are logged in each tick before they're sampled, a negative number disables sampling
+	Initial int `envconfig:"INITIAL" default:"10"`
+	// Thereafter is how often repeated logs are logged once the initial ones have been, e.g. 100 logs every 100th log
+	Thereafter int `envconfig:"THEREAFTER" default:"100"`
+	// Tick is the window that repeated logs are counted in, the first of them are logged again in the next tick
+	Tick time.Duration `envconfig:"TICK" default:"1s"`
+}
+
+// Option returns the option that samples the logs of a logger, it doesn't change the logger if sampling is disabled
+// The logger shouldn't be sampled already, e.g. zap.Config.Sampling should be nil
+func (c LogSamplingConfig) Option() zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if c.Initial < 0 || c.Tick <= 0 {
+			return core
+		}
+		return zapcore.NewSamplerWithOptions(core, c.Tick, c.Initial, c.Thereafter)
+	})
+}
+
+// CacheControlConfig is the Cache-Control header of the responses of the read endpoints, so that CDNs can serve the clients polling them
+type CacheControlConfig struct {
+	// MaxAge is how long the responses can be cached for, zero means that they aren't given a Cache-Control header
+	MaxAge time.Duration `envconfig:"MAX_AGE" default:"30s"`
+	// OngoingMaxAge is how long the responses to ongoing=true requests can be cached for, it is shorter as ongoing incidents change faster
+	OngoingMaxAge time.Duration `envconfig:"ONGOING_MAX_AGE" default:"5s"`
+}
+
+type CorsConfig struct {
+	// AllowedOrigins are the origins that browsers may call the api from, if it is empty only same origin requests are allowed
+	AllowedOrigins []string `envconfig:"ALLOWED_ORIGINS"`
+	AllowedMethods []string `envconfig:"ALLOWED_METHODS" default:"GET,POST,DELETE,HEAD,OPTIONS"`
+	// AllowedHeaders include the headers of the api keys, idempotent subscriptions and conditional requests so that browsers can send them
+	AllowedHeaders []string `envconfig:"ALLOWED_HEADERS" default:"Origin,Content-Length,Content-Type,If-None-Match,If-Modified-Since,Authorization,Idempotency-Key"`
+}
+
+type CacheConfig struct {
+	// IncidentTTL is how long incidents are cached for a status page
+	IncidentTTL time.Duration `envconfig:"INCIDENT_TTL"`
+	// StatusPageTTL is how long status pages are cached for, they are refreshed every minute so this only matters if the refresh fails
+	StatusPageTTL time.Duration `envconfig:"STATUS_PAGE_TTL"`
+	// CleanupInterval is how often expired entries are removed from the in memory caches
+	CleanupInterval time.Duration `envconfig:"CLEANUP_INTERVAL"`
+	// AffectedStatusPagesTTL is how long the status pages with ongoing incidents are cached for, it is short as finding them reads every ongoing incident
+	AffectedStatusPagesTTL time.Duration `envconfig:"AFFECTED_STATUS_PAGES_TTL"`
+	// RecentIncidentsTTL is how long the most recent incidents across every status page are cached for, it is short so that new incidents show up quickly
+	RecentIncidentsTTL time.Duration `envconfig:"RECENT_INCIDENTS_TTL"`
+	// RecentIncidentCountsTTL is how long the number of recent incidents of every status page are cached for, counting them reads a month of incidents
+	RecentIncidentCountsTTL time.Duration `envconfig:"RECENT_INCIDENT_COUNTS_TTL"`
+	// WarmStatusPageUrls are the popular status pages whose incidents are loaded into the cache on startup and by /admin/cache/warm
+	// so that their first requests after a deploy aren't cache misses
+	WarmStatusPageUrls []string `envconfig:"WARM_STATUS_PAGE_URLS"`
+	// WarmConcurrency is the most status pages whose incidents are loaded from the database at once while warming the cache
+	WarmConcurrency int `envconfig:"WARM_CONCURRENCY"`
+	// IncidentKeyStrategy is either statusPage (the default) or statusPageAndImpacts, see IncidentCacheKeyStatusPageAndImpacts
+	// Caching by impacts saves filtering the incidents of hot filtered requests, but each filter requested is another copy of
+	// the matching incidents in the cache, up to one for each set of impacts
+	IncidentKeyStrategy string `envconfig:"INCIDENT_KEY_STRATEGY"`
+	// IdempotencyKeyTTL is how long the subscription created for an Idempotency-Key is returned again for retries with the same key
+	IdempotencyKeyTTL time.Duration `envconfig:"IDEMPOTENCY_KEY_TTL"`
+}
+
+type IncidentsConfig struct {
+	// MaxLimit is the most incidents that the incidents endpoint returns at once, larger limits are clamped to it
+	MaxLimit int `envconfig:"MAX_LIMIT"`
+	// DefaultLimit is the number of incidents returned when no limit is given, it defaults to MaxLimit so that every incident is returned up to it
+	DefaultLimit int `envconfig:"DEFAULT_LIMIT"`
+	// StaleThreshold is how long an incident can be ongoing for before it is flagged as stale, as it was probably never resolved
+	// It defaults to 30 days, a negative threshold means incidents are never flagged as stale
+	StaleThreshold time.Duration `envconfig:"STALE_THRESHOLD"`
+	// SlaTargets are the target resolution times of the impacts, e.g. critical:1h,major:4h, incidents that take longer to resolve
+	// are flagged as having breached their sla. Impacts without a target are never breached
+	SlaTargets map[string]time.Duration `envconfig:"SLA_TARGETS"`
+	// MaxWait is the longest that a request can wait for the incidents of a status page to change, longer waits are clamped to it
+	MaxWait time.Duration `envconfig:"MAX_WAIT"`
+	// MaxStreamsPerClient is the most incident streams that a client can have open at once, clients are identified as they are by the rate limit
+	MaxStreamsPerClient int `envconfig:"MAX_STREAMS_PER_CLIENT"`
+	// StreamHeartbeatInterval is how often a comment is sent on the incident streams, so that proxies don't close them for being idle
+	StreamHeartbeatInterval time.Duration `envconfig:"STREAM_HEARTBEAT_INTERVAL"`
+	// StreamThreshold is the number of incidents above which the incidents of a status page that aren't cached are streamed
+	// from the database to requests without a limit, rather than all being read into memory and cached
+	// It defaults to 10000, a negative threshold means the incidents are never streamed
+	StreamThreshold int `envconfig:"STREAM_THRESHOLD"`
+	// MaxSearchWindow is the longest range of start times that the incidents of every status page can be searched over at once,
+	// which bounds the number of incidents that the database scans for a search
+	MaxSearchWindow time.Duration `envconfig:"MAX_SEARCH_WINDOW"`
+	// SeverityWeights are how much an ongoing incident of each impact adds to the severity score of its status page for each component it affects,
+	// e.g. critical:100,major:50,minor:10, impacts without a weight don't add to the score
+	SeverityWeights map[string]int `envconfig:"SEVERITY_WEIGHTS"`
+}
+
+const (
+	defaultIncidentsMaxLimit      = 1000
+	defaultIncidentStaleThreshold = 30 * 24 * time.Hour
+	defaultIncidentsMaxWait       = 60 * time.Second
+	defaultMaxStreamsPerClient    = 5
+	defaultStreamHeartbeat        = 15 * time.Second
+	defaultStreamThreshold        = 10000
+	defaultMaxSearchWindow        = 31 * 24 * time.Hour
+)
+
+// defaultSeverityWeights are the severity weights when none are configured
+var defaultSeverityWeights = map[string]int{"critical": 100, "major": 50, "minor": 10}
+
+// WithDefaults returns a copy of the incidents config with the limits, thresholds, max wait, stream settings, max search window and severity weights
+// set to their defaults if they're unset, the default limit is at most the max limit
+func (c IncidentsConfig) WithDefaults() IncidentsConfig {
+	if c.StaleThreshold == 0 {
+		c.StaleThreshold = defaultIncidentStaleThreshold
+	}
+	if c.MaxLimit <= 0 {
+		c.MaxLimit = defaultIncidentsMaxLimit
+	}
+	if c.MaxWait <= 0 {
+		c.MaxWait = defaultIncidentsMaxWait
+	}
+	if c.MaxStreamsPerClient <= 0 {
+		c.MaxStreamsPerClient = defaultMaxStreamsPerClient
+	}
+	if c.StreamHeartbeatInterval <= 0 {
+		c.StreamHeartbeatInterval = defaultStreamHeartbeat
+	}
+	if c.StreamThreshold == 0 {
+		c.StreamThreshold = defaultStreamThreshold
+	}
+	if c.MaxSearchWindow <= 0 {
+		c.MaxSearchWindow = defaultMaxSearchWindow
+	}
+	if len(c.SeverityWeights) == 0 {
+		c.SeverityWeights = defaultSeverityWeights
+	}
+	if c.DefaultLimit <= 0 || c.DefaultLimit > c.MaxLimit {
+		c.DefaultLimit = c.MaxLimit
+	}
+	return c
+}
+
+type RateLimitConfig struct {
+	// RequestsPerMinute is the rate each client can make requests to the incidents endpoints at, if it is zero there is no rate limit
+	RequestsPerMinute int `envconfig:"REQUESTS_PER_MINUTE"`
+	// Burst is the number of requests a client can make at once before it is limited, it defaults to RequestsPerMinute
+	Burst int `envconfig:"BURST"`
+}
+
+type TracingConfig struct {
+	// OtlpEndpoint is the url of the otlp/http collector that spans are exported to, e.g. http://otel-collector:4318, tracing is disabled if it is empty
+	OtlpEndpoint string `envconfig:"OTLP_ENDPOINT"`
+}
+
+const (
+	defaultIncidentTTL             = 1 * time.Minute
+	defaultStatusPageTTL           = 15 * time.Minute
+	defaultCleanupInterval         = 1 * time.Minute
+	defaultAffectedStatusPagesTTL  = 30 * time.Second
+	defaultRecentIncidentsTTL      = 30 * time.Second
+	defaultRecentIncidentCountsTTL = 5 * time.Minute
+	defaultWarmConcurrency         = 4
+	defaultIdempotencyKeyTTL       = 24 * time.Hour
+)
+
+// WithDefaults returns a copy of the cache config with any unset durations and the warm concurrency set to their defaults
+func (c CacheConfig) WithDefaults() CacheConfig {
+	if c.IncidentTTL <= 0 {
+		c.IncidentTTL = defaultIncidentTTL
+	}
+	if c.StatusPageTTL <= 0 {
+		c.StatusPageTTL = defaultStatusPageTTL
+	}
+	if c.CleanupInterval <= 0 {
+		c.CleanupInterval = defaultCleanupInterval
+	}
+	if c.AffectedStatusPagesTTL <= 0 {
+		c.AffectedStatusPagesTTL = defaultAffectedStatusPagesTTL
+	}
+	if c.RecentIncidentsTTL <= 0 {
+		c.RecentIncidentsTTL = defaultRecentIncidentsTTL
+	}
+	if c.RecentIncidentCountsTTL <= 0 {
+		c.RecentIncidentCountsTTL = defaultRecentIncidentCountsTTL
+	}
+	if c.WarmConcurrency <= 0 {
+		c.WarmConcurrency = defaultWarmConcurrency
+	}
+	if c.IdempotencyKeyTTL <= 0 {
+		c.IdempotencyKeyTTL = defaultIdempotencyKeyTTL
+	}
+	return c
+}
+
+func GetConfigFromEnvironment() (Config, error) {
+	var config Config
+	if err := envconfig.Process("STATUSPHERE", &config); err != nil {
+		return config, err
+	}
+	return config, config.Validate()
+}
+
+// Validate returns an error if the config has a value that envconfig can't check, e.g. an unknown cache backend
+func (c Config) Validate() error {
+	if c.CacheBackend != CacheBackendMemory && c.CacheBackend != CacheBackendRedis {
+		return errors.Errorf("the cache backend must be %s or %s, got %s", CacheBackendMemory, CacheBackendRedis, c.CacheBackend)
+	}
+	return nil
+}
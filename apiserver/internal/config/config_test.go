@@ -0,0 +1,92 @@
+package config
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestTrustedProxiesAreParsedFromTheEnvironment(t *testing.T) {
+	t.Setenv("STATUSPHERE_TRUSTED_PROXIES", "10.0.0.0/8,2001:db8::/32")
+	config, err := GetConfigFromEnvironment()
+	if err != nil {
+		t.Fatalf("failed to get the config: %v", err)
+	}
+	if len(config.TrustedProxies) != 2 || config.TrustedProxies[0] != netip.MustParsePrefix("10.0.0.0/8") || config.TrustedProxies[1] != netip.MustParsePrefix("2001:db8::/32") {
+		t.Fatalf("unexpected trusted proxies %v", config.TrustedProxies)
+	}
+
+	t.Setenv("STATUSPHERE_TRUSTED_PROXIES", "10.0.0.0/33")
+	if _, err := GetConfigFromEnvironment(); err == nil {
+		t.Fatalf("expected an invalid cidr to fail")
+	}
+}
+
+func TestUnknownCacheBackendsAreRejected(t *testing.T) {
+	for _, backend := range []string{CacheBackendMemory, CacheBackendRedis} {
+		t.Setenv("STATUSPHERE_CACHE_BACKEND", backend)
+		if _, err := GetConfigFromEnvironment(); err != nil {
+			t.Fatalf("expected the %s cache backend to be valid, got %v", backend, err)
+		}
+	}
+
+	t.Setenv("STATUSPHERE_CACHE_BACKEND", "Redis")
+	if _, err := GetConfigFromEnvironment(); err == nil {
+		t.Fatalf("expected an unknown cache backend to fail")
+	}
+}
+
+func TestLogSamplingLogsTheFirstAndPeriodicRepeats(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core, LogSamplingConfig{Initial: 2, Thereafter: 3, Tick: time.Hour}.Option())
+	for i := 0; i < 10; i++ {
+		logger.Error("failed to get incidents from database", zap.Int("request", i))
+	}
+	logger.Error("failed to search incidents in database")
+
+	var logged []int64
+	for _, entry := range logs.FilterMessage("failed to get incidents from database").All() {
+		logged = append(logged, entry.ContextMap()["request"].(int64))
+	}
+	// The first 2 are logged, then every 3rd
+	if len(logged) != 4 || logged[0] != 0 || logged[1] != 1 || logged[2] != 4 || logged[3] != 7 {
+		t.Fatalf("expected requests 0, 1, 4 and 7 to be logged, got %v", logged)
+	}
+	if logs.FilterMessage("failed to search incidents in database").Len() != 1 {
+		t.Fatalf("expected other messages to be sampled separately")
+	}
+}
+
+func TestLogSamplingCanBeDisabled(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core, LogSamplingConfig{Initial: -1, Thereafter: 100, Tick: time.Second}.Option())
+	for i := 0; i < 10; i++ {
+		logger.Error("failed to get incidents from database")
+	}
+	if logs.Len() != 10 {
+		t.Fatalf("expected every log without sampling, got %d", logs.Len())
+	}
+}
+
+func TestApiKeysAreParsedFromTheEnvironment(t *testing.T) {
+	hash := "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"
+	t.Setenv("STATUSPHERE_API_KEYS", "ci:write:"+hash+",ops:admin:"+hash)
+	config, err := GetConfigFromEnvironment()
+	if err != nil {
+		t.Fatalf("failed to get the config: %v", err)
+	}
+	expected := NewApiKey("ci", ApiKeyScopeWrite, "secret")
+	if len(config.ApiKeys) != 2 || config.ApiKeys[0].ID != "ci" || config.ApiKeys[0].Scope != ApiKeyScopeWrite || string(config.ApiKeys[0].Hash) != string(expected.Hash) || config.ApiKeys[1].Scope != ApiKeyScopeAdmin {
+		t.Fatalf("unexpected api keys %+v", config.ApiKeys)
+	}
+
+	for _, invalid := range []string{"ci:write", "ci:owner:" + hash, "ci:write:secret", ":read:" + hash} {
+		t.Setenv("STATUSPHERE_API_KEYS", invalid)
+		if _, err := GetConfigFromEnvironment(); err == nil {
+			t.Fatalf("expected the api key %q to be invalid", invalid)
+		}
+	}
+}
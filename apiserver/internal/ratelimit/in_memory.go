@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// InMemoryLimiter is a process local token bucket rate limiter
+// Each client has a bucket of up to burst tokens that refills at requestsPerMinute, every request takes a token
+type InMemoryLimiter struct {
+	// tokensPerSecond is how fast the buckets refill
+	tokensPerSecond float64
+	burst           float64
+	now             func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+func NewInMemoryLimiter(requestsPerMinute int, burst int) *InMemoryLimiter {
+	return &InMemoryLimiter{
+		tokensPerSecond: float64(requestsPerMinute) / 60,
+		burst:           float64(burst),
+		now:             time.Now,
+		buckets:         map[string]*bucket{},
+	}
+}
+
+func (l *InMemoryLimiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, found := l.buckets[key]
+	if !found {
+		b = &bucket{tokens: l.burst, lastSeen: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.lastSeen = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	if l.tokensPerSecond <= 0 {
+		return false, time.Duration(math.MaxInt64), nil
+	}
+	retryAfter := time.Duration((1 - b.tokens) / l.tokensPerSecond * float64(time.Second))
+	return false, retryAfter, nil
+}
+
+func (l *InMemoryLimiter) refill(b *bucket, now time.Time) float64 {
+	return math.Min(l.burst, b.tokens+now.Sub(b.lastSeen).Seconds()*l.tokensPerSecond)
+}
+
+// sweep removes the buckets that have refilled, they are the same as a new bucket so there is no need to keep them
+// It runs at most once a minute so that clients that have gone away don't use memory forever
+func (l *InMemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
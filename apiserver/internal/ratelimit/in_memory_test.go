@@ -0,0 +1,49 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInMemoryLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	limiter := NewInMemoryLimiter(60, 2)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if allowed, _, _ := limiter.Allow(ctx, "a"); !allowed {
+			t.Fatalf("expected request %d within the burst to be allowed", i)
+		}
+	}
+	allowed, retryAfter, err := limiter.Allow(ctx, "a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Fatalf("expected the request after the burst to be limited")
+	}
+	if retryAfter != time.Second {
+		t.Fatalf("expected to retry after 1s at 60 requests per minute, got %v", retryAfter)
+	}
+
+	// Other clients have their own buckets
+	if allowed, _, _ := limiter.Allow(ctx, "b"); !allowed {
+		t.Fatalf("expected another client to be allowed")
+	}
+
+	now = now.Add(time.Second)
+	if allowed, _, _ := limiter.Allow(ctx, "a"); !allowed {
+		t.Fatalf("expected a request to be allowed after the bucket refilled")
+	}
+	if allowed, _, _ := limiter.Allow(ctx, "a"); allowed {
+		t.Fatalf("expected the bucket to only refill one token in a second")
+	}
+
+	now = now.Add(time.Hour)
+	limiter.Allow(ctx, "c")
+	if _, found := limiter.buckets["a"]; found {
+		t.Fatalf("expected refilled buckets to be swept")
+	}
+}
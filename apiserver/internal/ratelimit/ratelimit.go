@@ -0,0 +1,14 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limiter limits the rate of requests made by each client
+// Implementations may keep their state process local or share it between replicas of the api server
+type Limiter interface {
+	// Allow takes a request from the client identified by the key
+	// If the client has exceeded its rate, it returns false and how long the client should wait before retrying
+	Allow(ctx context.Context, key string) (bool, time.Duration, error)
+}
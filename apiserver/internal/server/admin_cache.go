@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+type InvalidateCacheRequest struct {
+	StatusPageUrl string `json:"statusPageUrl" binding:"required"`
+}
+
+type InvalidateCacheResponse struct {
+	StatusPageUrl string `json:"statusPageUrl"`
+}
+
+// invalidateCache is a handler for the /admin/cache/invalidate endpoint.
+// It takes a json body with a required field of statusPageUrl
+// It removes the cached incidents for the status page and reloads the status page from the database.
+// If the status page is not cached, it returns a 404.
+func (s *Server) invalidateCache(context *gin.Context) {
+	ctx := context.Request.Context()
+	var request InvalidateCacheRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeStatusPageUrlRequired, "statusPageUrl is required")
+		return
+	}
+	statusPageUrl, err := api.CanonicalStatusPageUrl(request.StatusPageUrl)
+	if err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidStatusPageUrl, "statusPageUrl is invalid, "+err.Error())
+		return
+	}
+
+	_, found, err := s.statusPageCache.Get(ctx, statusPageUrl)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to get status page from cache", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get status page from cache")
+		return
+	}
+	if !found {
+		writeError(context, http.StatusNotFound, ErrorCodeStatusPageNotKnown, "status page not cached")
+		return
+	}
+
+	if err := s.deleteCachedStatusPage(ctx, statusPageUrl); err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to delete status page from cache", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to delete status page from cache")
+		return
+	}
+	if err := s.deleteCachedIncidents(ctx, statusPageUrl); err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to delete incidents from cache", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to delete incidents from cache")
+		return
+	}
+
+	// Reload the status page straight away rather than waiting for the next refresh
+	// so that the status page isn't reported as unknown in the meantime
+	statusPage, err := s.dbClient.GetStatusPage(ctx, statusPageUrl)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to reload status page", zap.Error(err))
+	} else if statusPage != nil {
+		if err := s.setCachedStatusPage(ctx, *statusPage); err != nil {
+			utils.GetLogger(ctx, s.logger).Warn("failed to set status page in cache", zap.Error(err))
+		}
+	}
+
+	context.JSON(http.StatusOK, InvalidateCacheResponse{StatusPageUrl: statusPageUrl})
+}
+
+// deleteCachedIncidents removes the incidents and current incidents of the status page from the caches
+// If the incidents are cached by impacts then the incidents of every impact filter are removed too
+// The incidents looked up by their id are found in the cached incidents of their status page, so they're removed along with them
+func (s *Server) deleteCachedIncidents(ctx context.Context, statusPageUrl string) error {
+	if err := s.incidentCache.Delete(ctx, statusPageUrl); err != nil {
+		return errors.Wrap(err, "failed to delete incidents from cache")
+	}
+	if s.cachesIncidentsByImpacts() {
+		for _, key := range filteredIncidentCacheKeys(statusPageUrl) {
+			if err := s.incidentCache.Delete(ctx, key); err != nil {
+				return errors.Wrap(err, "failed to delete filtered incidents from cache")
+			}
+		}
+	}
+	if err := s.currentIncidentCache.Delete(ctx, statusPageUrl); err != nil {
+		return errors.Wrap(err, "failed to delete current incidents from cache")
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/metoro-io/statusphere/apiserver/internal/cache"
+	"github.com/metoro-io/statusphere/apiserver/internal/config"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInvalidateCacheRemovesTheCachedIncidentsOfTheStatusPage(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{})
+	s.config.ApiKeys = []config.ApiKey{
+		config.NewApiKey("writer", config.ApiKeyScopeWrite, "write-key"),
+		config.NewApiKey("ops", config.ApiKeyScopeAdmin, "admin-key"),
+	}
+	ctx := context.Background()
+	incident := newTestIncident("a", api.ImpactMajor, time.Now())
+	otherIncident := api.NewIncident("b", nil, nil, time.Now(), nil, nil, "https://status.other.com/incidents/b", api.ImpactMinor, "https://status.other.com")
+	_ = s.incidentCache.Set(ctx, testStatusPageUrl, []api.Incident{incident}, cache.DefaultExpiration)
+	_ = s.currentIncidentCache.Set(ctx, testStatusPageUrl, []api.Incident{incident}, cache.DefaultExpiration)
+	_ = s.incidentCache.Set(ctx, otherIncident.StatusPageUrl, []api.Incident{otherIncident}, cache.DefaultExpiration)
+	_ = s.incidentStatusPageCache.Set(ctx, incident.DeepLink, testStatusPageUrl, cache.DefaultExpiration)
+	_ = s.incidentStatusPageCache.Set(ctx, otherIncident.DeepLink, otherIncident.StatusPageUrl, cache.DefaultExpiration)
+	r := s.router()
+	invalidate := func(statusPageUrl string, apiKey string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(InvalidateCacheRequest{StatusPageUrl: statusPageUrl})
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/api/v1/admin/cache/invalidate", bytes.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		if apiKey != "" {
+			request.Header.Set("Authorization", "Bearer "+apiKey)
+		}
+		r.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	tests := []struct {
+		statusPageUrl  string
+		apiKey         string
+		expectedStatus int
+		expectedCode   ErrorCode
+	}{
+		{statusPageUrl: testStatusPageUrl, expectedStatus: http.StatusUnauthorized, expectedCode: ErrorCodeInvalidApiKey},
+		{statusPageUrl: testStatusPageUrl, apiKey: "unknown-key", expectedStatus: http.StatusUnauthorized, expectedCode: ErrorCodeInvalidApiKey},
+		{statusPageUrl: testStatusPageUrl, apiKey: "write-key", expectedStatus: http.StatusForbidden, expectedCode: ErrorCodeInsufficientScope},
+		{statusPageUrl: "status.example.com", apiKey: "admin-key", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidStatusPageUrl},
+		{statusPageUrl: "https://status.unknown.com", apiKey: "admin-key", expectedStatus: http.StatusNotFound, expectedCode: ErrorCodeStatusPageNotKnown},
+	}
+	for _, test := range tests {
+		recorder := invalidate(test.statusPageUrl, test.apiKey)
+		if recorder.Code != test.expectedStatus {
+			t.Fatalf("%s with %q: expected status %d, got %d: %s", test.statusPageUrl, test.apiKey, test.expectedStatus, recorder.Code, recorder.Body.String())
+		}
+		var response ErrorResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || response.Code != test.expectedCode {
+			t.Fatalf("%s with %q: expected the error code %s, got %s", test.statusPageUrl, test.apiKey, test.expectedCode, recorder.Body.String())
+		}
+	}
+	if _, found, _ := s.incidentCache.Get(ctx, testStatusPageUrl); !found {
+		t.Fatalf("expected the incidents to stay cached after the rejected requests")
+	}
+
+	// The status page url is canonicalized, so the incidents of the status page are invalidated however its url is written
+	recorder := invalidate(strings.ToUpper(testStatusPageUrl)+"/", "admin-key")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if _, found, _ := s.incidentCache.Get(ctx, testStatusPageUrl); found {
+		t.Fatalf("expected the incidents to be removed from the cache")
+	}
+	if _, found, _ := s.currentIncidentCache.Get(ctx, testStatusPageUrl); found {
+		t.Fatalf("expected the current incidents to be removed from the cache")
+	}
+	if _, found, _ := s.getIncidentByIdFromCache(ctx, incident.DeepLink); found {
+		t.Fatalf("expected the incident not to be found by its id in the cache")
+	}
+	if _, found, _ := s.getIncidentByIdFromCache(ctx, otherIncident.DeepLink); !found {
+		t.Fatalf("expected the incidents of other status pages to still be found by their id in the cache")
+	}
+	if _, found, _ := s.statusPageCache.Get(ctx, testStatusPageUrl); !found {
+		t.Fatalf("expected the status page to be reloaded into the cache")
+	}
+}
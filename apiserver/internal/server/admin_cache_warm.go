@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
+	"net/http"
+	"sync"
+)
+
+type WarmCacheRequest struct {
+	// StatusPageUrls are the status pages to warm, the configured popular status pages are warmed if it is empty
+	StatusPageUrls []string `json:"statusPageUrls"`
+}
+
+type WarmCacheResponse struct {
+	// Warmed is the number of status pages whose incidents were loaded into the cache
+	Warmed int `json:"warmed"`
+	// NotKnown are the status pages that aren't known to statusphere
+	NotKnown []string `json:"notKnown"`
+	// Failed are the status pages whose incidents couldn't be read from the database
+	Failed []string `json:"failed"`
+}
+
+// warmCache is a handler for the /admin/cache/warm endpoint.
+// It takes an optional json body with a field of statusPageUrls, which defaults to the configured popular status pages
+// It loads the incidents of the status pages from the database into the cache, a bounded number of them at once,
+// and returns how many were warmed along with the ones that aren't known or failed
+func (s *Server) warmCache(context *gin.Context) {
+	var request WarmCacheRequest
+	if context.Request.ContentLength != 0 {
+		if err := context.ShouldBindJSON(&request); err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidBody, "the body must be a json object with a statusPageUrls array")
+			return
+		}
+	}
+	if len(request.StatusPageUrls) == 0 {
+		request.StatusPageUrls = s.config.Cache.WarmStatusPageUrls
+	}
+	statusPageUrls := make([]string, 0, len(request.StatusPageUrls))
+	for _, statusPageUrl := range request.StatusPageUrls {
+		canonicalUrl, err := api.CanonicalStatusPageUrl(statusPageUrl)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidStatusPageUrl, "statusPageUrls contains an invalid url, "+err.Error())
+			return
+		}
+		statusPageUrls = append(statusPageUrls, canonicalUrl)
+	}
+
+	context.JSON(http.StatusOK, s.warmIncidentCache(context.Request.Context(), statusPageUrls))
+}
+
+// warmIncidentCache loads the incidents of the status pages into the incident cache, at most the configured warm concurrency at once
+func (s *Server) warmIncidentCache(ctx context.Context, statusPageUrls []string) WarmCacheResponse {
+	response := WarmCacheResponse{NotKnown: []string{}, Failed: []string{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, s.config.Cache.WarmConcurrency)
+	for _, statusPageUrl := range statusPageUrls {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			// The status pages that weren't started are left cold
+			wg.Wait()
+			return response
+		}
+		wg.Add(1)
+		go func(statusPageUrl string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			_, found, err := s.getIncidentsFromDatabaseAndCache(ctx, statusPageUrl)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				utils.GetLogger(ctx, s.logger).Warn("failed to warm the incident cache", zap.String("statusPageUrl", statusPageUrl), zap.Error(err))
+				response.Failed = append(response.Failed, statusPageUrl)
+			case !found:
+				response.NotKnown = append(response.NotKnown, statusPageUrl)
+			default:
+				response.Warmed++
+			}
+		}(statusPageUrl)
+	}
+	wg.Wait()
+	return response
+}
+
+// warmIncidentCacheOnStartup warms the cache with the configured popular status pages, if there are any
+func (s *Server) warmIncidentCacheOnStartup(ctx context.Context) {
+	statusPageUrls := make([]string, 0, len(s.config.Cache.WarmStatusPageUrls))
+	for _, statusPageUrl := range s.config.Cache.WarmStatusPageUrls {
+		canonicalUrl, err := api.CanonicalStatusPageUrl(statusPageUrl)
+		if err != nil {
+			s.logger.Warn("not warming the cache for an invalid status page url", zap.String("statusPageUrl", statusPageUrl), zap.Error(err))
+			continue
+		}
+		statusPageUrls = append(statusPageUrls, canonicalUrl)
+	}
+	if len(statusPageUrls) == 0 {
+		return
+	}
+	response := s.warmIncidentCache(ctx, statusPageUrls)
+	s.logger.Info("warmed the incident cache", zap.Int("warmed", response.Warmed), zap.Strings("notKnown", response.NotKnown), zap.Strings("failed", response.Failed))
+}
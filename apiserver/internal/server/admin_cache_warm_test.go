@@ -0,0 +1,109 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// postWarmCache calls the warm cache handler with the body, which is omitted if it is nil
+func postWarmCache(s *Server, request *WarmCacheRequest) *httptest.ResponseRecorder {
+	var body []byte
+	if request != nil {
+		body, _ = json.Marshal(request)
+	}
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodPost, "/api/v1/admin/cache/warm", bytes.NewReader(body))
+	testContext.Request.Header.Set("Content-Type", "application/json")
+	s.warmCache(testContext)
+	return recorder
+}
+
+func TestWarmCacheLoadsIncidentsIntoTheCache(t *testing.T) {
+	dbClient := &fakeDbClient{
+		statusPages: []api.StatusPage{{URL: "https://status.quiet.com", IsIndexed: true}},
+		incidents:   []api.Incident{newTestIncident("a", api.ImpactMajor, time.Now())},
+	}
+	s := newTestServerWithDb(t, dbClient)
+	s.config.Cache.WarmStatusPageUrls = []string{testStatusPageUrl, "https://status.quiet.com/", "https://status.unknown.com"}
+
+	recorder := postWarmCache(s, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response WarmCacheResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	expected := WarmCacheResponse{Warmed: 2, NotKnown: []string{"https://status.unknown.com"}, Failed: []string{}}
+	if !reflect.DeepEqual(response, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, response)
+	}
+	if cached, found, err := s.incidentCache.Get(context.Background(), testStatusPageUrl); err != nil || !found || len(cached) != 1 {
+		t.Fatalf("expected the incidents to be cached, got %+v", cached)
+	}
+	// The configured urls are canonicalised without being modified
+	if s.config.Cache.WarmStatusPageUrls[1] != "https://status.quiet.com/" {
+		t.Fatalf("expected the configured status pages to be unchanged, got %v", s.config.Cache.WarmStatusPageUrls)
+	}
+
+	// The status pages in the body are warmed instead of the configured ones
+	recorder = postWarmCache(s, &WarmCacheRequest{StatusPageUrls: []string{"https://status.quiet.com"}})
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || response.Warmed != 1 || len(response.NotKnown) != 0 {
+		t.Fatalf("expected only the requested status page to be warmed, got %s", recorder.Body.String())
+	}
+
+	if recorder := postWarmCache(s, &WarmCacheRequest{StatusPageUrls: []string{"status.example.com"}}); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for an invalid url, got %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodPost, "/api/v1/admin/cache/warm", bytes.NewReader([]byte("[]")))
+	testContext.Request.Header.Set("Content-Type", "application/json")
+	s.warmCache(testContext)
+	var errorResponse ErrorResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &errorResponse); err != nil || recorder.Code != http.StatusBadRequest || errorResponse.Code != ErrorCodeInvalidBody {
+		t.Fatalf("expected status 400 with the error code %s for a body that isn't an object, got %d: %s", ErrorCodeInvalidBody, recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestWarmCacheReportsFailures(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{getIncidentsErr: errors.New("connection refused")})
+
+	response := s.warmIncidentCache(context.Background(), []string{testStatusPageUrl})
+	if response.Warmed != 0 || !reflect.DeepEqual(response.Failed, []string{testStatusPageUrl}) {
+		t.Fatalf("expected the status page to fail to warm, got %+v", response)
+	}
+}
+
+func TestWarmCacheBoundsConcurrency(t *testing.T) {
+	dbClient := &fakeDbClient{getIncidentsDelay: 20 * time.Millisecond}
+	s := newTestServerWithDb(t, dbClient)
+	s.config.Cache.WarmConcurrency = 2
+	var statusPageUrls []string
+	for _, host := range []string{"a", "b", "c", "d"} {
+		statusPageUrl := "https://status." + host + ".com"
+		dbClient.statusPages = append(dbClient.statusPages, api.StatusPage{URL: statusPageUrl, IsIndexed: true})
+		statusPageUrls = append(statusPageUrls, statusPageUrl)
+	}
+
+	start := time.Now()
+	response := s.warmIncidentCache(context.Background(), statusPageUrls)
+	if response.Warmed != 4 {
+		t.Fatalf("expected every status page to be warmed, got %+v", response)
+	}
+	// Four fetches two at a time take at least two delays
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("expected the fetches to be bounded to two at once, took %s", elapsed)
+	}
+}
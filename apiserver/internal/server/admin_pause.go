@@ -0,0 +1,62 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+type PauseStatusPageIndexingRequest struct {
+	StatusPageUrl string `json:"statusPageUrl" binding:"required"`
+	// Paused is a pointer so that a missing field can be told apart from false
+	Paused *bool `json:"paused" binding:"required"`
+}
+
+type PauseStatusPageIndexingResponse struct {
+	StatusPageUrl  string `json:"statusPageUrl"`
+	IndexingPaused bool   `json:"indexingPaused"`
+}
+
+// pauseStatusPageIndexing is a handler for the /admin/statusPages/pause endpoint.
+// It takes a json body with required fields of statusPageUrl and paused
+// It pauses the scraping of the status page if paused is true and resumes it if paused is false, the incidents of the status page are kept.
+// If the status page is not known to statusphere, it returns a 404.
+func (s *Server) pauseStatusPageIndexing(context *gin.Context) {
+	ctx := context.Request.Context()
+	var request PauseStatusPageIndexingRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidBody, "statusPageUrl and paused are required")
+		return
+	}
+	statusPageUrl, err := api.CanonicalStatusPageUrl(request.StatusPageUrl)
+	if err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidStatusPageUrl, "statusPageUrl is invalid, "+err.Error())
+		return
+	}
+
+	statusPage, err := s.dbClient.GetStatusPage(ctx, statusPageUrl)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to get status page", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get status page")
+		return
+	}
+	if statusPage == nil {
+		writeError(context, http.StatusNotFound, ErrorCodeStatusPageNotKnown, "status page not known to statusphere")
+		return
+	}
+
+	if err := s.dbClient.SetStatusPageIndexingPaused(ctx, statusPage.URL, *request.Paused); err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to set whether the indexing of the status page is paused", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to update status page")
+		return
+	}
+	updatedStatusPage := *statusPage
+	updatedStatusPage.IndexingPaused = *request.Paused
+	if err := s.setCachedStatusPage(ctx, updatedStatusPage); err != nil {
+		utils.GetLogger(ctx, s.logger).Warn("failed to set status page in cache", zap.Error(err))
+	}
+
+	context.JSON(http.StatusOK, PauseStatusPageIndexingResponse{StatusPageUrl: updatedStatusPage.URL, IndexingPaused: updatedStatusPage.IndexingPaused})
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPauseStatusPageIndexing(t *testing.T) {
+	dbClient := &fakeDbClient{statusPages: []api.StatusPage{{URL: "https://other.example.com", IsIndexed: true}}}
+	s := newTestServerWithDb(t, dbClient)
+	postPause := func(body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		testContext, _ := gin.CreateTestContext(recorder)
+		testContext.Request = httptest.NewRequest(http.MethodPost, "/api/v1/admin/statusPages/pause", bytes.NewReader([]byte(body)))
+		testContext.Request.Header.Set("Content-Type", "application/json")
+		s.pauseStatusPageIndexing(testContext)
+		return recorder
+	}
+
+	for _, paused := range []bool{true, false} {
+		body, _ := json.Marshal(map[string]interface{}{"statusPageUrl": "https://Other.example.com/", "paused": paused})
+		recorder := postPause(string(body))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+		var response PauseStatusPageIndexingResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if response.IndexingPaused != paused || dbClient.statusPages[0].IndexingPaused != paused {
+			t.Fatalf("expected the indexing to be paused %t, got %+v", paused, response)
+		}
+
+		cached, found, err := s.statusPageCache.Get(context.Background(), "https://other.example.com")
+		if err != nil || !found || indexingStatus(cached).IndexingPaused != paused {
+			t.Fatalf("expected the status endpoint to report the indexing paused %t", paused)
+		}
+	}
+
+	tests := []struct {
+		body           string
+		expectedStatus int
+		expectedCode   ErrorCode
+	}{
+		{body: `{"statusPageUrl": "https://other.example.com"}`, expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidBody},
+		{body: `{"statusPageUrl": "https://unknown.example.com", "paused": true}`, expectedStatus: http.StatusNotFound, expectedCode: ErrorCodeStatusPageNotKnown},
+	}
+	for _, test := range tests {
+		recorder := postPause(test.body)
+		if recorder.Code != test.expectedStatus {
+			t.Fatalf("%s: expected status %d, got %d", test.body, test.expectedStatus, recorder.Code)
+		}
+		var response ErrorResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || response.Code != test.expectedCode {
+			t.Fatalf("%s: expected the error code %s, got %s", test.body, test.expectedCode, recorder.Body.String())
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+type ReindexStatusPageRequest struct {
+	StatusPageUrl string `json:"statusPageUrl" binding:"required"`
+}
+
+type ReindexStatusPageResponse struct {
+	StatusPageUrl string `json:"statusPageUrl"`
+	// LastHistoricallyScraped and LastCurrentlyScraped are when the status page was last indexed, before it was marked for reindexing
+	LastHistoricallyScraped time.Time `json:"lastHistoricallyScraped"`
+	LastCurrentlyScraped    time.Time `json:"lastCurrentlyScraped"`
+}
+
+// reindexStatusPage is a handler for the /admin/statusPages/reindex endpoint.
+// It takes a json body with a required field of statusPageUrl
+// It marks the status page to be scraped again the next time the scraper runs and removes its cached incidents.
+// If the status page is not known to statusphere, it returns a 404.
+func (s *Server) reindexStatusPage(context *gin.Context) {
+	ctx := context.Request.Context()
+	var request ReindexStatusPageRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeStatusPageUrlRequired, "statusPageUrl is required")
+		return
+	}
+	statusPageUrl, err := api.CanonicalStatusPageUrl(request.StatusPageUrl)
+	if err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidStatusPageUrl, "statusPageUrl is invalid, "+err.Error())
+		return
+	}
+
+	statusPage, err := s.dbClient.GetStatusPage(ctx, statusPageUrl)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to get status page", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get status page")
+		return
+	}
+	if statusPage == nil {
+		writeError(context, http.StatusNotFound, ErrorCodeStatusPageNotKnown, "status page not known to statusphere")
+		return
+	}
+
+	if err := s.dbClient.MarkStatusPageForRescrape(ctx, statusPage.URL); err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to mark status page for rescrape", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to mark status page for rescrape")
+		return
+	}
+	if err := s.deleteCachedIncidents(ctx, statusPage.URL); err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to delete incidents from cache", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to delete incidents from cache")
+		return
+	}
+
+	response := ReindexStatusPageResponse{
+		StatusPageUrl:           statusPage.URL,
+		LastHistoricallyScraped: statusPage.LastHistoricallyScraped,
+		LastCurrentlyScraped:    statusPage.LastCurrentlyScraped,
+	}
+	rescrapedStatusPage := *statusPage
+	rescrapedStatusPage.LastHistoricallyScraped = time.Time{}
+	rescrapedStatusPage.LastCurrentlyScraped = time.Time{}
+	if err := s.setCachedStatusPage(ctx, rescrapedStatusPage); err != nil {
+		utils.GetLogger(ctx, s.logger).Warn("failed to set status page in cache", zap.Error(err))
+	}
+
+	context.JSON(http.StatusOK, response)
+}
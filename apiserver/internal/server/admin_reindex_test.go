@@ -0,0 +1,70 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReindexStatusPage(t *testing.T) {
+	lastScraped := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	dbClient := &fakeDbClient{statusPages: []api.StatusPage{{URL: "https://other.example.com", LastHistoricallyScraped: lastScraped, LastCurrentlyScraped: lastScraped}}}
+	s := newTestServerWithDb(t, dbClient)
+	ctx := context.Background()
+	if err := s.incidentCache.Set(ctx, "https://other.example.com", []api.Incident{newTestIncident("a", api.ImpactMajor, lastScraped)}, 0); err != nil {
+		t.Fatalf("failed to set cache: %v", err)
+	}
+	postReindex := func(statusPageUrl string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(ReindexStatusPageRequest{StatusPageUrl: statusPageUrl})
+		recorder := httptest.NewRecorder()
+		testContext, _ := gin.CreateTestContext(recorder)
+		testContext.Request = httptest.NewRequest(http.MethodPost, "/api/v1/admin/statusPages/reindex", bytes.NewReader(body))
+		testContext.Request.Header.Set("Content-Type", "application/json")
+		s.reindexStatusPage(testContext)
+		return recorder
+	}
+
+	recorder := postReindex("https://Other.example.com/")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response ReindexStatusPageResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if !response.LastHistoricallyScraped.Equal(lastScraped) || !response.LastCurrentlyScraped.Equal(lastScraped) {
+		t.Fatalf("expected the last indexed times from before the reindex, got %+v", response)
+	}
+	if !dbClient.statusPages[0].LastCurrentlyScraped.IsZero() || !dbClient.statusPages[0].LastHistoricallyScraped.IsZero() {
+		t.Fatalf("expected the status page to be marked for rescrape, got %+v", dbClient.statusPages[0])
+	}
+	if _, found, _ := s.incidentCache.Get(ctx, "https://other.example.com"); found {
+		t.Fatalf("expected the cached incidents to be removed")
+	}
+
+	tests := []struct {
+		statusPageUrl  string
+		expectedStatus int
+		expectedCode   ErrorCode
+	}{
+		{statusPageUrl: "", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeStatusPageUrlRequired},
+		{statusPageUrl: "other.example.com", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidStatusPageUrl},
+		{statusPageUrl: "https://unknown.example.com", expectedStatus: http.StatusNotFound, expectedCode: ErrorCodeStatusPageNotKnown},
+	}
+	for _, test := range tests {
+		recorder := postReindex(test.statusPageUrl)
+		if recorder.Code != test.expectedStatus {
+			t.Fatalf("%q: expected status %d, got %d", test.statusPageUrl, test.expectedStatus, recorder.Code)
+		}
+		var response ErrorResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || response.Code != test.expectedCode {
+			t.Fatalf("%q: expected the error code %s, got %s", test.statusPageUrl, test.expectedCode, recorder.Body.String())
+		}
+	}
+}
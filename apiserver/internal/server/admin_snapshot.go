@@ -0,0 +1,61 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+type StatusPageSnapshotResponse struct {
+	StatusPageUrl string    `json:"statusPageUrl"`
+	Provider      string    `json:"provider"`
+	ScrapedAt     time.Time `json:"scrapedAt"`
+	StatusCode    int       `json:"statusCode"`
+	ContentType   string    `json:"contentType"`
+	Error         string    `json:"error,omitempty"`
+	Truncated     bool      `json:"truncated"`
+	Body          string    `json:"body"`
+}
+
+// statusPageSnapshot is a handler for the /admin/statusPages/snapshot endpoint.
+// It takes a required query parameter of statusPageUrl
+// It returns the response of the status page to its last current scrape, along with the provider that parsed it and when,
+// so that scrapes that find the wrong incidents can be diagnosed without fetching the status page again.
+// If the status page hasn't been snapshotted, it returns a 404.
+func (s *Server) statusPageSnapshot(context *gin.Context) {
+	ctx := context.Request.Context()
+	statusPageUrl, ok := parseStatusPageUrlQuery(context)
+	if !ok {
+		return
+	}
+
+	snapshot, err := s.dbClient.GetStatusPageSnapshot(ctx, statusPageUrl)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to get status page snapshot", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get status page snapshot")
+		return
+	}
+	if snapshot == nil {
+		writeError(context, http.StatusNotFound, ErrorCodeStatusPageSnapshotNotKnown, "status page has not been snapshotted")
+		return
+	}
+	body, err := snapshot.Body()
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to read status page snapshot", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to read status page snapshot")
+		return
+	}
+
+	context.JSON(http.StatusOK, StatusPageSnapshotResponse{
+		StatusPageUrl: snapshot.StatusPageUrl,
+		Provider:      snapshot.Provider,
+		ScrapedAt:     snapshot.ScrapedAt,
+		StatusCode:    snapshot.StatusCode,
+		ContentType:   snapshot.ContentType,
+		Error:         snapshot.Error,
+		Truncated:     snapshot.Truncated,
+		Body:          string(body),
+	})
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestStatusPageSnapshot(t *testing.T) {
+	snapshot := api.StatusPageSnapshot{StatusPageUrl: testStatusPageUrl, Provider: "atlassian", StatusCode: http.StatusOK, ContentType: "text/html", Truncated: true}
+	if err := snapshot.SetBody([]byte("<html>")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := newTestServerWithDb(t, &fakeDbClient{snapshots: []api.StatusPageSnapshot{snapshot}})
+	s.config.AdminApiKey = "secret"
+	r := s.router()
+	getSnapshot := func(statusPageUrl string, apiKey string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/admin/statusPages/snapshot?statusPageUrl="+url.QueryEscape(statusPageUrl), nil)
+		if apiKey != "" {
+			request.Header.Set("Authorization", "Bearer "+apiKey)
+		}
+		r.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := getSnapshot(testStatusPageUrl, "secret")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response StatusPageSnapshotResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response.Provider != "atlassian" || response.Body != "<html>" || !response.Truncated || response.ContentType != "text/html" {
+		t.Fatalf("unexpected snapshot: %+v", response)
+	}
+
+	if recorder := getSnapshot(testStatusPageUrl, ""); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 without the admin api key, got %d", recorder.Code)
+	}
+	recorder = getSnapshot("https://unknown.example.com", "secret")
+	var errorResponse ErrorResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &errorResponse); err != nil || recorder.Code != http.StatusNotFound || errorResponse.Code != ErrorCodeStatusPageSnapshotNotKnown {
+		t.Fatalf("expected status 404 with the error code %s for a status page without a snapshot, got %d: %s", ErrorCodeStatusPageSnapshotNotKnown, recorder.Code, recorder.Body.String())
+	}
+}
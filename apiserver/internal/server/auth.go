@@ -0,0 +1,92 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/apiserver/internal/config"
+	"github.com/metoro-io/statusphere/common/utils"
+	"net/http"
+	"strings"
+)
+
+// apiKeyIdKey is the key of the id of the api key that authenticated the request, in the gin context and the mdc of the logs
+const apiKeyIdKey = "apiKeyId"
+
+// requireApiKey is a middleware that only allows requests with an Authorization: Bearer <key> header of an api key with the scope
+// Read endpoints are public unless RequireApiKeyForReads is set, and write endpoints are public until api keys are configured,
+// admin endpoints always require a key with the admin scope, see authorize
+func (s *Server) requireApiKey(scope config.ApiKeyScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch {
+		case scope == config.ApiKeyScopeRead && !s.config.RequireApiKeyForReads:
+		case scope == config.ApiKeyScopeWrite && len(s.config.ApiKeys) == 0:
+		default:
+			if !s.authorize(c, scope) {
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// authorize checks that the request has an Authorization: Bearer <key> header of an api key with the scope, for the options of
+// endpoints that need a scope that the endpoint itself doesn't, e.g. the admin options of the incidents endpoint
+// If it doesn't, it aborts the request with a 401 if the key is missing or unknown, or a 403 if the key doesn't have the scope, and returns false
+// If no api key has the admin scope then admin requests are rejected with a 403 as the admin endpoints are disabled
+// The id of the key is added to the logs of the request, the key itself is never logged
+func (s *Server) authorize(c *gin.Context, scope config.ApiKeyScope) bool {
+	keys := s.apiKeys()
+	if scope == config.ApiKeyScopeAdmin && !anyApiKeyHasScope(keys, config.ApiKeyScopeAdmin) {
+		abortWithError(c, http.StatusForbidden, ErrorCodeAdminDisabled, "admin endpoints are disabled")
+		return false
+	}
+
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !found {
+		abortWithError(c, http.StatusUnauthorized, ErrorCodeInvalidApiKey, "invalid api key")
+		return false
+	}
+	key := findApiKey(keys, token)
+	if key == nil {
+		abortWithError(c, http.StatusUnauthorized, ErrorCodeInvalidApiKey, "invalid api key")
+		return false
+	}
+	c.Set(apiKeyIdKey, key.ID)
+	c.Request = c.Request.WithContext(utils.UpdateContextMdc(c.Request.Context(), map[string]string{apiKeyIdKey: key.ID}))
+	if !key.Scope.Includes(scope) {
+		abortWithError(c, http.StatusForbidden, ErrorCodeInsufficientScope, "the api key doesn't have the "+string(scope)+" scope")
+		return false
+	}
+	return true
+}
+
+// apiKeys returns the configured api keys along with the admin api key, which has the admin scope
+func (s *Server) apiKeys() []config.ApiKey {
+	if s.config.AdminApiKey == "" {
+		return s.config.ApiKeys
+	}
+	return append([]config.ApiKey{config.NewApiKey("admin", config.ApiKeyScopeAdmin, s.config.AdminApiKey)}, s.config.ApiKeys...)
+}
+
+// findApiKey returns the api key whose secret is the token, or nil if there isn't one
+// Every key is compared in constant time so that the time taken doesn't reveal which keys are close to the token
+func findApiKey(keys []config.ApiKey, token string) *config.ApiKey {
+	hash := sha256.Sum256([]byte(token))
+	var found *config.ApiKey
+	for i := range keys {
+		if subtle.ConstantTimeCompare(hash[:], keys[i].Hash) == 1 && found == nil {
+			found = &keys[i]
+		}
+	}
+	return found
+}
+
+func anyApiKeyHasScope(keys []config.ApiKey, scope config.ApiKeyScope) bool {
+	for _, key := range keys {
+		if key.Scope.Includes(scope) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/metoro-io/statusphere/apiserver/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestApiKeyScopesAreEnforced(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{})
+	s.config.ApiKeys = []config.ApiKey{
+		config.NewApiKey("reader", config.ApiKeyScopeRead, "read-key"),
+		config.NewApiKey("writer", config.ApiKeyScopeWrite, "write-key"),
+		config.NewApiKey("ops", config.ApiKeyScopeAdmin, "admin-key"),
+	}
+	r := s.router()
+	request := func(method string, path string, apiKey string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(method, path, nil)
+		if apiKey != "" {
+			request.Header.Set("Authorization", "Bearer "+apiKey)
+		}
+		r.ServeHTTP(recorder, request)
+		return recorder
+	}
+	readPath := "/api/v1/incidents?statusPageUrl=" + url.QueryEscape(testStatusPageUrl)
+	tests := []struct {
+		method         string
+		path           string
+		apiKey         string
+		expectedStatus int
+		expectedCode   ErrorCode
+	}{
+		// Read endpoints are public by default
+		{method: http.MethodGet, path: readPath, expectedStatus: http.StatusOK},
+		{method: http.MethodPost, path: "/api/v1/statusPages", expectedStatus: http.StatusUnauthorized, expectedCode: ErrorCodeInvalidApiKey},
+		{method: http.MethodPost, path: "/api/v1/statusPages", apiKey: "unknown-key", expectedStatus: http.StatusUnauthorized, expectedCode: ErrorCodeInvalidApiKey},
+		{method: http.MethodPost, path: "/api/v1/statusPages", apiKey: "read-key", expectedStatus: http.StatusForbidden, expectedCode: ErrorCodeInsufficientScope},
+		{method: http.MethodPost, path: "/api/v1/statusPages", apiKey: "write-key", expectedStatus: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/api/v1/statusPages", apiKey: "admin-key", expectedStatus: http.StatusBadRequest},
+		{method: http.MethodDelete, path: "/api/v1/subscriptions/unknown", apiKey: "read-key", expectedStatus: http.StatusForbidden, expectedCode: ErrorCodeInsufficientScope},
+		// The deliveries of a subscription have the responses of its webhooks, so they need the same scope as redelivering them
+		{method: http.MethodGet, path: "/api/v1/subscriptions/unknown/deliveries", expectedStatus: http.StatusUnauthorized, expectedCode: ErrorCodeInvalidApiKey},
+		{method: http.MethodGet, path: "/api/v1/subscriptions/unknown/deliveries", apiKey: "read-key", expectedStatus: http.StatusForbidden, expectedCode: ErrorCodeInsufficientScope},
+		{method: http.MethodGet, path: "/api/v1/subscriptions/unknown/deliveries", apiKey: "write-key", expectedStatus: http.StatusNotFound, expectedCode: ErrorCodeSubscriptionNotKnown},
+		{method: http.MethodPost, path: "/api/v1/admin/statusPages/reindex", apiKey: "write-key", expectedStatus: http.StatusForbidden, expectedCode: ErrorCodeInsufficientScope},
+		{method: http.MethodPost, path: "/api/v1/admin/statusPages/reindex", apiKey: "admin-key", expectedStatus: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		recorder := request(test.method, test.path, test.apiKey)
+		if recorder.Code != test.expectedStatus {
+			t.Fatalf("%s %s with %q: expected status %d, got %d: %s", test.method, test.path, test.apiKey, test.expectedStatus, recorder.Code, recorder.Body.String())
+		}
+		if test.expectedCode == "" {
+			continue
+		}
+		var response ErrorResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || response.Code != test.expectedCode {
+			t.Fatalf("%s %s with %q: expected the error code %s, got %s", test.method, test.path, test.apiKey, test.expectedCode, recorder.Body.String())
+		}
+	}
+
+	s.config.RequireApiKeyForReads = true
+	if recorder := request(http.MethodGet, readPath, ""); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected reads to require an api key, got %d", recorder.Code)
+	}
+	if recorder := request(http.MethodGet, readPath, "read-key"); recorder.Code != http.StatusOK {
+		t.Fatalf("expected the read key to read, got %d", recorder.Code)
+	}
+}
+
+func TestWritesArePublicWithoutApiKeys(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{})
+	s.config.AdminApiKey = "secret"
+	r := s.router()
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/statusPages", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected writes not to need an api key when none are configured, got %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/admin/statusPages/reindex", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	r.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected the admin api key to have the admin scope, got %d", recorder.Code)
+	}
+}
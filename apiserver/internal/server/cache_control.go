@@ -0,0 +1,88 @@
+package server
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/apiserver/internal/config"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// cacheControlResponses sets the Cache-Control header of the responses to GET and HEAD requests so that CDNs can cache them
+// Successful responses are public for the max age, or the ongoing max age for ongoing=true requests, and other responses aren't stored
+// so that an error isn't served to everyone. If apiKeys is true, because api keys are configured, or the request has an Authorization header
+// then the responses can depend on the key, so they're private with a Vary of Authorization, as shared caches would serve them to clients
+// without the key. Handlers and middlewares that set their own Cache-Control, e.g. noStore, keep it
+func cacheControlResponses(cacheControl config.CacheControlConfig, apiKeys bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cacheControl.MaxAge <= 0 || (c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead) {
+			c.Next()
+			return
+		}
+		maxAge := cacheControl.MaxAge
+		if ongoing, _ := strconv.ParseBool(c.Query("ongoing")); ongoing {
+			maxAge = min(maxAge, cacheControl.OngoingMaxAge)
+		}
+		c.Writer = &cacheControlResponseWriter{ResponseWriter: c.Writer, maxAge: maxAge, private: apiKeys || c.GetHeader("Authorization") != ""}
+		c.Next()
+	}
+}
+
+// noStore stops the responses of the endpoints from being cached, e.g. for the admin endpoints
+func noStore() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		c.Next()
+	}
+}
+
+// cacheControlResponseWriter sets the Cache-Control header once the status of the response is known, just before the headers are sent
+type cacheControlResponseWriter struct {
+	gin.ResponseWriter
+	maxAge  time.Duration
+	private bool
+	set     bool
+}
+
+func (w *cacheControlResponseWriter) setCacheControl() {
+	if w.set {
+		return
+	}
+	w.set = true
+	if w.Header().Get("Cache-Control") != "" {
+		return
+	}
+	status := w.ResponseWriter.Status()
+	// A 304 refreshes the cached response, so it has the same Cache-Control as the response it stands for
+	if status != http.StatusOK && status != http.StatusNotModified {
+		w.Header().Set("Cache-Control", "no-store")
+		return
+	}
+	if w.private {
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(w.maxAge.Seconds())))
+		w.Header().Add("Vary", "Authorization")
+		return
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(w.maxAge.Seconds())))
+}
+
+func (w *cacheControlResponseWriter) Write(data []byte) (int, error) {
+	w.setCacheControl()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *cacheControlResponseWriter) WriteString(s string) (int, error) {
+	w.setCacheControl()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *cacheControlResponseWriter) WriteHeaderNow() {
+	w.setCacheControl()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *cacheControlResponseWriter) Flush() {
+	w.setCacheControl()
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/apiserver/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheControlResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(compressResponses(100, []string{"gzip"}))
+	r.Use(cacheControlResponses(config.CacheControlConfig{MaxAge: 30 * time.Second, OngoingMaxAge: 5 * time.Second}, false))
+	r.GET("/incidents", func(c *gin.Context) {
+		writeJSONWithETag(c, gin.H{"body": strings.Repeat("incident ", 100)})
+	})
+	r.GET("/missing", func(c *gin.Context) {
+		writeError(c, http.StatusNotFound, ErrorCodeStatusPageNotKnown, "status page not known")
+	})
+	r.GET("/stream", func(c *gin.Context) {
+		c.Header("Cache-Control", "no-cache")
+		c.String(http.StatusOK, "data")
+	})
+	r.GET("/admin", noStore(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	r.POST("/incidents", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	tests := []struct {
+		method               string
+		path                 string
+		header               string
+		expectedCacheControl string
+	}{
+		{method: http.MethodGet, path: "/incidents", expectedCacheControl: "public, max-age=30"},
+		{method: http.MethodGet, path: "/incidents?ongoing=true", expectedCacheControl: "public, max-age=5"},
+		{method: http.MethodGet, path: "/incidents", header: "Accept-Encoding", expectedCacheControl: "public, max-age=30"},
+		{method: http.MethodGet, path: "/incidents", header: "Authorization", expectedCacheControl: "private, max-age=30"},
+		{method: http.MethodGet, path: "/missing", expectedCacheControl: "no-store"},
+		{method: http.MethodGet, path: "/stream", expectedCacheControl: "no-cache"},
+		{method: http.MethodGet, path: "/admin", expectedCacheControl: "no-store"},
+		{method: http.MethodPost, path: "/incidents", expectedCacheControl: ""},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(test.method, test.path, nil)
+		switch test.header {
+		case "Accept-Encoding":
+			request.Header.Set("Accept-Encoding", "gzip")
+		case "Authorization":
+			request.Header.Set("Authorization", "Bearer key")
+		}
+		r.ServeHTTP(recorder, request)
+		if cacheControl := recorder.Header().Get("Cache-Control"); cacheControl != test.expectedCacheControl {
+			t.Fatalf("%s %s with %q: expected Cache-Control %q, got %q", test.method, test.path, test.header, test.expectedCacheControl, cacheControl)
+		}
+	}
+
+	// A 304 refreshes the cached response so it has the same Cache-Control
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/incidents", nil))
+	request := httptest.NewRequest(http.MethodGet, "/incidents", nil)
+	request.Header.Set("If-None-Match", recorder.Header().Get("ETag"))
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNotModified || recorder.Header().Get("Cache-Control") != "public, max-age=30" {
+		t.Fatalf("expected a cacheable 304, got %d with %q", recorder.Code, recorder.Header().Get("Cache-Control"))
+	}
+}
+
+func TestAdminAndIncidentsEndpointsCacheControl(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{})
+	s.config.CacheControl = config.CacheControlConfig{MaxAge: time.Minute, OngoingMaxAge: 10 * time.Second}
+	r := s.router()
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/incidents?statusPageUrl="+url.QueryEscape(testStatusPageUrl), nil))
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Cache-Control") != "public, max-age=60" {
+		t.Fatalf("expected the incidents to be cacheable, got %d with %q", recorder.Code, recorder.Header().Get("Cache-Control"))
+	}
+
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/admin/cache/invalidate", nil))
+	if recorder.Code != http.StatusForbidden || recorder.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("expected the admin endpoints not to be stored, got %d with %q", recorder.Code, recorder.Header().Get("Cache-Control"))
+	}
+}
+
+func TestCacheControlIsPrivateWithApiKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(s *Server)
+		apiKey string
+	}{
+		{name: "reads require a key", modify: func(s *Server) {
+			s.config.RequireApiKeyForReads = true
+			s.config.ApiKeys = []config.ApiKey{config.NewApiKey("reader", config.ApiKeyScopeRead, "read-key")}
+		}, apiKey: "read-key"},
+		{name: "api keys", modify: func(s *Server) {
+			s.config.ApiKeys = []config.ApiKey{config.NewApiKey("writer", config.ApiKeyScopeWrite, "write-key")}
+		}},
+		{name: "admin api key", modify: func(s *Server) { s.config.AdminApiKey = "admin-key" }},
+	}
+	for _, test := range tests {
+		s := newTestServerWithDb(t, &fakeDbClient{})
+		s.config.CacheControl = config.CacheControlConfig{MaxAge: time.Minute, OngoingMaxAge: 10 * time.Second}
+		test.modify(s)
+		r := s.router()
+
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/incidents?statusPageUrl="+url.QueryEscape(testStatusPageUrl), nil)
+		if test.apiKey != "" {
+			request.Header.Set("Authorization", "Bearer "+test.apiKey)
+		}
+		r.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusOK || recorder.Header().Get("Cache-Control") != "private, max-age=60" {
+			t.Fatalf("%s: expected the incidents to be private, got %d with %q", test.name, recorder.Code, recorder.Header().Get("Cache-Control"))
+		}
+		if !slices.Contains(recorder.Header().Values("Vary"), "Authorization") {
+			t.Fatalf("%s: expected the incidents to vary by Authorization, got %v", test.name, recorder.Header().Values("Vary"))
+		}
+	}
+}
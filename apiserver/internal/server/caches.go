@@ -2,24 +2,32 @@ package server
 
 import (
 	"context"
-	"github.com/patrickmn/go-cache"
+	"github.com/metoro-io/statusphere/apiserver/internal/cache"
+	"github.com/metoro-io/statusphere/common/api"
 	"go.uber.org/zap"
+	"maps"
 	"time"
 )
 
 func (s *Server) StartCaches(ctx context.Context) {
 	go s.updateStatusPageCache(ctx)
+	go s.warmIncidentCacheOnStartup(ctx)
+	go s.listenForIncidentChanges(ctx)
 }
 
 const statusPageCacheRefreshInterval = 1 * time.Minute
 
+// updateStatusPageCache refreshes the status page cache until the context is done
 func (s *Server) updateStatusPageCache(ctx context.Context) {
 	ticker := time.NewTicker(statusPageCacheRefreshInterval)
+	defer ticker.Stop()
 	s.updateStatusPageCacheInner(ctx)
 	for {
 		select {
 		case <-ticker.C:
 			s.updateStatusPageCacheInner(ctx)
+		case <-ctx.Done():
+			return
 		}
 	}
 }
@@ -31,7 +39,67 @@ func (s *Server) updateStatusPageCacheInner(ctx context.Context) {
 		return
 	}
 
+	allStatusPages := make(map[string]api.StatusPage, len(statusPages))
 	for _, statusPage := range statusPages {
-		s.statusPageCache.Set(statusPage.URL, statusPage, cache.DefaultExpiration)
+		if err := s.statusPageCache.Set(ctx, statusPage.URL, statusPage, cache.DefaultExpiration); err != nil {
+			s.logger.Error("failed to set status page in cache", zap.Error(err))
+			return
+		}
+		allStatusPages[statusPage.URL] = statusPage
+	}
+	if err := s.allStatusPagesCache.Set(ctx, allStatusPagesCacheKey, allStatusPages, cache.DefaultExpiration); err != nil {
+		s.logger.Error("failed to set every status page in cache", zap.Error(err))
+		return
+	}
+	s.statusPageCacheInitialized.Store(true)
+}
+
+// allStatusPagesCacheKey is the key of every status page in the allStatusPagesCache
+const allStatusPagesCacheKey = "all"
+
+// getCachedStatusPages returns every cached status page by its url, they're cached under a single key so that they can be read
+// without scanning the status page cache. The status pages are shared with the cache so must not be modified.
+func (s *Server) getCachedStatusPages(ctx context.Context) (map[string]api.StatusPage, error) {
+	statusPages, found, err := s.allStatusPagesCache.Get(ctx, allStatusPagesCacheKey)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return map[string]api.StatusPage{}, nil
+	}
+	return statusPages, nil
+}
+
+// setCachedStatusPage caches the status page by its url and replaces it in every cached status page
+// Replacing it isn't atomic, so a status page set by another replica at the same time can be missing from every cached status page
+// until the status page cache is next refreshed
+func (s *Server) setCachedStatusPage(ctx context.Context, statusPage api.StatusPage) error {
+	if err := s.statusPageCache.Set(ctx, statusPage.URL, statusPage, cache.DefaultExpiration); err != nil {
+		return err
+	}
+	return s.updateCachedStatusPages(ctx, func(statusPages map[string]api.StatusPage) {
+		statusPages[statusPage.URL] = statusPage
+	})
+}
+
+// deleteCachedStatusPage removes the status page from the cache and from every cached status page, see setCachedStatusPage
+func (s *Server) deleteCachedStatusPage(ctx context.Context, statusPageUrl string) error {
+	if err := s.statusPageCache.Delete(ctx, statusPageUrl); err != nil {
+		return err
+	}
+	return s.updateCachedStatusPages(ctx, func(statusPages map[string]api.StatusPage) {
+		delete(statusPages, statusPageUrl)
+	})
+}
+
+// updateCachedStatusPages updates a copy of every cached status page and caches it in their place
+func (s *Server) updateCachedStatusPages(ctx context.Context, update func(statusPages map[string]api.StatusPage)) error {
+	cached, err := s.getCachedStatusPages(ctx)
+	if err != nil {
+		return err
 	}
+	// The in memory cache returns the map it has cached, which other requests may be reading
+	statusPages := maps.Clone(cached)
+	update(statusPages)
+	return s.allStatusPagesCache.Set(ctx, allStatusPagesCacheKey, statusPages, cache.DefaultExpiration)
 }
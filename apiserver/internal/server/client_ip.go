@@ -0,0 +1,71 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// clientIPKey is the key of the client ip in the gin context, see addClientIP
+const clientIPKey = "clientIP"
+
+// addClientIP resolves the ip of the client of each request once, so that the rate limit and the request logs agree on it
+func addClientIP(trustedProxies []netip.Prefix) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(clientIPKey, resolveClientIP(c.Request.RemoteAddr, c.Request.Header, trustedProxies))
+		c.Next()
+	}
+}
+
+// clientIP returns the ip of the client of the request, see resolveClientIP
+// Requests that didn't go through addClientIP trust no proxies, so their ip is the one they were sent from
+func clientIP(c *gin.Context) string {
+	if ip := c.GetString(clientIPKey); ip != "" {
+		return ip
+	}
+	return resolveClientIP(c.Request.RemoteAddr, c.Request.Header, nil)
+}
+
+// resolveClientIP returns the ip of the client that sent a request from the remoteAddr with the headers
+// The X-Forwarded-For and X-Real-IP headers are only believed if the request was sent by one of the trusted proxies, as anyone else can set them
+// X-Forwarded-For is read from the right, skipping each trusted proxy that forwarded the request, so the first untrusted ip is the client.
+// If every ip in it is trusted then the leftmost one is the client, and X-Real-IP is only used if there is no valid X-Forwarded-For
+func resolveClientIP(remoteAddr string, header http.Header, trustedProxies []netip.Prefix) string {
+	host := remoteAddr
+	if splitHost, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		host = splitHost
+	}
+	remote, err := netip.ParseAddr(host)
+	if err != nil || !isTrustedProxy(remote, trustedProxies) {
+		return host
+	}
+
+	if forwardedFor := header.Values("X-Forwarded-For"); len(forwardedFor) > 0 {
+		hops := strings.Split(strings.Join(forwardedFor, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			if i == 0 || !isTrustedProxy(hop, trustedProxies) {
+				return hop.Unmap().String()
+			}
+		}
+	}
+	if realIP, err := netip.ParseAddr(strings.TrimSpace(header.Get("X-Real-IP"))); err == nil {
+		return realIP.Unmap().String()
+	}
+	return host
+}
+
+func isTrustedProxy(ip netip.Addr, trustedProxies []netip.Prefix) bool {
+	ip = ip.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/apiserver/internal/ratelimit"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestResolveClientIP(t *testing.T) {
+	trustedProxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("2001:db8::/32")}
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		forwardedFor   []string
+		realIP         string
+		trustedProxies []netip.Prefix
+		expected       string
+	}{
+		{name: "no proxy", remoteAddr: "203.0.113.7:4321", trustedProxies: trustedProxies, expected: "203.0.113.7"},
+		{name: "no trusted proxies", remoteAddr: "10.0.0.1:4321", forwardedFor: []string{"203.0.113.7"}, expected: "10.0.0.1"},
+		{name: "untrusted proxy", remoteAddr: "198.51.100.1:4321", forwardedFor: []string{"203.0.113.7"}, trustedProxies: trustedProxies, expected: "198.51.100.1"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.1:4321", forwardedFor: []string{"203.0.113.7"}, trustedProxies: trustedProxies, expected: "203.0.113.7"},
+		{name: "chain of trusted proxies", remoteAddr: "10.0.0.1:4321", forwardedFor: []string{"203.0.113.7, 10.0.0.3", "10.0.0.2"}, trustedProxies: trustedProxies, expected: "203.0.113.7"},
+		{name: "spoofed ip before the client", remoteAddr: "10.0.0.1:4321", forwardedFor: []string{"192.0.2.1, 203.0.113.7"}, trustedProxies: trustedProxies, expected: "203.0.113.7"},
+		{name: "only trusted proxies", remoteAddr: "10.0.0.1:4321", forwardedFor: []string{"10.0.0.3, 10.0.0.2"}, trustedProxies: trustedProxies, expected: "10.0.0.3"},
+		{name: "invalid forwarded for", remoteAddr: "10.0.0.1:4321", forwardedFor: []string{"unknown"}, realIP: "203.0.113.7", trustedProxies: trustedProxies, expected: "203.0.113.7"},
+		{name: "real ip", remoteAddr: "10.0.0.1:4321", realIP: "203.0.113.7", trustedProxies: trustedProxies, expected: "203.0.113.7"},
+		{name: "forwarded for takes precedence over real ip", remoteAddr: "10.0.0.1:4321", forwardedFor: []string{"203.0.113.7"}, realIP: "192.0.2.1", trustedProxies: trustedProxies, expected: "203.0.113.7"},
+		{name: "invalid headers", remoteAddr: "10.0.0.1:4321", forwardedFor: []string{"unknown"}, realIP: "unknown", trustedProxies: trustedProxies, expected: "10.0.0.1"},
+		{name: "ipv6 trusted proxy", remoteAddr: "[2001:db8::1]:4321", forwardedFor: []string{"2001:db9::7"}, trustedProxies: trustedProxies, expected: "2001:db9::7"},
+		{name: "ipv4 mapped trusted proxy", remoteAddr: "[::ffff:10.0.0.1]:4321", forwardedFor: []string{"::ffff:203.0.113.7"}, trustedProxies: trustedProxies, expected: "203.0.113.7"},
+	}
+	for _, test := range tests {
+		header := http.Header{}
+		for _, forwardedFor := range test.forwardedFor {
+			header.Add("X-Forwarded-For", forwardedFor)
+		}
+		if test.realIP != "" {
+			header.Set("X-Real-IP", test.realIP)
+		}
+		if ip := resolveClientIP(test.remoteAddr, header, test.trustedProxies); ip != test.expected {
+			t.Fatalf("%s: expected the client ip to be %s, got %s", test.name, test.expected, ip)
+		}
+	}
+}
+
+func TestClientIPIsUsedByTheRateLimitAndLogs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zap.InfoLevel)
+	r := gin.New()
+	r.Use(addClientIP([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}))
+	r.Use(ginZap(zap.New(core)))
+	r.Use(rateLimit(zap.NewNop(), ratelimit.NewInMemoryLimiter(1, 1)))
+	r.GET("/api/v1/incidents", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	get := func(forwardedFor string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/incidents", nil)
+		request.RemoteAddr = "10.0.0.1:4321"
+		request.Header.Set("X-Forwarded-For", forwardedFor)
+		r.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// Each client behind the load balancer has its own limit rather than sharing the limit of the load balancer
+	if recorder := get("203.0.113.7"); recorder.Code != http.StatusOK {
+		t.Fatalf("expected the first client to be allowed, got %d", recorder.Code)
+	}
+	if recorder := get("203.0.113.8"); recorder.Code != http.StatusOK {
+		t.Fatalf("expected the second client to be allowed, got %d", recorder.Code)
+	}
+	if recorder := get("203.0.113.7"); recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the first client to be limited, got %d", recorder.Code)
+	}
+
+	entries := logs.TakeAll()
+	if len(entries) != 3 || entries[0].ContextMap()["clientIP"] != "203.0.113.7" || entries[1].ContextMap()["clientIP"] != "203.0.113.8" {
+		t.Fatalf("expected the requests to be logged with the client ips, got %v", entries)
+	}
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressors create the writers that compress responses with each of the supported encodings, they favour speed over size
+var compressors = map[string]func(w io.Writer) io.WriteCloser{
+	"br": func(w io.Writer) io.WriteCloser {
+		return brotli.NewWriterLevel(w, brotli.BestSpeed)
+	},
+	"gzip": func(w io.Writer) io.WriteCloser {
+		compressor, _ := gzip.NewWriterLevel(w, gzip.BestSpeed)
+		return compressor
+	},
+	"deflate": func(w io.Writer) io.WriteCloser {
+		compressor, _ := flate.NewWriter(w, flate.BestSpeed)
+		return compressor
+	},
+}
+
+// compressResponses compresses responses with one of the encodings if the client accepts it and the response is at least minSize bytes.
+// The encodings are in the order they're preferred in when the client accepts several of them as much, encodings that aren't supported are ignored.
+// Responses are buffered until they reach minSize, so smaller responses are sent uncompressed with their original headers.
+// It must run before handlers that set an ETag, which should be weak because the compressed bytes differ from the ones that were hashed.
+func compressResponses(minSize int, encodings []string) gin.HandlerFunc {
+	var supported []string
+	for _, encoding := range encodings {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if _, ok := compressors[encoding]; ok {
+			supported = append(supported, encoding)
+		}
+	}
+	return func(c *gin.Context) {
+		encoding := negotiateContentEncoding(c.GetHeader("Accept-Encoding"), supported)
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		writer := &compressingResponseWriter{ResponseWriter: c.Writer, encoding: encoding, minSize: minSize}
+		c.Writer = writer
+		// Caches must not give a compressed response to clients that don't accept it
+		c.Header("Vary", "Accept-Encoding")
+		defer writer.close()
+		c.Next()
+	}
+}
+
+// negotiateContentEncoding returns the encoding that the Accept-Encoding header gives the highest quality value, see section 12.5.3 of RFC 9110
+// Encodings that aren't listed get the quality of * if there is one, ties go to the encoding that comes first in encodings
+// It returns "" for the identity encoding if none of the encodings are acceptable
+func negotiateContentEncoding(acceptEncoding string, encodings []string) string {
+	qualities := parseAcceptEncoding(acceptEncoding)
+	best := ""
+	bestQuality := 0.0
+	for _, encoding := range encodings {
+		quality, found := qualities[encoding]
+		if !found {
+			quality = qualities["*"]
+		}
+		if quality > bestQuality {
+			best = encoding
+			bestQuality = quality
+		}
+	}
+	return best
+}
+
+// parseAcceptEncoding returns the quality value of each coding of the Accept-Encoding header, which is 1 unless it has a q parameter
+// Codings are lower case, quality values outside of 0 to 1 are clamped and invalid ones are treated as 0 so the coding isn't used
+func parseAcceptEncoding(acceptEncoding string) map[string]float64 {
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, found := strings.Cut(param, "=")
+			if !found || !strings.EqualFold(strings.TrimSpace(name), "q") {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				parsed = 0
+			}
+			quality = min(max(parsed, 0), 1)
+		}
+		qualities[coding] = quality
+	}
+	return qualities
+}
+
+type compressingResponseWriter struct {
+	gin.ResponseWriter
+	encoding string
+	minSize  int
+	// buffer holds the start of the body until it is known whether it will be compressed
+	buffer     bytes.Buffer
+	compressor io.WriteCloser
+	// decided is true once the headers have been sent, with or without compression
+	decided bool
+}
+
+func (w *compressingResponseWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.compressor != nil {
+			return w.compressor.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+	w.buffer.Write(data)
+	if w.buffer.Len() >= w.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressingResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow sends the headers of a response without a body, e.g. a 304, so there is nothing to compress
+func (w *compressingResponseWriter) WriteHeaderNow() {
+	if !w.decided && w.buffer.Len() == 0 {
+		_ = w.decide(false)
+	}
+}
+
+// Flush is used to stream responses whose final size isn't known, so they are compressed regardless of minSize
+func (w *compressingResponseWriter) Flush() {
+	if !w.decided {
+		_ = w.decide(true)
+	}
+	if flusher, ok := w.compressor.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressingResponseWriter) decide(compress bool) error {
+	w.decided = true
+	status := w.ResponseWriter.Status()
+	// Don't compress responses that have no body or are already encoded
+	if status == http.StatusNoContent || status == http.StatusNotModified || w.Header().Get("Content-Encoding") != "" {
+		compress = false
+	}
+	if compress {
+		w.Header().Set("Content-Encoding", w.encoding)
+		w.Header().Del("Content-Length")
+		w.compressor = compressors[w.encoding](w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+	if w.buffer.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.compressor != nil {
+		_, err = w.compressor.Write(w.buffer.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buffer.Bytes())
+	}
+	w.buffer.Reset()
+	return err
+}
+
+// close sends anything that is still buffered, which is smaller than minSize so is sent uncompressed, and finishes the compressed stream
+func (w *compressingResponseWriter) close() {
+	if !w.decided {
+		_ = w.decide(false)
+	}
+	if w.compressor != nil {
+		_ = w.compressor.Close()
+	}
+}
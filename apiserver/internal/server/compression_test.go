@@ -0,0 +1,145 @@
+package server
+
+import (
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newCompressionTestRouter(minSize int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(compressResponses(minSize, []string{"br", "gzip", "deflate"}))
+	r.GET("/small", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	r.GET("/large", func(c *gin.Context) {
+		writeJSONWithETag(c, gin.H{"body": strings.Repeat("incident ", 100)})
+	})
+	return r
+}
+
+func TestCompressResponsesOnlyCompressesLargeResponses(t *testing.T) {
+	r := newCompressionTestRouter(100)
+
+	tests := []struct {
+		path             string
+		acceptEncoding   string
+		expectedEncoding string
+	}{
+		{path: "/large", acceptEncoding: "gzip, deflate", expectedEncoding: "gzip"},
+		{path: "/large", acceptEncoding: "br;q=1.0, gzip;q=0.5", expectedEncoding: "br"},
+		{path: "/large", acceptEncoding: "gzip;q=0, deflate", expectedEncoding: "deflate"},
+		{path: "/large", acceptEncoding: "", expectedEncoding: ""},
+		{path: "/small", acceptEncoding: "gzip", expectedEncoding: ""},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, test.path, nil)
+		request.Header.Set("Accept-Encoding", test.acceptEncoding)
+		r.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s with %q: expected status 200, got %d", test.path, test.acceptEncoding, recorder.Code)
+		}
+		if encoding := recorder.Header().Get("Content-Encoding"); encoding != test.expectedEncoding {
+			t.Fatalf("%s with %q: expected encoding %q, got %q", test.path, test.acceptEncoding, test.expectedEncoding, encoding)
+		}
+		var reader io.Reader
+		switch test.expectedEncoding {
+		case "gzip":
+			gzipReader, err := gzip.NewReader(recorder.Body)
+			if err != nil {
+				t.Fatalf("failed to read gzip body: %v", err)
+			}
+			reader = gzipReader
+		case "br":
+			reader = brotli.NewReader(recorder.Body)
+		default:
+			continue
+		}
+		body, _ := io.ReadAll(reader)
+		if !strings.Contains(string(body), "incident incident") {
+			t.Fatalf("unexpected decompressed body %q", body)
+		}
+	}
+}
+
+func TestCompressResponsesHonoursETags(t *testing.T) {
+	r := newCompressionTestRouter(0)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/large", nil)
+	request.Header.Set("Accept-Encoding", "gzip")
+	r.ServeHTTP(recorder, request)
+	etag := recorder.Header().Get("ETag")
+	if !strings.HasPrefix(etag, "W/") {
+		t.Fatalf("expected a weak ETag for a compressed response, got %q", etag)
+	}
+
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodGet, "/large", nil)
+	request.Header.Set("Accept-Encoding", "gzip")
+	request.Header.Set("If-None-Match", etag)
+	r.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNotModified {
+		t.Fatalf("expected status 304, got %d", recorder.Code)
+	}
+	if recorder.Body.Len() != 0 || recorder.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("expected an unencoded empty body, got %q with encoding %q", recorder.Body.String(), recorder.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestNegotiateContentEncodingUsesQualityValues(t *testing.T) {
+	encodings := []string{"br", "gzip", "deflate"}
+	tests := []struct {
+		acceptEncoding string
+		encodings      []string
+		expected       string
+	}{
+		{acceptEncoding: "br;q=1.0, gzip;q=0.5", encodings: encodings, expected: "br"},
+		{acceptEncoding: "br;q=0.5, gzip;q=1.0", encodings: encodings, expected: "gzip"},
+		{acceptEncoding: "gzip, deflate, br", encodings: encodings, expected: "br"},
+		{acceptEncoding: "deflate, gzip", encodings: encodings, expected: "gzip"},
+		{acceptEncoding: "deflate;q=0.9, gzip;q=0.8", encodings: encodings, expected: "deflate"},
+		{acceptEncoding: "BR; Q=0.4, GZIP; q=0.3", encodings: encodings, expected: "br"},
+		{acceptEncoding: "gzip;q=0", encodings: encodings, expected: ""},
+		{acceptEncoding: "*", encodings: encodings, expected: "br"},
+		{acceptEncoding: "*;q=0.5, br;q=0.1", encodings: encodings, expected: "gzip"},
+		{acceptEncoding: "*;q=0, deflate", encodings: encodings, expected: "deflate"},
+		{acceptEncoding: "identity", encodings: encodings, expected: ""},
+		{acceptEncoding: "gzip;q=invalid, deflate;q=0.1", encodings: encodings, expected: "deflate"},
+		{acceptEncoding: "gzip;q=2, br;q=1", encodings: encodings, expected: "br"},
+		{acceptEncoding: "zstd, gzip;q=0.1", encodings: encodings, expected: "gzip"},
+		{acceptEncoding: "", encodings: encodings, expected: ""},
+		{acceptEncoding: "br;q=1.0, gzip;q=0.5", encodings: []string{"gzip", "deflate"}, expected: "gzip"},
+		{acceptEncoding: "gzip, br", encodings: []string{"gzip", "br"}, expected: "gzip"},
+		{acceptEncoding: "gzip", encodings: nil, expected: ""},
+	}
+	for _, test := range tests {
+		if encoding := negotiateContentEncoding(test.acceptEncoding, test.encodings); encoding != test.expected {
+			t.Fatalf("%q with %v: expected %q, got %q", test.acceptEncoding, test.encodings, test.expected, encoding)
+		}
+	}
+}
+
+func TestCompressResponsesIgnoresUnsupportedEncodings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(compressResponses(0, []string{"zstd", " GZIP "}))
+	r.GET("/large", func(c *gin.Context) {
+		c.String(http.StatusOK, strings.Repeat("incident ", 100))
+	})
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/large", nil)
+	request.Header.Set("Accept-Encoding", "zstd, br, gzip;q=0.5")
+	r.ServeHTTP(recorder, request)
+	if encoding := recorder.Header().Get("Content-Encoding"); encoding != "gzip" {
+		t.Fatalf("expected the only supported enabled encoding, got %q", encoding)
+	}
+}
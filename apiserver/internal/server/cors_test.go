@@ -0,0 +1,73 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/apiserver/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestHandleCorsAnswersPreflightWithoutCallingHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(handleCors(config.CorsConfig{
+		AllowedOrigins: []string{"https://frontend.example.com"},
+		AllowedMethods: []string{"GET"},
+		AllowedHeaders: []string{"Content-Type"},
+	}))
+	handlerCalls := 0
+	handler := func(c *gin.Context) {
+		handlerCalls++
+		c.Status(http.StatusOK)
+	}
+	r.GET("/api/v1/incidents", handler)
+	r.OPTIONS("/api/v1/incidents", handler)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodOptions, "/api/v1/incidents", nil)
+	request.Header.Set("Origin", "https://frontend.example.com")
+	request.Header.Set("Access-Control-Request-Method", "GET")
+	r.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204 for a preflight request, got %d", recorder.Code)
+	}
+	if origin := recorder.Header().Get("Access-Control-Allow-Origin"); origin != "https://frontend.example.com" {
+		t.Fatalf("expected the origin to be allowed, got %q", origin)
+	}
+	if handlerCalls != 0 {
+		t.Fatalf("expected the preflight request to not reach the handler")
+	}
+
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents", nil)
+	request.Header.Set("Origin", "https://evil.example.com")
+	r.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403 for a disallowed origin, got %d", recorder.Code)
+	}
+	if handlerCalls != 0 {
+		t.Fatalf("expected the request from a disallowed origin to not reach the handler")
+	}
+}
+
+func TestCorsConfigDefaultsToSameOriginOnly(t *testing.T) {
+	t.Setenv("STATUSPHERE_CORS_ALLOWED_ORIGINS", "")
+	c, err := config.GetConfigFromEnvironment()
+	if err != nil {
+		t.Fatalf("failed to get config: %v", err)
+	}
+	if len(c.Cors.AllowedOrigins) != 0 {
+		t.Fatalf("expected no allowed origins by default, got %v", c.Cors.AllowedOrigins)
+	}
+	if len(c.Cors.AllowedMethods) == 0 || len(c.Cors.AllowedHeaders) == 0 {
+		t.Fatalf("expected default methods and headers, got %v and %v", c.Cors.AllowedMethods, c.Cors.AllowedHeaders)
+	}
+	// The headers that the api reads are allowed so that browsers can send them
+	for _, header := range []string{"Authorization", "Idempotency-Key", "If-None-Match", "If-Modified-Since"} {
+		if !slices.Contains(c.Cors.AllowedHeaders, header) {
+			t.Fatalf("expected %s to be allowed by default, got %v", header, c.Cors.AllowedHeaders)
+		}
+	}
+}
@@ -4,10 +4,10 @@ import (
 	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/metoro-io/statusphere/common/api"
-	"github.com/patrickmn/go-cache"
-	"github.com/pkg/errors"
+	"github.com/metoro-io/statusphere/common/utils"
 	"go.uber.org/zap"
 	"net/http"
+	"sort"
 )
 
 type Status string
@@ -21,74 +21,118 @@ const (
 type CurrentStatusResponse struct {
 	Status    Status `json:"status"`
 	IsIndexed bool   `json:"isIndexed"`
+	// SeverityScore is how broken the status page currently is, the sum of the scores of its ongoing incidents, see severityScore
+	SeverityScore int `json:"severityScore"`
+	// ContributingIncidents are the ongoing incidents that add to the severity score, highest score first
+	ContributingIncidents []SeverityContribution `json:"contributingIncidents,omitempty"`
+}
+
+// SeverityContribution is how much an ongoing incident adds to the severity score of its status page
+type SeverityContribution struct {
+	DeepLink   string     `json:"deepLink"`
+	Title      string     `json:"title"`
+	Impact     api.Impact `json:"impact"`
+	Components []string   `json:"components"`
+	Score      int        `json:"score"`
 }
 
 // currentStatus is a handler for the /current-status endpoint.
 // It has a required query parameter of statusPageUrl
 // It returns the current status of the status page.
 // If the status page is not known to statusphere, it returns a 404.
-// If the status page is known to statusphere and it is indexed, it returns UP or DEGRADED depending on the current incidents.
+// If the status page is known to statusphere and it is indexed, it returns UP or DEGRADED depending on the current incidents,
+// along with the severity score of the current incidents so that status pages can be ranked by how broken they are.
 // If the status page is known to statusphere and it is not indexed, it returns UNKNOWN.
 func (s *Server) currentStatus(context *gin.Context) {
 	ctx := context.Request.Context()
-	statusPageUrl := context.Query("statusPageUrl")
-	if statusPageUrl == "" {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "statusPageUrl is required"})
+	statusPageUrl, ok := parseStatusPageUrlQuery(context)
+	if !ok {
 		return
 	}
 
-	statusPageInterface, found := s.statusPageCache.Get(statusPageUrl)
-	if !found {
-		context.JSON(http.StatusNotFound, gin.H{"error": "status page not known to statusphere"})
+	statusPage, found, err := s.statusPageCache.Get(ctx, statusPageUrl)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to get status page from cache", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get status page from cache")
 		return
 	}
-
-	statusPageInterfaceCasted, ok := statusPageInterface.(api.StatusPage)
-	if !ok {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cast status page to api.StatusPage"})
+	if !found {
+		writeError(context, http.StatusNotFound, ErrorCodeStatusPageNotKnown, "status page not known to statusphere")
 		return
 	}
 
-	if !statusPageInterfaceCasted.IsIndexed {
+	if !statusPage.IsIndexed {
 		context.JSON(http.StatusOK, CurrentStatusResponse{Status: StatusUnknown, IsIndexed: false})
 		return
 	}
 
 	// Attempt to get the incidents from the cache
+	// If the cache is unavailable then we fall back to the database
 	incidents, found, err := s.getCurrentIncidentsFromCache(ctx, statusPageUrl)
 	if err != nil {
-		s.logger.Error("failed to get incidents from cache", zap.Error(err))
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get incidents from cache"})
-		return
+		utils.GetLogger(ctx, s.logger).Warn("failed to get incidents from cache, falling back to the database", zap.Error(err))
 	}
 	if found {
-		if len(incidents) > 0 {
-			context.JSON(http.StatusOK, CurrentStatusResponse{Status: StatusDegraded, IsIndexed: true})
-			return
-		}
-		context.JSON(http.StatusOK, CurrentStatusResponse{Status: StatusUp, IsIndexed: true})
+		context.JSON(http.StatusOK, s.currentStatusOf(incidents))
 		return
 	}
 
 	// Attempt to get the incidents from the database
 	incidents, found, err = s.getCurrentIncidentsFromDatabase(ctx, statusPageUrl)
 	if err != nil {
-		s.logger.Error("failed to get incidents from database", zap.Error(err))
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get incidents from database"})
+		utils.GetLogger(ctx, s.logger).Error("failed to get incidents from database", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get incidents from database")
 		return
 	}
 	if !found {
-		context.JSON(http.StatusNotFound, gin.H{"error": "status page not known to statusphere"})
+		writeError(context, http.StatusNotFound, ErrorCodeStatusPageNotKnown, "status page not known to statusphere")
 		return
 	}
 
-	s.currentIncidentCache.Set(statusPageUrl, incidents, cache.DefaultExpiration)
+	if err := s.currentIncidentCache.Set(ctx, statusPageUrl, incidents, s.config.Cache.IncidentTTL); err != nil {
+		utils.GetLogger(ctx, s.logger).Warn("failed to set incidents in cache", zap.Error(err))
+	}
+	context.JSON(http.StatusOK, s.currentStatusOf(incidents))
+}
+
+// currentStatusOf returns the current status of an indexed status page with the current incidents
+func (s *Server) currentStatusOf(incidents []api.Incident) CurrentStatusResponse {
+	score, contributions := severityScore(incidents, s.config.Incidents.SeverityWeights)
+	response := CurrentStatusResponse{Status: StatusUp, IsIndexed: true, SeverityScore: score, ContributingIncidents: contributions}
 	if len(incidents) > 0 {
-		context.JSON(http.StatusOK, CurrentStatusResponse{Status: StatusDegraded, IsIndexed: true})
-		return
+		response.Status = StatusDegraded
 	}
+	return response
+}
 
-	context.JSON(http.StatusOK, CurrentStatusResponse{Status: StatusUp, IsIndexed: true})
+// severityScore returns the severity score of the ongoing incidents and how much each of them contributed to it
+// Each incident scores the weight of its impact for each component it affects, an incident that doesn't name its components
+// scores the weight once, and incidents whose impact has no weight don't contribute
+func severityScore(incidents []api.Incident, weights map[string]int) (int, []SeverityContribution) {
+	total := 0
+	var contributions []SeverityContribution
+	for _, incident := range incidents {
+		if !incident.IsOngoing() {
+			continue
+		}
+		weight := weights[string(incident.Impact)]
+		if weight <= 0 {
+			continue
+		}
+		score := weight * max(len(incident.Components), 1)
+		total += score
+		contributions = append(contributions, SeverityContribution{
+			DeepLink:   incident.DeepLink,
+			Title:      incident.Title,
+			Impact:     incident.Impact,
+			Components: incident.Components,
+			Score:      score,
+		})
+	}
+	sort.SliceStable(contributions, func(i, j int) bool {
+		return contributions[i].Score > contributions[j].Score
+	})
+	return total, contributions
 }
 
 // getCurrentIncidentsFromCache attempts to get the current incidents from the cache.
@@ -96,17 +140,7 @@ func (s *Server) currentStatus(context *gin.Context) {
 // If the incidents are not found in the cache, it returns false for the second return value.
 
 func (s *Server) getCurrentIncidentsFromCache(ctx context.Context, statusPageUrl string) ([]api.Incident, bool, error) {
-	incidents, found := s.currentIncidentCache.Get(statusPageUrl)
-	if !found {
-		return nil, false, nil
-	}
-
-	incidentsCasted, ok := incidents.([]api.Incident)
-	if !ok {
-		return nil, false, errors.New("failed to cast incidents to []api.Incident")
-	}
-
-	return incidentsCasted, true, nil
+	return s.currentIncidentCache.Get(ctx, statusPageUrl)
 }
 
 // getCurrentIncidentsFromDatabase attempts to get the current incidents from the database.
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSeverityScore(t *testing.T) {
+	weights := map[string]int{"critical": 100, "major": 50, "minor": 10}
+	now := time.Now()
+	withComponents := func(incident api.Incident, components ...string) api.Incident {
+		incident.Components = components
+		return incident
+	}
+	resolved := newTestIncident("resolved", api.ImpactCritical, now.Add(-time.Hour))
+	resolved.EndTime = &now
+	tests := []struct {
+		name                  string
+		incidents             []api.Incident
+		expectedScore         int
+		expectedContributions []string
+	}{
+		{name: "no incidents", expectedScore: 0},
+		{
+			name:                  "one of each impact",
+			incidents:             []api.Incident{newTestIncident("minor", api.ImpactMinor, now), newTestIncident("critical", api.ImpactCritical, now), newTestIncident("major", api.ImpactMajor, now)},
+			expectedScore:         160,
+			expectedContributions: []string{"critical", "major", "minor"},
+		},
+		{
+			name:                  "weighted by components",
+			incidents:             []api.Incident{withComponents(newTestIncident("major", api.ImpactMajor, now), "api", "web", "db"), newTestIncident("critical", api.ImpactCritical, now)},
+			expectedScore:         250,
+			expectedContributions: []string{"major", "critical"},
+		},
+		{
+			name:                  "impacts without a weight and resolved incidents",
+			incidents:             []api.Incident{newTestIncident("maintenance", api.ImpactMaintenance, now), newTestIncident("none", api.ImpactNone, now), resolved, newTestIncident("minor", api.ImpactMinor, now)},
+			expectedScore:         10,
+			expectedContributions: []string{"minor"},
+		},
+	}
+	for _, test := range tests {
+		score, contributions := severityScore(test.incidents, weights)
+		if score != test.expectedScore {
+			t.Fatalf("%s: expected the score %d, got %d", test.name, test.expectedScore, score)
+		}
+		if len(contributions) != len(test.expectedContributions) {
+			t.Fatalf("%s: expected %d contributing incidents, got %+v", test.name, len(test.expectedContributions), contributions)
+		}
+		for i, contribution := range contributions {
+			if contribution.Title != test.expectedContributions[i] {
+				t.Fatalf("%s: expected the contributing incidents %v, got %+v", test.name, test.expectedContributions, contributions)
+			}
+		}
+	}
+}
+
+func TestCurrentStatusReturnsTheSeverityScore(t *testing.T) {
+	now := time.Now()
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{
+		newTestIncident("critical", api.ImpactCritical, now),
+		newTestIncident("minor", api.ImpactMinor, now),
+	}})
+	s.config.Incidents.SeverityWeights = map[string]int{"critical": 7, "minor": 1}
+
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/currentStatus?statusPageUrl="+url.QueryEscape(testStatusPageUrl), nil)
+	s.currentStatus(testContext)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response CurrentStatusResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response.Status != StatusDegraded || response.SeverityScore != 8 || len(response.ContributingIncidents) != 2 || response.ContributingIncidents[0].Score != 7 {
+		t.Fatalf("unexpected current status: %+v", response)
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/db"
+	"time"
+)
+
+// DbClient is the subset of db.DbClient used by the api server
+// It is an interface so that the database can be faked in tests
+type DbClient interface {
+	Ping(ctx context.Context) error
+	GetAllStatusPages(ctx context.Context) ([]api.StatusPage, error)
+	GetStatusPage(ctx context.Context, url string) (*api.StatusPage, error)
+	CreateStatusPage(ctx context.Context, statusPage api.StatusPage) error
+	MarkStatusPageForRescrape(ctx context.Context, url string) error
+	SetStatusPageIndexingPaused(ctx context.Context, url string, paused bool) error
+	GetIncidents(ctx context.Context, statusPageUrl string) ([]api.Incident, error)
+	GetIncidentsIncludingDeleted(ctx context.Context, statusPageUrl string) ([]api.Incident, error)
+	GetIncidentsUpdatedSince(ctx context.Context, statusPageUrl string, since time.Time) ([]api.Incident, error)
+	GetCurrentIncidents(ctx context.Context, statusPageUrl string) ([]api.Incident, error)
+	GetAllCurrentIncidents(ctx context.Context) ([]api.Incident, error)
+	GetRecentIncidents(ctx context.Context, limit int, impacts []api.Impact) ([]api.Incident, error)
+	GetIncidentByID(ctx context.Context, id string) (*api.Incident, error)
+	SearchIncidents(ctx context.Context, statusPageUrl string, query string) ([]api.Incident, error)
+	SearchAllIncidents(ctx context.Context, search db.IncidentsSearch) ([]api.Incident, error)
+	QueryIncidents(ctx context.Context, query db.IncidentsQuery) ([]api.Incident, error)
+	StreamIncidents(ctx context.Context, query db.IncidentsQuery, incidents chan<- api.Incident) error
+	CountIncidents(ctx context.Context, query db.IncidentsQuery) (db.IncidentCounts, error)
+	CountIncidentsByImpact(ctx context.Context, query db.IncidentsQuery) (map[api.Impact]int, error)
+	CountIncidentsByStatusPage(ctx context.Context, since time.Time) (map[string]int, error)
+	ListenForIncidentChanges(ctx context.Context, onChange func(statusPageUrl string)) error
+	CreateSubscription(ctx context.Context, subscription api.Subscription) error
+	DeleteSubscription(ctx context.Context, id string) error
+	GetSubscription(ctx context.Context, id string) (*api.Subscription, error)
+	GetSubscriptionDeliveries(ctx context.Context, subscriptionId string) ([]api.SubscriptionDelivery, error)
+	RequestSubscriptionRedelivery(ctx context.Context, subscriptionId string, deliveryId string) error
+	GetStatusPageSnapshot(ctx context.Context, statusPageUrl string) (*api.StatusPageSnapshot, error)
+}
+
+var _ DbClient = &db.DbClient{}
@@ -0,0 +1,350 @@
+package server
+
+import (
+	"context"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/db"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// fakeDbClient is an in memory DbClient for tests
+type fakeDbClient struct {
+	statusPages   []api.StatusPage
+	incidents     []api.Incident
+	subscriptions []api.Subscription
+	deliveries    []api.SubscriptionDelivery
+	snapshots     []api.StatusPageSnapshot
+	// getIncidentsDelay slows down GetIncidents so that tests can issue concurrent requests while it is in flight
+	getIncidentsDelay               time.Duration
+	getIncidentsCalls               atomic.Int32
+	searchIncidentsCalls            atomic.Int32
+	getAllCurrentIncidentsCalls     atomic.Int32
+	getRecentIncidentsCalls         atomic.Int32
+	queryIncidentsCalls             atomic.Int32
+	streamIncidentsCalls            atomic.Int32
+	countIncidentsByStatusPageCalls atomic.Int32
+	countIncidentsByImpactCalls     atomic.Int32
+	// pingErr is returned by Ping to simulate the database being unavailable
+	pingErr error
+	// getIncidentsErr is returned by GetIncidents to simulate a failing query
+	getIncidentsErr error
+	// streamIncidentsErr is returned by StreamIncidents after it sends streamIncidentsErrAfter incidents to simulate a failing query
+	streamIncidentsErr      error
+	streamIncidentsErrAfter int
+	// incidentChanges are the status pages passed to the listeners of ListenForIncidentChanges, if it is nil they're never notified
+	incidentChanges chan string
+}
+
+func (f *fakeDbClient) Ping(ctx context.Context) error {
+	return f.pingErr
+}
+
+func (f *fakeDbClient) GetAllStatusPages(ctx context.Context) ([]api.StatusPage, error) {
+	return f.statusPages, nil
+}
+
+func (f *fakeDbClient) GetStatusPage(ctx context.Context, url string) (*api.StatusPage, error) {
+	for _, statusPage := range f.statusPages {
+		if statusPage.URL == url {
+			return &statusPage, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeDbClient) CreateStatusPage(ctx context.Context, statusPage api.StatusPage) error {
+	for _, existing := range f.statusPages {
+		if existing.URL == statusPage.URL {
+			return db.ErrStatusPageExists
+		}
+	}
+	f.statusPages = append(f.statusPages, statusPage)
+	return nil
+}
+
+func (f *fakeDbClient) MarkStatusPageForRescrape(ctx context.Context, url string) error {
+	for i := range f.statusPages {
+		if f.statusPages[i].URL == url {
+			f.statusPages[i].LastHistoricallyScraped = time.Time{}
+			f.statusPages[i].LastCurrentlyScraped = time.Time{}
+		}
+	}
+	return nil
+}
+
+func (f *fakeDbClient) SetStatusPageIndexingPaused(ctx context.Context, url string, paused bool) error {
+	for i := range f.statusPages {
+		if f.statusPages[i].URL == url {
+			f.statusPages[i].IndexingPaused = paused
+		}
+	}
+	return nil
+}
+
+func (f *fakeDbClient) GetIncidents(ctx context.Context, statusPageUrl string) ([]api.Incident, error) {
+	f.getIncidentsCalls.Add(1)
+	time.Sleep(f.getIncidentsDelay)
+	if f.getIncidentsErr != nil {
+		return nil, f.getIncidentsErr
+	}
+	var incidents []api.Incident
+	for _, incident := range f.incidents {
+		if incident.StatusPageUrl == statusPageUrl && incident.DeletedAt == nil {
+			incidents = append(incidents, incident)
+		}
+	}
+	return incidents, nil
+}
+
+func (f *fakeDbClient) GetIncidentsIncludingDeleted(ctx context.Context, statusPageUrl string) ([]api.Incident, error) {
+	var incidents []api.Incident
+	for _, incident := range f.incidents {
+		if incident.StatusPageUrl == statusPageUrl {
+			incidents = append(incidents, incident)
+		}
+	}
+	return incidents, nil
+}
+
+func (f *fakeDbClient) GetIncidentsUpdatedSince(ctx context.Context, statusPageUrl string, since time.Time) ([]api.Incident, error) {
+	var incidents []api.Incident
+	for _, incident := range f.incidents {
+		if incident.StatusPageUrl == statusPageUrl && incident.UpdatedAt.After(since) {
+			incidents = append(incidents, incident)
+		}
+	}
+	sort.SliceStable(incidents, func(i, j int) bool {
+		return incidents[i].UpdatedAt.Before(incidents[j].UpdatedAt)
+	})
+	return incidents, nil
+}
+
+func (f *fakeDbClient) GetCurrentIncidents(ctx context.Context, statusPageUrl string) ([]api.Incident, error) {
+	var incidents []api.Incident
+	for _, incident := range f.incidents {
+		if incident.StatusPageUrl == statusPageUrl && incident.IsOngoing() {
+			incidents = append(incidents, incident)
+		}
+	}
+	return incidents, nil
+}
+
+func (f *fakeDbClient) GetAllCurrentIncidents(ctx context.Context) ([]api.Incident, error) {
+	f.getAllCurrentIncidentsCalls.Add(1)
+	var incidents []api.Incident
+	for _, incident := range f.incidents {
+		if incident.IsOngoing() {
+			incidents = append(incidents, incident)
+		}
+	}
+	return incidents, nil
+}
+
+// filterFakeIncidentsByImpacts filters the incidents like the database does, where nil impacts match every incident
+func filterFakeIncidentsByImpacts(incidents []api.Incident, impacts []api.Impact) []api.Incident {
+	if impacts == nil {
+		return incidents
+	}
+	var filteredIncidents []api.Incident
+	for _, incident := range incidents {
+		for _, impact := range impacts {
+			if incident.Impact == impact {
+				filteredIncidents = append(filteredIncidents, incident)
+				break
+			}
+		}
+	}
+	return filteredIncidents
+}
+
+func (f *fakeDbClient) GetRecentIncidents(ctx context.Context, limit int, impacts []api.Impact) ([]api.Incident, error) {
+	f.getRecentIncidentsCalls.Add(1)
+	incidents := filterFakeIncidentsByImpacts(sortIncidents(f.incidents, sortOrderDescending), impacts)
+	if len(incidents) > limit {
+		incidents = incidents[:limit]
+	}
+	return incidents, nil
+}
+
+func (f *fakeDbClient) GetIncidentByID(ctx context.Context, id string) (*api.Incident, error) {
+	for _, incident := range f.incidents {
+		if incident.DeepLink == id {
+			return &incident, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeDbClient) SearchIncidents(ctx context.Context, statusPageUrl string, query string) ([]api.Incident, error) {
+	f.searchIncidentsCalls.Add(1)
+	var incidents []api.Incident
+	for _, incident := range f.incidents {
+		if incident.StatusPageUrl == statusPageUrl {
+			incidents = append(incidents, incident)
+		}
+	}
+	return filterIncidentsByQuery(incidents, query), nil
+}
+
+func (f *fakeDbClient) SearchAllIncidents(ctx context.Context, search db.IncidentsSearch) ([]api.Incident, error) {
+	var incidents []api.Incident
+	for _, incident := range f.incidents {
+		if incident.DeletedAt != nil || incident.StartTime.Before(search.From) || incident.StartTime.After(search.To) {
+			continue
+		}
+		if search.AfterDeepLink != "" && !isIncidentBefore(api.Incident{StartTime: search.AfterStartTime, DeepLink: search.AfterDeepLink}, incident, sortOrderDescending) {
+			continue
+		}
+		incidents = append(incidents, incident)
+	}
+	incidents = sortIncidents(filterIncidentsByQuery(incidents, search.Query), sortOrderDescending)
+	if len(incidents) > search.Limit {
+		incidents = incidents[:search.Limit]
+	}
+	return incidents, nil
+}
+
+func (f *fakeDbClient) filterIncidents(query db.IncidentsQuery) []api.Incident {
+	var incidents []api.Incident
+	for _, incident := range f.incidents {
+		if incident.StatusPageUrl == query.StatusPageUrl && incident.DeletedAt == nil {
+			incidents = append(incidents, incident)
+		}
+	}
+	incidents = filterFakeIncidentsByImpacts(incidents, query.Impacts)
+	return filterIncidentsByTimeRange(incidents, incidentTimeRange{from: query.From, to: query.To})
+}
+
+func (f *fakeDbClient) QueryIncidents(ctx context.Context, query db.IncidentsQuery) ([]api.Incident, error) {
+	f.queryIncidentsCalls.Add(1)
+	return f.queryIncidents(query), nil
+}
+
+func (f *fakeDbClient) StreamIncidents(ctx context.Context, query db.IncidentsQuery, incidents chan<- api.Incident) error {
+	defer close(incidents)
+	f.streamIncidentsCalls.Add(1)
+	for i, incident := range f.queryIncidents(query) {
+		if f.streamIncidentsErr != nil && i == f.streamIncidentsErrAfter {
+			return f.streamIncidentsErr
+		}
+		select {
+		case incidents <- incident:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return f.streamIncidentsErr
+}
+
+func (f *fakeDbClient) queryIncidents(query db.IncidentsQuery) []api.Incident {
+	order := sortOrderDescending
+	if query.Ascending {
+		order = sortOrderAscending
+	}
+	incidents := sortIncidents(f.filterIncidents(query), order)
+	incidents = incidents[min(query.Offset, len(incidents)):]
+	if query.Limit != nil && len(incidents) > *query.Limit {
+		incidents = incidents[:*query.Limit]
+	}
+	return incidents
+}
+
+func (f *fakeDbClient) CountIncidents(ctx context.Context, query db.IncidentsQuery) (db.IncidentCounts, error) {
+	incidents := f.filterIncidents(query)
+	return db.IncidentCounts{Total: len(incidents), Ongoing: countOngoingIncidents(incidents)}, nil
+}
+
+func (f *fakeDbClient) CountIncidentsByImpact(ctx context.Context, query db.IncidentsQuery) (map[api.Impact]int, error) {
+	f.countIncidentsByImpactCalls.Add(1)
+	query.Impacts = nil
+	counts := make(map[api.Impact]int)
+	for _, incident := range f.filterIncidents(query) {
+		counts[incident.Impact]++
+	}
+	return counts, nil
+}
+
+func (f *fakeDbClient) CountIncidentsByStatusPage(ctx context.Context, since time.Time) (map[string]int, error) {
+	f.countIncidentsByStatusPageCalls.Add(1)
+	// The query of the database is cancelled along with its context
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	for _, incident := range f.incidents {
+		if !incident.StartTime.Before(since) && incident.DeletedAt == nil {
+			counts[incident.StatusPageUrl]++
+		}
+	}
+	return counts, nil
+}
+
+func (f *fakeDbClient) ListenForIncidentChanges(ctx context.Context, onChange func(statusPageUrl string)) error {
+	for {
+		select {
+		case statusPageUrl := <-f.incidentChanges:
+			onChange(statusPageUrl)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (f *fakeDbClient) CreateSubscription(ctx context.Context, subscription api.Subscription) error {
+	f.subscriptions = append(f.subscriptions, subscription)
+	return nil
+}
+
+func (f *fakeDbClient) DeleteSubscription(ctx context.Context, id string) error {
+	for i, subscription := range f.subscriptions {
+		if subscription.ID == id {
+			f.subscriptions = append(f.subscriptions[:i], f.subscriptions[i+1:]...)
+			return nil
+		}
+	}
+	return db.ErrSubscriptionNotFound
+}
+
+func (f *fakeDbClient) GetSubscription(ctx context.Context, id string) (*api.Subscription, error) {
+	for _, subscription := range f.subscriptions {
+		if subscription.ID == id {
+			return &subscription, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeDbClient) GetSubscriptionDeliveries(ctx context.Context, subscriptionId string) ([]api.SubscriptionDelivery, error) {
+	var deliveries []api.SubscriptionDelivery
+	for _, delivery := range f.deliveries {
+		if delivery.SubscriptionID == subscriptionId {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].AttemptedAt.After(deliveries[j].AttemptedAt)
+	})
+	return deliveries, nil
+}
+
+func (f *fakeDbClient) RequestSubscriptionRedelivery(ctx context.Context, subscriptionId string, deliveryId string) error {
+	for i, delivery := range f.deliveries {
+		if delivery.SubscriptionID == subscriptionId && delivery.ID == deliveryId {
+			requestedAt := time.Now()
+			f.deliveries[i].RedeliveryRequestedAt = &requestedAt
+			return nil
+		}
+	}
+	return db.ErrSubscriptionDeliveryNotFound
+}
+
+func (f *fakeDbClient) GetStatusPageSnapshot(ctx context.Context, statusPageUrl string) (*api.StatusPageSnapshot, error) {
+	for _, snapshot := range f.snapshots {
+		if snapshot.StatusPageUrl == statusPageUrl {
+			return &snapshot, nil
+		}
+	}
+	return nil, nil
+}
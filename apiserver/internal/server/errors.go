@@ -0,0 +1,91 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorCode identifies why a request failed, unlike the message of the error it doesn't change so clients can match on it
+type ErrorCode string
+
+const (
+	ErrorCodeStatusPageUrlRequired    ErrorCode = "STATUS_PAGE_URL_REQUIRED"
+	ErrorCodeInvalidStatusPageUrl     ErrorCode = "INVALID_STATUS_PAGE_URL"
+	ErrorCodeStatusPageNotKnown       ErrorCode = "STATUS_PAGE_NOT_KNOWN"
+	ErrorCodeInvalidImpact            ErrorCode = "INVALID_IMPACT"
+	ErrorCodeConflictingImpactFilters ErrorCode = "CONFLICTING_IMPACT_FILTERS"
+	ErrorCodeInvalidType              ErrorCode = "INVALID_TYPE"
+	ErrorCodeInvalidLimit             ErrorCode = "INVALID_LIMIT"
+	ErrorCodeInvalidOrder             ErrorCode = "INVALID_ORDER"
+	ErrorCodeInvalidCursor            ErrorCode = "INVALID_CURSOR"
+	ErrorCodeInvalidTimeRange         ErrorCode = "INVALID_TIME_RANGE"
+	ErrorCodeInvalidTimeZone          ErrorCode = "INVALID_TIME_ZONE"
+	ErrorCodeInvalidComponentMatch    ErrorCode = "INVALID_COMPONENT_MATCH"
+	ErrorCodeInvalidFields            ErrorCode = "INVALID_FIELDS"
+	ErrorCodeInvalidWait              ErrorCode = "INVALID_WAIT"
+	// ErrorCodeInvalidStatusPageUrlCount is returned when more status pages are requested at once than an endpoint allows
+	ErrorCodeInvalidStatusPageUrlCount ErrorCode = "INVALID_STATUS_PAGE_URL_COUNT"
+	// ErrorCodeInvalidBody is returned for a json body that can't be decoded or is missing a required field
+	ErrorCodeInvalidBody ErrorCode = "INVALID_BODY"
+	// ErrorCodeQueryRequired is returned by the searches when the search query is missing
+	ErrorCodeQueryRequired ErrorCode = "QUERY_REQUIRED"
+	// ErrorCodeStatusPageRequired is returned when neither statusPageUrl nor statusPageName is given
+	ErrorCodeStatusPageRequired ErrorCode = "STATUS_PAGE_REQUIRED"
+	// ErrorCodeConflictingStatusPageFilters is returned when both statusPageUrl and statusPageName are given
+	ErrorCodeConflictingStatusPageFilters ErrorCode = "CONFLICTING_STATUS_PAGE_FILTERS"
+	// ErrorCodeIncidentIdRequired is returned when the id of the incident is missing
+	ErrorCodeIncidentIdRequired ErrorCode = "INCIDENT_ID_REQUIRED"
+	// ErrorCodeInvalidOffset is returned for an offset that isn't a non-negative integer
+	ErrorCodeInvalidOffset ErrorCode = "INVALID_OFFSET"
+	// ErrorCodeInvalidPeriod is returned for a by of the grouped incidents that isn't day, week or month
+	ErrorCodeInvalidPeriod ErrorCode = "INVALID_PERIOD"
+	// ErrorCodeInvalidDays is returned for a days of the incident stats that isn't a positive integer
+	ErrorCodeInvalidDays ErrorCode = "INVALID_DAYS"
+	// ErrorCodeInvalidFormat is returned for a format of a subscription that isn't json or slack
+	ErrorCodeInvalidFormat ErrorCode = "INVALID_FORMAT"
+	// ErrorCodeInvalidTargetUrl is returned for a targetUrl of a subscription that isn't an http(s) url with a host
+	ErrorCodeInvalidTargetUrl ErrorCode = "INVALID_TARGET_URL"
+	// ErrorCodeInvalidBoolean is returned for a query parameter that must be a boolean but isn't, e.g. ongoing=maybe
+	ErrorCodeInvalidBoolean ErrorCode = "INVALID_BOOLEAN"
+	ErrorCodeAdminDisabled  ErrorCode = "ADMIN_DISABLED"
+	ErrorCodeInvalidApiKey  ErrorCode = "INVALID_API_KEY"
+	// ErrorCodeInsufficientScope is returned for a valid api key that doesn't have the scope that the request needs
+	ErrorCodeInsufficientScope ErrorCode = "INSUFFICIENT_SCOPE"
+	ErrorCodeRateLimited       ErrorCode = "RATE_LIMITED"
+	ErrorCodeTooManyStreams    ErrorCode = "TOO_MANY_STREAMS"
+	// ErrorCodeInvalidIdempotencyKey is returned for an Idempotency-Key header that is too long
+	ErrorCodeInvalidIdempotencyKey ErrorCode = "INVALID_IDEMPOTENCY_KEY"
+	// ErrorCodeIdempotencyKeyReused is returned when an Idempotency-Key is sent again with a different request to the one it was first used for
+	ErrorCodeIdempotencyKeyReused ErrorCode = "IDEMPOTENCY_KEY_REUSED"
+	// ErrorCodeIncidentNotKnown is returned for an incident id that isn't the deep link of an incident that statusphere knows about
+	ErrorCodeIncidentNotKnown ErrorCode = "INCIDENT_NOT_KNOWN"
+	// ErrorCodeSubscriptionNotKnown is returned for a subscription id that isn't the id of a subscription
+	ErrorCodeSubscriptionNotKnown ErrorCode = "SUBSCRIPTION_NOT_KNOWN"
+	// ErrorCodeSubscriptionDeliveryNotKnown is returned for a delivery id that isn't the id of a delivery of the subscription
+	ErrorCodeSubscriptionDeliveryNotKnown ErrorCode = "SUBSCRIPTION_DELIVERY_NOT_KNOWN"
+	// ErrorCodeStatusPageAlreadyKnown is returned when a status page is submitted that statusphere already knows about
+	ErrorCodeStatusPageAlreadyKnown ErrorCode = "STATUS_PAGE_ALREADY_KNOWN"
+	// ErrorCodeStatusPageNotReachable is returned when a status page is submitted that doesn't respond successfully
+	ErrorCodeStatusPageNotReachable ErrorCode = "STATUS_PAGE_NOT_REACHABLE"
+	// ErrorCodeStatusPageHostNotAllowed is returned when a status page is submitted whose host isn't allowed to be indexed, see hosts.Config
+	ErrorCodeStatusPageHostNotAllowed ErrorCode = "STATUS_PAGE_HOST_NOT_ALLOWED"
+	// ErrorCodeStatusPageSnapshotNotKnown is returned for a status page that hasn't been snapshotted by a current scrape yet
+	ErrorCodeStatusPageSnapshotNotKnown ErrorCode = "STATUS_PAGE_SNAPSHOT_NOT_KNOWN"
+	ErrorCodeInternal                   ErrorCode = "INTERNAL_ERROR"
+)
+
+// ErrorResponse is the body of an error response
+type ErrorResponse struct {
+	// Error is a human readable description of the error
+	Error string    `json:"error"`
+	Code  ErrorCode `json:"code"`
+}
+
+// writeError writes an error response with the status code, error code and message
+func writeError(context *gin.Context, status int, code ErrorCode, message string) {
+	context.JSON(status, ErrorResponse{Error: message, Code: code})
+}
+
+// abortWithError is writeError for middleware, it also stops the handlers that follow from running
+func abortWithError(context *gin.Context, status int, code ErrorCode, message string) {
+	context.AbortWithStatusJSON(status, ErrorResponse{Error: message, Code: code})
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIncidentsErrorCodes(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{newTestIncident("a", api.ImpactMajor, time.Now())}})
+	failing := api.StatusPage{URL: "https://status.failing.com", IsIndexed: true}
+	failingServer := newTestServerWithDb(t, &fakeDbClient{getIncidentsErr: errors.New("connection refused")})
+	failingServer.setCachedStatusPage(context.Background(), failing)
+	adminServer := newTestServerWithDb(t, &fakeDbClient{})
+	adminServer.config.AdminApiKey = "secret"
+
+	tests := []struct {
+		server         *Server
+		query          string
+		expectedStatus int
+		expectedCode   ErrorCode
+	}{
+		{server: s, query: "", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeStatusPageUrlRequired},
+		{server: s, query: "statusPageUrl=status.example.com", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidStatusPageUrl},
+		{server: s, query: "statusPageUrl=ftp://status.example.com", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidStatusPageUrl},
+		{server: s, query: "statusPageUrl=https://status.unknown.com", expectedStatus: http.StatusNotFound, expectedCode: ErrorCodeStatusPageNotKnown},
+		{server: s, query: "statusPageUrl=https://status.unknown.com&q=outage", expectedStatus: http.StatusNotFound, expectedCode: ErrorCodeStatusPageNotKnown},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&impact=severe", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidImpact},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&excludeImpact=severe", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidImpact},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&impact=major&excludeImpact=minor", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeConflictingImpactFilters},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&type=outage", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidType},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&type=maintenance&impact=major", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeConflictingImpactFilters},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&limit=ten", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidLimit},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&wait=soon", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidWait},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&wait=-1s", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidWait},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&ongoing=maybe", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidBoolean},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&order=newest", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidOrder},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&cursor=invalid", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidCursor},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&from=yesterday", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidTimeRange},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&to=tomorrow", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidTimeRange},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&from=2024-03-02T00:00:00Z&to=2024-03-01T00:00:00Z", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidTimeRange},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&at=yesterday", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidTimeRange},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&at=2024-03-01T00:00:00Z&from=2024-03-01T00:00:00Z", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidTimeRange},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&at=2024-03-01T00:00:00Z&includeOngoing=true", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidTimeRange},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&includeOngoing=maybe", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidBoolean},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&componentMatch=fuzzy", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidComponentMatch},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&includeEvents=maybe", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidBoolean},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&fields=impact,severity", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidFields},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&tz=Mars/Olympus_Mons", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidTimeZone},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&includeDeleted=maybe", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidBoolean},
+		{server: s, query: "statusPageUrl=" + testStatusPageUrl + "&includeDeleted=true", expectedStatus: http.StatusForbidden, expectedCode: ErrorCodeAdminDisabled},
+		{server: adminServer, query: "statusPageUrl=" + testStatusPageUrl + "&includeDeleted=true", expectedStatus: http.StatusUnauthorized, expectedCode: ErrorCodeInvalidApiKey},
+		{server: failingServer, query: "statusPageUrl=" + failing.URL, expectedStatus: http.StatusInternalServerError, expectedCode: ErrorCodeInternal},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		testContext, _ := gin.CreateTestContext(recorder)
+		testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents?"+test.query, nil)
+		test.server.incidents(testContext)
+		if recorder.Code != test.expectedStatus {
+			t.Fatalf("%s: expected status %d, got %d", test.query, test.expectedStatus, recorder.Code)
+		}
+		var response ErrorResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: failed to unmarshal response: %v", test.query, err)
+		}
+		if response.Code != test.expectedCode || response.Error == "" {
+			t.Fatalf("%s: expected code %s with a message, got %+v", test.query, test.expectedCode, response)
+		}
+	}
+}
+
+func TestEndpointErrorCodes(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{newTestIncident("a", api.ImpactMajor, time.Now())}})
+	s.statusPageChecker = func(ctx context.Context, statusPageUrl string) error { return errors.New("connection refused") }
+	r := s.router()
+
+	tests := []struct {
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedCode   ErrorCode
+	}{
+		{method: http.MethodGet, path: "/api/v1/statusPage", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeStatusPageRequired},
+		{method: http.MethodGet, path: "/api/v1/statusPage?statusPageUrl=" + testStatusPageUrl + "&statusPageName=example", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeConflictingStatusPageFilters},
+		{method: http.MethodGet, path: "/api/v1/statusPage?statusPageName=unknown", expectedStatus: http.StatusNotFound, expectedCode: ErrorCodeStatusPageNotKnown},
+		{method: http.MethodGet, path: "/api/v1/statusPages?offset=-1", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidOffset},
+		{method: http.MethodGet, path: "/api/v1/statusPages?indexedOnly=maybe", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidBoolean},
+		{method: http.MethodGet, path: "/api/v1/statusPages?cursor=invalid", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidCursor},
+		{method: http.MethodGet, path: "/api/v1/statusPages/search", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeQueryRequired},
+		{method: http.MethodGet, path: "/api/v1/statusPages/stats?statusPageUrl=" + testStatusPageUrl + "&days=0", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidDays},
+		{method: http.MethodGet, path: "/api/v1/incidents/grouped?statusPageUrl=" + testStatusPageUrl + "&by=year", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidPeriod},
+		{method: http.MethodGet, path: "/api/v1/incidents/all?limit=-1", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidLimit},
+		{method: http.MethodGet, path: "/api/v1/incidents/" + url.PathEscape("https://status.unknown.com/incidents/a"), expectedStatus: http.StatusNotFound, expectedCode: ErrorCodeIncidentNotKnown},
+		{method: http.MethodPost, path: "/api/v1/incidents/batch", body: `{}`, expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidBody},
+		{method: http.MethodPost, path: "/api/v1/incidents/batch", body: `{"statusPageUrls": ["` + testStatusPageUrl + `"], "impact": ["severe"]}`, expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidImpact},
+		{method: http.MethodPost, path: "/api/v1/statusPages", body: `{}`, expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidBody},
+		{method: http.MethodPost, path: "/api/v1/statusPages", body: `{"url": "` + testStatusPageUrl + `"}`, expectedStatus: http.StatusConflict, expectedCode: ErrorCodeStatusPageAlreadyKnown},
+		{method: http.MethodPost, path: "/api/v1/statusPages", body: `{"url": "https://status.new.com"}`, expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeStatusPageNotReachable},
+		{method: http.MethodPost, path: "/api/v1/subscriptions", body: `{"statusPageUrl": "` + testStatusPageUrl + `", "targetUrl": "https://hooks.example.com", "format": "xml"}`, expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidFormat},
+		{method: http.MethodPost, path: "/api/v1/subscriptions", body: `{"statusPageUrl": "` + testStatusPageUrl + `", "targetUrl": "ftp://hooks.example.com"}`, expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidTargetUrl},
+		{method: http.MethodDelete, path: "/api/v1/subscriptions/unknown", expectedStatus: http.StatusNotFound, expectedCode: ErrorCodeSubscriptionNotKnown},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		request.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(recorder, request)
+		if recorder.Code != test.expectedStatus {
+			t.Fatalf("%s %s: expected status %d, got %d: %s", test.method, test.path, test.expectedStatus, recorder.Code, recorder.Body.String())
+		}
+		var response ErrorResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s %s: failed to unmarshal response: %v", test.method, test.path, err)
+		}
+		if response.Code != test.expectedCode || response.Error == "" {
+			t.Fatalf("%s %s: expected code %s with a message, got %+v", test.method, test.path, test.expectedCode, response)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// writeJSONWithETag writes the response as json with an ETag of its body.
+// If the request has an If-None-Match header matching the ETag, it returns a 304 without a body instead.
+// The ETag is weak because responses may be compressed, so the bytes sent are not always the ones that were hashed.
+func writeJSONWithETag(context *gin.Context, response interface{}) {
+	writeJSONWithValidators(context, response, time.Time{})
+}
+
+// writeJSONWithValidators is writeJSONWithETag with a Last-Modified header of lastModified, unless it is zero
+// If the request has an If-Modified-Since header at or after lastModified, it returns a 304 without a body instead
+// If-Modified-Since is ignored when the request has an If-None-Match header, as described in section 13.1.3 of RFC 9110
+// The body is indented if the request asks for it with pretty=true, the ETag is of the compact body so it doesn't depend on pretty
+func writeJSONWithValidators(context *gin.Context, response interface{}, lastModified time.Time) {
+	body, err := json.Marshal(response)
+	if err != nil {
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to marshal response")
+		return
+	}
+
+	// The hash doesn't need to be cryptographic, it just needs to change when the body does
+	hash := fnv.New64a()
+	_, _ = hash.Write(body)
+	etag := fmt.Sprintf(`W/"%x"`, hash.Sum64())
+
+	context.Header("ETag", etag)
+	notModified := ifNoneMatchMatches(context.GetHeader("If-None-Match"), etag)
+	if !lastModified.IsZero() {
+		// A Last-Modified in the future isn't allowed, e.g. for maintenance that is scheduled to start later
+		if now := time.Now(); lastModified.After(now) {
+			lastModified = now
+		}
+		context.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+		if context.GetHeader("If-None-Match") == "" {
+			notModified = ifModifiedSinceMatches(context.GetHeader("If-Modified-Since"), lastModified)
+		}
+	}
+	if notModified {
+		context.Status(http.StatusNotModified)
+		context.Writer.WriteHeaderNow()
+		return
+	}
+	if wantsPrettyJSON(context) {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, body, "", "  "); err == nil {
+			body = indented.Bytes()
+		}
+	}
+	context.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// wantsPrettyJSON returns true if the request has a pretty query parameter of true, e.g. to make responses readable with curl
+// The json is compact by default, and when pretty isn't a boolean, so that clients aren't sent the extra whitespace
+func wantsPrettyJSON(context *gin.Context) bool {
+	pretty, err := strconv.ParseBool(context.Query("pretty"))
+	return err == nil && pretty
+}
+
+// ifModifiedSinceMatches returns true if the If-Modified-Since header is at or after lastModified, so the response hasn't changed since it
+// The header only has a precision of seconds, so lastModified is truncated to seconds before they're compared
+func ifModifiedSinceMatches(ifModifiedSince string, lastModified time.Time) bool {
+	if ifModifiedSince == "" {
+		return false
+	}
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// ifNoneMatchMatches returns true if any of the entity tags of the If-None-Match header match the etag
+// It uses the weak comparison described in section 13.1.2 of RFC 9110
+func ifNoneMatchMatches(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
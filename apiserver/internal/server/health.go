@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// readinessCheckTimeout bounds all the readiness checks together so that probes don't hang when a dependency does
+const readinessCheckTimeout = 2 * time.Second
+
+type ReadinessResponse struct {
+	Ready bool `json:"ready"`
+	// Failed has the reason each dependency that isn't ready failed its check
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+// healthz is a handler for the /healthz endpoint, it is a liveness probe that succeeds as long as the process is serving requests.
+func (s *Server) healthz(context *gin.Context) {
+	context.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readyz is a handler for the /readyz endpoint, it is a readiness probe.
+// It checks that the database and redis, if it is the cache backend, can be reached and that the status page cache has been loaded.
+// It returns a 503 listing the dependencies that failed if any of them aren't ready.
+func (s *Server) readyz(context *gin.Context) {
+	ctx, cancel := contextWithReadinessTimeout(context.Request.Context())
+	defer cancel()
+
+	failed := make(map[string]string)
+	if err := s.dbClient.Ping(ctx); err != nil {
+		failed["database"] = err.Error()
+	}
+	if s.redisClient != nil {
+		if err := s.redisClient.Ping(ctx).Err(); err != nil {
+			failed["redis"] = err.Error()
+		}
+	}
+	if !s.statusPageCacheInitialized.Load() {
+		failed["statusPageCache"] = "status pages have not been loaded from the database yet"
+	}
+
+	if len(failed) > 0 {
+		context.JSON(http.StatusServiceUnavailable, ReadinessResponse{Ready: false, Failed: failed})
+		return
+	}
+	context.JSON(http.StatusOK, ReadinessResponse{Ready: true})
+}
+
+func contextWithReadinessTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, readinessCheckTimeout)
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/apiserver/internal/config"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func getReadyz(s *Server) (*httptest.ResponseRecorder, ReadinessResponse) {
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	s.readyz(testContext)
+	var response ReadinessResponse
+	_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+	return recorder, response
+}
+
+func TestReadyz(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	miniRedis := miniredis.RunT(t)
+	dbClient := &fakeDbClient{}
+	s := NewServer(zap.NewNop(), dbClient, redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}), config.Config{})
+
+	// The server isn't ready until the status pages have been loaded
+	recorder, response := getReadyz(s)
+	if recorder.Code != http.StatusServiceUnavailable || response.Ready || response.Failed["statusPageCache"] == "" {
+		t.Fatalf("expected the status page cache to not be ready, got %d %+v", recorder.Code, response)
+	}
+
+	s.updateStatusPageCacheInner(context.Background())
+	recorder, response = getReadyz(s)
+	if recorder.Code != http.StatusOK || !response.Ready || len(response.Failed) != 0 {
+		t.Fatalf("expected the server to be ready, got %d %+v", recorder.Code, response)
+	}
+
+	dbClient.pingErr = errors.New("connection refused")
+	miniRedis.Close()
+	recorder, response = getReadyz(s)
+	if recorder.Code != http.StatusServiceUnavailable || len(response.Failed) != 2 || response.Failed["database"] == "" || response.Failed["redis"] == "" {
+		t.Fatalf("expected the database and redis to fail, got %d %+v", recorder.Code, response)
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	s := newTestServer(t)
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	s.healthz(testContext)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+type IncidentResponse struct {
+	Incident      api.Incident `json:"incident"`
+	StatusPageUrl string       `json:"statusPageUrl"`
+}
+
+// incident is a handler for the /incidents/:id endpoint.
+// The id is the deep link of the incident, it should be url escaped
+// It returns the incident and the url of the status page that it belongs to.
+// If the incident is not known to statusphere, it returns a 404.
+func (s *Server) incident(context *gin.Context) {
+	ctx := context.Request.Context()
+	id := context.Param("id")
+	if id == "" {
+		writeError(context, http.StatusBadRequest, ErrorCodeIncidentIdRequired, "id is required")
+		return
+	}
+
+	incident, found, err := s.lookupIncident(ctx, id)
+	if err != nil {
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get incident from database")
+		return
+	}
+	if !found {
+		writeError(context, http.StatusNotFound, ErrorCodeIncidentNotKnown, "incident not known to statusphere")
+		return
+	}
+	context.JSON(http.StatusOK, IncidentResponse{Incident: flagIncidentAt(incident, time.Now(), s.config.Incidents), StatusPageUrl: incident.StatusPageUrl})
+}
+
+// lookupIncident gets the incident with the id from the cached incidents of its status page, or from the database if they aren't cached
+// The status page of an incident read from the database is cached by the id of the incident, so that it is found in the cached incidents next time
+// It returns false if the incident is not known to statusphere
+func (s *Server) lookupIncident(ctx context.Context, id string) (api.Incident, bool, error) {
+	// Attempt to get the incident from the caches
+	// If the caches are unavailable then we fall back to the database
+	incident, found, err := s.getIncidentByIdFromCache(ctx, id)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Warn("failed to get incident from cache, falling back to the database", zap.Error(err))
+	}
+	if found {
+		return incident, true, nil
+	}
+
+	incidentFromDb, err := s.dbClient.GetIncidentByID(ctx, id)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to get incident from database", zap.Error(err))
+		return api.Incident{}, false, err
+	}
+	if incidentFromDb == nil {
+		return api.Incident{}, false, nil
+	}
+
+	if err := s.incidentStatusPageCache.Set(ctx, id, incidentFromDb.StatusPageUrl, s.config.Cache.IncidentTTL); err != nil {
+		utils.GetLogger(ctx, s.logger).Warn("failed to set the status page of the incident in cache", zap.Error(err))
+	}
+	return *incidentFromDb, true, nil
+}
+
+// getIncidentByIdFromCache attempts to get the incident from the cached incidents of its status page, which is cached by the id of the incident
+// Both are looked up by their key, so the lookup doesn't depend on how many incidents are cached for other status pages
+// If the status page of the incident or its incidents aren't cached, it returns false for the second return value.
+func (s *Server) getIncidentByIdFromCache(ctx context.Context, id string) (api.Incident, bool, error) {
+	statusPageUrl, found, err := s.incidentStatusPageCache.Get(ctx, id)
+	if err != nil || !found {
+		return api.Incident{}, false, err
+	}
+	incidents, found, err := s.incidentCache.Get(ctx, statusPageUrl)
+	if err != nil || !found {
+		return api.Incident{}, false, err
+	}
+	for _, incident := range incidents {
+		if incident.DeepLink == id {
+			return incident, true, nil
+		}
+	}
+	return api.Incident{}, false, nil
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"github.com/metoro-io/statusphere/apiserver/internal/config"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
+	"strings"
+)
+
+// cachesIncidentsByImpacts returns true if the incidents are also cached for each impact filter, see config.IncidentCacheKeyStatusPageAndImpacts
+func (s *Server) cachesIncidentsByImpacts() bool {
+	return s.config.Cache.IncidentKeyStrategy == config.IncidentCacheKeyStatusPageAndImpacts
+}
+
+// incidentCacheKey returns the key of the incidents of the status page that pass the impact filter in the incident cache
+// Filters that keep the same impacts have the same key, e.g. impact=critical,major and excludeImpact=minor,none,maintenance,
+// and filters that keep every impact have the key of all the incidents, which is the status page url
+func incidentCacheKey(statusPageUrl string, impacts impactFilter) string {
+	included := impacts.includedImpacts()
+	if included == nil {
+		return statusPageUrl
+	}
+	impactSet := make(map[api.Impact]struct{}, len(included))
+	for _, impact := range included {
+		impactSet[impact] = struct{}{}
+	}
+	if len(impactSet) == len(api.Impacts) {
+		return statusPageUrl
+	}
+	var keys []string
+	for _, impact := range api.Impacts {
+		if _, ok := impactSet[impact]; ok {
+			keys = append(keys, string(impact))
+		}
+	}
+	return statusPageUrl + "|impacts:" + strings.Join(keys, ",")
+}
+
+// filteredIncidentCacheKeys returns the keys of every impact filter of the status page bar the one that keeps every impact,
+// so that they can all be removed from the cache
+func filteredIncidentCacheKeys(statusPageUrl string) []string {
+	var keys []string
+	// Each filter is built from the impacts it excludes, as a filter that includes no impacts keeps every incident
+	for set := 1; set < 1<<len(api.Impacts); set++ {
+		var excluded []api.Impact
+		for i, impact := range api.Impacts {
+			if set&(1<<i) != 0 {
+				excluded = append(excluded, impact)
+			}
+		}
+		keys = append(keys, incidentCacheKey(statusPageUrl, excludeImpacts(excluded...)))
+	}
+	return keys
+}
+
+// setFilteredIncidentsInCache caches the incidents of the status page that passed the impact filter under the key of the filter
+// It does nothing unless the incidents are cached by impacts, or if the filter keeps every impact as they are cached under the status page url
+func (s *Server) setFilteredIncidentsInCache(ctx context.Context, statusPageUrl string, impacts impactFilter, incidents []api.Incident) {
+	key := incidentCacheKey(statusPageUrl, impacts)
+	if !s.cachesIncidentsByImpacts() || key == statusPageUrl {
+		return
+	}
+	if err := s.incidentCache.Set(ctx, key, incidents, s.config.Cache.IncidentTTL); err != nil {
+		utils.GetLogger(ctx, s.logger).Warn("failed to set filtered incidents in cache", zap.Error(err))
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"github.com/metoro-io/statusphere/apiserver/internal/cache"
+	"github.com/metoro-io/statusphere/apiserver/internal/config"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIncidentCacheKeyCanonicalisesTheImpacts(t *testing.T) {
+	criticalAndMajor := testStatusPageUrl + "|impacts:critical,major"
+	tests := []struct {
+		name     string
+		impacts  impactFilter
+		expected string
+	}{
+		{name: "no filter", impacts: impactFilter{}, expected: testStatusPageUrl},
+		{name: "included impacts", impacts: includeImpacts(api.ImpactCritical, api.ImpactMajor), expected: criticalAndMajor},
+		{name: "reordered and repeated impacts", impacts: includeImpacts(api.ImpactMajor, api.ImpactCritical, api.ImpactMajor), expected: criticalAndMajor},
+		{name: "excluded impacts", impacts: excludeImpacts(api.ImpactMinor, api.ImpactMaintenance, api.ImpactNone), expected: criticalAndMajor},
+		{name: "every impact", impacts: includeImpacts(api.Impacts...), expected: testStatusPageUrl},
+		{name: "no impacts", impacts: excludeImpacts(api.Impacts...), expected: testStatusPageUrl + "|impacts:"},
+	}
+	for _, test := range tests {
+		if key := incidentCacheKey(testStatusPageUrl, test.impacts); key != test.expected {
+			t.Fatalf("%s: expected %q, got %q", test.name, test.expected, key)
+		}
+	}
+
+	// Every filter but the one keeping every impact has its own key
+	keys := filteredIncidentCacheKeys(testStatusPageUrl)
+	unique := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if key == testStatusPageUrl {
+			t.Fatalf("expected the key of every incident not to be a filtered key")
+		}
+		unique[key] = struct{}{}
+	}
+	if len(unique) != 1<<len(api.Impacts)-1 {
+		t.Fatalf("expected %d filtered keys, got %d", 1<<len(api.Impacts)-1, len(unique))
+	}
+	if _, ok := unique[criticalAndMajor]; !ok {
+		t.Fatalf("expected the filtered keys to include %q", criticalAndMajor)
+	}
+}
+
+func TestFilteredIncidentsAreOnlyCachedByStatusPageByDefault(t *testing.T) {
+	dbClient := &fakeDbClient{incidents: []api.Incident{
+		newTestIncident("a", api.ImpactMajor, time.Now()),
+		newTestIncident("b", api.ImpactMinor, time.Now().Add(-time.Hour)),
+	}}
+	s := newTestServerWithDb(t, dbClient)
+
+	if recorder := getIncidents(s, "impact=major"); recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	if cached, found, _ := s.incidentCache.Get(context.Background(), testStatusPageUrl); !found || len(cached) != 2 {
+		t.Fatalf("expected every incident to be cached under the status page, got %+v", cached)
+	}
+	if _, found, _ := s.incidentCache.Get(context.Background(), incidentCacheKey(testStatusPageUrl, includeImpacts(api.ImpactMajor))); found {
+		t.Fatalf("expected the filtered incidents not to be cached")
+	}
+}
+
+func TestFilteredIncidentsAreCachedByImpactsWhenConfigured(t *testing.T) {
+	dbClient := &fakeDbClient{incidents: []api.Incident{
+		newTestIncident("a", api.ImpactMajor, time.Now()),
+		newTestIncident("b", api.ImpactMinor, time.Now().Add(-time.Hour)),
+	}}
+	s := newTestServerWithDb(t, dbClient)
+	s.config.Cache.IncidentKeyStrategy = config.IncidentCacheKeyStatusPageAndImpacts
+	ctx := context.Background()
+	filteredKey := incidentCacheKey(testStatusPageUrl, includeImpacts(api.ImpactMajor))
+
+	// The cold request caches both every incident and the ones that passed the filter
+	realIncidents := getIncidents(s, "impact=major")
+	if cached, found, _ := s.incidentCache.Get(ctx, testStatusPageUrl); !found || len(cached) != 2 {
+		t.Fatalf("expected every incident to be cached under the status page, got %+v", cached)
+	}
+	if cached, found, _ := s.incidentCache.Get(ctx, filteredKey); !found || len(cached) != 1 || cached[0].Impact != api.ImpactMajor {
+		t.Fatalf("expected the major incidents to be cached under their own key, got %+v", cached)
+	}
+
+	// The filtered entry is used without filtering the incidents of the status page again, which they would no longer pass
+	_ = s.incidentCache.Set(ctx, testStatusPageUrl, []api.Incident{}, cache.DefaultExpiration)
+	if recorder := getIncidents(s, "excludeImpact=critical,minor,maintenance,none"); recorder.Body.String() != realIncidents.Body.String() {
+		t.Fatalf("expected the same filter to be served from the filtered entry, got %s", recorder.Body.String())
+	}
+	if calls := dbClient.getIncidentsCalls.Load(); calls != 1 {
+		t.Fatalf("expected the database to be read once, got %d", calls)
+	}
+
+	// A filter that isn't cached yet is filtered from the incidents of the status page and then cached
+	_ = s.incidentCache.Set(ctx, testStatusPageUrl, dbClient.incidents, cache.DefaultExpiration)
+	getIncidents(s, "impact=minor")
+	if cached, found, _ := s.incidentCache.Get(ctx, incidentCacheKey(testStatusPageUrl, includeImpacts(api.ImpactMinor))); !found || len(cached) != 1 {
+		t.Fatalf("expected the minor incidents to be cached under their own key, got %+v", cached)
+	}
+
+	// Invalidating the status page removes the incidents of every filter
+	if err := s.deleteCachedIncidents(ctx, testStatusPageUrl); err != nil {
+		t.Fatalf("failed to delete the cached incidents: %v", err)
+	}
+	for _, key := range []string{testStatusPageUrl, filteredKey, incidentCacheKey(testStatusPageUrl, includeImpacts(api.ImpactMinor))} {
+		if _, found, _ := s.incidentCache.Get(ctx, key); found {
+			t.Fatalf("expected %q to be removed from the cache", key)
+		}
+	}
+}
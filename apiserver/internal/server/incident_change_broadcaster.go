@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// incidentChangesRetryInterval is how long to wait before listening for incident changes again after the listening connection failed
+const incidentChangesRetryInterval = 5 * time.Second
+
+// incidentChangeBroadcaster wakes the requests waiting for the incidents of a status page to change
+type incidentChangeBroadcaster struct {
+	mu sync.Mutex
+	// changed has a channel for each status page that requests are waiting on, it is closed and removed when the status page changes
+	changed map[string]chan struct{}
+}
+
+func newIncidentChangeBroadcaster() *incidentChangeBroadcaster {
+	return &incidentChangeBroadcaster{changed: make(map[string]chan struct{})}
+}
+
+// wait returns a channel that is closed the next time the incidents of the status page change
+// It should be called before the incidents are read, so that a change while they're being read isn't missed
+func (b *incidentChangeBroadcaster) wait(statusPageUrl string) <-chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	changed, ok := b.changed[statusPageUrl]
+	if !ok {
+		changed = make(chan struct{})
+		b.changed[statusPageUrl] = changed
+	}
+	return changed
+}
+
+// publish wakes every request waiting for the incidents of the status page to change
+func (b *incidentChangeBroadcaster) publish(statusPageUrl string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if changed, ok := b.changed[statusPageUrl]; ok {
+		close(changed)
+		delete(b.changed, statusPageUrl)
+	}
+}
+
+// listenForIncidentChanges listens for the notifications that the scraper sends when it changes the incidents of a status page
+// until the context is done, listening again if the connection fails
+func (s *Server) listenForIncidentChanges(ctx context.Context) {
+	for {
+		err := s.dbClient.ListenForIncidentChanges(ctx, func(statusPageUrl string) {
+			s.handleIncidentChange(ctx, statusPageUrl)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		s.logger.Warn("stopped listening for incident changes, listening again shortly", zap.Error(err))
+		select {
+		case <-time.After(incidentChangesRetryInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handleIncidentChange removes the incidents of the status page from the caches, as they're out of date, then wakes the requests waiting for them to change
+func (s *Server) handleIncidentChange(ctx context.Context, statusPageUrl string) {
+	if err := s.deleteCachedIncidents(ctx, statusPageUrl); err != nil {
+		s.logger.Warn("failed to delete the changed incidents from cache", zap.String("statusPageUrl", statusPageUrl), zap.Error(err))
+	}
+	s.changeBroadcaster.publish(statusPageUrl)
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// incidentFields are the names of the fields of api.Incident in the json responses, which the fields query parameter can select
+var incidentFields = jsonFieldNames(reflect.TypeOf(api.Incident{}))
+
+// projectedIncidentsResponse is an IncidentsResponse whose incidents only have the fields selected by the fields query parameter
+type projectedIncidentsResponse struct {
+	IncidentsResponse
+	// Incidents shadows the field of IncidentsResponse, so it is the one that is marshalled
+	Incidents []map[string]json.RawMessage `json:"incidents"`
+}
+
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// parseFieldsQuery parses the optional fields query parameter, a comma separated list of the fields of the incidents to return e.g. fields=deepLink,impact,startTime
+// It returns nil if fields isn't given, in which case the incidents have all of their fields
+// If any of the fields isn't a field of api.Incident, it writes a 400 response and returns false
+func parseFieldsQuery(context *gin.Context) ([]string, bool) {
+	fieldsQuery := context.Query("fields")
+	if fieldsQuery == "" {
+		return nil, true
+	}
+	var fields []string
+	for _, field := range strings.Split(fieldsQuery, ",") {
+		field = strings.TrimSpace(field)
+		if !incidentFields[field] {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidFields, "unknown field in fields: "+field)
+			return nil, false
+		}
+		fields = append(fields, field)
+	}
+	return fields, true
+}
+
+// projectIncidents returns the incidents as json objects with only the fields
+// Fields that are omitted from the json of an incident when they're empty, e.g. deletedAt, are omitted from its object too
+func projectIncidents(incidents []api.Incident, fields []string) ([]map[string]json.RawMessage, error) {
+	projected := make([]map[string]json.RawMessage, 0, len(incidents))
+	for _, incident := range incidents {
+		marshalled, err := json.Marshal(incident)
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(marshalled, &all); err != nil {
+			return nil, err
+		}
+		object := make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			if value, ok := all[field]; ok {
+				object[field] = value
+			}
+		}
+		projected = append(projected, object)
+	}
+	return projected, nil
+}
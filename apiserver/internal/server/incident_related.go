@@ -0,0 +1,170 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// defaultRelatedIncidentsLimit is the number of related incidents returned when no limit is given
+	defaultRelatedIncidentsLimit = 10
+	// maxRelatedIncidentsLimit is the most related incidents returned at once, larger limits are clamped to it
+	maxRelatedIncidentsLimit = 50
+)
+
+type RelatedIncidentsResponse struct {
+	Incident api.Incident      `json:"incident"`
+	Related  []RelatedIncident `json:"related"`
+}
+
+// RelatedIncident is an incident of the same status page that overlapped or is similar to the incident that it is related to
+type RelatedIncident struct {
+	Incident api.Incident `json:"incident"`
+	// Score is how related the incident is, from 0 to 2. It is the fraction of the shorter of the two incidents that they overlapped for
+	// plus the fraction of the keywords in their titles that they share
+	Score float64 `json:"score"`
+	// Overlaps is true if the incidents were ongoing at the same time
+	Overlaps bool `json:"overlaps"`
+	// SharedKeywords are the keywords that are in the titles of both incidents, sorted
+	SharedKeywords []string `json:"sharedKeywords"`
+}
+
+// relatedIncidents is a handler for the /incidents/:id/related endpoint.
+// The id is the deep link of the incident, it should be url escaped
+// It returns the incidents of the same status page that overlapped with the incident or have titles that share keywords with it, most related first
+// It has an optional query parameter of limit (default is 10), the most related incidents to return, limits above 50 are clamped to it
+// If the incident is not known to statusphere, it returns a 404.
+func (s *Server) relatedIncidents(context *gin.Context) {
+	ctx := context.Request.Context()
+	limit, ok := parseLimitQuery(context)
+	if !ok {
+		return
+	}
+	if limit != nil && *limit < 0 {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidLimit, "limit must be a non-negative integer")
+		return
+	}
+	relatedLimit := defaultRelatedIncidentsLimit
+	if limit != nil {
+		relatedLimit = min(*limit, maxRelatedIncidentsLimit)
+	}
+
+	incident, found, err := s.lookupIncident(ctx, context.Param("id"))
+	if err != nil {
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get incident from database")
+		return
+	}
+	if !found {
+		writeError(context, http.StatusNotFound, ErrorCodeIncidentNotKnown, "incident not known to statusphere")
+		return
+	}
+
+	_, incidents, lookupErr := s.lookupStatusPageIncidents(ctx, incident.StatusPageUrl, impactFilter{})
+	if lookupErr != nil {
+		lookupErr.write(context)
+		return
+	}
+	now := time.Now()
+	related := findRelatedIncidents(incident, incidents, now, relatedLimit)
+	for i := range related {
+		related[i].Incident = flagIncidentAt(related[i].Incident, now, s.config.Incidents)
+	}
+	writeJSONWithETag(context, RelatedIncidentsResponse{Incident: flagIncidentAt(incident, now, s.config.Incidents), Related: related})
+}
+
+// findRelatedIncidents returns the incidents that overlapped with the incident or share keywords with it, ranked by their score, see RelatedIncident
+// Incidents with the same score are ranked by how close they started to the incident, at most limit incidents are returned
+// Ongoing incidents are treated as ending at now
+func findRelatedIncidents(incident api.Incident, incidents []api.Incident, now time.Time, limit int) []RelatedIncident {
+	keywords := titleKeywords(incident.Title)
+	related := []RelatedIncident{}
+	for _, candidate := range incidents {
+		if candidate.DeepLink == incident.DeepLink {
+			continue
+		}
+		overlap := overlapFraction(incident, candidate, now)
+		shared, similarity := sharedKeywords(keywords, titleKeywords(candidate.Title))
+		if overlap == 0 && similarity == 0 {
+			continue
+		}
+		related = append(related, RelatedIncident{Incident: candidate, Score: overlap + similarity, Overlaps: overlap > 0, SharedKeywords: shared})
+	}
+	sort.SliceStable(related, func(i, j int) bool {
+		if related[i].Score != related[j].Score {
+			return related[i].Score > related[j].Score
+		}
+		return related[i].Incident.StartTime.Sub(incident.StartTime).Abs() < related[j].Incident.StartTime.Sub(incident.StartTime).Abs()
+	})
+	if len(related) > limit {
+		related = related[:limit]
+	}
+	return related
+}
+
+// overlapFraction returns the fraction of the shorter of the incidents that they were both ongoing for, from 0 to 1
+// Incidents that are instants, i.e. that ended when they started, overlap completely with the incidents they happened during
+func overlapFraction(a api.Incident, b api.Incident, now time.Time) float64 {
+	aEnd, bEnd := incidentEnd(a, now), incidentEnd(b, now)
+	start, end := a.StartTime, aEnd
+	if b.StartTime.After(start) {
+		start = b.StartTime
+	}
+	if bEnd.Before(end) {
+		end = bEnd
+	}
+	if end.Before(start) {
+		return 0
+	}
+	shorter := min(aEnd.Sub(a.StartTime), bEnd.Sub(b.StartTime))
+	if shorter <= 0 {
+		return 1
+	}
+	return float64(end.Sub(start)) / float64(shorter)
+}
+
+func incidentEnd(incident api.Incident, now time.Time) time.Time {
+	if incident.IsOngoing() {
+		return now
+	}
+	return *incident.EndTime
+}
+
+// sharedKeywords returns the sorted keywords that are in both sets and their jaccard similarity, from 0 to 1
+func sharedKeywords(a map[string]bool, b map[string]bool) ([]string, float64) {
+	shared := []string{}
+	for keyword := range a {
+		if b[keyword] {
+			shared = append(shared, keyword)
+		}
+	}
+	if len(shared) == 0 {
+		return shared, 0
+	}
+	sort.Strings(shared)
+	return shared, float64(len(shared)) / float64(len(a)+len(b)-len(shared))
+}
+
+// titleStopWords are the words that don't say what an incident is about, so they don't make titles similar
+var titleStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "from": true, "some": true, "are": true, "was": true, "were": true, "our": true,
+	"issue": true, "issues": true, "incident": true, "investigating": true, "resolved": true, "degraded": true, "elevated": true,
+	"increased": true, "partial": true, "users": true, "customers": true,
+}
+
+// titleKeywords returns the lower case words of the title that are at least 3 characters long and aren't stop words
+func titleKeywords(title string) map[string]bool {
+	keywords := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) >= 3 && !titleStopWords[word] {
+			keywords[word] = true
+		}
+	}
+	return keywords
+}
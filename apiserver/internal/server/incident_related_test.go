@@ -0,0 +1,152 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// getRelatedIncidents calls the related incidents handler for the incident with the deep link
+func getRelatedIncidents(s *Server, deepLink string, query string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents/"+url.PathEscape(deepLink)+"/related?"+query, nil)
+	testContext.Params = gin.Params{{Key: "id", Value: deepLink}}
+	s.relatedIncidents(testContext)
+	return recorder
+}
+
+func newTestIncidentBetween(deepLink string, title string, start time.Time, end time.Time) api.Incident {
+	incident := newTestIncident(deepLink, api.ImpactMajor, start)
+	incident.Title = title
+	incident.EndTime = &end
+	return incident
+}
+
+func TestRelatedIncidentsAreRankedByOverlapAndSimilarity(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	target := newTestIncidentBetween("target", "Elevated API error rates", start, start.Add(2*time.Hour))
+	incidents := []api.Incident{
+		target,
+		// Overlaps for the whole of the shorter incident and shares a keyword
+		newTestIncidentBetween("overlapping-similar", "API latency", start.Add(30*time.Minute), start.Add(time.Hour)),
+		// Overlaps for half of the target
+		newTestIncidentBetween("overlapping", "Dashboard unavailable", start.Add(time.Hour), start.Add(5*time.Hour)),
+		// Shares every keyword long before the target, elevated is a stop word
+		newTestIncidentBetween("similar", "API error rates", start.Add(-30*24*time.Hour), start.Add(-30*24*time.Hour+time.Hour)),
+		// Ends when the target starts and shares only stop words
+		newTestIncidentBetween("unrelated", "Elevated issues", start.Add(-time.Hour), start),
+	}
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: incidents})
+
+	recorder := getRelatedIncidents(s, target.DeepLink, "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response RelatedIncidentsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response.Incident.DeepLink != target.DeepLink {
+		t.Fatalf("expected the target incident, got %s", response.Incident.DeepLink)
+	}
+	expected := []struct {
+		deepLink string
+		score    float64
+		overlaps bool
+		shared   int
+	}{
+		{deepLink: incidents[1].DeepLink, score: 1 + 1.0/4, overlaps: true, shared: 1},
+		{deepLink: incidents[3].DeepLink, score: 1, overlaps: false, shared: 3},
+		{deepLink: incidents[2].DeepLink, score: 0.5, overlaps: true, shared: 0},
+	}
+	if len(response.Related) != len(expected) {
+		t.Fatalf("expected %d related incidents, got %+v", len(expected), response.Related)
+	}
+	for i, related := range response.Related {
+		if related.Incident.DeepLink != expected[i].deepLink || related.Score != expected[i].score || related.Overlaps != expected[i].overlaps || len(related.SharedKeywords) != expected[i].shared {
+			t.Fatalf("expected related incident %d to be %+v, got %+v", i, expected[i], related)
+		}
+	}
+
+	recorder = getRelatedIncidents(s, target.DeepLink, "limit=1")
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(response.Related) != 1 || response.Related[0].Incident.DeepLink != incidents[1].DeepLink {
+		t.Fatalf("expected only the most related incident, got %+v", response.Related)
+	}
+}
+
+func TestRelatedIncidentsOnlyComeFromTheSameStatusPage(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	target := newTestIncidentBetween("target", "API outage", start, start.Add(time.Hour))
+	otherPage := newTestIncidentBetween("other", "API outage", start, start.Add(time.Hour))
+	otherPage.StatusPageUrl = "https://status.other.com"
+	otherPage.DeepLink = "https://status.other.com/incidents/other"
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{target, otherPage}})
+
+	recorder := getRelatedIncidents(s, target.DeepLink, "")
+	var response RelatedIncidentsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if recorder.Code != http.StatusOK || len(response.Related) != 0 {
+		t.Fatalf("expected no related incidents, got %d %+v", recorder.Code, response.Related)
+	}
+}
+
+func TestRelatedIncidentsErrors(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	target := newTestIncident("target", api.ImpactMajor, start)
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{target}})
+
+	tests := []struct {
+		deepLink       string
+		query          string
+		expectedStatus int
+		expectedCode   ErrorCode
+	}{
+		{deepLink: testStatusPageUrl + "/incidents/unknown", expectedStatus: http.StatusNotFound, expectedCode: ErrorCodeIncidentNotKnown},
+		{deepLink: target.DeepLink, query: "limit=-1", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidLimit},
+		{deepLink: target.DeepLink, query: "limit=many", expectedStatus: http.StatusBadRequest, expectedCode: ErrorCodeInvalidLimit},
+	}
+	for _, test := range tests {
+		recorder := getRelatedIncidents(s, test.deepLink, test.query)
+		var response ErrorResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if recorder.Code != test.expectedStatus || response.Code != test.expectedCode {
+			t.Fatalf("%s?%s: expected %d %s, got %d %s", test.deepLink, test.query, test.expectedStatus, test.expectedCode, recorder.Code, response.Code)
+		}
+	}
+}
+
+func TestOverlapFraction(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	now := start.Add(10 * time.Hour)
+	incident := newTestIncidentBetween("a", "", start, start.Add(2*time.Hour))
+	tests := []struct {
+		name     string
+		other    api.Incident
+		expected float64
+	}{
+		{name: "within", other: newTestIncidentBetween("b", "", start.Add(time.Hour), start.Add(90*time.Minute)), expected: 1},
+		{name: "half", other: newTestIncidentBetween("b", "", start.Add(time.Hour), start.Add(3*time.Hour)), expected: 0.5},
+		{name: "touching", other: newTestIncidentBetween("b", "", start.Add(2*time.Hour), start.Add(3*time.Hour)), expected: 0},
+		{name: "before", other: newTestIncidentBetween("b", "", start.Add(-2*time.Hour), start.Add(-time.Hour)), expected: 0},
+		{name: "instant during", other: newTestIncidentBetween("b", "", start.Add(time.Hour), start.Add(time.Hour)), expected: 1},
+		{name: "ongoing since", other: newTestIncident("b", api.ImpactMajor, start.Add(time.Hour)), expected: 0.5},
+	}
+	for _, test := range tests {
+		if fraction := overlapFraction(incident, test.other, now); fraction != test.expected {
+			t.Fatalf("%s: expected an overlap of %v, got %v", test.name, test.expected, fraction)
+		}
+	}
+}
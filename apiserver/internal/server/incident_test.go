@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/apiserver/internal/cache"
+	"github.com/metoro-io/statusphere/apiserver/internal/config"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// getIncident calls the incident handler for the incident with the deep link
+func getIncident(s *Server, deepLink string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents/"+url.PathEscape(deepLink), nil)
+	testContext.Params = gin.Params{{Key: "id", Value: deepLink}}
+	s.incident(testContext)
+	return recorder
+}
+
+func TestIncidentIsFoundInTheCachedIncidentsOfItsStatusPage(t *testing.T) {
+	for name, redisClient := range map[string]redis.UniversalClient{
+		"memory": nil,
+		"redis":  redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			incident := newTestIncident("cached", api.ImpactMajor, time.Now().Add(-time.Hour))
+			dbClient := &fakeDbClient{incidents: []api.Incident{incident}}
+			s := NewServer(zap.NewNop(), dbClient, redisClient, config.Config{})
+			getIncidentResponse := func() IncidentResponse {
+				t.Helper()
+				recorder := getIncident(s, incident.DeepLink)
+				if recorder.Code != http.StatusOK {
+					t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+				}
+				var response IncidentResponse
+				if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+					t.Fatalf("failed to unmarshal response: %v", err)
+				}
+				return response
+			}
+
+			// The incidents of the status page aren't cached yet, so the incident is read from the database
+			if response := getIncidentResponse(); response.Incident.DeepLink != incident.DeepLink || response.StatusPageUrl != testStatusPageUrl {
+				t.Fatalf("expected the incident from the database, got %+v", response)
+			}
+			if statusPageUrl, found, _ := s.incidentStatusPageCache.Get(context.Background(), incident.DeepLink); !found || statusPageUrl != testStatusPageUrl {
+				t.Fatalf("expected the status page of the incident to be cached by its id, got %q", statusPageUrl)
+			}
+
+			// Once the incidents of its status page are cached the incident is found in them rather than the database
+			dbClient.incidents = nil
+			updated := incident
+			updated.Title = "updated"
+			if err := s.incidentCache.Set(context.Background(), testStatusPageUrl, []api.Incident{updated}, cache.DefaultExpiration); err != nil {
+				t.Fatalf("failed to set incidents in cache: %v", err)
+			}
+			if response := getIncidentResponse(); response.Incident.Title != "updated" {
+				t.Fatalf("expected the incident from the cached incidents of its status page, got %+v", response)
+			}
+
+			if recorder := getIncident(s, testStatusPageUrl+"/incidents/unknown"); recorder.Code != http.StatusNotFound {
+				t.Fatalf("expected status 404 for an unknown incident, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
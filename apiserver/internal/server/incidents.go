@@ -3,153 +3,863 @@ package server
 import (
 	"context"
 	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/apiserver/internal/config"
 	"github.com/metoro-io/statusphere/common/api"
-	"github.com/patrickmn/go-cache"
+	"github.com/metoro-io/statusphere/common/db"
+	"github.com/metoro-io/statusphere/common/utils"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"net/http"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type IncidentsResponse struct {
 	Incidents []api.Incident `json:"incidents"`
 	IsIndexed bool           `json:"isIndexed"`
+	// NextCursor is the cursor to pass to get the next page of incidents, it is empty if there are no more incidents
+	NextCursor string `json:"nextCursor"`
+	// OngoingCount is the number of ongoing incidents matching the filters, before the limit is applied
+	OngoingCount int `json:"ongoingCount"`
+	// TotalCount is the number of incidents matching the filters, before the cursor and limit are applied
+	TotalCount int `json:"totalCount"`
+	// MatchCount is the number of times the q query parameter appears in the titles and descriptions of the incidents matching the filters, it is only set if q is given
+	MatchCount int `json:"matchCount,omitempty"`
+	// Pagination is how the incidents were paged, the nextCursor and totalCount are also at the top level for the clients that read them from there
+	Pagination Pagination `json:"pagination"`
+}
+
+// Pagination is the paging contract of a list of incidents, so that clients can page through them without reading the headers
+type Pagination struct {
+	// NextCursor is the cursor to pass to get the next page of incidents, it is empty if there are no more incidents
+	NextCursor string `json:"nextCursor"`
+	// TotalCount is the number of incidents matching the filters, before the cursor and limit are applied
+	TotalCount int `json:"totalCount"`
+	// Limit is the most incidents that the page could have, after it was clamped to the max limit, it is omitted if the incidents weren't limited
+	Limit *int `json:"limit,omitempty"`
+}
+
+// paginated returns the response with its pagination set from its next cursor and total count, along with the limit of the page
+func (r IncidentsResponse) paginated(limit *int) IncidentsResponse {
+	r.Pagination = Pagination{NextCursor: r.NextCursor, TotalCount: r.TotalCount, Limit: limit}
+	return r
 }
 
 // incidents is a handler for the /incidents endpoint.
 // It has a required query parameter of statusPageUrl
 // It has an optional query parameter of impact (default is all), which is an array of impacts e.g. impact=critical,major,minor,none to exclude maintenance
+// It has an optional query parameter of excludeImpact, which is an array of impacts to exclude e.g. excludeImpact=maintenance, it can't be used with impact
+// It has an optional query parameter of type, which is either maintenance (only scheduled maintenance) or incident (everything but scheduled maintenance),
+// it can't be used with impact or excludeImpact
+// It has an optional query parameter of order (default is desc), which is either asc (oldest first) or desc (most recent first)
+// It has an optional query parameter of limit (default is the configured default limit), which is the maximum number of incidents to return,
+// taken from the start of the ordered incidents, limits above the configured max limit are clamped to it
+// It has an optional query parameter of cursor, which is the nextCursor returned by a previous request, used to get the next page of incidents
+// It has optional query parameters of from and to (RFC3339), which filter the incidents by their start time, either can be omitted to leave that side open-ended
+// It has an optional query parameter of includeOngoing (default is false), which also includes incidents that started before from but have not ended yet
+// It has an optional query parameter of at (RFC3339), which only returns the incidents that were active at that instant, i.e. that started at or before it and ended after it
+// or are ongoing, it can't be used together with from, to or includeOngoing
+// It has an optional query parameter of ongoing (default is false), which only returns ongoing incidents, i.e. incidents that have no end time yet
+// It has an optional query parameter of q, which only returns incidents whose title or description contains q case insensitively, the number of times q appears in them is returned as matchCount
+// It has an optional query parameter of component, which only returns incidents that affected the component
+// It has an optional query parameter of componentMatch (default is exact), which is either exact or substring, how the component is compared case insensitively
+// It has an optional query parameter of tag, which only returns incidents that the scraper tagged with it, compared case insensitively
+// It has an optional query parameter of includeUpdates (default is true), the updates posted to each incident, which can be set to false to keep the response small,
+// includeEvents is an alias of it, includeUpdates is used if both are given
+// It has an optional query parameter of includeImpactHistory (default is false), which also returns the impacts that each incident had and when they changed
+// It has an optional query parameter of fields (default is all), which is an array of the fields of the incidents to return e.g. fields=deepLink,impact,startTime,
+// unknown fields return a 400
+// It has an optional query parameter of tz (default is UTC), an IANA time zone name e.g. America/New_York, which the returned timestamps are converted to
+// It has an optional query parameter of includeDeleted (default is false), which also returns the incidents that have been removed from the status page,
+// it needs the admin api key and always reads the incidents from the database
+// It has an optional query parameter of wait, a duration e.g. 30s that is clamped to the configured max wait, for long polling: if the response would be a 304
+// then the request waits up to wait for the scraper to change the incidents of the status page, and only returns the 304 if they didn't change
+// The total number of incidents matching the filters is returned in the X-Total-Count header as well as the totalCount field
+// The pagination object has the nextCursor, totalCount and the limit that was applied, which is the default limit if no limit is given
+// The response has an ETag, if it matches the If-None-Match header of the request then a 304 is returned without a body
 func (s *Server) incidents(context *gin.Context) {
-	ctx := context.Request.Context()
-	statusPageUrl := context.Query("statusPageUrl")
-	if statusPageUrl == "" {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "statusPageUrl is required"})
+	wait, ok := s.parseWaitQuery(context)
+	if !ok {
 		return
 	}
+	if wait == 0 {
+		s.writeIncidents(context)
+		return
+	}
+	statusPageUrl, ok := parseStatusPageUrlQuery(context)
+	if !ok {
+		return
+	}
+	s.longPoll(context, statusPageUrl, wait, s.writeIncidents)
+}
 
-	impactQuery := context.Query("impact")
-	var impacts []api.Impact
-	if impactQuery != "" {
-		impactsStr := strings.Split(impactQuery, ",")
-		for _, impactStr := range impactsStr {
-			impact, err := api.ParseImpact(impactStr)
-			if err != nil {
-				context.JSON(http.StatusBadRequest, gin.H{"error": "invalid impact"})
-				return
-			}
-			impacts = append(impacts, impact)
+// writeIncidents writes the response of the incidents endpoint without waiting for the incidents to change, see incidents
+func (s *Server) writeIncidents(context *gin.Context) {
+	statusPageUrl, ok := parseStatusPageUrlQuery(context)
+	if !ok {
+		return
+	}
+
+	impacts, ok := parseImpactsQuery(context)
+	if !ok {
+		return
+	}
+
+	limit, ok := parseLimitQuery(context)
+	if !ok {
+		return
+	}
+	if limit != nil && *limit < 0 {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidLimit, "limit must be a non-negative integer")
+		return
+	}
+	// Limits above the max are clamped rather than rejected, the response has a nextCursor to page through the rest
+	pageLimit := s.config.Incidents.DefaultLimit
+	if limit != nil {
+		pageLimit = min(*limit, s.config.Incidents.MaxLimit)
+	}
+
+	ongoingOnly := false
+	if ongoingStr := context.Query("ongoing"); ongoingStr != "" {
+		ongoing, err := strconv.ParseBool(ongoingStr)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidBoolean, "ongoing must be a boolean")
+			return
 		}
+		ongoingOnly = ongoing
 	}
 
-	var limit *int = nil
-	if limitStr := context.Query("limit"); limitStr != "" {
-		limitInt, err := strconv.Atoi(limitStr)
+	order := sortOrderDescending
+	if orderStr := context.Query("order"); orderStr != "" {
+		parsedOrder, err := parseSortOrder(orderStr)
 		if err != nil {
-			context.JSON(http.StatusBadRequest, gin.H{"error": "limit must be an integer"})
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidOrder, "order must be asc or desc")
 			return
 		}
-		limit = &limitInt
+		order = parsedOrder
 	}
 
-	// Check to see that the status page is known to statusphere and is indexed
-	statusPage, found := s.statusPageCache.Get(statusPageUrl)
-	if !found {
-		context.JSON(http.StatusNotFound, gin.H{"error": "status page not known to statusphere"})
+	var cursor *incidentsCursor = nil
+	if cursorStr := context.Query("cursor"); cursorStr != "" {
+		decodedCursor, err := decodeIncidentsCursor(cursorStr)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidCursor, "invalid cursor")
+			return
+		}
+		cursor = decodedCursor
+	}
+
+	timeRange, ok := parseTimeRangeQuery(context)
+	if !ok {
 		return
 	}
 
-	statusPageCasted, ok := statusPage.(api.StatusPage)
+	at, ok := parseAtQuery(context, timeRange)
 	if !ok {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cast status page"})
 		return
 	}
 
-	if !statusPageCasted.IsIndexed {
-		context.JSON(http.StatusOK, IncidentsResponse{Incidents: []api.Incident{}, IsIndexed: false})
+	component := context.Query("component")
+	match := componentMatchExact
+	if matchStr := context.Query("componentMatch"); matchStr != "" {
+		parsedMatch, err := parseComponentMatch(matchStr)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidComponentMatch, "componentMatch must be exact or substring")
+			return
+		}
+		match = parsedMatch
+	}
+
+	tag := strings.TrimSpace(context.Query("tag"))
+
+	query := context.Query("q")
+
+	includeEvents := true
+	includeEventsParameter := "includeUpdates"
+	includeEventsStr := context.Query(includeEventsParameter)
+	if includeEventsStr == "" {
+		includeEventsParameter = "includeEvents"
+		includeEventsStr = context.Query(includeEventsParameter)
+	}
+	if includeEventsStr != "" {
+		include, err := strconv.ParseBool(includeEventsStr)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidBoolean, includeEventsParameter+" must be a boolean")
+			return
+		}
+		includeEvents = include
+	}
+
+	includeImpactHistory := false
+	if includeImpactHistoryStr := context.Query("includeImpactHistory"); includeImpactHistoryStr != "" {
+		include, err := strconv.ParseBool(includeImpactHistoryStr)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidBoolean, "includeImpactHistory must be a boolean")
+			return
+		}
+		includeImpactHistory = include
+	}
+
+	location, ok := parseTimeZoneQuery(context)
+	if !ok {
 		return
 	}
 
-	// Attempt to get the incidents from the cache
-	incidents, found, err := s.getIncidentsFromCache(ctx, statusPageUrl, impacts)
-	if err != nil {
-		s.logger.Error("failed to get incidents from cache", zap.Error(err))
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get incidents from cache"})
+	fields, ok := parseFieldsQuery(context)
+	if !ok {
 		return
 	}
-	if found {
-		sortIncidentsDescending(incidents)
-		if limit != nil && len(incidents) > *limit {
-			incidents = incidents[:*limit]
+
+	includeDeleted := false
+	if includeDeletedStr := context.Query("includeDeleted"); includeDeletedStr != "" {
+		include, err := strconv.ParseBool(includeDeletedStr)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidBoolean, "includeDeleted must be a boolean")
+			return
 		}
-		context.JSON(http.StatusOK, IncidentsResponse{Incidents: incidents, IsIndexed: true})
+		includeDeleted = include
+	}
+	if includeDeleted && !s.authorize(context, config.ApiKeyScopeAdmin) {
 		return
 	}
 
-	// Attempt to get the incidents from the database
-	incidents, found, err = s.getIncidentsFromDatabase(ctx, statusPageUrl)
-	if err != nil {
-		s.logger.Error("failed to get incidents from database", zap.Error(err))
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get incidents from database"})
+	var statusPage api.StatusPage
+	var incidents []api.Incident
+	var page *incidentsPage
+	if includeDeleted {
+		statusPage, incidents, ok = s.getStatusPageIncidentsIncludingDeleted(context, statusPageUrl, impacts)
+		if query != "" {
+			incidents = filterIncidentsByQuery(incidents, query)
+		}
+	} else if query != "" {
+		statusPage, incidents, ok = s.searchStatusPageIncidents(context, statusPageUrl, impacts, query)
+	} else {
+		// A single page of incidents can be read from the database while the cache is cold if the database can do all the filtering
+		var pageQuery *db.IncidentsQuery = nil
+		var streamQuery *db.IncidentsQuery = nil
+		if cursor == nil && component == "" && tag == "" && !ongoingOnly && !timeRange.includeOngoing && at == nil {
+			databaseQuery := &db.IncidentsQuery{
+				StatusPageUrl: statusPageUrl,
+				Impacts:       impacts.includedImpacts(),
+				From:          timeRange.from,
+				To:            timeRange.to,
+				Ascending:     order == sortOrderAscending,
+			}
+			// Requests without a limit still read every incident so that they can be cached, unless there are too many of them,
+			// in which case every incident is streamed rather than a page of them as they're never all in memory
+			if limit != nil {
+				databaseQuery.Limit = &pageLimit
+				pageQuery = databaseQuery
+			} else {
+				streamQuery = databaseQuery
+			}
+		}
+		statusPage, incidents, page, ok = s.getStatusPageIncidentsOrPage(context, statusPageUrl, impacts, pageQuery, streamQuery)
+	}
+	if !ok {
 		return
 	}
-	if !found {
-		context.JSON(http.StatusNotFound, gin.H{"error": "status page not known to statusphere"})
+	if !statusPage.IsIndexed {
+		context.Header("X-Total-Count", "0")
+		writeJSONWithETag(context, IncidentsResponse{Incidents: []api.Incident{}, IsIndexed: false}.paginated(&pageLimit))
+		return
+	}
+	if page != nil && page.streamQuery != nil {
+		s.writeStreamedIncidents(context, *page, includeEvents, includeImpactHistory, location, fields)
+		return
+	}
+	if page != nil {
+		// The page doesn't have every matching incident, so the newest of them isn't known and there is no Last-Modified
+		s.writeIncidentsResponse(context, page.incidents, IncidentsResponse{IsIndexed: true, NextCursor: page.nextCursor, OngoingCount: page.ongoingCount, TotalCount: page.totalCount}.paginated(&pageLimit), includeEvents, includeImpactHistory, location, fields, time.Time{})
+		return
+	}
+
+	incidents = sortIncidents(incidents, order)
+	incidents = filterIncidentsByTimeRange(incidents, timeRange)
+	if at != nil {
+		incidents = filterIncidentsActiveAt(incidents, *at)
+	}
+	if ongoingOnly {
+		incidents = filterOngoingIncidents(incidents)
+	}
+	if component != "" {
+		incidents = filterIncidentsByComponent(incidents, component, match)
+	}
+	if tag != "" {
+		incidents = filterIncidentsByTag(incidents, tag)
+	}
+	ongoingCount := countOngoingIncidents(incidents)
+	totalCount := len(incidents)
+	var matchCount int
+	if query != "" {
+		matchCount = countQueryMatches(incidents, query)
+	}
+	lastModified := incidentsLastModified(incidents, time.Now(), s.config.Incidents)
+	incidents, nextCursor := paginateIncidents(incidents, order, cursor, &pageLimit)
+	s.writeIncidentsResponse(context, incidents, IncidentsResponse{IsIndexed: true, NextCursor: nextCursor, OngoingCount: ongoingCount, TotalCount: totalCount, MatchCount: matchCount}.paginated(&pageLimit), includeEvents, includeImpactHistory, location, fields, lastModified)
+}
+
+// writeIncidentsResponse writes the response with the page of incidents, see presentIncidents
+// If fields isn't nil then the incidents only have those fields, see projectIncidents
+// The response has a Last-Modified header of lastModified unless it is zero, see writeJSONWithValidators
+func (s *Server) writeIncidentsResponse(context *gin.Context, incidents []api.Incident, response IncidentsResponse, includeEvents bool, includeImpactHistory bool, location *time.Location, fields []string, lastModified time.Time) {
+	incidents = s.presentIncidents(incidents, includeEvents, includeImpactHistory, location)
+	if incidents == nil {
+		// Always return an array so that clients don't have to handle null
+		incidents = []api.Incident{}
+	}
+	context.Header("X-Total-Count", strconv.Itoa(response.TotalCount))
+	if fields != nil {
+		projected, err := projectIncidents(incidents, fields)
+		if err != nil {
+			writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to select the fields of the incidents")
+			return
+		}
+		writeJSONWithValidators(context, projectedIncidentsResponse{IncidentsResponse: response, Incidents: projected}, lastModified)
 		return
 	}
+	response.Incidents = incidents
+	writeJSONWithValidators(context, response, lastModified)
+}
 
-	sortIncidentsDescending(incidents)
-	s.incidentCache.Set(statusPageUrl, incidents, cache.DefaultExpiration)
-	if limit != nil && len(incidents) > *limit {
-		incidents = incidents[:*limit]
+// presentIncidents returns copies of the incidents flagged if they're stale or breached their sla, without their events if includeEvents is false,
+// without their impact history if includeImpactHistory is false and with their timestamps in the location if it isn't nil
+func (s *Server) presentIncidents(incidents []api.Incident, includeEvents bool, includeImpactHistory bool, location *time.Location) []api.Incident {
+	incidents = s.flagIncidents(incidents)
+	if !includeEvents {
+		incidents = withoutEvents(incidents)
 	}
-	context.JSON(http.StatusOK, IncidentsResponse{Incidents: incidents, IsIndexed: true})
+	if !includeImpactHistory {
+		incidents = withoutImpactHistory(incidents)
+	}
+	if location != nil {
+		incidents = inTimeZone(incidents, location)
+	}
+	return incidents
 }
 
-func sortIncidentsDescending(incidents []api.Incident) {
-	sort.Slice(incidents, func(i, j int) bool {
-		return incidents[i].StartTime.After(incidents[j].StartTime)
+// parseStatusPageUrlQuery parses the required statusPageUrl query parameter into its canonical form, see api.CanonicalStatusPageUrl
+// If it is missing or isn't an http(s) url, it writes a 400 response and returns false
+func parseStatusPageUrlQuery(context *gin.Context) (string, bool) {
+	statusPageUrl := context.Query("statusPageUrl")
+	if statusPageUrl == "" {
+		writeError(context, http.StatusBadRequest, ErrorCodeStatusPageUrlRequired, "statusPageUrl is required")
+		return "", false
+	}
+	canonicalUrl, err := api.CanonicalStatusPageUrl(statusPageUrl)
+	if err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidStatusPageUrl, "statusPageUrl is invalid, "+err.Error())
+		return "", false
+	}
+	return canonicalUrl, true
+}
+
+// parseLimitQuery parses the optional limit query parameter
+// If the limit is invalid, it writes a 400 response and returns false
+func parseLimitQuery(context *gin.Context) (*int, bool) {
+	limitStr := context.Query("limit")
+	if limitStr == "" {
+		return nil, true
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidLimit, "limit must be an integer")
+		return nil, false
+	}
+	return &limit, true
+}
+
+// parseTimeZoneQuery parses the optional tz query parameter, an IANA time zone name
+// It returns nil if tz isn't given, so that timestamps are left in UTC
+// If the time zone is unknown, it writes a 400 response and returns false
+func parseTimeZoneQuery(context *gin.Context) (*time.Location, bool) {
+	tz := context.Query("tz")
+	if tz == "" {
+		return nil, true
+	}
+	// Local is the time zone of the server, which clients can't know, so it isn't accepted
+	location, err := time.LoadLocation(tz)
+	if err != nil || tz == "Local" {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidTimeZone, "tz must be a known IANA time zone")
+		return nil, false
+	}
+	return location, true
+}
+
+// parseTimeRangeQuery parses the optional from, to and includeOngoing query parameters
+// If the time range is invalid, it writes a 400 response and returns false
+func parseTimeRangeQuery(context *gin.Context) (incidentTimeRange, bool) {
+	var timeRange incidentTimeRange
+	if fromStr := context.Query("from"); fromStr != "" {
+		from, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidTimeRange, "from must be an RFC3339 timestamp")
+			return incidentTimeRange{}, false
+		}
+		timeRange.from = &from
+	}
+	if toStr := context.Query("to"); toStr != "" {
+		to, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidTimeRange, "to must be an RFC3339 timestamp")
+			return incidentTimeRange{}, false
+		}
+		timeRange.to = &to
+	}
+	if timeRange.from != nil && timeRange.to != nil && timeRange.from.After(*timeRange.to) {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidTimeRange, "from must not be after to")
+		return incidentTimeRange{}, false
+	}
+	if includeOngoingStr := context.Query("includeOngoing"); includeOngoingStr != "" {
+		includeOngoing, err := strconv.ParseBool(includeOngoingStr)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidBoolean, "includeOngoing must be a boolean")
+			return incidentTimeRange{}, false
+		}
+		timeRange.includeOngoing = includeOngoing
+	}
+	return timeRange, true
+}
+
+// parseAtQuery parses the optional at query parameter, an instant that the incidents have to be active at
+// at selects the incidents by their whole duration rather than their start time, so it can't be used together with the time range
+// If at is invalid or the time range is also given, it writes a 400 response and returns false
+func parseAtQuery(context *gin.Context, timeRange incidentTimeRange) (*time.Time, bool) {
+	atStr := context.Query("at")
+	if atStr == "" {
+		return nil, true
+	}
+	at, err := time.Parse(time.RFC3339, atStr)
+	if err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidTimeRange, "at must be an RFC3339 timestamp")
+		return nil, false
+	}
+	if !timeRange.isEmpty() || timeRange.includeOngoing {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidTimeRange, "at can't be used together with from, to or includeOngoing")
+		return nil, false
+	}
+	return &at, true
+}
+
+// incidentType is the value of the type query parameter, a shorthand for the impacts of maintenance or of unplanned incidents
+type incidentType string
+
+const (
+	// incidentTypeMaintenance only includes scheduled maintenance, i.e. impact=maintenance
+	incidentTypeMaintenance incidentType = "maintenance"
+	// incidentTypeIncident excludes scheduled maintenance, i.e. excludeImpact=maintenance
+	incidentTypeIncident incidentType = "incident"
+)
+
+// parseImpactsQuery parses the optional impact and excludeImpact query parameters, comma separated lists of impacts,
+// and the optional type query parameter, which is either maintenance or incident
+// type is translated to the impacts of that type of incident, so it can't be given with impact or excludeImpact
+// If the impacts or type are invalid or more than one of the parameters are given, it writes a 400 response and returns false
+func parseImpactsQuery(context *gin.Context) (impactFilter, bool) {
+	impactQuery := context.Query("impact")
+	excludeImpactQuery := context.Query("excludeImpact")
+	if impactQuery != "" && excludeImpactQuery != "" {
+		writeError(context, http.StatusBadRequest, ErrorCodeConflictingImpactFilters, "impact and excludeImpact can't be used together")
+		return impactFilter{}, false
+	}
+	if typeQuery := context.Query("type"); typeQuery != "" {
+		if impactQuery != "" || excludeImpactQuery != "" {
+			writeError(context, http.StatusBadRequest, ErrorCodeConflictingImpactFilters, "type can't be used together with impact or excludeImpact")
+			return impactFilter{}, false
+		}
+		switch incidentType(strings.ToLower(typeQuery)) {
+		case incidentTypeMaintenance:
+			return includeImpacts(api.ImpactMaintenance), true
+		case incidentTypeIncident:
+			return excludeImpacts(api.ImpactMaintenance), true
+		default:
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidType, "type must be either maintenance or incident")
+			return impactFilter{}, false
+		}
+	}
+	if excludeImpactQuery != "" {
+		impacts, err := parseImpactList(excludeImpactQuery)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidImpact, "invalid excludeImpact")
+			return impactFilter{}, false
+		}
+		return excludeImpacts(impacts...), true
+	}
+	impacts, err := parseImpactList(impactQuery)
+	if err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidImpact, "invalid impact")
+		return impactFilter{}, false
+	}
+	return includeImpacts(impacts...), true
+}
+
+// parseImpactList parses a comma separated list of impacts
+// Whitespace around each impact is ignored, and so are empty impacts, e.g. the trailing comma of major,minor,
+func parseImpactList(impactList string) ([]api.Impact, error) {
+	var impacts []api.Impact
+	for _, impactStr := range strings.Split(impactList, ",") {
+		impactStr = strings.TrimSpace(impactStr)
+		if impactStr == "" {
+			continue
+		}
+		impact, err := api.ParseImpact(impactStr)
+		if err != nil {
+			return nil, err
+		}
+		impacts = append(impacts, impact)
+	}
+	return impacts, nil
+}
+
+// incidentsPage is a page of the incidents of a status page that was read from the database, see queryIncidentsPage
+type incidentsPage struct {
+	incidents  []api.Incident
+	nextCursor string
+	// totalCount and ongoingCount are the counts of all the incidents matching the query, not just the ones in the page
+	totalCount   int
+	ongoingCount int
+	// streamQuery is the query of the incidents if there are too many to read into memory, in which case they're streamed
+	// from the database by writeStreamedIncidents rather than being in incidents
+	streamQuery *db.IncidentsQuery
+}
+
+// getStatusPageIncidentsOrPage is getStatusPageIncidents, except that if the incidents aren't cached and there is a page query
+// then only the page is read from the database and returned, so that the request doesn't wait for all the incidents to be read.
+// All the incidents are then read and cached in the background for the requests that follow.
+// If the incidents aren't cached and there is a stream query, a status page with more incidents than the stream threshold
+// returns a page to stream instead, see streamedIncidentsPage
+// If the incidents can't be returned, it writes an error response and returns false
+func (s *Server) getStatusPageIncidentsOrPage(context *gin.Context, statusPageUrl string, impacts impactFilter, pageQuery *db.IncidentsQuery, streamQuery *db.IncidentsQuery) (api.StatusPage, []api.Incident, *incidentsPage, bool) {
+	ctx := context.Request.Context()
+	statusPage, incidents, found, lookupErr := s.lookupCachedStatusPageIncidents(ctx, statusPageUrl, impacts)
+	if lookupErr != nil {
+		lookupErr.write(context)
+		return api.StatusPage{}, nil, nil, false
+	}
+	if found {
+		return statusPage, incidents, nil, true
+	}
+
+	if pageQuery != nil {
+		page, err := s.queryIncidentsPage(ctx, *pageQuery)
+		if err == nil {
+			go func() {
+				// The fetch outlives the request, getIncidentsFromDatabaseAndCache doesn't cancel it when the request completes
+				if _, _, err := s.getIncidentsFromDatabaseAndCache(ctx, statusPageUrl); err != nil {
+					utils.GetLogger(ctx, s.logger).Error("failed to get incidents from database", zap.Error(err))
+				}
+			}()
+			return statusPage, nil, &page, true
+		}
+		utils.GetLogger(ctx, s.logger).Warn("failed to query a page of incidents, falling back to all the incidents", zap.Error(err))
+	}
+	if streamQuery != nil {
+		if page, stream := s.streamedIncidentsPage(ctx, *streamQuery); stream {
+			return statusPage, nil, page, true
+		}
+	}
+
+	incidents, lookupErr = s.lookupDatabaseStatusPageIncidents(ctx, statusPageUrl, impacts)
+	if lookupErr != nil {
+		lookupErr.write(context)
+		return api.StatusPage{}, nil, nil, false
+	}
+	return statusPage, incidents, nil, true
+}
+
+// queryIncidentsPage reads the page of incidents matching the query from the database, along with the counts of all the incidents matching it
+func (s *Server) queryIncidentsPage(ctx context.Context, query db.IncidentsQuery) (page incidentsPage, err error) {
+	ctx, span := s.tracer.Start(ctx, "queryIncidentsPage", trace.WithAttributes(attribute.String("statusPageUrl", query.StatusPageUrl)))
+	defer func() { endSpan(span, err) }()
+
+	incidents, err := s.dbClient.QueryIncidents(ctx, query)
+	if err != nil {
+		return incidentsPage{}, err
+	}
+	counts, err := s.dbClient.CountIncidents(ctx, query)
+	if err != nil {
+		return incidentsPage{}, err
+	}
+	span.SetAttributes(attribute.Int("incident.count", len(incidents)))
+
+	page = incidentsPage{incidents: incidents, totalCount: counts.Total, ongoingCount: counts.Ongoing}
+	if len(incidents) > 0 && counts.Total > query.Offset+len(incidents) {
+		page.nextCursor = encodeIncidentsCursor(incidents[len(incidents)-1])
+	}
+	return page, nil
+}
+
+// getStatusPageIncidents gets the incidents for the status page filtered by the impacts, see lookupStatusPageIncidents
+// If the incidents can't be returned, it writes an error response and returns false
+func (s *Server) getStatusPageIncidents(context *gin.Context, statusPageUrl string, impacts impactFilter) (api.StatusPage, []api.Incident, bool) {
+	statusPage, incidents, err := s.lookupStatusPageIncidents(context.Request.Context(), statusPageUrl, impacts)
+	if err != nil {
+		err.write(context)
+		return api.StatusPage{}, nil, false
+	}
+	return statusPage, incidents, true
+}
+
+// getStatusPageIncidentsIncludingDeleted gets the incidents for the status page filtered by the impacts, including the ones that have been deleted
+// The deleted incidents aren't cached, so the incidents are always read from the database
+// If the incidents can't be returned, it writes an error response and returns false
+func (s *Server) getStatusPageIncidentsIncludingDeleted(context *gin.Context, statusPageUrl string, impacts impactFilter) (api.StatusPage, []api.Incident, bool) {
+	ctx := context.Request.Context()
+	statusPage, lookupErr := s.lookupStatusPage(ctx, statusPageUrl)
+	if lookupErr != nil {
+		lookupErr.write(context)
+		return api.StatusPage{}, nil, false
+	}
+	if !statusPage.IsIndexed {
+		return statusPage, nil, true
+	}
+
+	incidents, err := s.dbClient.GetIncidentsIncludingDeleted(ctx, statusPageUrl)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to get incidents from database", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get incidents from database")
+		return api.StatusPage{}, nil, false
+	}
+	return statusPage, filterIncidentsByImpacts(incidents, impacts), true
+}
+
+// searchStatusPageIncidents gets the incidents for the status page filtered by the impacts whose title or description contains the query case insensitively, sorted by sortOrderDescending
+// If the incidents of the status page are cached then they are searched, otherwise the search is done by the database
+// If the incidents can't be returned, it writes an error response and returns false
+func (s *Server) searchStatusPageIncidents(context *gin.Context, statusPageUrl string, impacts impactFilter, query string) (api.StatusPage, []api.Incident, bool) {
+	ctx := context.Request.Context()
+	statusPage, lookupErr := s.lookupStatusPage(ctx, statusPageUrl)
+	if lookupErr != nil {
+		lookupErr.write(context)
+		return api.StatusPage{}, nil, false
+	}
+	if !statusPage.IsIndexed {
+		return statusPage, nil, true
+	}
+
+	incidents, found, err := s.getIncidentsFromCache(ctx, statusPageUrl, impacts)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Warn("failed to get incidents from cache, falling back to the database", zap.Error(err))
+	}
+	if found {
+		return statusPage, filterIncidentsByQuery(incidents, query), true
+	}
+
+	// Only the matching incidents are fetched, so they aren't cached
+	incidents, err = s.dbClient.SearchIncidents(ctx, statusPageUrl, query)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to search incidents in database", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to search incidents in database")
+		return api.StatusPage{}, nil, false
+	}
+	incidents = sortIncidents(filterIncidentsByImpacts(incidents, impacts), sortOrderDescending)
+	return statusPage, incidents, true
+}
+
+// lookupStatusPage gets the status page from the status page cache
+func (s *Server) lookupStatusPage(ctx context.Context, statusPageUrl string) (api.StatusPage, *incidentsLookupError) {
+	_, span := s.tracer.Start(ctx, "lookupStatusPage", trace.WithAttributes(attribute.String("statusPageUrl", statusPageUrl)))
+	defer span.End()
+
+	// Check to see that the status page is known to statusphere
+	statusPage, found, err := s.statusPageCache.Get(ctx, statusPageUrl)
+	recordCacheLookup("status_pages", found, err)
+	span.SetAttributes(attribute.Bool("cache.hit", found))
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to get status page from cache", zap.Error(err))
+		return api.StatusPage{}, &incidentsLookupError{status: http.StatusInternalServerError, code: ErrorCodeInternal, message: "failed to get status page from cache"}
+	}
+	if !found {
+		return api.StatusPage{}, &incidentsLookupError{status: http.StatusNotFound, code: ErrorCodeStatusPageNotKnown, message: "status page not known to statusphere"}
+	}
+	return statusPage, nil
+}
+
+// incidentsLookupError is the reason that the incidents of a status page couldn't be looked up
+type incidentsLookupError struct {
+	status  int
+	code    ErrorCode
+	message string
+}
+
+func (e *incidentsLookupError) write(context *gin.Context) {
+	writeError(context, e.status, e.code, e.message)
+}
+
+// lookupStatusPageIncidents gets the incidents for the status page filtered by the impacts, sorted by sortOrderDescending
+// The incidents are shared with the cache so must not be modified
+// If the status page is not indexed, it returns the status page and no incidents
+func (s *Server) lookupStatusPageIncidents(ctx context.Context, statusPageUrl string, impacts impactFilter) (api.StatusPage, []api.Incident, *incidentsLookupError) {
+	statusPage, incidents, found, lookupErr := s.lookupCachedStatusPageIncidents(ctx, statusPageUrl, impacts)
+	if lookupErr != nil || found {
+		return statusPage, incidents, lookupErr
+	}
+
+	incidents, lookupErr = s.lookupDatabaseStatusPageIncidents(ctx, statusPageUrl, impacts)
+	if lookupErr != nil {
+		return api.StatusPage{}, nil, lookupErr
+	}
+	return statusPage, incidents, nil
+}
+
+// lookupCachedStatusPageIncidents is the part of lookupStatusPageIncidents that doesn't read from the database
+// If the status page is indexed but its incidents aren't cached then it returns false for the third return value
+func (s *Server) lookupCachedStatusPageIncidents(ctx context.Context, statusPageUrl string, impacts impactFilter) (api.StatusPage, []api.Incident, bool, *incidentsLookupError) {
+	statusPageCasted, lookupErr := s.lookupStatusPage(ctx, statusPageUrl)
+	if lookupErr != nil {
+		return api.StatusPage{}, nil, false, lookupErr
+	}
+
+	if !statusPageCasted.IsIndexed {
+		return statusPageCasted, nil, true, nil
+	}
+
+	// Attempt to get the incidents from the cache
+	// If the cache is unavailable then we fall back to the database
+	incidents, found, err := s.getIncidentsFromCache(ctx, statusPageUrl, impacts)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Warn("failed to get incidents from cache, falling back to the database", zap.Error(err))
+	}
+	return statusPageCasted, incidents, found, nil
+}
+
+// lookupDatabaseStatusPageIncidents is the part of lookupStatusPageIncidents that reads the incidents from the database and caches them
+func (s *Server) lookupDatabaseStatusPageIncidents(ctx context.Context, statusPageUrl string, impacts impactFilter) ([]api.Incident, *incidentsLookupError) {
+	incidents, found, err := s.getIncidentsFromDatabaseAndCache(ctx, statusPageUrl)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to get incidents from database", zap.Error(err))
+		return nil, &incidentsLookupError{status: http.StatusInternalServerError, code: ErrorCodeInternal, message: "failed to get incidents from database"}
+	}
+	if !found {
+		return nil, &incidentsLookupError{status: http.StatusNotFound, code: ErrorCodeStatusPageNotKnown, message: "status page not known to statusphere"}
+	}
+	// All the incidents are fetched so that they can be cached, so they have to be filtered here as they are in getIncidentsFromCache
+	incidents = filterIncidentsByImpacts(incidents, impacts)
+	s.setFilteredIncidentsInCache(ctx, statusPageUrl, impacts, incidents)
+	return incidents, nil
+}
+
+type sortOrder string
+
+const (
+	sortOrderAscending  sortOrder = "asc"
+	sortOrderDescending sortOrder = "desc"
+)
+
+func parseSortOrder(order string) (sortOrder, error) {
+	switch order {
+	case "asc":
+		return sortOrderAscending, nil
+	case "desc":
+		return sortOrderDescending, nil
+	default:
+		return "", errors.New("invalid sort order")
+	}
+}
+
+// sortIncidents returns a copy of the incidents sorted by start time in the given order
+// The incidents passed in are not modified as they may be shared with the cache
+// Incidents with the same start time are ordered by deep link so that the order is stable between requests
+func sortIncidents(incidents []api.Incident, order sortOrder) []api.Incident {
+	sortedIncidents := make([]api.Incident, len(incidents))
+	copy(sortedIncidents, incidents)
+	sort.Slice(sortedIncidents, func(i, j int) bool {
+		return isIncidentBefore(sortedIncidents[i], sortedIncidents[j], order)
 	})
+	return sortedIncidents
+}
+
+// isIncidentBefore returns true if a should be returned before b when incidents are sorted in the given order
+func isIncidentBefore(a api.Incident, b api.Incident, order sortOrder) bool {
+	if order == sortOrderAscending {
+		a, b = b, a
+	}
+	if a.StartTime.Equal(b.StartTime) {
+		return a.DeepLink > b.DeepLink
+	}
+	return a.StartTime.After(b.StartTime)
 }
 
 // getIncidentsFromCache attempts to get the incidents from the cache.
 // If the incidents are found in the cache, it returns them.
 // If the incidents are not found in the cache, it returns false for the second return value.
-func (s *Server) getIncidentsFromCache(ctx context.Context, statusPageUrl string, impacts []api.Impact) ([]api.Incident, bool, error) {
-	incidents, found := s.incidentCache.Get(statusPageUrl)
-	if !found {
-		return nil, false, nil
+func (s *Server) getIncidentsFromCache(ctx context.Context, statusPageUrl string, impacts impactFilter) (_ []api.Incident, _ bool, err error) {
+	ctx, span := s.tracer.Start(ctx, "getIncidentsFromCache", trace.WithAttributes(attribute.String("statusPageUrl", statusPageUrl)))
+	defer func() { endSpan(span, err) }()
+
+	// The incidents that passed the filter are cached under their own key if the incidents are cached by impacts,
+	// otherwise or if they aren't cached yet then the incidents of the status page are filtered
+	if key := incidentCacheKey(statusPageUrl, impacts); s.cachesIncidentsByImpacts() && key != statusPageUrl {
+		incidents, found, err := s.incidentCache.Get(ctx, key)
+		recordCacheLookup("filtered_incidents", found, err)
+		span.SetAttributes(attribute.Bool("cache.filtered_hit", found))
+		if err == nil && found {
+			span.SetAttributes(attribute.Bool("cache.hit", true), attribute.Int("incident.count", len(incidents)))
+			return incidents, true, nil
+		}
 	}
 
-	incidentsCasted, ok := incidents.([]api.Incident)
-	if !ok {
-		return nil, false, errors.New("failed to cast incidents to []api.Incident")
+	incidentsCasted, found, err := s.incidentCache.Get(ctx, statusPageUrl)
+	recordCacheLookup("incidents", found, err)
+	span.SetAttributes(attribute.Bool("cache.hit", found))
+	if err != nil || !found {
+		return nil, false, err
 	}
 
-	if len(impacts) > 0 {
-		var filteredIncidents []api.Incident
-		for _, incident := range incidentsCasted {
-			for _, impact := range impacts {
-				if incident.Impact == impact {
-					filteredIncidents = append(filteredIncidents, incident)
-				}
-			}
+	incidents := filterIncidentsByImpacts(incidentsCasted, impacts)
+	s.setFilteredIncidentsInCache(ctx, statusPageUrl, impacts, incidents)
+	span.SetAttributes(attribute.Int("incident.count", len(incidents)))
+	return incidents, true, nil
+}
+
+type incidentsFromDatabase struct {
+	incidents []api.Incident
+	found     bool
+}
+
+// getIncidentsFromDatabaseAndCache gets the incidents from the database, sorted by sortOrderDescending, and caches them.
+// Concurrent calls for the same status page share a single database fetch so that an expired cache entry
+// for a popular status page doesn't result in a stampede of identical queries.
+// The incidents returned are shared between the callers so must not be modified.
+func (s *Server) getIncidentsFromDatabaseAndCache(ctx context.Context, statusPageUrl string) ([]api.Incident, bool, error) {
+	// The fetch is shared so it shouldn't be cancelled if the request that started it goes away
+	ctx = context.WithoutCancel(ctx)
+	result, err, _ := s.incidentDatabaseFetches.Do(statusPageUrl, func() (interface{}, error) {
+		incidents, found, err := s.getIncidentsFromDatabase(ctx, statusPageUrl)
+		if err != nil || !found {
+			return incidentsFromDatabase{found: found}, err
+		}
+
+		incidents = sortIncidents(incidents, sortOrderDescending)
+		if err := s.incidentCache.Set(ctx, statusPageUrl, incidents, s.config.Cache.IncidentTTL); err != nil {
+			utils.GetLogger(ctx, s.logger).Warn("failed to set incidents in cache", zap.Error(err))
 		}
-		incidentsCasted = filteredIncidents
+		return incidentsFromDatabase{incidents: incidents, found: true}, nil
+	})
+	if err != nil {
+		return nil, false, err
 	}
 
-	return incidentsCasted, true, nil
+	fetched := result.(incidentsFromDatabase)
+	return fetched.incidents, fetched.found, nil
 }
 
 // getIncidentsFromDatabase attempts to get the incidents from the database.
 // If the incidents are found in the database, it returns them.
 // If the incidents are not found in the database, it returns false for the second return value.
-func (s *Server) getIncidentsFromDatabase(ctx context.Context, statusPageUrl string) ([]api.Incident, bool, error) {
+func (s *Server) getIncidentsFromDatabase(ctx context.Context, statusPageUrl string) (_ []api.Incident, _ bool, err error) {
+	ctx, span := s.tracer.Start(ctx, "getIncidentsFromDatabase", trace.WithAttributes(attribute.String("statusPageUrl", statusPageUrl)))
+	defer func() { endSpan(span, err) }()
+
 	incidents, err := s.dbClient.GetIncidents(ctx, statusPageUrl)
 	if err != nil {
 		return nil, false, err
 	}
+	span.SetAttributes(attribute.Int("incident.count", len(incidents)))
 
 	if len(incidents) == 0 {
 		// See if the status page exists
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+)
+
+// maxBatchStatusPageUrls is the maximum number of status pages that can be looked up in one batch request
+const maxBatchStatusPageUrls = 50
+
+type BatchIncidentsRequest struct {
+	StatusPageUrls []string     `json:"statusPageUrls" binding:"required"`
+	Impact         []api.Impact `json:"impact"`
+	Limit          *int         `json:"limit"`
+}
+
+// BatchIncidentsResult is either the incidents of a status page or the reason they couldn't be returned
+type BatchIncidentsResult struct {
+	*IncidentsResponse
+	Error string `json:"error,omitempty"`
+}
+
+type BatchIncidentsResponse struct {
+	// Results has an entry for every status page url in the request
+	Results map[string]BatchIncidentsResult `json:"results"`
+}
+
+// incidentsBatch is a handler for the /incidents/batch endpoint.
+// It takes a json body with a required field of statusPageUrls, an array of at most maxBatchStatusPageUrls status page urls
+// It has optional fields of impact, an array of impacts, and limit, which behave the same as they do for the /incidents endpoint
+// It returns the most recent incidents for each status page, if the incidents of a status page can't be returned then its result has an error instead.
+func (s *Server) incidentsBatch(context *gin.Context) {
+	ctx := context.Request.Context()
+	var request BatchIncidentsRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidBody, "statusPageUrls is required")
+		return
+	}
+	if len(request.StatusPageUrls) > maxBatchStatusPageUrls {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidStatusPageUrlCount, fmt.Sprintf("at most %d statusPageUrls can be requested at once", maxBatchStatusPageUrls))
+		return
+	}
+	for i, impact := range request.Impact {
+		parsedImpact, err := api.ParseImpact(string(impact))
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidImpact, "invalid impact")
+			return
+		}
+		// Synonyms are replaced by the impact they map to so that they match the impacts of incidents
+		request.Impact[i] = parsedImpact
+	}
+	if request.Limit != nil && *request.Limit < 0 {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidLimit, "limit must be a non-negative integer")
+		return
+	}
+
+	response := BatchIncidentsResponse{Results: make(map[string]BatchIncidentsResult, len(request.StatusPageUrls))}
+	for _, statusPageUrl := range request.StatusPageUrls {
+		if _, ok := response.Results[statusPageUrl]; ok {
+			continue
+		}
+		statusPage, incidents, err := s.lookupStatusPageIncidents(ctx, statusPageUrl, includeImpacts(request.Impact...))
+		if err != nil {
+			response.Results[statusPageUrl] = BatchIncidentsResult{Error: err.message}
+			continue
+		}
+		if !statusPage.IsIndexed {
+			result := IncidentsResponse{Incidents: []api.Incident{}, IsIndexed: false}.paginated(request.Limit)
+			response.Results[statusPageUrl] = BatchIncidentsResult{IncidentsResponse: &result}
+			continue
+		}
+
+		ongoingCount := countOngoingIncidents(incidents)
+		totalCount := len(incidents)
+		incidents, nextCursor := paginateIncidents(incidents, sortOrderDescending, nil, request.Limit)
+		incidents = s.flagIncidents(incidents)
+		if incidents == nil {
+			incidents = []api.Incident{}
+		}
+		result := IncidentsResponse{Incidents: incidents, IsIndexed: true, NextCursor: nextCursor, OngoingCount: ongoingCount, TotalCount: totalCount}.paginated(request.Limit)
+		response.Results[statusPageUrl] = BatchIncidentsResult{IncidentsResponse: &result}
+	}
+
+	context.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func postIncidentsBatch(s *Server, request BatchIncidentsRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(request)
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodPost, "/api/v1/incidents/batch", bytes.NewReader(body))
+	testContext.Request.Header.Set("Content-Type", "application/json")
+	s.incidentsBatch(testContext)
+	return recorder
+}
+
+func TestIncidentsBatchReturnsPerUrlErrors(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{
+		newTestIncident("a", api.ImpactMajor, start),
+		newTestIncident("b", api.ImpactMinor, start.Add(time.Hour)),
+		newTestIncident("c", api.ImpactMajor, start.Add(2*time.Hour)),
+	}})
+	unknownStatusPageUrl := "https://unknown.example.com"
+
+	limit := 1
+	recorder := postIncidentsBatch(s, BatchIncidentsRequest{
+		StatusPageUrls: []string{testStatusPageUrl, unknownStatusPageUrl},
+		Impact:         []api.Impact{api.ImpactMajor},
+		Limit:          &limit,
+	})
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response struct {
+		Results map[string]struct {
+			Incidents  []api.Incident `json:"incidents"`
+			NextCursor string         `json:"nextCursor"`
+			Error      string         `json:"error"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	known := response.Results[testStatusPageUrl]
+	if known.Error != "" || len(known.Incidents) != 1 || known.Incidents[0].DeepLink != testStatusPageUrl+"/incidents/c" || known.NextCursor == "" {
+		t.Fatalf("unexpected result for the known status page: %+v", known)
+	}
+	unknown := response.Results[unknownStatusPageUrl]
+	if unknown.Error == "" || unknown.Incidents != nil {
+		t.Fatalf("expected an error for the unknown status page, got %+v", unknown)
+	}
+}
+
+func TestIncidentsBatchLimitsNumberOfUrls(t *testing.T) {
+	s := newTestServer(t)
+	statusPageUrls := make([]string, maxBatchStatusPageUrls+1)
+	for i := range statusPageUrls {
+		statusPageUrls[i] = testStatusPageUrl
+	}
+
+	recorder := postIncidentsBatch(s, BatchIncidentsRequest{StatusPageUrls: statusPageUrls})
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", recorder.Code)
+	}
+}
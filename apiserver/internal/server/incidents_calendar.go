@@ -0,0 +1,99 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// defaultMaintenanceDuration is the length of the calendar event for maintenance that has no end time yet
+const defaultMaintenanceDuration = time.Hour
+
+const icalendarTimeFormat = "20060102T150405Z"
+
+// maintenanceCalendar is a handler for the /incidents/maintenance.ics endpoint.
+// It has a required query parameter of statusPageUrl
+// It returns an iCalendar (RFC 5545) calendar with an event for each maintenance window of the status page.
+// Maintenance that has not ended yet is given an end time of defaultMaintenanceDuration after it started.
+// If the status page is not known to statusphere, it returns a 404.
+func (s *Server) maintenanceCalendar(context *gin.Context) {
+	statusPageUrl, ok := parseStatusPageUrlQuery(context)
+	if !ok {
+		return
+	}
+
+	statusPage, incidents, ok := s.getStatusPageIncidents(context, statusPageUrl, includeImpacts(api.ImpactMaintenance))
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	var calendar strings.Builder
+	writeCalendarLine(&calendar, "BEGIN:VCALENDAR")
+	writeCalendarLine(&calendar, "VERSION:2.0")
+	writeCalendarLine(&calendar, "PRODID:-//statusphere//maintenance//EN")
+	writeCalendarLine(&calendar, "CALSCALE:GREGORIAN")
+	writeCalendarLine(&calendar, "X-WR-CALNAME:"+escapeCalendarText(statusPageName(statusPage)+" maintenance"))
+	for _, incident := range incidents {
+		end := incident.StartTime.Add(defaultMaintenanceDuration)
+		if incident.EndTime != nil && !incident.EndTime.IsZero() {
+			end = *incident.EndTime
+		}
+		writeCalendarLine(&calendar, "BEGIN:VEVENT")
+		// The deep link is the primary key of the incident, so it is a stable and globally unique id for the event
+		writeCalendarLine(&calendar, "UID:"+escapeCalendarText(incident.DeepLink))
+		writeCalendarLine(&calendar, "DTSTAMP:"+now.UTC().Format(icalendarTimeFormat))
+		writeCalendarLine(&calendar, "DTSTART:"+incident.StartTime.UTC().Format(icalendarTimeFormat))
+		writeCalendarLine(&calendar, "DTEND:"+end.UTC().Format(icalendarTimeFormat))
+		writeCalendarLine(&calendar, "SUMMARY:"+escapeCalendarText(incident.Title))
+		if incident.Description != nil {
+			writeCalendarLine(&calendar, "DESCRIPTION:"+escapeCalendarText(*incident.Description))
+		}
+		writeCalendarLine(&calendar, "URL:"+incident.DeepLink)
+		writeCalendarLine(&calendar, "END:VEVENT")
+	}
+	writeCalendarLine(&calendar, "END:VCALENDAR")
+
+	context.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(calendar.String()))
+}
+
+func statusPageName(statusPage api.StatusPage) string {
+	if statusPage.Name == "" {
+		return statusPage.URL
+	}
+	return statusPage.Name
+}
+
+// escapeCalendarText escapes a TEXT value as described in section 3.3.11 of RFC 5545
+func escapeCalendarText(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(text)
+}
+
+// writeCalendarLine writes a content line terminated by CRLF, folding it so that no line is longer than 75 octets
+func writeCalendarLine(calendar *strings.Builder, line string) {
+	const maxLineLength = 75
+	// Continuation lines start with a space, which counts towards their length
+	lineLength := maxLineLength
+	for len(line) > lineLength {
+		split := lineLength
+		// Don't split a multi-byte utf-8 character across lines
+		for split > 0 && !utf8.RuneStart(line[split]) {
+			split--
+		}
+		calendar.WriteString(line[:split])
+		calendar.WriteString("\r\n ")
+		line = line[split:]
+		lineLength = maxLineLength - 1
+	}
+	calendar.WriteString(line)
+	calendar.WriteString("\r\n")
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceCalendarOnlyContainsMaintenance(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	scheduled := newTestIncident("scheduled", api.ImpactMaintenance, start)
+	scheduled.EndTime = &end
+	scheduled.Title = "Database upgrade; expect brief downtime, maybe"
+	ongoing := newTestIncident("ongoing", api.ImpactMaintenance, start.Add(24*time.Hour))
+	outage := newTestIncident("outage", api.ImpactMajor, start)
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{scheduled, ongoing, outage}})
+
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents/maintenance.ics?statusPageUrl="+url.QueryEscape(testStatusPageUrl), nil)
+	s.maintenanceCalendar(testContext)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/calendar") {
+		t.Fatalf("expected a calendar content type, got %s", contentType)
+	}
+
+	// Unfold the content lines before checking them
+	body := strings.ReplaceAll(recorder.Body.String(), "\r\n ", "")
+	lines := strings.Split(strings.TrimSuffix(body, "\r\n"), "\r\n")
+	if lines[0] != "BEGIN:VCALENDAR" || lines[len(lines)-1] != "END:VCALENDAR" {
+		t.Fatalf("expected a VCALENDAR, got %q", body)
+	}
+	for _, line := range strings.Split(recorder.Body.String(), "\r\n") {
+		if len(line) > 75 {
+			t.Fatalf("expected content lines to be folded at 75 octets, got %q", line)
+		}
+	}
+	if events := strings.Count(body, "BEGIN:VEVENT"); events != 2 {
+		t.Fatalf("expected 2 events, got %d", events)
+	}
+	expectedLines := []string{
+		"UID:" + scheduled.DeepLink,
+		"DTSTART:20240301T140000Z",
+		"DTEND:20240301T160000Z",
+		`SUMMARY:Database upgrade\; expect brief downtime\, maybe`,
+		"DTSTART:20240302T140000Z",
+		"DTEND:20240302T150000Z",
+	}
+	for _, expectedLine := range expectedLines {
+		if !strings.Contains(body, "\r\n"+expectedLine+"\r\n") {
+			t.Errorf("expected calendar to contain %q, got %q", expectedLine, body)
+		}
+	}
+	if strings.Contains(body, outage.DeepLink) {
+		t.Fatalf("expected calendar to not contain non maintenance incidents")
+	}
+}
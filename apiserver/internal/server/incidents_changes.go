@@ -0,0 +1,68 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+type IncidentChangesResponse struct {
+	// Incidents are the incidents that were stored or changed after since, oldest change first, including the deleted ones
+	Incidents []api.Incident `json:"incidents"`
+	// NextSince is the server time that the changes were read at, clients pass it as since on their next poll
+	NextSince time.Time `json:"nextSince"`
+	IsIndexed bool      `json:"isIndexed"`
+}
+
+// incidentChanges is a handler for the /incidents/changes endpoint.
+// It has a required query parameter of statusPageUrl
+// It has a required query parameter of since, an RFC3339 timestamp, usually the nextSince of the previous response
+// It returns the incidents that were stored or changed after since, so that polling clients don't have to fetch the incidents that haven't changed
+// Incidents that have been removed from the status page are returned with their deletedAt so that clients can remove them
+// If the status page is not known to statusphere, it returns a 404.
+func (s *Server) incidentChanges(context *gin.Context) {
+	ctx := context.Request.Context()
+	statusPageUrl, ok := parseStatusPageUrlQuery(context)
+	if !ok {
+		return
+	}
+
+	sinceStr := context.Query("since")
+	if sinceStr == "" {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidTimeRange, "since is required")
+		return
+	}
+	since, err := time.Parse(time.RFC3339, sinceStr)
+	if err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidTimeRange, "since must be an RFC3339 timestamp")
+		return
+	}
+
+	statusPage, lookupErr := s.lookupStatusPage(ctx, statusPageUrl)
+	if lookupErr != nil {
+		lookupErr.write(context)
+		return
+	}
+	// The time is read before the incidents, so that changes made while they are read are returned again by the next poll rather than missed
+	nextSince := time.Now().UTC()
+	if !statusPage.IsIndexed {
+		context.JSON(http.StatusOK, IncidentChangesResponse{Incidents: []api.Incident{}, NextSince: nextSince, IsIndexed: false})
+		return
+	}
+
+	incidents, err := s.dbClient.GetIncidentsUpdatedSince(ctx, statusPageUrl, since)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to get incidents from database", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get incidents from database")
+		return
+	}
+	incidents = s.flagIncidents(incidents)
+	if incidents == nil {
+		// Always return an array so that clients don't have to handle null
+		incidents = []api.Incident{}
+	}
+	context.JSON(http.StatusOK, IncidentChangesResponse{Incidents: incidents, NextSince: nextSince, IsIndexed: true})
+}
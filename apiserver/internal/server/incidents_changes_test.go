@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func getIncidentChanges(s *Server, statusPageUrl string, since string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents/changes?statusPageUrl="+url.QueryEscape(statusPageUrl)+"&since="+url.QueryEscape(since), nil)
+	s.incidentChanges(testContext)
+	return recorder
+}
+
+func TestIncidentChangesReturnsTheIncidentsChangedSince(t *testing.T) {
+	lastPoll := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	unchanged := newTestIncident("unchanged", api.ImpactMinor, lastPoll.Add(-48*time.Hour))
+	unchanged.UpdatedAt = lastPoll.Add(-time.Hour)
+	resolved := newTestIncident("resolved", api.ImpactMajor, lastPoll.Add(-2*time.Hour))
+	resolved.UpdatedAt = lastPoll.Add(2 * time.Minute)
+	created := newTestIncident("created", api.ImpactCritical, lastPoll.Add(time.Minute))
+	created.UpdatedAt = lastPoll.Add(time.Minute)
+	deletedAt := lastPoll.Add(3 * time.Minute)
+	deleted := newTestIncident("deleted", api.ImpactMinor, lastPoll.Add(-time.Hour))
+	deleted.DeletedAt = &deletedAt
+	deleted.UpdatedAt = deletedAt
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{unchanged, resolved, created, deleted}})
+
+	before := time.Now()
+	recorder := getIncidentChanges(s, testStatusPageUrl, lastPoll.Format(time.RFC3339))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response IncidentChangesResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(response.Incidents) != 3 || response.Incidents[0].Title != "created" || response.Incidents[1].Title != "resolved" || response.Incidents[2].Title != "deleted" {
+		t.Fatalf("expected the created, resolved and deleted incidents oldest change first, got %+v", response.Incidents)
+	}
+	if response.Incidents[2].DeletedAt == nil {
+		t.Fatalf("expected the deleted incident to have its deletedAt")
+	}
+	if response.NextSince.Before(before.Truncate(time.Second)) || !response.IsIndexed {
+		t.Fatalf("expected the server time as the next since, got %+v", response)
+	}
+
+	// Polling again from the next since returns nothing as nothing has changed
+	recorder = getIncidentChanges(s, testStatusPageUrl, response.NextSince.Format(time.RFC3339Nano))
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response.Incidents == nil || len(response.Incidents) != 0 {
+		t.Fatalf("expected an empty array of incidents, got %+v", response.Incidents)
+	}
+}
+
+func TestIncidentChangesRejectsInvalidSince(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{})
+	for _, since := range []string{"", "yesterday"} {
+		if recorder := getIncidentChanges(s, testStatusPageUrl, since); recorder.Code != http.StatusBadRequest {
+			t.Fatalf("%q: expected status 400, got %d", since, recorder.Code)
+		}
+	}
+	if recorder := getIncidentChanges(s, "https://status.unknown.com", time.Now().Format(time.RFC3339)); recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for an unknown status page, got %d", recorder.Code)
+	}
+}
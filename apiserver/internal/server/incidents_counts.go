@@ -0,0 +1,91 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/db"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+type IncidentCountsResponse struct {
+	Critical    int  `json:"critical"`
+	Major       int  `json:"major"`
+	Minor       int  `json:"minor"`
+	None        int  `json:"none"`
+	Maintenance int  `json:"maintenance"`
+	Total       int  `json:"total"`
+	IsIndexed   bool `json:"isIndexed"`
+}
+
+// incidentCounts is a handler for the /incidents/counts endpoint.
+// It has a required query parameter of statusPageUrl
+// It has optional query parameters of from, to and includeOngoing, which filter the incidents the same way as they do for the /incidents endpoint
+// It returns the number of incidents of each impact, if the incidents of the status page aren't cached then they're counted by the database
+// rather than being read
+func (s *Server) incidentCounts(context *gin.Context) {
+	statusPageUrl, ok := parseStatusPageUrlQuery(context)
+	if !ok {
+		return
+	}
+
+	timeRange, ok := parseTimeRangeQuery(context)
+	if !ok {
+		return
+	}
+
+	ctx := context.Request.Context()
+	statusPage, incidents, found, lookupErr := s.lookupCachedStatusPageIncidents(ctx, statusPageUrl, impactFilter{})
+	if lookupErr != nil {
+		lookupErr.write(context)
+		return
+	}
+	if !statusPage.IsIndexed {
+		writeJSONWithETag(context, IncidentCountsResponse{IsIndexed: false})
+		return
+	}
+
+	var counts map[api.Impact]int
+	switch {
+	case found:
+		counts = countIncidentsByImpact(filterIncidentsByTimeRange(incidents, timeRange))
+	case timeRange.includeOngoing:
+		// The database can't include the ongoing incidents that started before from, so every incident is read
+		incidents, lookupErr = s.lookupDatabaseStatusPageIncidents(ctx, statusPageUrl, impactFilter{})
+		if lookupErr != nil {
+			lookupErr.write(context)
+			return
+		}
+		counts = countIncidentsByImpact(filterIncidentsByTimeRange(incidents, timeRange))
+	default:
+		var err error
+		counts, err = s.dbClient.CountIncidentsByImpact(ctx, db.IncidentsQuery{StatusPageUrl: statusPageUrl, From: timeRange.from, To: timeRange.to})
+		if err != nil {
+			utils.GetLogger(ctx, s.logger).Error("failed to count incidents in database", zap.Error(err))
+			writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to count incidents in database")
+			return
+		}
+	}
+
+	response := IncidentCountsResponse{
+		Critical:    counts[api.ImpactCritical],
+		Major:       counts[api.ImpactMajor],
+		Minor:       counts[api.ImpactMinor],
+		None:        counts[api.ImpactNone],
+		Maintenance: counts[api.ImpactMaintenance],
+		IsIndexed:   true,
+	}
+	for _, count := range counts {
+		response.Total += count
+	}
+	writeJSONWithETag(context, response)
+}
+
+func countIncidentsByImpact(incidents []api.Incident) map[api.Impact]int {
+	counts := make(map[api.Impact]int)
+	for _, incident := range incidents {
+		counts[incident.Impact]++
+	}
+	return counts
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/apiserver/internal/cache"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// getIncidentCounts calls the incident counts handler with the given query string
+func getIncidentCounts(t *testing.T, s *Server, query string) IncidentCountsResponse {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents/counts?statusPageUrl="+url.QueryEscape(testStatusPageUrl)+"&"+query, nil)
+	s.incidentCounts(testContext)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response IncidentCountsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	return response
+}
+
+func TestIncidentCountsCountsEachImpact(t *testing.T) {
+	start := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	ongoingBefore := newTestIncident("ongoing", api.ImpactCritical, start.AddDate(0, -1, 0))
+	incidents := []api.Incident{
+		newTestIncident("a", api.ImpactCritical, start),
+		newTestIncident("b", api.ImpactMajor, start.Add(time.Hour)),
+		newTestIncident("c", api.ImpactMajor, start.Add(2*time.Hour)),
+		newTestIncident("d", api.ImpactMaintenance, start.Add(3*time.Hour)),
+		newTestIncident("e", api.ImpactMinor, start.AddDate(0, 1, 0)),
+		ongoingBefore,
+	}
+	inMarch := "from=2024-03-01T00:00:00Z&to=2024-03-31T00:00:00Z"
+	expected := IncidentCountsResponse{Critical: 1, Major: 2, Maintenance: 1, Total: 4, IsIndexed: true}
+
+	dbClient := &fakeDbClient{incidents: incidents}
+	s := newTestServerWithDb(t, dbClient)
+	if response := getIncidentCounts(t, s, inMarch); response != expected {
+		t.Fatalf("expected %+v, got %+v", expected, response)
+	}
+	if dbClient.countIncidentsByImpactCalls.Load() != 1 || dbClient.getIncidentsCalls.Load() != 0 {
+		t.Fatalf("expected the uncached incidents to be counted by the database rather than read")
+	}
+
+	// Cached incidents are counted without the database
+	_ = s.incidentCache.Set(context.Background(), testStatusPageUrl, incidents, cache.DefaultExpiration)
+	if response := getIncidentCounts(t, s, inMarch); response != expected {
+		t.Fatalf("expected %+v from the cache, got %+v", expected, response)
+	}
+	if dbClient.countIncidentsByImpactCalls.Load() != 1 {
+		t.Fatalf("expected the cached incidents to be counted without the database")
+	}
+
+	expected.Critical++
+	expected.Total++
+	if response := getIncidentCounts(t, s, inMarch+"&includeOngoing=true"); response != expected {
+		t.Fatalf("expected the ongoing incident to be included, got %+v", response)
+	}
+	if response := getIncidentCounts(t, s, ""); response.Total != len(incidents) || response.Minor != 1 {
+		t.Fatalf("expected every incident to be counted without a range, got %+v", response)
+	}
+}
+
+func TestIncidentCountsValidatesTheRange(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{})
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents/counts?statusPageUrl="+url.QueryEscape(testStatusPageUrl)+"&from=yesterday", nil)
+	s.incidentCounts(testContext)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", recorder.Code)
+	}
+}
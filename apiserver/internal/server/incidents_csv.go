@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// csvFlushInterval is the number of rows written before they are flushed to the client
+const csvFlushInterval = 100
+
+var csvFilenameDisallowedCharacters = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+// incidentsCsv is a handler for the /incidents.csv endpoint.
+// It has a required query parameter of statusPageUrl
+// It has optional query parameters of impact, from, to and limit, which behave the same as they do for the /incidents endpoint
+// It returns the incidents of the status page, most recent first, as a CSV file with a header row.
+// If the status page is not known to statusphere, it returns a 404.
+func (s *Server) incidentsCsv(context *gin.Context) {
+	statusPageUrl, ok := parseStatusPageUrlQuery(context)
+	if !ok {
+		return
+	}
+
+	impacts, ok := parseImpactsQuery(context)
+	if !ok {
+		return
+	}
+
+	limit, ok := parseLimitQuery(context)
+	if !ok {
+		return
+	}
+
+	timeRange, ok := parseTimeRangeQuery(context)
+	if !ok {
+		return
+	}
+
+	statusPage, incidents, ok := s.getStatusPageIncidents(context, statusPageUrl, impacts)
+	if !ok {
+		return
+	}
+
+	context.Header("Content-Type", "text/csv; charset=utf-8")
+	context.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", csvFilename(statusPage)))
+	context.Status(http.StatusOK)
+
+	writer := csv.NewWriter(context.Writer)
+	if err := writer.Write([]string{"id", "impact", "startTime", "endTime", "title", "description"}); err != nil {
+		utils.GetLogger(context.Request.Context(), s.logger).Warn("failed to write csv header", zap.Error(err))
+		return
+	}
+	written := 0
+	for _, incident := range incidents {
+		if limit != nil && written >= *limit {
+			break
+		}
+		if !timeRange.contains(incident) {
+			continue
+		}
+		if err := writer.Write(incidentCsvRecord(incident)); err != nil {
+			// The client has likely gone away, the status has already been written so there is nothing more to do
+			utils.GetLogger(context.Request.Context(), s.logger).Warn("failed to write csv row", zap.Error(err))
+			return
+		}
+		written++
+		if written%csvFlushInterval == 0 {
+			writer.Flush()
+			context.Writer.Flush()
+		}
+	}
+	writer.Flush()
+}
+
+func incidentCsvRecord(incident api.Incident) []string {
+	endTime := ""
+	if !incident.IsOngoing() {
+		endTime = incident.EndTime.UTC().Format(time.RFC3339)
+	}
+	description := ""
+	if incident.Description != nil {
+		description = *incident.Description
+	}
+	return []string{
+		csvCell(incident.DeepLink),
+		string(incident.Impact),
+		incident.StartTime.UTC().Format(time.RFC3339),
+		endTime,
+		csvCell(incident.Title),
+		csvCell(description),
+	}
+}
+
+// csvCell returns the value as a cell that spreadsheets won't evaluate as a formula
+// The values come from the scraped status pages, so a value starting with a formula character is prefixed with a quote
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// csvFilename returns the name of the csv file for the status page, e.g. status.example.com-incidents.csv
+func csvFilename(statusPage api.StatusPage) string {
+	name := statusPage.URL
+	if parsed, err := url.Parse(statusPage.URL); err == nil && parsed.Host != "" {
+		name = parsed.Host + parsed.Path
+	}
+	name = strings.Trim(csvFilenameDisallowedCharacters.ReplaceAllString(name, "-"), "-")
+	if name == "" {
+		name = "statusphere"
+	}
+	return name + "-incidents.csv"
+}
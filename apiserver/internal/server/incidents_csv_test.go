@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/csv"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIncidentsCsvRespectsFilters(t *testing.T) {
+	description := "Requests are failing, \"a lot\"\nfor some users"
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	old := newTestIncident("old", api.ImpactMajor, start.Add(-48*time.Hour))
+	resolved := newTestIncident("resolved", api.ImpactMajor, start)
+	resolved.EndTime = &end
+	resolved.Description = &description
+	ongoing := newTestIncident("ongoing", api.ImpactMajor, start.Add(2*time.Hour))
+	newest := newTestIncident("newest", api.ImpactMajor, start.Add(4*time.Hour))
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{old, resolved, ongoing, newest}})
+
+	query := url.Values{}
+	query.Set("statusPageUrl", testStatusPageUrl)
+	query.Set("from", start.Add(-time.Hour).Format(time.RFC3339))
+	query.Set("to", start.Add(3*time.Hour).Format(time.RFC3339))
+	query.Set("limit", "2")
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents.csv?"+query.Encode(), nil)
+	s.incidentsCsv(testContext)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	if disposition := recorder.Header().Get("Content-Disposition"); disposition != `attachment; filename="status.example.com-incidents.csv"` {
+		t.Fatalf("unexpected content disposition %s", disposition)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(recorder.Body.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse csv: %v", err)
+	}
+	expected := [][]string{
+		{"id", "impact", "startTime", "endTime", "title", "description"},
+		{ongoing.DeepLink, "major", "2024-03-01T16:00:00Z", "", ongoing.Title, ""},
+		{resolved.DeepLink, "major", "2024-03-01T14:00:00Z", "2024-03-01T15:00:00Z", resolved.Title, description},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d records, got %d: %v", len(expected), len(records), records)
+	}
+	for i := range expected {
+		if strings.Join(records[i], "|") != strings.Join(expected[i], "|") {
+			t.Fatalf("expected record %d to be %v, got %v", i, expected[i], records[i])
+		}
+	}
+}
+
+func TestIncidentsCsvEscapesFormulas(t *testing.T) {
+	description := "@SUM(A1:A2)"
+	incident := newTestIncident("=HYPERLINK(\"https://example.com\")", api.ImpactMinor, time.Now().Add(-time.Hour))
+	incident.Description = &description
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{incident}})
+
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents.csv?statusPageUrl="+url.QueryEscape(testStatusPageUrl), nil)
+	s.incidentsCsv(testContext)
+
+	records, err := csv.NewReader(strings.NewReader(recorder.Body.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse csv: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d: %v", len(records), records)
+	}
+	if title := records[1][4]; title != "'"+incident.Title {
+		t.Fatalf("expected the title to be escaped, got %s", title)
+	}
+	if escapedDescription := records[1][5]; escapedDescription != "'"+description {
+		t.Fatalf("expected the description to be escaped, got %s", escapedDescription)
+	}
+	for _, value := range []string{"-1", "+1", "=1", "@1"} {
+		if cell := csvCell(value); cell != "'"+value {
+			t.Fatalf("expected %s to be escaped, got %s", value, cell)
+		}
+	}
+	if cell := csvCell("Elevated errors"); cell != "Elevated errors" {
+		t.Fatalf("expected a value without a formula character to be unchanged, got %s", cell)
+	}
+}
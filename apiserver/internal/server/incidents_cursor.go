@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// incidentsCursor marks the position of the last incident returned in a page of incidents.
+// It is handed to clients as an opaque base64 string, so the encoding can change without breaking them.
+type incidentsCursor struct {
+	StartTime time.Time `json:"s"`
+	// DeepLink is the primary key of an incident, it is used as a tiebreaker for incidents with the same start time
+	DeepLink string `json:"d"`
+}
+
+func encodeIncidentsCursor(incident api.Incident) string {
+	// Marshalling a struct of a time and a string cannot fail
+	marshalled, _ := json.Marshal(incidentsCursor{StartTime: incident.StartTime, DeepLink: incident.DeepLink})
+	return base64.RawURLEncoding.EncodeToString(marshalled)
+}
+
+func decodeIncidentsCursor(cursor string) (*incidentsCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode cursor")
+	}
+	var decodedCursor incidentsCursor
+	if err := json.Unmarshal(decoded, &decodedCursor); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal cursor")
+	}
+	if decodedCursor.DeepLink == "" {
+		return nil, errors.New("cursor is missing the incident")
+	}
+	return &decodedCursor, nil
+}
+
+// isAfterCursor returns true if the incident comes after the cursor when sorted in the given order.
+func (c *incidentsCursor) isAfterCursor(incident api.Incident, order sortOrder) bool {
+	return isIncidentBefore(api.Incident{StartTime: c.StartTime, DeepLink: c.DeepLink}, incident, order)
+}
+
+// paginateIncidents returns the page of incidents that comes after the cursor, of at most limit incidents.
+// The incidents must already be sorted in the given order by sortIncidents.
+// The second return value is the cursor that should be used to get the next page, it is empty if this is the last page.
+func paginateIncidents(incidents []api.Incident, order sortOrder, cursor *incidentsCursor, limit *int) ([]api.Incident, string) {
+	if cursor != nil {
+		var incidentsAfterCursor []api.Incident
+		for _, incident := range incidents {
+			if cursor.isAfterCursor(incident, order) {
+				incidentsAfterCursor = append(incidentsAfterCursor, incident)
+			}
+		}
+		incidents = incidentsAfterCursor
+	}
+
+	if limit == nil || len(incidents) <= *limit {
+		return incidents, ""
+	}
+
+	incidents = incidents[:*limit]
+	if len(incidents) == 0 {
+		return incidents, ""
+	}
+	return incidents, encodeIncidentsCursor(incidents[len(incidents)-1])
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"time"
+)
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	PubDate     string  `xml:"pubDate"`
+	Guid        rssGuid `xml:"guid"`
+}
+
+type rssGuid struct {
+	// IsPermaLink is false because the guid is the id of the incident rather than a link to it
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// incidentsRss is a handler for the /incidents/feed.rss endpoint.
+// It has a required query parameter of statusPageUrl
+// It has an optional query parameter of impact (default is all), which is an array of impacts e.g. impact=critical,major,minor,none to exclude maintenance
+// It returns an RSS 2.0 feed of the incidents of the status page, most recent first.
+// If the status page is not known to statusphere, it returns a 404.
+func (s *Server) incidentsRss(context *gin.Context) {
+	statusPage, incidents, ok := s.getFeedIncidents(context)
+	if !ok {
+		return
+	}
+
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       feedTitle(statusPage),
+			Link:        statusPage.URL,
+			Description: fmt.Sprintf("Incidents reported on %s, aggregated by statusphere", statusPage.URL),
+			Items:       []rssItem{},
+		},
+	}
+	for _, incident := range incidents {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       feedItemTitle(incident),
+			Link:        incident.DeepLink,
+			Description: rssItemDescription(incident),
+			PubDate:     incident.StartTime.UTC().Format(time.RFC1123Z),
+			Guid:        rssGuid{IsPermaLink: false, Value: incident.DeepLink},
+		})
+	}
+
+	writeXml(context, "application/rss+xml; charset=utf-8", feed)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Id      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Id        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Updated   string   `xml:"updated"`
+	Published string   `xml:"published"`
+	Link      atomLink `xml:"link"`
+	Summary   string   `xml:"summary,omitempty"`
+}
+
+// incidentsAtom is a handler for the /incidents/feed.atom endpoint.
+// It takes the same query parameters as the /incidents/feed.rss endpoint and returns the same incidents as an Atom 1.0 feed.
+// The feed is updated at the start time of the most recent incident, or now if there are no incidents, and each entry has the deep link of the incident as its id.
+func (s *Server) incidentsAtom(context *gin.Context) {
+	statusPage, incidents, ok := s.getFeedIncidents(context)
+	if !ok {
+		return
+	}
+
+	// Atom requires an updated time even if there are no incidents
+	updated := time.Now()
+	if len(incidents) > 0 {
+		updated = incidents[0].StartTime
+	}
+
+	feed := atomFeed{
+		Id:      statusPage.URL,
+		Title:   feedTitle(statusPage),
+		Updated: updated.UTC().Format(time.RFC3339),
+		Link:    atomLink{Href: statusPage.URL, Rel: "alternate"},
+		Author:  atomAuthor{Name: "statusphere"},
+		Entries: []atomEntry{},
+	}
+	for _, incident := range incidents {
+		entryUpdated := incident.StartTime
+		if incident.EndTime != nil && incident.EndTime.After(entryUpdated) {
+			entryUpdated = *incident.EndTime
+		}
+		entry := atomEntry{
+			Id:        incident.DeepLink,
+			Title:     feedItemTitle(incident),
+			Updated:   entryUpdated.UTC().Format(time.RFC3339),
+			Published: incident.StartTime.UTC().Format(time.RFC3339),
+			Link:      atomLink{Href: incident.DeepLink, Rel: "alternate"},
+		}
+		if incident.Description != nil {
+			entry.Summary = *incident.Description
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	writeXml(context, "application/atom+xml; charset=utf-8", feed)
+}
+
+// getFeedIncidents gets the incidents for the statusPageUrl and impact query parameters of a feed request
+// If the incidents can't be returned, it writes an error response and returns false
+func (s *Server) getFeedIncidents(context *gin.Context) (api.StatusPage, []api.Incident, bool) {
+	statusPageUrl, ok := parseStatusPageUrlQuery(context)
+	if !ok {
+		return api.StatusPage{}, nil, false
+	}
+
+	impacts, ok := parseImpactsQuery(context)
+	if !ok {
+		return api.StatusPage{}, nil, false
+	}
+
+	return s.getStatusPageIncidents(context, statusPageUrl, impacts)
+}
+
+func feedTitle(statusPage api.StatusPage) string {
+	return statusPageName(statusPage) + " incidents"
+}
+
+func feedItemTitle(incident api.Incident) string {
+	return fmt.Sprintf("[%s] %s", incident.Impact, incident.Title)
+}
+
+// rssItemDescription returns the impact of the incident followed by its description, if it has one
+func rssItemDescription(incident api.Incident) string {
+	description := fmt.Sprintf("Impact: %s", incident.Impact)
+	if incident.Description != nil && *incident.Description != "" {
+		description += "\n\n" + *incident.Description
+	}
+	return description
+}
+
+func writeXml(context *gin.Context, contentType string, document interface{}) {
+	marshalled, err := xml.Marshal(document)
+	if err != nil {
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to render feed")
+		return
+	}
+	context.Data(http.StatusOK, contentType, append([]byte(xml.Header), marshalled...))
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/xml"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/mmcdole/gofeed/atom"
+	"github.com/mmcdole/gofeed/rss"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIncidentsRssIsValidRss(t *testing.T) {
+	description := "Requests are failing <b>a lot</b>"
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	major := newTestIncident("a", api.ImpactMajor, start)
+	major.Description = &description
+	minor := newTestIncident("b", api.ImpactMinor, start.Add(2*time.Hour))
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{major, minor}})
+	getFeed := func(query string) *rss.Feed {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		testContext, _ := gin.CreateTestContext(recorder)
+		testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents/feed.rss?statusPageUrl="+url.QueryEscape(testStatusPageUrl)+"&"+query, nil)
+		s.incidentsRss(testContext)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", query, recorder.Code)
+		}
+		if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/rss+xml") {
+			t.Fatalf("%s: expected an rss content type, got %s", query, contentType)
+		}
+		feed, err := (&rss.Parser{}).Parse(strings.NewReader(recorder.Body.String()))
+		if err != nil {
+			t.Fatalf("%s: failed to parse rss feed: %v", query, err)
+		}
+		return feed
+	}
+
+	feed := getFeed("")
+	if feed.Version != "2.0" || feed.Title == "" || feed.Link != testStatusPageUrl {
+		t.Fatalf("feed is missing required elements: %+v", feed)
+	}
+	if len(feed.Items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(feed.Items))
+	}
+	item := feed.Items[1]
+	if item.GUID == nil || item.GUID.Value != major.DeepLink {
+		t.Fatalf("expected the guid to be the id of the incident, got %+v", item.GUID)
+	}
+	if item.PubDateParsed == nil || !item.PubDateParsed.Equal(start) {
+		t.Fatalf("expected the pub date to be the start time of the incident, got %v", item.PubDateParsed)
+	}
+	if item.Title != "[major] "+major.Title || item.Description != "Impact: major\n\n"+description {
+		t.Fatalf("expected the impact in the title and description, got %s and %s", item.Title, item.Description)
+	}
+
+	feed = getFeed("impact=minor")
+	if len(feed.Items) != 1 || feed.Items[0].GUID == nil || feed.Items[0].GUID.Value != minor.DeepLink || feed.Items[0].Description != "Impact: minor" {
+		t.Fatalf("expected only the minor incident, got %+v", feed.Items)
+	}
+}
+
+func TestIncidentsAtomIsValidAtom(t *testing.T) {
+	description := "Requests are failing <b>a lot</b>"
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	resolved := newTestIncident("a", api.ImpactMajor, start)
+	resolved.EndTime = &end
+	resolved.Description = &description
+	ongoing := newTestIncident("b", api.ImpactMinor, start.Add(2*time.Hour))
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{resolved, ongoing}})
+
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents/feed.atom?statusPageUrl="+url.QueryEscape(testStatusPageUrl), nil)
+	s.incidentsAtom(testContext)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/atom+xml") {
+		t.Fatalf("expected an atom content type, got %s", contentType)
+	}
+
+	// The Atom spec requires the feed to be in the Atom namespace and the feed and every entry to have an id, title and updated time
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(recorder.Body.Bytes(), &root); err != nil {
+		t.Fatalf("feed is not well formed xml: %v", err)
+	}
+	if root.XMLName.Space != "http://www.w3.org/2005/Atom" || root.XMLName.Local != "feed" {
+		t.Fatalf("expected an atom feed element, got %v", root.XMLName)
+	}
+	feed, err := (&atom.Parser{}).Parse(strings.NewReader(recorder.Body.String()))
+	if err != nil {
+		t.Fatalf("failed to parse atom feed: %v", err)
+	}
+	if feed.ID == "" || feed.Title == "" || feed.UpdatedParsed == nil || len(feed.Authors) == 0 {
+		t.Fatalf("feed is missing required elements: %+v", feed)
+	}
+	if !feed.UpdatedParsed.Equal(ongoing.StartTime) {
+		t.Fatalf("expected the feed to be updated at the most recent incident, got %v", feed.UpdatedParsed)
+	}
+	if len(feed.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(feed.Entries))
+	}
+	for _, entry := range feed.Entries {
+		if entry.ID == "" || entry.Title == "" || entry.UpdatedParsed == nil {
+			t.Fatalf("entry is missing required elements: %+v", entry)
+		}
+	}
+	if feed.Entries[1].ID != resolved.DeepLink || feed.Entries[1].Summary != description || !feed.Entries[1].UpdatedParsed.Equal(end) {
+		t.Fatalf("unexpected entry for the resolved incident: %+v", feed.Entries[1])
+	}
+}
+
+func TestIncidentsAtomWithoutIncidentsIsUpdatedNow(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{})
+
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents/feed.atom?statusPageUrl="+url.QueryEscape(testStatusPageUrl), nil)
+	before := time.Now().Truncate(time.Second)
+	s.incidentsAtom(testContext)
+
+	feed, err := (&atom.Parser{}).Parse(strings.NewReader(recorder.Body.String()))
+	if err != nil {
+		t.Fatalf("failed to parse atom feed: %v", err)
+	}
+	if len(feed.Entries) != 0 || feed.UpdatedParsed == nil || feed.UpdatedParsed.Before(before) {
+		t.Fatalf("expected an empty feed updated now, got %+v", feed)
+	}
+}
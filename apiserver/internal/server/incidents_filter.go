@@ -0,0 +1,319 @@
+package server
+
+import (
+	"github.com/metoro-io/statusphere/apiserver/internal/config"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+)
+
+// incidentTimeRange filters incidents by their start time.
+// A nil from or to means that side of the range is open-ended.
+type incidentTimeRange struct {
+	from *time.Time
+	to   *time.Time
+	// includeOngoing includes incidents that started before from but have not ended yet
+	includeOngoing bool
+}
+
+func (r incidentTimeRange) isEmpty() bool {
+	return r.from == nil && r.to == nil
+}
+
+func (r incidentTimeRange) contains(incident api.Incident) bool {
+	if r.to != nil && incident.StartTime.After(*r.to) {
+		return false
+	}
+	if r.from != nil && incident.StartTime.Before(*r.from) {
+		return r.includeOngoing && incident.IsOngoing()
+	}
+	return true
+}
+
+// filterIncidentsByTimeRange returns the incidents that are within the time range.
+// It does not modify the incidents passed in as they may be shared with the cache.
+func filterIncidentsByTimeRange(incidents []api.Incident, timeRange incidentTimeRange) []api.Incident {
+	if timeRange.isEmpty() {
+		return incidents
+	}
+	var filteredIncidents []api.Incident
+	for _, incident := range incidents {
+		if timeRange.contains(incident) {
+			filteredIncidents = append(filteredIncidents, incident)
+		}
+	}
+	return filteredIncidents
+}
+
+// filterIncidentsActiveAt returns the incidents that were active at the instant, see api.Incident.IsActiveAt.
+func filterIncidentsActiveAt(incidents []api.Incident, at time.Time) []api.Incident {
+	var activeIncidents []api.Incident
+	for _, incident := range incidents {
+		if incident.IsActiveAt(at) {
+			activeIncidents = append(activeIncidents, incident)
+		}
+	}
+	return activeIncidents
+}
+
+// filterOngoingIncidents returns the incidents that are ongoing, see api.Incident.IsOngoing.
+func filterOngoingIncidents(incidents []api.Incident) []api.Incident {
+	var ongoingIncidents []api.Incident
+	for _, incident := range incidents {
+		if incident.IsOngoing() {
+			ongoingIncidents = append(ongoingIncidents, incident)
+		}
+	}
+	return ongoingIncidents
+}
+
+// incidentsLastModified returns the newest time that the incidents started, ended, were updated, were deleted, became stale at
+// or breached their sla at while ongoing
+// The incidents are the ones matching the filters of the request so that differently filtered responses have their own time
+// It returns the zero time if there are no incidents
+func incidentsLastModified(incidents []api.Incident, now time.Time, incidentsConfig config.IncidentsConfig) time.Time {
+	staleThreshold := incidentsConfig.StaleThreshold
+	var lastModified time.Time
+	latest := func(t time.Time) {
+		if t.After(lastModified) {
+			lastModified = t
+		}
+	}
+	for _, incident := range incidents {
+		latest(incident.StartTime)
+		if incident.EndTime != nil {
+			latest(*incident.EndTime)
+		}
+		if incident.DeletedAt != nil {
+			latest(*incident.DeletedAt)
+		}
+		for _, event := range incident.Events {
+			latest(event.Time)
+		}
+		if incident.IsStaleAt(now, staleThreshold) {
+			latest(incident.StartTime.Add(staleThreshold))
+		}
+		if target := incidentsConfig.SlaTargets[string(incident.Impact)]; incident.IsOngoing() && incident.IsSlaBreachedAt(now, target) {
+			latest(incident.StartTime.Add(target))
+		}
+	}
+	return lastModified
+}
+
+func countOngoingIncidents(incidents []api.Incident) int {
+	count := 0
+	for _, incident := range incidents {
+		if incident.IsOngoing() {
+			count++
+		}
+	}
+	return count
+}
+
+// filterIncidentsByTag returns the incidents that have the tag, compared case insensitively
+func filterIncidentsByTag(incidents []api.Incident, tag string) []api.Incident {
+	var filteredIncidents []api.Incident
+	for _, incident := range incidents {
+		for _, incidentTag := range incident.Tags {
+			if strings.EqualFold(incidentTag, tag) {
+				filteredIncidents = append(filteredIncidents, incident)
+				break
+			}
+		}
+	}
+	return filteredIncidents
+}
+
+type componentMatch string
+
+const (
+	componentMatchExact     componentMatch = "exact"
+	componentMatchSubstring componentMatch = "substring"
+)
+
+func parseComponentMatch(match string) (componentMatch, error) {
+	switch componentMatch(match) {
+	case componentMatchExact, componentMatchSubstring:
+		return componentMatch(match), nil
+	default:
+		return "", errors.New("invalid component match")
+	}
+}
+
+// filterIncidentsByComponent returns the incidents that affected the component, compared case insensitively
+func filterIncidentsByComponent(incidents []api.Incident, component string, match componentMatch) []api.Incident {
+	component = strings.ToLower(component)
+	var filteredIncidents []api.Incident
+	for _, incident := range incidents {
+		for _, incidentComponent := range incident.Components {
+			incidentComponent = strings.ToLower(incidentComponent)
+			if incidentComponent == component || (match == componentMatchSubstring && strings.Contains(incidentComponent, component)) {
+				filteredIncidents = append(filteredIncidents, incident)
+				break
+			}
+		}
+	}
+	return filteredIncidents
+}
+
+// impactFilter filters incidents by their impact, it either keeps or excludes the incidents that have one of the impacts
+// The zero value keeps all the incidents
+type impactFilter struct {
+	impacts []api.Impact
+	exclude bool
+}
+
+// includeImpacts returns a filter that keeps the incidents that have one of the impacts, or all the incidents if there are no impacts
+func includeImpacts(impacts ...api.Impact) impactFilter {
+	return impactFilter{impacts: impacts}
+}
+
+// excludeImpacts returns a filter that removes the incidents that have one of the impacts
+func excludeImpacts(impacts ...api.Impact) impactFilter {
+	return impactFilter{impacts: impacts, exclude: true}
+}
+
+// includedImpacts returns the impacts that pass the filter, or nil if every impact does
+func (f impactFilter) includedImpacts() []api.Impact {
+	if len(f.impacts) == 0 {
+		return nil
+	}
+	if !f.exclude {
+		return f.impacts
+	}
+	excluded := make(map[api.Impact]struct{}, len(f.impacts))
+	for _, impact := range f.impacts {
+		excluded[impact] = struct{}{}
+	}
+	// It is empty rather than nil if every impact is excluded
+	included := []api.Impact{}
+	for _, impact := range api.Impacts {
+		if _, ok := excluded[impact]; !ok {
+			included = append(included, impact)
+		}
+	}
+	return included
+}
+
+// filterIncidentsByImpacts returns the incidents that pass the impact filter
+func filterIncidentsByImpacts(incidents []api.Incident, filter impactFilter) []api.Incident {
+	if len(filter.impacts) == 0 {
+		return incidents
+	}
+	// Build a set of the impacts so that repeated impacts in the filter don't duplicate incidents
+	impactSet := make(map[api.Impact]struct{}, len(filter.impacts))
+	for _, impact := range filter.impacts {
+		impactSet[impact] = struct{}{}
+	}
+	var filteredIncidents []api.Incident
+	for _, incident := range incidents {
+		if _, ok := impactSet[incident.Impact]; ok != filter.exclude {
+			filteredIncidents = append(filteredIncidents, incident)
+		}
+	}
+	return filteredIncidents
+}
+
+// filterIncidentsByQuery returns the incidents whose title or description contains the query, compared case insensitively
+func filterIncidentsByQuery(incidents []api.Incident, query string) []api.Incident {
+	query = strings.ToLower(query)
+	var filteredIncidents []api.Incident
+	for _, incident := range incidents {
+		if countIncidentQueryMatches(incident, query) > 0 {
+			filteredIncidents = append(filteredIncidents, incident)
+		}
+	}
+	return filteredIncidents
+}
+
+// countQueryMatches returns the number of times the query appears in the titles and descriptions of the incidents, compared case insensitively
+func countQueryMatches(incidents []api.Incident, query string) int {
+	query = strings.ToLower(query)
+	count := 0
+	for _, incident := range incidents {
+		count += countIncidentQueryMatches(incident, query)
+	}
+	return count
+}
+
+// countIncidentQueryMatches expects the query to already be lower case
+func countIncidentQueryMatches(incident api.Incident, query string) int {
+	count := strings.Count(strings.ToLower(incident.Title), query)
+	if incident.Description != nil {
+		count += strings.Count(strings.ToLower(*incident.Description), query)
+	}
+	return count
+}
+
+// withoutEvents returns copies of the incidents without their events
+// It does not modify the incidents passed in as they may be shared with the cache.
+func withoutEvents(incidents []api.Incident) []api.Incident {
+	var strippedIncidents []api.Incident
+	for _, incident := range incidents {
+		incident.Events = nil
+		strippedIncidents = append(strippedIncidents, incident)
+	}
+	return strippedIncidents
+}
+
+// withoutImpactHistory returns copies of the incidents without their impact history
+// It does not modify the incidents passed in as they may be shared with the cache.
+func withoutImpactHistory(incidents []api.Incident) []api.Incident {
+	var strippedIncidents []api.Incident
+	for _, incident := range incidents {
+		incident.ImpactHistory = nil
+		strippedIncidents = append(strippedIncidents, incident)
+	}
+	return strippedIncidents
+}
+
+// withFlags returns copies of the incidents with Stale and SlaBreached set as they are at now, see api.Incident.IsStaleAt and api.Incident.IsSlaBreachedAt
+// It does not modify the incidents passed in as they may be shared with the cache.
+func withFlags(incidents []api.Incident, now time.Time, incidentsConfig config.IncidentsConfig) []api.Incident {
+	var flaggedIncidents []api.Incident
+	for _, incident := range incidents {
+		flaggedIncidents = append(flaggedIncidents, flagIncidentAt(incident, now, incidentsConfig))
+	}
+	return flaggedIncidents
+}
+
+// flagIncidentAt returns the incident with Stale and SlaBreached set as they are at now
+func flagIncidentAt(incident api.Incident, now time.Time, incidentsConfig config.IncidentsConfig) api.Incident {
+	incident.Stale = incident.IsStaleAt(now, incidentsConfig.StaleThreshold)
+	incident.SlaBreached = incident.IsSlaBreachedAt(now, incidentsConfig.SlaTargets[string(incident.Impact)])
+	return incident
+}
+
+// flagIncidents returns copies of the incidents flagged if they've been ongoing for longer than the stale threshold
+// or took longer to resolve than the sla target of their impact
+func (s *Server) flagIncidents(incidents []api.Incident) []api.Incident {
+	return withFlags(incidents, time.Now(), s.config.Incidents)
+}
+
+// inTimeZone returns copies of the incidents with their timestamps converted to the location
+// It does not modify the incidents passed in as they may be shared with the cache.
+func inTimeZone(incidents []api.Incident, location *time.Location) []api.Incident {
+	var localizedIncidents []api.Incident
+	for _, incident := range incidents {
+		incident.StartTime = incident.StartTime.In(location)
+		if incident.EndTime != nil {
+			endTime := incident.EndTime.In(location)
+			incident.EndTime = &endTime
+		}
+		if incident.DeletedAt != nil {
+			deletedAt := incident.DeletedAt.In(location)
+			incident.DeletedAt = &deletedAt
+		}
+		if incident.Events != nil {
+			events := make(api.IncidentEventArray, len(incident.Events))
+			for i, event := range incident.Events {
+				event.Time = event.Time.In(location)
+				events[i] = event
+			}
+			incident.Events = events
+		}
+		localizedIncidents = append(localizedIncidents, incident)
+	}
+	return localizedIncidents
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxIncidentGroups is the maximum number of groups returned, so that filling the gaps of a long range can't produce a huge response
+const maxIncidentGroups = 1000
+
+type IncidentGroup struct {
+	// Period identifies the group e.g. 2024-03-01 for a day, 2024-W09 for an ISO week or 2024-03 for a month
+	Period string `json:"period"`
+	// Start is the start of the period in the requested time zone
+	Start     time.Time      `json:"start"`
+	Incidents []api.Incident `json:"incidents"`
+	Count     int            `json:"count"`
+}
+
+type IncidentGroupsResponse struct {
+	Groups    []IncidentGroup `json:"groups"`
+	IsIndexed bool            `json:"isIndexed"`
+}
+
+type groupPeriod string
+
+const (
+	groupPeriodDay   groupPeriod = "day"
+	groupPeriodWeek  groupPeriod = "week"
+	groupPeriodMonth groupPeriod = "month"
+)
+
+func parseGroupPeriod(period string) (groupPeriod, error) {
+	switch groupPeriod(period) {
+	case groupPeriodDay, groupPeriodWeek, groupPeriodMonth:
+		return groupPeriod(period), nil
+	default:
+		return "", errors.New("invalid group period")
+	}
+}
+
+// start returns the start of the period containing t, in the location of t
+// Weeks start on a Monday, as they do for ISO weeks
+func (p groupPeriod) start(t time.Time) time.Time {
+	year, month, day := t.Date()
+	switch p {
+	case groupPeriodWeek:
+		daysSinceMonday := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-daysSinceMonday, 0, 0, 0, 0, t.Location())
+	case groupPeriodMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// previous returns the start of the period before the one starting at start
+func (p groupPeriod) previous(start time.Time) time.Time {
+	switch p {
+	case groupPeriodWeek:
+		return start.AddDate(0, 0, -7)
+	case groupPeriodMonth:
+		return start.AddDate(0, -1, 0)
+	default:
+		return start.AddDate(0, 0, -1)
+	}
+}
+
+func (p groupPeriod) label(start time.Time) string {
+	switch p {
+	case groupPeriodWeek:
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case groupPeriodMonth:
+		return start.Format("2006-01")
+	default:
+		return start.Format("2006-01-02")
+	}
+}
+
+// incidentsGrouped is a handler for the /incidents/grouped endpoint.
+// It has a required query parameter of statusPageUrl
+// It has an optional query parameter of by (default is month), which is day, week or month, the period that incidents are grouped by their start time into
+// It has optional query parameters of impact, from, to and includeOngoing, which filter the incidents the same way as they do for the /incidents endpoint
+// It has an optional query parameter of tz (default is UTC), an IANA time zone name, which the periods and returned timestamps are in
+// It has an optional query parameter of fillGaps (default is false), which also returns empty groups for the periods without incidents
+// between from (or the oldest incident) and to (or now)
+// The groups are returned most recent first, as are the incidents within them
+func (s *Server) incidentsGrouped(context *gin.Context) {
+	statusPageUrl, ok := parseStatusPageUrlQuery(context)
+	if !ok {
+		return
+	}
+
+	impacts, ok := parseImpactsQuery(context)
+	if !ok {
+		return
+	}
+
+	period := groupPeriodMonth
+	if byStr := context.Query("by"); byStr != "" {
+		parsedPeriod, err := parseGroupPeriod(byStr)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidPeriod, "by must be day, week or month")
+			return
+		}
+		period = parsedPeriod
+	}
+
+	timeRange, ok := parseTimeRangeQuery(context)
+	if !ok {
+		return
+	}
+
+	location, ok := parseTimeZoneQuery(context)
+	if !ok {
+		return
+	}
+	if location == nil {
+		location = time.UTC
+	}
+
+	fillGaps := false
+	if fillGapsStr := context.Query("fillGaps"); fillGapsStr != "" {
+		fill, err := strconv.ParseBool(fillGapsStr)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidBoolean, "fillGaps must be a boolean")
+			return
+		}
+		fillGaps = fill
+	}
+
+	statusPage, incidents, ok := s.getStatusPageIncidents(context, statusPageUrl, impacts)
+	if !ok {
+		return
+	}
+	if !statusPage.IsIndexed {
+		writeJSONWithETag(context, IncidentGroupsResponse{Groups: []IncidentGroup{}, IsIndexed: false})
+		return
+	}
+
+	incidents = filterIncidentsByTimeRange(sortIncidents(incidents, sortOrderDescending), timeRange)
+	incidents = inTimeZone(s.flagIncidents(incidents), location)
+
+	var groups []IncidentGroup
+	if fillGaps {
+		rangeStart, rangeEnd := groupRange(incidents, timeRange, time.Now())
+		var err error
+		groups, err = groupIncidentsFillingGaps(incidents, period, rangeStart.In(location), rangeEnd.In(location))
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidTimeRange, fmt.Sprintf("at most %d groups can be returned, use a shorter range or a longer period", maxIncidentGroups))
+			return
+		}
+	} else {
+		groups = groupIncidents(incidents, period)
+	}
+	if groups == nil {
+		// Always return an array so that clients don't have to handle null
+		groups = []IncidentGroup{}
+	}
+	writeJSONWithETag(context, IncidentGroupsResponse{Groups: groups, IsIndexed: true})
+}
+
+// groupIncidents groups the incidents by the period they started in
+// The incidents must be sorted by sortOrderDescending, the groups are returned in the same order
+func groupIncidents(incidents []api.Incident, period groupPeriod) []IncidentGroup {
+	var groups []IncidentGroup
+	for _, incident := range incidents {
+		start := period.start(incident.StartTime)
+		if len(groups) == 0 || !groups[len(groups)-1].Start.Equal(start) {
+			groups = append(groups, IncidentGroup{Period: period.label(start), Start: start, Incidents: []api.Incident{}})
+		}
+		group := &groups[len(groups)-1]
+		group.Incidents = append(group.Incidents, incident)
+		group.Count++
+	}
+	return groups
+}
+
+// groupIncidentsFillingGaps groups the incidents by the period they started in, with an empty group for each period
+// between rangeStart and rangeEnd that has no incidents
+// The incidents must be sorted by sortOrderDescending and start within the range, the groups are returned in the same order
+// It returns an error if there would be more than maxIncidentGroups groups
+func groupIncidentsFillingGaps(incidents []api.Incident, period groupPeriod, rangeStart time.Time, rangeEnd time.Time) ([]IncidentGroup, error) {
+	groupsWithIncidents := groupIncidents(incidents, period)
+	var groups []IncidentGroup
+	first := period.start(rangeStart)
+	for start := period.start(rangeEnd); !start.Before(first); start = period.previous(start) {
+		if len(groups) == maxIncidentGroups {
+			return nil, errors.New("too many groups")
+		}
+		if len(groupsWithIncidents) > 0 && groupsWithIncidents[0].Start.Equal(start) {
+			groups = append(groups, groupsWithIncidents[0])
+			groupsWithIncidents = groupsWithIncidents[1:]
+			continue
+		}
+		groups = append(groups, IncidentGroup{Period: period.label(start), Start: start, Incidents: []api.Incident{}})
+	}
+	return groups, nil
+}
+
+// groupRange returns the range that gaps are filled within, from the start of the time range (or the oldest incident) to its end (or now)
+// The range is widened to include all the incidents, e.g. ongoing incidents that started before the time range
+// The incidents must be sorted by sortOrderDescending
+func groupRange(incidents []api.Incident, timeRange incidentTimeRange, now time.Time) (time.Time, time.Time) {
+	rangeStart, rangeEnd := now, now
+	if timeRange.from != nil {
+		rangeStart = *timeRange.from
+	}
+	if timeRange.to != nil {
+		rangeEnd = *timeRange.to
+	}
+	if len(incidents) > 0 {
+		if oldest := incidents[len(incidents)-1].StartTime; timeRange.from == nil || oldest.Before(rangeStart) {
+			rangeStart = oldest
+		}
+		if newest := incidents[0].StartTime; newest.After(rangeEnd) {
+			rangeEnd = newest
+		}
+	}
+	if rangeStart.After(rangeEnd) {
+		rangeStart = rangeEnd
+	}
+	return rangeStart, rangeEnd
+}
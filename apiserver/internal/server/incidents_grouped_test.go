@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func getIncidentsGrouped(s *Server, query string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents/grouped?statusPageUrl="+url.QueryEscape(testStatusPageUrl)+"&"+query, nil)
+	s.incidentsGrouped(testContext)
+	return recorder
+}
+
+func groupSummary(t *testing.T, recorder *httptest.ResponseRecorder) map[string]int {
+	t.Helper()
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	var response IncidentGroupsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	summary := make(map[string]int, len(response.Groups))
+	for i, group := range response.Groups {
+		if i > 0 && !group.Start.Before(response.Groups[i-1].Start) {
+			t.Fatalf("expected the groups to be most recent first, got %+v", response.Groups)
+		}
+		if group.Count != len(group.Incidents) {
+			t.Fatalf("expected the count of %s to match its incidents, got %+v", group.Period, group)
+		}
+		summary[group.Period] = group.Count
+	}
+	return summary
+}
+
+func TestIncidentsGroupedByPeriod(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{
+		// Friday 1st March
+		newTestIncident("a", api.ImpactMajor, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)),
+		newTestIncident("b", api.ImpactMinor, time.Date(2024, 3, 1, 23, 30, 0, 0, time.UTC)),
+		// Monday 4th March
+		newTestIncident("c", api.ImpactMaintenance, time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC)),
+		newTestIncident("d", api.ImpactCritical, time.Date(2024, 5, 20, 8, 0, 0, 0, time.UTC)),
+	}})
+
+	tests := []struct {
+		query    string
+		expected map[string]int
+	}{
+		{query: "", expected: map[string]int{"2024-03": 3, "2024-05": 1}},
+		{query: "by=day", expected: map[string]int{"2024-03-01": 2, "2024-03-04": 1, "2024-05-20": 1}},
+		{query: "by=week", expected: map[string]int{"2024-W09": 2, "2024-W10": 1, "2024-W21": 1}},
+		{query: "by=day&tz=Asia/Tokyo", expected: map[string]int{"2024-03-01": 1, "2024-03-02": 1, "2024-03-04": 1, "2024-05-20": 1}},
+		{query: "impact=major,minor", expected: map[string]int{"2024-03": 2}},
+		{query: "from=2024-03-01T00:00:00Z&to=2024-03-31T00:00:00Z&fillGaps=true", expected: map[string]int{"2024-03": 3}},
+		{query: "from=2024-03-01T00:00:00Z&to=2024-05-31T00:00:00Z&fillGaps=true", expected: map[string]int{"2024-03": 3, "2024-04": 0, "2024-05": 1}},
+		{query: "by=week&from=2024-03-01T00:00:00Z&to=2024-03-13T00:00:00Z&impact=critical&fillGaps=true", expected: map[string]int{"2024-W09": 0, "2024-W10": 0, "2024-W11": 0}},
+	}
+	for _, test := range tests {
+		summary := groupSummary(t, getIncidentsGrouped(s, test.query))
+		if len(summary) != len(test.expected) {
+			t.Fatalf("%s: expected groups %v, got %v", test.query, test.expected, summary)
+		}
+		for period, count := range test.expected {
+			if actual, ok := summary[period]; !ok || actual != count {
+				t.Fatalf("%s: expected groups %v, got %v", test.query, test.expected, summary)
+			}
+		}
+	}
+}
+
+func TestIncidentsGroupedRejectsInvalidParameters(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{
+		newTestIncident("a", api.ImpactMajor, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)),
+	}})
+	for _, query := range []string{"by=year", "fillGaps=maybe", "tz=Nowhere/Special", "impact=bad", "by=day&from=1970-01-01T00:00:00Z&fillGaps=true"} {
+		if recorder := getIncidentsGrouped(s, query); recorder.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", query, recorder.Code)
+		}
+	}
+}
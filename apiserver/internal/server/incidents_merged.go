@@ -0,0 +1,103 @@
+package server
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+)
+
+// maxMergedStatusPageUrls is the maximum number of status pages whose incidents can be merged in one request
+const maxMergedStatusPageUrls = maxBatchStatusPageUrls
+
+type MergedIncidentsResponse struct {
+	// Incidents are the incidents of every status page merged together, most recent first, each incident has the statusPageUrl it belongs to
+	Incidents []api.Incident `json:"incidents"`
+	// TotalCount is the number of incidents of the status pages matching the filters, before the limit is applied
+	TotalCount int `json:"totalCount"`
+	// NotIndexed are the status pages that haven't been indexed yet, so they have no incidents
+	NotIndexed []string `json:"notIndexed"`
+	// Errors has the reason that the incidents of a status page couldn't be returned for each status page that they couldn't be
+	Errors map[string]string `json:"errors"`
+}
+
+// mergedIncidents is a handler for the /incidents/merged endpoint.
+// It has a required query parameter of statusPageUrl, which is repeated for each status page e.g. statusPageUrl=a&statusPageUrl=b,
+// at most maxMergedStatusPageUrls status pages can be merged at once
+// It has optional query parameters of impact, excludeImpact and type, which filter the incidents the same way as they do for the /incidents endpoint
+// It has an optional query parameter of limit (default is the configured default limit), which is the maximum number of merged incidents to return,
+// limits above the configured max limit are clamped to it
+// It returns the incidents of every status page in a single list, most recent first
+// A status page that isn't indexed or can't be looked up doesn't fail the request, it is reported in notIndexed or errors instead
+func (s *Server) mergedIncidents(context *gin.Context) {
+	ctx := context.Request.Context()
+	queryUrls := context.QueryArray("statusPageUrl")
+	if len(queryUrls) == 0 {
+		writeError(context, http.StatusBadRequest, ErrorCodeStatusPageUrlRequired, "statusPageUrl is required")
+		return
+	}
+	var statusPageUrls []string
+	seen := make(map[string]bool)
+	for _, queryUrl := range queryUrls {
+		statusPageUrl, err := api.CanonicalStatusPageUrl(queryUrl)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidStatusPageUrl, "statusPageUrl is invalid, "+err.Error())
+			return
+		}
+		if !seen[statusPageUrl] {
+			seen[statusPageUrl] = true
+			statusPageUrls = append(statusPageUrls, statusPageUrl)
+		}
+	}
+	if len(statusPageUrls) > maxMergedStatusPageUrls {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidStatusPageUrlCount, fmt.Sprintf("at most %d statusPageUrls can be merged at once", maxMergedStatusPageUrls))
+		return
+	}
+
+	impacts, ok := parseImpactsQuery(context)
+	if !ok {
+		return
+	}
+
+	limit, ok := parseLimitQuery(context)
+	if !ok {
+		return
+	}
+	if limit != nil && *limit < 0 {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidLimit, "limit must be a non-negative integer")
+		return
+	}
+	mergedLimit := s.config.Incidents.DefaultLimit
+	if limit != nil {
+		mergedLimit = min(*limit, s.config.Incidents.MaxLimit)
+	}
+
+	response := MergedIncidentsResponse{NotIndexed: []string{}, Errors: map[string]string{}}
+	var incidents []api.Incident
+	for _, statusPageUrl := range statusPageUrls {
+		statusPage, statusPageIncidents, lookupErr := s.lookupStatusPageIncidents(ctx, statusPageUrl, impacts)
+		if lookupErr != nil {
+			response.Errors[statusPageUrl] = lookupErr.message
+			continue
+		}
+		if !statusPage.IsIndexed {
+			response.NotIndexed = append(response.NotIndexed, statusPageUrl)
+			continue
+		}
+		incidents = append(incidents, statusPageIncidents...)
+	}
+
+	// The incidents of each status page are shared with the cache, sortIncidents sorts a copy of them
+	incidents = sortIncidents(incidents, sortOrderDescending)
+	response.TotalCount = len(incidents)
+	if len(incidents) > mergedLimit {
+		incidents = incidents[:mergedLimit]
+	}
+	incidents = s.flagIncidents(incidents)
+	if incidents == nil {
+		// Always return an array so that clients don't have to handle null
+		incidents = []api.Incident{}
+	}
+	response.Incidents = incidents
+	writeJSONWithETag(context, response)
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// getMergedIncidents calls the merged incidents handler with the given query string
+func getMergedIncidents(s *Server, query string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents/merged?"+query, nil)
+	s.mergedIncidents(testContext)
+	return recorder
+}
+
+func TestMergedIncidentsAreSortedAcrossStatusPages(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	other := api.StatusPage{URL: "https://status.other.com", IsIndexed: true}
+	unindexed := api.StatusPage{URL: "https://status.new.com", IsIndexed: false}
+	otherIncident := newTestIncident("b", api.ImpactMinor, start.Add(time.Hour))
+	otherIncident.StatusPageUrl = other.URL
+	otherIncident.DeepLink = other.URL + "/incidents/b"
+	s := newTestServerWithDb(t, &fakeDbClient{
+		statusPages: []api.StatusPage{other, unindexed},
+		incidents: []api.Incident{
+			newTestIncident("a", api.ImpactMajor, start),
+			otherIncident,
+			newTestIncident("c", api.ImpactMajor, start.Add(2*time.Hour)),
+		},
+	})
+	s.setCachedStatusPage(context.Background(), other)
+	s.setCachedStatusPage(context.Background(), unindexed)
+
+	recorder := getMergedIncidents(s, "statusPageUrl="+testStatusPageUrl+"&statusPageUrl="+other.URL+"/&statusPageUrl="+unindexed.URL+"&statusPageUrl=https://status.unknown.com&limit=2")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response MergedIncidentsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	var titles []string
+	for _, incident := range response.Incidents {
+		titles = append(titles, incident.Title+"@"+incident.StatusPageUrl)
+	}
+	expectedTitles := []string{"c@" + testStatusPageUrl, "b@" + other.URL}
+	if !reflect.DeepEqual(titles, expectedTitles) {
+		t.Fatalf("expected the incidents %v, got %v", expectedTitles, titles)
+	}
+	if response.TotalCount != 3 {
+		t.Fatalf("expected a total count of 3, got %d", response.TotalCount)
+	}
+	if !reflect.DeepEqual(response.NotIndexed, []string{unindexed.URL}) {
+		t.Fatalf("expected the unindexed status page to be reported, got %v", response.NotIndexed)
+	}
+	if _, ok := response.Errors["https://status.unknown.com"]; !ok || len(response.Errors) != 1 {
+		t.Fatalf("expected an error for the unknown status page, got %v", response.Errors)
+	}
+
+	// The impact filters apply to every status page
+	if err := json.Unmarshal(getMergedIncidents(s, "statusPageUrl="+testStatusPageUrl+"&statusPageUrl="+other.URL+"&impact=minor").Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(response.Incidents) != 1 || response.Incidents[0].Title != "b" {
+		t.Fatalf("expected only the minor incident, got %+v", response.Incidents)
+	}
+}
+
+func TestMergedIncidentsRejectsInvalidQueries(t *testing.T) {
+	s := newTestServer(t)
+	tooManyStatusPageUrls := make([]string, maxMergedStatusPageUrls+1)
+	for i := range tooManyStatusPageUrls {
+		tooManyStatusPageUrls[i] = "statusPageUrl=https://status" + strconv.Itoa(i) + ".example.com"
+	}
+	tests := []struct {
+		query        string
+		expectedCode ErrorCode
+	}{
+		{query: "", expectedCode: ErrorCodeStatusPageUrlRequired},
+		{query: "statusPageUrl=status.example.com", expectedCode: ErrorCodeInvalidStatusPageUrl},
+		{query: "statusPageUrl=" + testStatusPageUrl + "&limit=-1", expectedCode: ErrorCodeInvalidLimit},
+		{query: "statusPageUrl=" + testStatusPageUrl + "&impact=severe", expectedCode: ErrorCodeInvalidImpact},
+		{query: strings.Join(tooManyStatusPageUrls, "&"), expectedCode: ErrorCodeInvalidStatusPageUrlCount},
+	}
+	for _, test := range tests {
+		recorder := getMergedIncidents(s, test.query)
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", test.query, recorder.Code)
+		}
+		var response ErrorResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || response.Code != test.expectedCode {
+			t.Fatalf("%s: expected the error code %s, got %s", test.query, test.expectedCode, recorder.Body.String())
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"sort"
+	"time"
+)
+
+type NavigateIncidentsResponse struct {
+	// Previous is the incident that started most recently before at, or null if no incident started before it
+	Previous *api.Incident `json:"previous"`
+	// Next is the incident that started soonest after at, or null if no incident started after it
+	Next      *api.Incident `json:"next"`
+	IsIndexed bool          `json:"isIndexed"`
+}
+
+// navigateIncidents is a handler for the /incidents/navigate endpoint.
+// It has required query parameters of statusPageUrl and at, an RFC3339 timestamp
+// It has optional query parameters of impact and excludeImpact, which filter the incidents the same way as they do for the /incidents endpoint
+// It returns the incidents that started immediately before and immediately after at, so that clients can step through the incidents of a timeline
+// Incidents that started exactly at at are neither, so stepping from the start time of an incident moves to its neighbours
+func (s *Server) navigateIncidents(context *gin.Context) {
+	statusPageUrl, ok := parseStatusPageUrlQuery(context)
+	if !ok {
+		return
+	}
+	atStr := context.Query("at")
+	if atStr == "" {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidTimeRange, "at is required")
+		return
+	}
+	at, err := time.Parse(time.RFC3339, atStr)
+	if err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidTimeRange, "at must be an RFC3339 timestamp")
+		return
+	}
+	impacts, ok := parseImpactsQuery(context)
+	if !ok {
+		return
+	}
+
+	statusPage, incidents, lookupErr := s.lookupStatusPageIncidents(context.Request.Context(), statusPageUrl, impacts)
+	if lookupErr != nil {
+		lookupErr.write(context)
+		return
+	}
+	if !statusPage.IsIndexed {
+		writeJSONWithETag(context, NavigateIncidentsResponse{IsIndexed: false})
+		return
+	}
+
+	previous, next := incidentsAround(incidents, at)
+	now := time.Now()
+	response := NavigateIncidentsResponse{IsIndexed: true}
+	if previous != nil {
+		flagged := flagIncidentAt(*previous, now, s.config.Incidents)
+		response.Previous = &flagged
+	}
+	if next != nil {
+		flagged := flagIncidentAt(*next, now, s.config.Incidents)
+		response.Next = &flagged
+	}
+	writeJSONWithETag(context, response)
+}
+
+// incidentsAround returns the incident that started most recently before at and the incident that started soonest after it, either is nil if there isn't one
+// The incidents must be sorted by sortOrderDescending, they are binary searched rather than scanned as the cached lists can be long
+func incidentsAround(incidents []api.Incident, at time.Time) (*api.Incident, *api.Incident) {
+	// The first incident that started before at is the most recent one, as the incidents are sorted newest first
+	var previous *api.Incident
+	if i := sort.Search(len(incidents), func(i int) bool { return incidents[i].StartTime.Before(at) }); i < len(incidents) {
+		previous = &incidents[i]
+	}
+	// The incident before the first one that didn't start after at is the last of the incidents that started after at, so the soonest of them
+	var next *api.Incident
+	if i := sort.Search(len(incidents), func(i int) bool { return !incidents[i].StartTime.After(at) }); i > 0 {
+		next = &incidents[i-1]
+	}
+	return previous, next
+}
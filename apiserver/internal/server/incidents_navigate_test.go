@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func getNavigateIncidents(s *Server, query string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents/navigate?"+query, nil)
+	s.navigateIncidents(testContext)
+	return recorder
+}
+
+func navigateQuery(at time.Time) string {
+	return "statusPageUrl=" + url.QueryEscape(testStatusPageUrl) + "&at=" + url.QueryEscape(at.Format(time.RFC3339))
+}
+
+func TestNavigateIncidentsReturnsTheNeighboursOfAt(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{
+		newTestIncident("first", api.ImpactMajor, start),
+		newTestIncident("second", api.ImpactMinor, start.Add(24*time.Hour)),
+		newTestIncident("third", api.ImpactCritical, start.Add(48*time.Hour)),
+		newTestIncident("fourth", api.ImpactMajor, start.Add(72*time.Hour)),
+	}})
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		previous string
+		next     string
+	}{
+		{name: "between incidents", at: start.Add(36 * time.Hour), previous: "second", next: "third"},
+		{name: "at the start of an incident", at: start.Add(24 * time.Hour), previous: "first", next: "third"},
+		{name: "before every incident", at: start.Add(-time.Hour), previous: "", next: "first"},
+		{name: "after every incident", at: start.Add(96 * time.Hour), previous: "fourth", next: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := getNavigateIncidents(s, navigateQuery(tt.at))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+			var response NavigateIncidentsResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if !response.IsIndexed {
+				t.Fatalf("expected the status page to be indexed")
+			}
+			assertNavigatedIncident(t, "previous", response.Previous, tt.previous)
+			assertNavigatedIncident(t, "next", response.Next, tt.next)
+		})
+	}
+}
+
+// assertNavigatedIncident checks that the incident is the test incident with the title, or that there is no incident if the title is empty
+func assertNavigatedIncident(t *testing.T, side string, incident *api.Incident, title string) {
+	t.Helper()
+	if title == "" {
+		if incident != nil {
+			t.Fatalf("expected no %s incident, got %s", side, incident.Title)
+		}
+		return
+	}
+	if incident == nil {
+		t.Fatalf("expected the %s incident to be %s, got null", side, title)
+	}
+	if incident.Title != title {
+		t.Fatalf("expected the %s incident to be %s, got %s", side, title, incident.Title)
+	}
+}
+
+func TestNavigateIncidentsReturnsNullsWithoutIncidents(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{})
+
+	recorder := getNavigateIncidents(s, navigateQuery(time.Now()))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response map[string]any
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	for _, side := range []string{"previous", "next"} {
+		value, ok := response[side]
+		if !ok || value != nil {
+			t.Fatalf("expected %s to be null, got %v", side, recorder.Body.String())
+		}
+	}
+}
+
+func TestNavigateIncidentsRequiresAt(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{})
+
+	for _, query := range []string{
+		"statusPageUrl=" + url.QueryEscape(testStatusPageUrl),
+		"statusPageUrl=" + url.QueryEscape(testStatusPageUrl) + "&at=yesterday",
+	} {
+		recorder := getNavigateIncidents(s, query)
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400 for %s, got %d: %s", query, recorder.Code, recorder.Body.String())
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+const (
+	defaultRecentIncidentsLimit = 50
+	// maxRecentIncidentsLimit is also the number of incidents that are cached, so every limit can be served from the cache
+	maxRecentIncidentsLimit = 500
+)
+
+type RecentIncidentsResponse struct {
+	Incidents []api.Incident `json:"incidents"`
+}
+
+// recentIncidents is a handler for the /incidents/all endpoint.
+// It returns the most recent incidents across every status page, most recent first, each incident has the statusPageUrl it belongs to
+// It has an optional query parameter of limit (default is defaultRecentIncidentsLimit), which is capped at maxRecentIncidentsLimit
+// It has optional query parameters of impact and excludeImpact, which filter the incidents the same way as they do for the /incidents endpoint
+// The result is cached for a short time as finding it reads the incidents of every status page
+func (s *Server) recentIncidents(context *gin.Context) {
+	ctx := context.Request.Context()
+	impacts, ok := parseImpactsQuery(context)
+	if !ok {
+		return
+	}
+
+	limit, ok := parseLimitQuery(context)
+	if !ok {
+		return
+	}
+	if limit != nil && *limit < 0 {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidLimit, "limit must be a non-negative integer")
+		return
+	}
+	recentLimit := defaultRecentIncidentsLimit
+	if limit != nil {
+		recentLimit = min(*limit, maxRecentIncidentsLimit)
+	}
+
+	cacheKey := impactFilterCacheKey(impacts)
+	incidents, found, err := s.recentIncidentsCache.Get(ctx, cacheKey)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Warn("failed to get recent incidents from cache", zap.Error(err))
+	}
+	if !found {
+		// Coalesce concurrent requests so that only one of them reads the recent incidents when the cache expires
+		result, err, _ := s.incidentDatabaseFetches.Do("recent:"+cacheKey, func() (interface{}, error) {
+			return s.getRecentIncidentsFromDatabase(ctx, impacts)
+		})
+		if err != nil {
+			utils.GetLogger(ctx, s.logger).Error("failed to get recent incidents from database", zap.Error(err))
+			writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get recent incidents from database")
+			return
+		}
+		incidents = result.([]api.Incident)
+		if err := s.recentIncidentsCache.Set(ctx, cacheKey, incidents, s.config.Cache.RecentIncidentsTTL); err != nil {
+			utils.GetLogger(ctx, s.logger).Warn("failed to set recent incidents in cache", zap.Error(err))
+		}
+	}
+
+	if len(incidents) > recentLimit {
+		incidents = incidents[:recentLimit]
+	}
+	incidents = s.flagIncidents(incidents)
+	if incidents == nil {
+		// Always return an array so that clients don't have to handle null
+		incidents = []api.Incident{}
+	}
+	writeJSONWithETag(context, RecentIncidentsResponse{Incidents: incidents})
+}
+
+// getRecentIncidentsFromDatabase gets the maxRecentIncidentsLimit most recent incidents that pass the impact filter, sorted by sortOrderDescending
+func (s *Server) getRecentIncidentsFromDatabase(ctx context.Context, impacts impactFilter) ([]api.Incident, error) {
+	includedImpacts := impacts.includedImpacts()
+	if includedImpacts != nil && len(includedImpacts) == 0 {
+		// Every impact is excluded
+		return []api.Incident{}, nil
+	}
+	incidents, err := s.dbClient.GetRecentIncidents(ctx, maxRecentIncidentsLimit, includedImpacts)
+	if err != nil {
+		return nil, err
+	}
+	return sortIncidents(incidents, sortOrderDescending), nil
+}
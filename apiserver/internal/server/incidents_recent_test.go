@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func getRecentIncidents(t *testing.T, s *Server, query string) []api.Incident {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents/all?"+query, nil)
+	s.recentIncidents(testContext)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("%s: expected status 200, got %d", query, recorder.Code)
+	}
+	var response RecentIncidentsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	return response.Incidents
+}
+
+func TestRecentIncidentsAcrossStatusPages(t *testing.T) {
+	now := time.Now()
+	other := api.NewIncident("other", nil, nil, now.Add(-30*time.Minute), nil, nil, "https://status.other.com/incidents/other", api.ImpactCritical, "https://status.other.com")
+	dbClient := &fakeDbClient{incidents: []api.Incident{
+		newTestIncident("a", api.ImpactMajor, now.Add(-time.Hour)),
+		newTestIncident("b", api.ImpactMaintenance, now),
+		other,
+	}}
+	s := newTestServerWithDb(t, dbClient)
+
+	incidents := getRecentIncidents(t, s, "")
+	if len(incidents) != 3 || incidents[0].DeepLink != testStatusPageUrl+"/incidents/b" || incidents[1].StatusPageUrl != "https://status.other.com" || incidents[2].DeepLink != testStatusPageUrl+"/incidents/a" {
+		t.Fatalf("expected the incidents of every status page most recent first, got %+v", incidents)
+	}
+
+	if incidents := getRecentIncidents(t, s, "limit=1"); len(incidents) != 1 || incidents[0].Impact != api.ImpactMaintenance {
+		t.Fatalf("expected the most recent incident, got %+v", incidents)
+	}
+	if calls := dbClient.getRecentIncidentsCalls.Load(); calls != 1 {
+		t.Fatalf("expected different limits to be served from the cache, got %d database calls", calls)
+	}
+
+	if incidents := getRecentIncidents(t, s, "excludeImpact=maintenance"); len(incidents) != 2 || incidents[0].Impact != api.ImpactCritical {
+		t.Fatalf("expected maintenance to be excluded, got %+v", incidents)
+	}
+	if incidents := getRecentIncidents(t, s, "impact=major,minor"); len(incidents) != 1 || incidents[0].Impact != api.ImpactMajor {
+		t.Fatalf("expected only the major incident, got %+v", incidents)
+	}
+	if incidents := getRecentIncidents(t, s, "excludeImpact=critical,major,minor,maintenance,none"); len(incidents) != 0 {
+		t.Fatalf("expected no incidents when every impact is excluded, got %+v", incidents)
+	}
+}
+
+func TestRecentIncidentsCapsLimit(t *testing.T) {
+	now := time.Now()
+	dbClient := &fakeDbClient{}
+	for i := 0; i < maxRecentIncidentsLimit+10; i++ {
+		dbClient.incidents = append(dbClient.incidents, newTestIncident(strconv.Itoa(i), api.ImpactMinor, now.Add(-time.Duration(i)*time.Minute)))
+	}
+	s := newTestServerWithDb(t, dbClient)
+
+	if incidents := getRecentIncidents(t, s, ""); len(incidents) != defaultRecentIncidentsLimit {
+		t.Fatalf("expected %d incidents by default, got %d", defaultRecentIncidentsLimit, len(incidents))
+	}
+	if incidents := getRecentIncidents(t, s, "limit=100000"); len(incidents) != maxRecentIncidentsLimit {
+		t.Fatalf("expected the limit to be capped at %d, got %d", maxRecentIncidentsLimit, len(incidents))
+	}
+
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents/all?limit=-1", nil)
+	s.recentIncidents(testContext)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for a negative limit, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/db"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// defaultSearchAllLimit is the number of incidents returned by a search of every status page when no limit is given
+const defaultSearchAllLimit = 100
+
+type SearchAllIncidentsResponse struct {
+	// Incidents are the matching incidents of every status page, each has the statusPageUrl of the status page it belongs to
+	Incidents []api.Incident `json:"incidents"`
+	// NextCursor is the cursor to pass to get the next page of incidents, it is empty if there are no more incidents
+	NextCursor string `json:"nextCursor"`
+}
+
+// searchAllIncidents is a handler for the /incidents/search endpoint.
+// It searches the incidents of every status page, e.g. to find which status pages had a dns incident last week
+// It has a required query parameter of q, which only returns incidents whose title or description contains q case insensitively
+// It has required query parameters of from and to (RFC3339), which filter the incidents by their start time,
+// the range can be at most the configured max search window so that a search doesn't scan every incident
+// It has an optional query parameter of limit (default is 100), which is the maximum number of incidents to return, limits above the configured max limit are clamped to it
+// It has an optional query parameter of cursor, which is the nextCursor returned by a previous request, used to get the next page of incidents
+// The incidents are returned most recent first
+func (s *Server) searchAllIncidents(context *gin.Context) {
+	ctx := context.Request.Context()
+	query := context.Query("q")
+	if query == "" {
+		writeError(context, http.StatusBadRequest, ErrorCodeQueryRequired, "q is required")
+		return
+	}
+
+	timeRange, ok := parseTimeRangeQuery(context)
+	if !ok {
+		return
+	}
+	if timeRange.from == nil || timeRange.to == nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidTimeRange, "from and to are required")
+		return
+	}
+	if timeRange.includeOngoing {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidTimeRange, "includeOngoing can't be used when searching every status page")
+		return
+	}
+	if timeRange.to.Sub(*timeRange.from) > s.config.Incidents.MaxSearchWindow {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidTimeRange, "from and to must be at most "+s.config.Incidents.MaxSearchWindow.String()+" apart")
+		return
+	}
+
+	limit, ok := parseLimitQuery(context)
+	if !ok {
+		return
+	}
+	if limit != nil && *limit < 0 {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidLimit, "limit must be a non-negative integer")
+		return
+	}
+	pageLimit := min(defaultSearchAllLimit, s.config.Incidents.MaxLimit)
+	if limit != nil {
+		pageLimit = min(*limit, s.config.Incidents.MaxLimit)
+	}
+
+	search := db.IncidentsSearch{Query: query, From: *timeRange.from, To: *timeRange.to, Limit: pageLimit + 1}
+	if cursorStr := context.Query("cursor"); cursorStr != "" {
+		cursor, err := decodeIncidentsCursor(cursorStr)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidCursor, "invalid cursor")
+			return
+		}
+		search.AfterStartTime = cursor.StartTime
+		search.AfterDeepLink = cursor.DeepLink
+	}
+
+	// One more incident than the limit is read to know whether there is another page
+	incidents, err := s.dbClient.SearchAllIncidents(ctx, search)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to search incidents in database", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to search incidents in database")
+		return
+	}
+	response := SearchAllIncidentsResponse{}
+	if len(incidents) > pageLimit {
+		incidents = incidents[:pageLimit]
+		if pageLimit > 0 {
+			response.NextCursor = encodeIncidentsCursor(incidents[pageLimit-1])
+		}
+	}
+	response.Incidents = s.presentIncidents(incidents, true, false, nil)
+	if response.Incidents == nil {
+		// Always return an array so that clients don't have to handle null
+		response.Incidents = []api.Incident{}
+	}
+	writeJSONWithETag(context, response)
+}
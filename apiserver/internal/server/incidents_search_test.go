@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func searchAllIncidents(s *Server, query string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents/search?"+query, nil)
+	s.searchAllIncidents(testContext)
+	return recorder
+}
+
+func TestSearchAllIncidentsSearchesEveryStatusPage(t *testing.T) {
+	start := time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+	otherStatusPageUrl := "https://status.other.com"
+	dns := newTestIncident("dns", api.ImpactMajor, start)
+	dns.Title = "DNS resolution failures"
+	otherDns := api.NewIncident("Degraded dns lookups", nil, nil, start.Add(time.Hour), nil, nil, otherStatusPageUrl+"/incidents/1", api.ImpactMinor, otherStatusPageUrl)
+	olderDns := newTestIncident("older-dns", api.ImpactMajor, start.Add(-30*24*time.Hour))
+	olderDns.Title = "DNS outage"
+	unrelated := newTestIncident("api", api.ImpactMajor, start.Add(2*time.Hour))
+	unrelated.Title = "API errors"
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{dns, otherDns, olderDns, unrelated}})
+
+	recorder := searchAllIncidents(s, "q=dns&from=2024-03-01T00:00:00Z&to=2024-03-08T00:00:00Z&limit=1")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var firstPage SearchAllIncidentsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &firstPage); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(firstPage.Incidents) != 1 || firstPage.Incidents[0].StatusPageUrl != otherStatusPageUrl || firstPage.NextCursor == "" {
+		t.Fatalf("expected the most recent match of the other status page and a cursor, got %+v", firstPage)
+	}
+
+	recorder = searchAllIncidents(s, "q=dns&from=2024-03-01T00:00:00Z&to=2024-03-08T00:00:00Z&limit=1&cursor="+firstPage.NextCursor)
+	var secondPage SearchAllIncidentsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &secondPage); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(secondPage.Incidents) != 1 || secondPage.Incidents[0].DeepLink != dns.DeepLink || secondPage.Incidents[0].StatusPageUrl != testStatusPageUrl || secondPage.NextCursor != "" {
+		t.Fatalf("expected the match of the test status page on the last page, got %+v", secondPage)
+	}
+}
+
+func TestSearchAllIncidentsRequiresABoundedWindow(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{})
+	tests := []struct {
+		query        string
+		expectedCode ErrorCode
+	}{
+		{query: "from=2024-03-01T00:00:00Z&to=2024-03-08T00:00:00Z", expectedCode: ErrorCodeQueryRequired},
+		{query: "q=dns", expectedCode: ErrorCodeInvalidTimeRange},
+		{query: "q=dns&from=2024-03-01T00:00:00Z", expectedCode: ErrorCodeInvalidTimeRange},
+		{query: "q=dns&from=2024-01-01T00:00:00Z&to=2024-03-08T00:00:00Z", expectedCode: ErrorCodeInvalidTimeRange},
+		{query: "q=dns&from=2024-03-01T00:00:00Z&to=2024-03-08T00:00:00Z&includeOngoing=true", expectedCode: ErrorCodeInvalidTimeRange},
+		{query: "q=dns&from=2024-03-01T00:00:00Z&to=2024-03-08T00:00:00Z&limit=-1", expectedCode: ErrorCodeInvalidLimit},
+		{query: "q=dns&from=2024-03-01T00:00:00Z&to=2024-03-08T00:00:00Z&cursor=invalid", expectedCode: ErrorCodeInvalidCursor},
+	}
+	for _, test := range tests {
+		recorder := searchAllIncidents(s, test.query)
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", test.query, recorder.Code)
+		}
+		var response ErrorResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: failed to unmarshal response: %v", test.query, err)
+		}
+		if response.Code != test.expectedCode {
+			t.Fatalf("%s: expected code %s, got %+v", test.query, test.expectedCode, response)
+		}
+	}
+}
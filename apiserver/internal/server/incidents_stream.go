@@ -0,0 +1,140 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The events sent on the incident streams, the data of each is the incident as json
+const (
+	streamEventCreated  = "created"
+	streamEventUpdated  = "updated"
+	streamEventResolved = "resolved"
+)
+
+// incidentsStream is a handler for the /incidents/stream endpoint.
+// It has a required query parameter of statusPageUrl
+// It opens a server-sent events stream that sends a created, updated or resolved event with the incident whenever the scraper
+// changes one of the incidents of the status page, see db.DbClient.ListenForIncidentChanges
+// A heartbeat comment is sent at the configured interval so that proxies don't close the stream for being idle
+// Each client can have up to the configured max streams open at once, it returns a 429 for any more
+// If the status page is not known to statusphere, it returns a 404.
+func (s *Server) incidentsStream(context *gin.Context) {
+	statusPageUrl, ok := parseStatusPageUrlQuery(context)
+	if !ok {
+		return
+	}
+	client := rateLimitKey(context)
+	if !s.streams.acquire(client) {
+		writeError(context, http.StatusTooManyRequests, ErrorCodeTooManyStreams, "too many incident streams are open")
+		return
+	}
+	defer s.streams.release(client)
+
+	// The changes are waited for before the incidents are read so that a change while they're being read isn't missed
+	changed := s.changeBroadcaster.wait(statusPageUrl)
+	_, incidents, ok := s.getStatusPageIncidents(context, statusPageUrl, impactFilter{})
+	if !ok {
+		return
+	}
+
+	context.Header("Content-Type", "text/event-stream")
+	context.Header("Cache-Control", "no-cache")
+	// Stops nginx from buffering the events
+	context.Header("X-Accel-Buffering", "no")
+	context.Status(http.StatusOK)
+	context.Writer.Flush()
+
+	ctx := context.Request.Context()
+	heartbeat := time.NewTicker(s.config.Incidents.StreamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.shuttingDown.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := io.WriteString(context.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-changed:
+			changed = s.changeBroadcaster.wait(statusPageUrl)
+			_, current, lookupErr := s.lookupStatusPageIncidents(ctx, statusPageUrl, impactFilter{})
+			if lookupErr != nil {
+				utils.GetLogger(ctx, s.logger).Warn("failed to get the changed incidents for the stream", zap.String("error", lookupErr.message))
+				continue
+			}
+			for _, event := range diffStreamIncidents(incidents, s.flagIncidents(current)) {
+				context.SSEvent(event.name, event.incident)
+			}
+			incidents = current
+		}
+		context.Writer.Flush()
+	}
+}
+
+type streamEvent struct {
+	name     string
+	incident api.Incident
+}
+
+// diffStreamIncidents returns the events of the incidents that are new, resolved or updated since the previous incidents
+// Incidents are matched by their deep link, an incident is updated if its updated time changed, see api.Incident.UpdatedAt
+func diffStreamIncidents(previous []api.Incident, current []api.Incident) []streamEvent {
+	previousByDeepLink := make(map[string]api.Incident, len(previous))
+	for _, incident := range previous {
+		previousByDeepLink[incident.DeepLink] = incident
+	}
+	var events []streamEvent
+	for _, incident := range current {
+		before, ok := previousByDeepLink[incident.DeepLink]
+		switch {
+		case !ok:
+			events = append(events, streamEvent{name: streamEventCreated, incident: incident})
+		case before.IsOngoing() && !incident.IsOngoing():
+			events = append(events, streamEvent{name: streamEventResolved, incident: incident})
+		case !before.UpdatedAt.Equal(incident.UpdatedAt):
+			events = append(events, streamEvent{name: streamEventUpdated, incident: incident})
+		}
+	}
+	return events
+}
+
+// streamLimiter limits the streams that each client has open at once
+type streamLimiter struct {
+	mu     sync.Mutex
+	max    int
+	counts map[string]int
+}
+
+func newStreamLimiter(max int) *streamLimiter {
+	return &streamLimiter{max: max, counts: make(map[string]int)}
+}
+
+// acquire returns true if the client can open another stream, the stream must be released once it is closed
+func (l *streamLimiter) acquire(client string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counts[client] >= l.max {
+		return false
+	}
+	l.counts[client]++
+	return true
+}
+
+// release frees the stream of the client, clients without any streams are removed so that the counts don't grow forever
+func (l *streamLimiter) release(client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counts[client]--
+	if l.counts[client] <= 0 {
+		delete(l.counts, client)
+	}
+}
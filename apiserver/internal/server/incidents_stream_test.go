@@ -0,0 +1,129 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/apiserver/internal/cache"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openIncidentsStream opens an incident stream of the test status page from a test http server that serves the stream handler
+func openIncidentsStream(t *testing.T, s *Server) (*http.Response, *bufio.Reader) {
+	t.Helper()
+	r := gin.New()
+	r.GET("/incidents/stream", s.incidentsStream)
+	httpServer := httptest.NewServer(r)
+	t.Cleanup(httpServer.Close)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, httpServer.URL+"/incidents/stream?statusPageUrl="+url.QueryEscape(testStatusPageUrl), nil)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("failed to open the stream: %v", err)
+	}
+	t.Cleanup(func() { _ = response.Body.Close() })
+	return response, bufio.NewReader(response.Body)
+}
+
+// readUntil reads lines from the stream until one starts with the prefix, returning it
+func readUntil(t *testing.T, reader *bufio.Reader, prefix string) string {
+	t.Helper()
+	lines := make(chan string)
+	go func() {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				close(lines)
+				return
+			}
+			if strings.HasPrefix(line, prefix) {
+				lines <- line
+				return
+			}
+		}
+	}()
+	select {
+	case line, ok := <-lines:
+		if !ok {
+			t.Fatalf("expected a line starting with %q before the stream ended", prefix)
+		}
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected a line starting with %q", prefix)
+	}
+	return ""
+}
+
+func TestIncidentsStreamSendsChangedIncidents(t *testing.T) {
+	now := time.Now()
+	ongoing := newTestIncident("a", api.ImpactMajor, now.Add(-time.Hour))
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{ongoing}})
+	response, reader := openIncidentsStream(t, s)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", response.StatusCode)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %s", contentType)
+	}
+
+	resolved := ongoing
+	resolved.EndTime = &now
+	created := newTestIncident("b", api.ImpactMinor, now)
+	waitForWaiter(t, s, testStatusPageUrl)
+	_ = s.incidentCache.Set(context.Background(), testStatusPageUrl, []api.Incident{resolved, created}, cache.DefaultExpiration)
+	s.changeBroadcaster.publish(testStatusPageUrl)
+
+	events := map[string]string{}
+	for len(events) < 2 {
+		event := strings.TrimSpace(strings.TrimPrefix(readUntil(t, reader, "event:"), "event:"))
+		data := readUntil(t, reader, "data:")
+		events[event] = data
+	}
+	if !strings.Contains(events[streamEventResolved], `"deepLink":"`+ongoing.DeepLink+`"`) {
+		t.Fatalf("expected a resolved event for %s, got %q", ongoing.DeepLink, events[streamEventResolved])
+	}
+	if !strings.Contains(events[streamEventCreated], `"deepLink":"`+created.DeepLink+`"`) {
+		t.Fatalf("expected a created event for %s, got %q", created.DeepLink, events[streamEventCreated])
+	}
+}
+
+func TestIncidentsStreamSendsHeartbeats(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{})
+	s.config.Incidents.StreamHeartbeatInterval = 10 * time.Millisecond
+	_, reader := openIncidentsStream(t, s)
+	readUntil(t, reader, ": heartbeat")
+}
+
+func TestIncidentsStreamLimitsTheStreamsOfEachClient(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{})
+	s.streams = newStreamLimiter(1)
+	first, _ := openIncidentsStream(t, s)
+	if first.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", first.StatusCode)
+	}
+	second, _ := openIncidentsStream(t, s)
+	if second.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", second.StatusCode)
+	}
+
+	// The stream is released once the client disconnects
+	_ = first.Body.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.streams.mu.Lock()
+		open := len(s.streams.counts)
+		s.streams.mu.Unlock()
+		if open == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected the stream to be released once the client disconnected")
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/db"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// streamedIncidentsBuffer is the number of incidents read ahead of the response from the database
+const streamedIncidentsBuffer = 64
+
+// streamedIncidentsTrailer is the rest of IncidentsResponse, which is written after the streamed incidents
+type streamedIncidentsTrailer struct {
+	IsIndexed    bool       `json:"isIndexed"`
+	NextCursor   string     `json:"nextCursor"`
+	OngoingCount int        `json:"ongoingCount"`
+	TotalCount   int        `json:"totalCount"`
+	Pagination   Pagination `json:"pagination"`
+}
+
+// streamedIncidentsPage returns the page of the incidents matching the query to stream from the database, see writeStreamedIncidents
+// The incidents are only streamed if the status page has more incidents than the stream threshold, otherwise it returns false
+// and they're all read and cached as usual
+func (s *Server) streamedIncidentsPage(ctx context.Context, query db.IncidentsQuery) (*incidentsPage, bool) {
+	if s.config.Incidents.StreamThreshold < 0 {
+		return nil, false
+	}
+	// Every incident of the status page is read to be cached, so it's the number of them that matters rather than the number matching the query
+	all, err := s.dbClient.CountIncidents(ctx, db.IncidentsQuery{StatusPageUrl: query.StatusPageUrl})
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Warn("failed to count the incidents, reading all of them", zap.Error(err))
+		return nil, false
+	}
+	if all.Total <= s.config.Incidents.StreamThreshold {
+		return nil, false
+	}
+	matching := all
+	if query.Impacts != nil || query.From != nil || query.To != nil {
+		matching, err = s.dbClient.CountIncidents(ctx, query)
+		if err != nil {
+			utils.GetLogger(ctx, s.logger).Warn("failed to count the matching incidents, reading all of them", zap.Error(err))
+			return nil, false
+		}
+	}
+	return &incidentsPage{totalCount: matching.Total, ongoingCount: matching.Ongoing, streamQuery: &query}, true
+}
+
+// writeStreamedIncidents writes the response of the incidents endpoint with the incidents of the stream query of the page,
+// writing each incident as it is read from the database so that they're never all in memory at once, see db.DbClient.StreamIncidents
+// The incidents are presented as they are by writeIncidentsResponse, but the response doesn't have an ETag or Last-Modified
+// as they'd need every incident before the response is started
+// If the stream fails once the response has started then the response is cut short, which clients see as invalid json
+func (s *Server) writeStreamedIncidents(context *gin.Context, page incidentsPage, includeEvents bool, includeImpactHistory bool, location *time.Location, fields []string) {
+	ctx := context.Request.Context()
+	incidents := make(chan api.Incident, streamedIncidentsBuffer)
+	streamErr := make(chan error, 1)
+	go func() {
+		streamErr <- s.dbClient.StreamIncidents(ctx, *page.streamQuery, incidents)
+	}()
+
+	// The first incident is read before the response is started so that a query that fails straight away returns an error
+	first, more := <-incidents
+	if !more {
+		if err := <-streamErr; err != nil {
+			utils.GetLogger(ctx, s.logger).Error("failed to stream incidents from database", zap.Error(err))
+			writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get incidents from database")
+			return
+		}
+	}
+
+	context.Header("X-Total-Count", strconv.Itoa(page.totalCount))
+	context.Header("Content-Type", "application/json; charset=utf-8")
+	context.Status(http.StatusOK)
+	if _, err := context.Writer.WriteString(`{"incidents":[`); err != nil {
+		return
+	}
+	written := 0
+	var last api.Incident
+	for incident := first; more; incident, more = <-incidents {
+		marshalled, err := s.marshalStreamedIncident(incident, includeEvents, includeImpactHistory, location, fields)
+		if err != nil {
+			utils.GetLogger(ctx, s.logger).Error("failed to marshal a streamed incident", zap.Error(err))
+			return
+		}
+		if written > 0 {
+			marshalled = append([]byte{','}, marshalled...)
+		}
+		if _, err := context.Writer.Write(marshalled); err != nil {
+			return
+		}
+		written++
+		last = incident
+	}
+	if err := <-streamErr; err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to stream incidents from database", zap.Error(err))
+		return
+	}
+
+	trailer := streamedIncidentsTrailer{IsIndexed: true, OngoingCount: page.ongoingCount, TotalCount: page.totalCount}
+	if written > 0 && page.totalCount > written {
+		trailer.NextCursor = encodeIncidentsCursor(last)
+	}
+	trailer.Pagination = Pagination{NextCursor: trailer.NextCursor, TotalCount: trailer.TotalCount, Limit: page.streamQuery.Limit}
+	marshalled, err := json.Marshal(trailer)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to marshal the streamed incidents response", zap.Error(err))
+		return
+	}
+	// The trailer is an object, so its opening brace is replaced to continue the response
+	marshalled[0] = ','
+	_, _ = context.Writer.WriteString("]")
+	_, _ = context.Writer.Write(marshalled)
+}
+
+// marshalStreamedIncident marshals an incident of writeStreamedIncidents
+func (s *Server) marshalStreamedIncident(incident api.Incident, includeEvents bool, includeImpactHistory bool, location *time.Location, fields []string) ([]byte, error) {
+	presented := s.presentIncidents([]api.Incident{incident}, includeEvents, includeImpactHistory, location)
+	if fields != nil {
+		projected, err := projectIncidents(presented, fields)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(projected[0])
+	}
+	return json.Marshal(presented[0])
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/db"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIncidentsStreamsLargeStatusPagesWithoutCachingThem(t *testing.T) {
+	now := time.Now()
+	var incidents []api.Incident
+	for i := 0; i < 5; i++ {
+		incidents = append(incidents, newTestIncident(strconv.Itoa(i), api.ImpactMajor, now.Add(-time.Duration(i)*time.Hour)))
+	}
+	incidents = append(incidents, newTestIncident("minor", api.ImpactMinor, now.Add(-time.Minute)))
+	ended := now.Add(-time.Minute)
+	incidents[1].EndTime = &ended
+
+	for _, query := range []string{"", "impact=major", "order=asc&includeEvents=false", "fields=deepLink,impact", "tz=America/New_York"} {
+		cached := newTestServerWithDb(t, &fakeDbClient{incidents: incidents})
+		cached.config.Incidents.StreamThreshold = -1
+		expected := getIncidents(cached, query)
+
+		dbClient := &fakeDbClient{incidents: incidents}
+		s := newTestServerWithDb(t, dbClient)
+		s.config.Incidents.StreamThreshold = 5
+		recorder := getIncidents(s, query)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status 200 for %q, got %d: %s", query, recorder.Code, recorder.Body.String())
+		}
+		if dbClient.streamIncidentsCalls.Load() != 1 || dbClient.getIncidentsCalls.Load() != 0 {
+			t.Fatalf("expected the incidents to be streamed for %q rather than all read", query)
+		}
+		var expectedBody, body map[string]interface{}
+		if err := json.Unmarshal(expected.Body.Bytes(), &expectedBody); err != nil {
+			t.Fatalf("failed to unmarshal the cached response: %v", err)
+		}
+		// The cached incidents are limited to the default limit, which is more than there are, but the streamed ones aren't limited
+		delete(expectedBody["pagination"].(map[string]interface{}), "limit")
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to unmarshal the streamed response for %q: %v", query, err)
+		}
+		if !reflect.DeepEqual(body, expectedBody) {
+			t.Fatalf("expected the streamed response for %q to be the same as the cached one\nexpected %s\ngot      %s", query, expected.Body.String(), recorder.Body.String())
+		}
+		if recorder.Header().Get("X-Total-Count") != expected.Header().Get("X-Total-Count") {
+			t.Fatalf("expected X-Total-Count %s for %q, got %s", expected.Header().Get("X-Total-Count"), query, recorder.Header().Get("X-Total-Count"))
+		}
+		if _, found, _ := s.incidentCache.Get(context.Background(), testStatusPageUrl); found {
+			t.Fatalf("expected the streamed incidents not to be cached")
+		}
+	}
+}
+
+func TestIncidentsStreamsEveryIncidentWithoutALimit(t *testing.T) {
+	now := time.Now()
+	var incidents []api.Incident
+	for i := 0; i < 5; i++ {
+		incidents = append(incidents, newTestIncident(strconv.Itoa(i), api.ImpactMajor, now.Add(-time.Duration(i)*time.Hour)))
+	}
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: incidents})
+	s.config.Incidents.StreamThreshold = 1
+	s.config.Incidents.DefaultLimit = 2
+
+	var response IncidentsResponse
+	if err := json.Unmarshal(getIncidents(s, "").Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	// The default limit isn't applied to the streamed incidents, as they're streamed so that every incident can be returned
+	if len(response.Incidents) != 5 || response.TotalCount != 5 || response.NextCursor != "" {
+		t.Fatalf("expected every one of the 5 incidents without a cursor, got %+v", response)
+	}
+	if response.Pagination.NextCursor != "" || response.Pagination.TotalCount != 5 || response.Pagination.Limit != nil {
+		t.Fatalf("expected the pagination of the streamed incidents without a limit, got %+v", response.Pagination)
+	}
+}
+
+func TestIncidentsStreamCutShortIsInvalidJson(t *testing.T) {
+	now := time.Now()
+	var incidents []api.Incident
+	for i := 0; i < 5; i++ {
+		incidents = append(incidents, newTestIncident(strconv.Itoa(i), api.ImpactMajor, now.Add(-time.Duration(i)*time.Hour)))
+	}
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: incidents, streamIncidentsErr: errors.New("connection reset"), streamIncidentsErrAfter: 2})
+	s.config.Incidents.StreamThreshold = 1
+
+	// The response has already started by the time the stream fails, so it can only be cut short
+	recorder := getIncidents(s, "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	var response IncidentsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err == nil {
+		t.Fatalf("expected the response cut short to be invalid json, got %s", recorder.Body.String())
+	}
+	if !strings.HasPrefix(recorder.Body.String(), `{"incidents":[`) || strings.Count(recorder.Body.String(), `"deepLink"`) != 2 {
+		t.Fatalf("expected the 2 incidents streamed before the failure, got %s", recorder.Body.String())
+	}
+}
+
+func TestIncidentsDoesNotStreamSmallStatusPages(t *testing.T) {
+	dbClient := &fakeDbClient{incidents: []api.Incident{newTestIncident("a", api.ImpactMajor, time.Now())}}
+	s := newTestServerWithDb(t, dbClient)
+	s.config.Incidents.StreamThreshold = 1
+	if recorder := getIncidents(s, ""); recorder.Code != http.StatusOK || recorder.Header().Get("ETag") == "" {
+		t.Fatalf("expected a cached response with an ETag, got %d", recorder.Code)
+	}
+	if dbClient.streamIncidentsCalls.Load() != 0 {
+		t.Fatalf("expected the incidents not to be streamed")
+	}
+	if _, found, _ := s.incidentCache.Get(context.Background(), testStatusPageUrl); !found {
+		t.Fatalf("expected the incidents to be cached")
+	}
+}
+
+func TestIncidentsReturnsAnErrorIfTheStreamFailsToStart(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{
+		incidents:          []api.Incident{newTestIncident("a", api.ImpactMajor, time.Now()), newTestIncident("b", api.ImpactMajor, time.Now())},
+		streamIncidentsErr: errors.New("connection refused"),
+	})
+	s.config.Incidents.StreamThreshold = 1
+	recorder := getIncidents(s, "")
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d", recorder.Code)
+	}
+	var response ErrorResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || response.Code != ErrorCodeInternal {
+		t.Fatalf("expected an internal error, got %s", recorder.Body.String())
+	}
+}
+
+// generatedDbClient is a fakeDbClient whose status page has count incidents, which are generated as they're read
+// so that the incidents the benchmark is measuring aren't already in memory
+type generatedDbClient struct {
+	*fakeDbClient
+	count int
+}
+
+func (g *generatedDbClient) generate(i int) api.Incident {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Add(-time.Duration(i) * time.Minute)
+	description := "The service was degraded"
+	return api.NewIncident(fmt.Sprintf("Incident %d", i), nil, nil, start, &start, &description, fmt.Sprintf("%s/incidents/%d", testStatusPageUrl, i), api.ImpactMinor, testStatusPageUrl)
+}
+
+func (g *generatedDbClient) GetIncidents(ctx context.Context, statusPageUrl string) ([]api.Incident, error) {
+	incidents := make([]api.Incident, 0, g.count)
+	for i := 0; i < g.count; i++ {
+		incidents = append(incidents, g.generate(i))
+	}
+	return incidents, nil
+}
+
+func (g *generatedDbClient) CountIncidents(ctx context.Context, query db.IncidentsQuery) (db.IncidentCounts, error) {
+	return db.IncidentCounts{Total: g.count}, nil
+}
+
+func (g *generatedDbClient) StreamIncidents(ctx context.Context, query db.IncidentsQuery, incidents chan<- api.Incident) error {
+	defer close(incidents)
+	for i := 0; i < g.count && (query.Limit == nil || i < *query.Limit); i++ {
+		incidents <- g.generate(i)
+	}
+	return nil
+}
+
+// discardResponseWriter is a response writer that throws away the body, so that the body isn't in memory either
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (d *discardResponseWriter) Header() http.Header         { return d.header }
+func (d *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardResponseWriter) WriteHeader(int)             {}
+
+// BenchmarkIncidentsStreaming reports the peak heap used by a request without a limit for every incident of status pages
+// of increasing sizes. The peak heap stays flat when the incidents are streamed, and grows with the status page when they're read and cached
+func BenchmarkIncidentsStreaming(b *testing.B) {
+	gin.SetMode(gin.TestMode)
+	for _, streamed := range []bool{true, false} {
+		for _, count := range []int{1000, 10000, 50000} {
+			b.Run(fmt.Sprintf("streamed=%t/incidents=%d", streamed, count), func(b *testing.B) {
+				dbClient := &generatedDbClient{fakeDbClient: &fakeDbClient{}, count: count}
+				s := newTestServerWithDb(b, dbClient.fakeDbClient)
+				s.dbClient = dbClient
+				s.config.Incidents.MaxLimit = count
+				s.config.Incidents.DefaultLimit = count
+				s.config.Incidents.StreamThreshold = 0
+				if !streamed {
+					s.config.Incidents.StreamThreshold = -1
+				}
+				request := httptest.NewRequest(http.MethodGet, "/api/v1/incidents?statusPageUrl="+url.QueryEscape(testStatusPageUrl), nil)
+
+				var peak uint64
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					_ = s.incidentCache.Delete(context.Background(), testStatusPageUrl)
+					runtime.GC()
+					peak = max(peak, peakHeapDuring(func() {
+						testContext, _ := gin.CreateTestContext(&discardResponseWriter{header: http.Header{}})
+						testContext.Request = request
+						s.incidents(testContext)
+					}))
+				}
+				b.ReportMetric(float64(peak), "peak-heap-B")
+			})
+		}
+	}
+}
+
+// peakHeapDuring returns the most heap, above what it was before, that was in use while f ran, sampled every millisecond
+func peakHeapDuring(f func()) uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	baseline := stats.HeapAlloc
+	var peak atomic.Uint64
+	sample := func() {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		if stats.HeapAlloc > baseline && stats.HeapAlloc-baseline > peak.Load() {
+			peak.Store(stats.HeapAlloc - baseline)
+		}
+	}
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				sample()
+			}
+		}
+	}()
+	f()
+	sample()
+	close(done)
+	<-sampled
+	return peak.Load()
+}
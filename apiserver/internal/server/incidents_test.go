@@ -0,0 +1,1125 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/apiserver/internal/cache"
+	"github.com/metoro-io/statusphere/apiserver/internal/config"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testStatusPageUrl = "https://status.example.com"
+
+func newTestServer(t testing.TB) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	return NewServer(zap.NewNop(), nil, nil, config.Config{})
+}
+
+// newTestServerWithDb creates a server backed by a fake database containing a single indexed status page
+func newTestServerWithDb(t testing.TB, dbClient *fakeDbClient) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	statusPage := api.StatusPage{URL: testStatusPageUrl, IsIndexed: true}
+	dbClient.statusPages = append(dbClient.statusPages, statusPage)
+	s := NewServer(zap.NewNop(), dbClient, nil, config.Config{})
+	s.setCachedStatusPage(context.Background(), statusPage)
+	return s
+}
+
+// getIncidents calls the incidents handler with the given query string
+func getIncidents(s *Server, query string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents?statusPageUrl="+url.QueryEscape(testStatusPageUrl)+"&"+query, nil)
+	s.incidents(testContext)
+	return recorder
+}
+
+func newTestIncident(deepLink string, impact api.Impact, startTime time.Time) api.Incident {
+	return api.NewIncident(deepLink, nil, nil, startTime, nil, nil, testStatusPageUrl+"/incidents/"+deepLink, impact, testStatusPageUrl)
+}
+
+func TestGetIncidentsFromCacheDeduplicatesImpacts(t *testing.T) {
+	s := newTestServer(t)
+	now := time.Now()
+	_ = s.incidentCache.Set(context.Background(), testStatusPageUrl, []api.Incident{
+		newTestIncident("a", api.ImpactMajor, now),
+		newTestIncident("b", api.ImpactMinor, now.Add(-time.Hour)),
+		newTestIncident("c", api.ImpactCritical, now.Add(-2*time.Hour)),
+		newTestIncident("d", api.ImpactMajor, now.Add(-3*time.Hour)),
+	}, cache.DefaultExpiration)
+
+	incidents, found, err := s.getIncidentsFromCache(context.Background(), testStatusPageUrl, includeImpacts(api.ImpactMinor, api.ImpactMajor, api.ImpactMajor, api.ImpactMinor))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !found {
+		t.Fatalf("expected incidents to be found in the cache")
+	}
+	if len(incidents) != 3 {
+		t.Fatalf("expected 3 incidents, got %d", len(incidents))
+	}
+}
+
+func TestGetIncidentsFromCacheExcludesImpacts(t *testing.T) {
+	s := newTestServer(t)
+	now := time.Now()
+	_ = s.incidentCache.Set(context.Background(), testStatusPageUrl, []api.Incident{
+		newTestIncident("a", api.ImpactMajor, now),
+		newTestIncident("b", api.ImpactMaintenance, now.Add(-time.Hour)),
+		newTestIncident("c", api.ImpactCritical, now.Add(-2*time.Hour)),
+		newTestIncident("d", api.ImpactNone, now.Add(-3*time.Hour)),
+		newTestIncident("e", api.ImpactMajor, now.Add(-4*time.Hour)),
+	}, cache.DefaultExpiration)
+
+	incidents, found, err := s.getIncidentsFromCache(context.Background(), testStatusPageUrl, excludeImpacts(api.ImpactMaintenance, api.ImpactNone, api.ImpactMaintenance))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !found {
+		t.Fatalf("expected incidents to be found in the cache")
+	}
+	if len(incidents) != 3 {
+		t.Fatalf("expected 3 incidents, got %d", len(incidents))
+	}
+	for _, incident := range incidents {
+		if incident.Impact == api.ImpactMaintenance || incident.Impact == api.ImpactNone {
+			t.Fatalf("expected %s incidents to be excluded", incident.Impact)
+		}
+	}
+}
+
+func TestIncidentsExcludeImpact(t *testing.T) {
+	now := time.Now()
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{
+		newTestIncident("a", api.ImpactMajor, now),
+		newTestIncident("b", api.ImpactMaintenance, now.Add(-time.Hour)),
+		newTestIncident("c", api.ImpactMinor, now.Add(-2*time.Hour)),
+	}})
+	tests := []struct {
+		query          string
+		expectedStatus int
+		expectedCount  int
+	}{
+		{query: "excludeImpact=maintenance", expectedStatus: http.StatusOK, expectedCount: 2},
+		{query: "excludeImpact=maintenance,minor", expectedStatus: http.StatusOK, expectedCount: 1},
+		{query: "excludeImpact=maintenance,minor,major", expectedStatus: http.StatusOK, expectedCount: 0},
+		{query: "excludeImpact=severe", expectedStatus: http.StatusBadRequest},
+		{query: "impact=major&excludeImpact=maintenance", expectedStatus: http.StatusBadRequest},
+		{query: "type=maintenance", expectedStatus: http.StatusOK, expectedCount: 1},
+		{query: "type=incident", expectedStatus: http.StatusOK, expectedCount: 2},
+		{query: "type=outage", expectedStatus: http.StatusBadRequest},
+		{query: "type=incident&excludeImpact=minor", expectedStatus: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		recorder := getIncidents(s, test.query)
+		if recorder.Code != test.expectedStatus {
+			t.Fatalf("%s: expected status %d, got %d", test.query, test.expectedStatus, recorder.Code)
+		}
+		if test.expectedStatus != http.StatusOK {
+			continue
+		}
+		var response IncidentsResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if len(response.Incidents) != test.expectedCount {
+			t.Fatalf("%s: expected %d incidents, got %+v", test.query, test.expectedCount, response.Incidents)
+		}
+	}
+}
+
+func TestIncidentsFiltersImpactsOnColdCache(t *testing.T) {
+	now := time.Now()
+	dbClient := &fakeDbClient{incidents: []api.Incident{
+		newTestIncident("a", api.ImpactMaintenance, now),
+		newTestIncident("b", api.ImpactMajor, now.Add(-time.Hour)),
+		newTestIncident("c", api.ImpactMaintenance, now.Add(-2*time.Hour)),
+		newTestIncident("d", api.ImpactCritical, now.Add(-3*time.Hour)),
+	}}
+	s := newTestServerWithDb(t, dbClient)
+
+	recorder := getIncidents(s, "impact=major,critical")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	if calls := dbClient.getIncidentsCalls.Load(); calls != 1 {
+		t.Fatalf("expected the incidents to be fetched from the database, got %d calls", calls)
+	}
+	var response IncidentsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(response.Incidents) != 2 || response.Incidents[0].Impact != api.ImpactMajor || response.Incidents[1].Impact != api.ImpactCritical {
+		t.Fatalf("expected the 2 matching incidents, got %+v", response)
+	}
+
+	// The cache must still have every incident so that requests with other impacts can use it
+	cached, found, err := s.getIncidentsFromCache(context.Background(), testStatusPageUrl, impactFilter{})
+	if err != nil || !found || len(cached) != 4 {
+		t.Fatalf("expected every incident to be cached, got %+v", cached)
+	}
+}
+
+func TestIncidentsIgnoresWhitespaceAndEmptyImpacts(t *testing.T) {
+	now := time.Now()
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{
+		newTestIncident("a", api.ImpactMajor, now),
+		newTestIncident("b", api.ImpactMinor, now.Add(-time.Hour)),
+		newTestIncident("c", api.ImpactCritical, now.Add(-2*time.Hour)),
+	}})
+
+	for _, impact := range []string{"major, minor", "major,minor,", "major,,minor", " major ,minor"} {
+		recorder := getIncidents(s, "impact="+url.QueryEscape(impact))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status 200 for impact=%q, got %d: %s", impact, recorder.Code, recorder.Body.String())
+		}
+		var response IncidentsResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if len(response.Incidents) != 2 || response.Incidents[0].Impact != api.ImpactMajor || response.Incidents[1].Impact != api.ImpactMinor {
+			t.Fatalf("expected the major and minor incidents for impact=%q, got %+v", impact, response.Incidents)
+		}
+	}
+}
+
+func TestIncidentsReturnsEmptyArrayWhenNoIncidentsMatch(t *testing.T) {
+	s := newTestServer(t)
+	s.setCachedStatusPage(context.Background(), api.StatusPage{URL: testStatusPageUrl, IsIndexed: true})
+	_ = s.incidentCache.Set(context.Background(), testStatusPageUrl, []api.Incident{
+		newTestIncident("a", api.ImpactMinor, time.Now()),
+	}, cache.DefaultExpiration)
+
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents?statusPageUrl="+url.QueryEscape(testStatusPageUrl)+"&impact=critical", nil)
+	s.incidents(testContext)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	if !strings.Contains(recorder.Body.String(), `"incidents":[]`) {
+		t.Fatalf("expected an empty incidents array, got %s", recorder.Body.String())
+	}
+}
+
+func TestGetIncidentsFromCacheWithRedis(t *testing.T) {
+	miniRedis := miniredis.RunT(t)
+	s := NewServer(zap.NewNop(), nil, redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}), config.Config{})
+	now := time.Now().UTC()
+	_ = s.incidentCache.Set(context.Background(), testStatusPageUrl, []api.Incident{
+		newTestIncident("a", api.ImpactMajor, now),
+		newTestIncident("b", api.ImpactMinor, now.Add(-time.Hour)),
+	}, cache.DefaultExpiration)
+
+	incidents, found, err := s.getIncidentsFromCache(context.Background(), testStatusPageUrl, includeImpacts(api.ImpactMinor))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !found || len(incidents) != 1 || incidents[0].DeepLink != testStatusPageUrl+"/incidents/b" {
+		t.Fatalf("expected the minor incident to be found in the cache, got %v", incidents)
+	}
+
+	// If redis is unavailable then the lookup reports a miss with an error so the handler can fall back to the database
+	miniRedis.Close()
+	_, found, err = s.getIncidentsFromCache(context.Background(), testStatusPageUrl, impactFilter{})
+	if err == nil || found {
+		t.Fatalf("expected a cache miss with an error when redis is unavailable")
+	}
+}
+
+func TestIncidentsCoalescesConcurrentDatabaseFetches(t *testing.T) {
+	dbClient := &fakeDbClient{
+		incidents:         []api.Incident{newTestIncident("a", api.ImpactMinor, time.Now())},
+		getIncidentsDelay: 100 * time.Millisecond,
+	}
+	s := newTestServerWithDb(t, dbClient)
+
+	const numRequests = 20
+	var wg sync.WaitGroup
+	codes := make([]int, numRequests)
+	for i := 0; i < numRequests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = getIncidents(s, "").Code
+		}(i)
+	}
+	wg.Wait()
+
+	for _, code := range codes {
+		if code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", code)
+		}
+	}
+	if calls := dbClient.getIncidentsCalls.Load(); calls != 1 {
+		t.Fatalf("expected the database to be queried once, got %d", calls)
+	}
+}
+
+func TestIncidentsTotalCountIsIndependentOfLimit(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{
+		newTestIncident("a", api.ImpactMajor, start),
+		newTestIncident("b", api.ImpactMajor, start.Add(time.Hour)),
+		newTestIncident("c", api.ImpactMajor, start.Add(2*time.Hour)),
+		newTestIncident("d", api.ImpactMajor, start.Add(3*time.Hour)),
+	}})
+	// Only b and c are within the time range
+	timeRangeQuery := "from=" + url.QueryEscape(start.Add(time.Minute).Format(time.RFC3339)) + "&to=" + url.QueryEscape(start.Add(2*time.Hour).Format(time.RFC3339))
+
+	for _, limitQuery := range []string{"", "&limit=0", "&limit=1", "&limit=10"} {
+		recorder := getIncidents(s, timeRangeQuery+limitQuery)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", recorder.Code)
+		}
+		var response IncidentsResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if response.TotalCount != 2 {
+			t.Fatalf("expected a total count of 2 with query %q, got %d", limitQuery, response.TotalCount)
+		}
+		if header := recorder.Header().Get("X-Total-Count"); header != "2" {
+			t.Fatalf("expected an X-Total-Count header of 2 with query %q, got %q", limitQuery, header)
+		}
+	}
+}
+
+func TestIncidentsActiveAtAnInstant(t *testing.T) {
+	at := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	endingAt := newTestIncident("ending-at", api.ImpactMajor, at.Add(-time.Hour))
+	endingAt.EndTime = &at
+	endingAfter := newTestIncident("ending-after", api.ImpactMajor, at.Add(-time.Hour))
+	endTime := at.Add(time.Second)
+	endingAfter.EndTime = &endTime
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{
+		endingAt,
+		endingAfter,
+		newTestIncident("starting-at", api.ImpactMinor, at),
+		newTestIncident("starting-after", api.ImpactMinor, at.Add(time.Second)),
+		newTestIncident("ongoing", api.ImpactCritical, at.Add(-24*time.Hour)),
+	}})
+
+	recorder := getIncidents(s, "at="+url.QueryEscape(at.Format(time.RFC3339)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	var response IncidentsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	// The start of an incident is inclusive and its end is exclusive
+	var deepLinks []string
+	for _, incident := range response.Incidents {
+		deepLinks = append(deepLinks, strings.TrimPrefix(incident.DeepLink, testStatusPageUrl+"/incidents/"))
+	}
+	if strings.Join(deepLinks, ",") != "starting-at,ending-after,ongoing" || response.TotalCount != 3 {
+		t.Fatalf("expected the incidents active at %s, got %v", at, deepLinks)
+	}
+}
+
+func TestIncidentsReturnsNotModifiedWhenETagMatches(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{
+		newTestIncident("a", api.ImpactMajor, start),
+		newTestIncident("b", api.ImpactMinor, start.Add(time.Hour)),
+	}})
+	getIncidentsIfNoneMatch := func(query string, ifNoneMatch string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		testContext, _ := gin.CreateTestContext(recorder)
+		testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents?statusPageUrl="+url.QueryEscape(testStatusPageUrl)+"&"+query, nil)
+		testContext.Request.Header.Set("If-None-Match", ifNoneMatch)
+		s.incidents(testContext)
+		return recorder
+	}
+
+	first := getIncidents(s, "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected a 200 with an ETag, got %d with %q", first.Code, etag)
+	}
+
+	notModified := getIncidentsIfNoneMatch("", etag)
+	if notModified.Code != http.StatusNotModified {
+		t.Fatalf("expected status 304, got %d", notModified.Code)
+	}
+	if notModified.Body.Len() != 0 {
+		t.Fatalf("expected no body, got %q", notModified.Body.String())
+	}
+
+	changed := getIncidentsIfNoneMatch("impact=major", etag)
+	if changed.Code != http.StatusOK {
+		t.Fatalf("expected status 200 when the response changed, got %d", changed.Code)
+	}
+	if changed.Header().Get("ETag") == etag {
+		t.Fatalf("expected the ETag to change when the response changed")
+	}
+}
+
+func TestIncidentsCanBePrettyPrinted(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{newTestIncident("a", api.ImpactMajor, time.Now())}})
+
+	compact := getIncidents(s, "")
+	pretty := getIncidents(s, "pretty=true")
+	if pretty.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", pretty.Code)
+	}
+	if strings.Contains(compact.Body.String(), "\n") {
+		t.Fatalf("expected the response to be compact by default, got %s", compact.Body.String())
+	}
+	if !strings.HasPrefix(pretty.Body.String(), "{\n  \"incidents\": [") {
+		t.Fatalf("expected the response to be indented, got %s", pretty.Body.String())
+	}
+	var compactResponse, prettyResponse IncidentsResponse
+	if err := json.Unmarshal(compact.Body.Bytes(), &compactResponse); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if err := json.Unmarshal(pretty.Body.Bytes(), &prettyResponse); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if !reflect.DeepEqual(compactResponse, prettyResponse) || compact.Header().Get("ETag") != pretty.Header().Get("ETag") {
+		t.Fatalf("expected the same response and ETag whether or not it is indented")
+	}
+	if strings.Contains(getIncidents(s, "pretty=maybe").Body.String(), "\n") {
+		t.Fatalf("expected the response to be compact when pretty isn't a boolean")
+	}
+}
+
+func TestIncidentsReturnsNotModifiedWhenNotModifiedSince(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	resolvedAt := start.Add(3 * time.Hour)
+	major := newTestIncident("a", api.ImpactMajor, start)
+	minor := newTestIncident("b", api.ImpactMinor, start.Add(time.Hour))
+	minor.EndTime = &resolvedAt
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{major, minor}})
+	// The major incident would have become stale long after it started, which would also modify the incidents
+	s.config.Incidents.StaleThreshold = -1
+	getIncidentsIfModifiedSince := func(query string, ifModifiedSince time.Time) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		testContext, _ := gin.CreateTestContext(recorder)
+		testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents?statusPageUrl="+url.QueryEscape(testStatusPageUrl)+"&"+query, nil)
+		testContext.Request.Header.Set("If-Modified-Since", ifModifiedSince.Format(http.TimeFormat))
+		s.incidents(testContext)
+		return recorder
+	}
+
+	if lastModified := getIncidents(s, "").Header().Get("Last-Modified"); lastModified != resolvedAt.Format(http.TimeFormat) {
+		t.Fatalf("expected a Last-Modified of when the minor incident was resolved, got %q", lastModified)
+	}
+	if lastModified := getIncidents(s, "impact=major").Header().Get("Last-Modified"); lastModified != start.Format(http.TimeFormat) {
+		t.Fatalf("expected a Last-Modified of when the major incident started, got %q", lastModified)
+	}
+
+	if recorder := getIncidentsIfModifiedSince("", resolvedAt); recorder.Code != http.StatusNotModified || recorder.Body.Len() != 0 {
+		t.Fatalf("expected status 304 without a body, got %d", recorder.Code)
+	}
+	if recorder := getIncidentsIfModifiedSince("", resolvedAt.Add(-time.Second)); recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200 when modified since, got %d", recorder.Code)
+	}
+	// The major incident hasn't changed since it started, even though the unfiltered incidents have
+	if recorder := getIncidentsIfModifiedSince("impact=major", start.Add(time.Minute)); recorder.Code != http.StatusNotModified {
+		t.Fatalf("expected status 304 for the filtered incidents, got %d", recorder.Code)
+	}
+	if recorder := getIncidentsIfModifiedSince("", start.Add(time.Minute)); recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for the unfiltered incidents, got %d", recorder.Code)
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents?statusPageUrl="+url.QueryEscape(testStatusPageUrl), nil)
+	testContext.Request.Header.Set("If-Modified-Since", resolvedAt.Format(http.TimeFormat))
+	testContext.Request.Header.Set("If-None-Match", `W/"stale"`)
+	s.incidents(testContext)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200 when the ETag doesn't match, got %d", recorder.Code)
+	}
+}
+
+func TestIncidentsFiltersByComponent(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	api1 := newTestIncident("api", api.ImpactMajor, start)
+	api1.Components = []string{"API"}
+	apiEu := newTestIncident("api-eu", api.ImpactMajor, start.Add(time.Hour))
+	apiEu.Components = []string{"Dashboard", "API (EU)"}
+	dashboard := newTestIncident("dashboard", api.ImpactMajor, start.Add(2*time.Hour))
+	dashboard.Components = []string{"Dashboard"}
+	oldApi := newTestIncident("old-api", api.ImpactMajor, start.Add(-48*time.Hour))
+	oldApi.Components = []string{"API"}
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{api1, apiEu, dashboard, oldApi}})
+	fromQuery := "&from=" + url.QueryEscape(start.Add(-time.Hour).Format(time.RFC3339))
+
+	tests := []struct {
+		query              string
+		expectedDeepLinks  []string
+		expectedStatusCode int
+	}{
+		{query: "component=api" + fromQuery, expectedDeepLinks: []string{api1.DeepLink}, expectedStatusCode: http.StatusOK},
+		{query: "component=api&componentMatch=substring" + fromQuery, expectedDeepLinks: []string{apiEu.DeepLink, api1.DeepLink}, expectedStatusCode: http.StatusOK},
+		{query: "component=api", expectedDeepLinks: []string{api1.DeepLink, oldApi.DeepLink}, expectedStatusCode: http.StatusOK},
+		{query: "component=api&componentMatch=fuzzy", expectedStatusCode: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		recorder := getIncidents(s, test.query)
+		if recorder.Code != test.expectedStatusCode {
+			t.Fatalf("%s: expected status %d, got %d", test.query, test.expectedStatusCode, recorder.Code)
+		}
+		if test.expectedStatusCode != http.StatusOK {
+			continue
+		}
+		var response IncidentsResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		var deepLinks []string
+		for _, incident := range response.Incidents {
+			deepLinks = append(deepLinks, incident.DeepLink)
+		}
+		if strings.Join(deepLinks, ",") != strings.Join(test.expectedDeepLinks, ",") {
+			t.Fatalf("%s: expected %v, got %v", test.query, test.expectedDeepLinks, deepLinks)
+		}
+	}
+}
+
+func TestIncidentsSearchesTitlesAndDescriptions(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	description := "The primary Database failed over to the secondary database"
+	inDescription := newTestIncident("description", api.ImpactMajor, start)
+	inDescription.Description = &description
+	inTitle := newTestIncident("title", api.ImpactMinor, start.Add(time.Hour))
+	inTitle.Title = "Database latency"
+	unrelated := newTestIncident("unrelated", api.ImpactMajor, start.Add(2*time.Hour))
+	unrelated.Title = "Dashboard unavailable"
+	dbClient := &fakeDbClient{incidents: []api.Incident{inDescription, inTitle, unrelated}}
+	s := newTestServerWithDb(t, dbClient)
+
+	getSearch := func(query string) IncidentsResponse {
+		recorder := getIncidents(s, query)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", query, recorder.Code)
+		}
+		var response IncidentsResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		return response
+	}
+
+	// The incidents aren't cached yet, so the search is done by the database
+	uncached := getSearch("q=DATABASE&impact=major")
+	if dbClient.searchIncidentsCalls.Load() != 1 || dbClient.getIncidentsCalls.Load() != 0 {
+		t.Fatalf("expected the uncached search to be done by the database")
+	}
+	if len(uncached.Incidents) != 1 || uncached.Incidents[0].DeepLink != inDescription.DeepLink || uncached.MatchCount != 2 {
+		t.Fatalf("unexpected uncached search response %+v", uncached)
+	}
+
+	// Populate the cache, then the search is done in memory
+	getIncidents(s, "")
+	cached := getSearch("q=database")
+	if dbClient.searchIncidentsCalls.Load() != 1 {
+		t.Fatalf("expected the cached search to not query the database")
+	}
+	if len(cached.Incidents) != 2 || cached.Incidents[0].DeepLink != inTitle.DeepLink || cached.MatchCount != 3 {
+		t.Fatalf("unexpected cached search response %+v", cached)
+	}
+}
+
+func TestIncidentsFiltersByTag(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	dns := newTestIncident("dns", api.ImpactMajor, start)
+	dns.Tags = []string{"network"}
+	replication := newTestIncident("replication", api.ImpactMajor, start.Add(time.Hour))
+	replication.Tags = []string{"database", "network"}
+	login := newTestIncident("login", api.ImpactMinor, start.Add(2*time.Hour))
+	login.Tags = []string{"auth"}
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{dns, replication, login, newTestIncident("untagged", api.ImpactMinor, start)}})
+
+	tests := []struct {
+		query             string
+		expectedDeepLinks []string
+	}{
+		{query: "tag=network", expectedDeepLinks: []string{replication.DeepLink, dns.DeepLink}},
+		{query: "tag=Database", expectedDeepLinks: []string{replication.DeepLink}},
+		{query: "tag=network&impact=minor", expectedDeepLinks: nil},
+		{query: "tag=storage", expectedDeepLinks: nil},
+	}
+	for _, test := range tests {
+		recorder := getIncidents(s, test.query)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", test.query, recorder.Code)
+		}
+		var response IncidentsResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		var deepLinks []string
+		for _, incident := range response.Incidents {
+			deepLinks = append(deepLinks, incident.DeepLink)
+		}
+		if strings.Join(deepLinks, ",") != strings.Join(test.expectedDeepLinks, ",") || response.TotalCount != len(test.expectedDeepLinks) {
+			t.Fatalf("%s: expected %v, got %v", test.query, test.expectedDeepLinks, deepLinks)
+		}
+	}
+}
+
+func TestIncidentsCanExcludeEvents(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	incident := newTestIncident("outage", api.ImpactMajor, start)
+	incident.Events = api.IncidentEventArray{
+		api.NewIncidentEvent("Investigating", "We are looking into it", start),
+		api.NewIncidentEvent("Resolved", "It is fixed", start.Add(time.Hour)),
+	}
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{incident}})
+
+	tests := []struct {
+		query          string
+		expectedEvents int
+	}{
+		{query: "", expectedEvents: 2},
+		{query: "includeEvents=true", expectedEvents: 2},
+		{query: "includeEvents=false", expectedEvents: 0},
+		{query: "includeUpdates=true", expectedEvents: 2},
+		{query: "includeUpdates=false", expectedEvents: 0},
+		{query: "includeUpdates=false&includeEvents=true", expectedEvents: 0},
+	}
+	for _, test := range tests {
+		recorder := getIncidents(s, test.query)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", test.query, recorder.Code)
+		}
+		var response IncidentsResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if len(response.Incidents) != 1 || len(response.Incidents[0].Events) != test.expectedEvents {
+			t.Fatalf("%s: expected %d events, got %+v", test.query, test.expectedEvents, response.Incidents)
+		}
+	}
+
+	// Excluding the events must not remove them from the cached incidents
+	cached, found, err := s.getIncidentsFromCache(context.Background(), testStatusPageUrl, impactFilter{})
+	if err != nil || !found || len(cached[0].Events) != 2 {
+		t.Fatalf("expected the cached incident to keep its events, got %+v", cached)
+	}
+
+	for _, query := range []string{"includeEvents=maybe", "includeUpdates=maybe"} {
+		if recorder := getIncidents(s, query); recorder.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", query, recorder.Code)
+		}
+	}
+}
+
+func TestIncidentsCanIncludeTheImpactHistory(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	incident := newTestIncident("outage", api.ImpactMajor, start)
+	incident.ImpactHistory = api.ImpactChangeArray{
+		{Impact: api.ImpactMinor, Time: start},
+		{Impact: api.ImpactMajor, Time: start.Add(time.Hour)},
+	}
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{incident}})
+
+	tests := []struct {
+		query           string
+		expectedHistory int
+	}{
+		{query: "", expectedHistory: 0},
+		{query: "includeImpactHistory=false", expectedHistory: 0},
+		{query: "includeImpactHistory=true", expectedHistory: 2},
+	}
+	for _, test := range tests {
+		recorder := getIncidents(s, test.query)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", test.query, recorder.Code)
+		}
+		var response IncidentsResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if len(response.Incidents) != 1 || len(response.Incidents[0].ImpactHistory) != test.expectedHistory {
+			t.Fatalf("%s: expected %d impact changes, got %+v", test.query, test.expectedHistory, response.Incidents)
+		}
+		if response.Incidents[0].Impact != api.ImpactMajor {
+			t.Fatalf("%s: expected the impact to stay the latest one, got %s", test.query, response.Incidents[0].Impact)
+		}
+	}
+
+	// Excluding the impact history must not remove it from the cached incidents
+	cached, found, err := s.getIncidentsFromCache(context.Background(), testStatusPageUrl, impactFilter{})
+	if err != nil || !found || len(cached[0].ImpactHistory) != 2 {
+		t.Fatalf("expected the cached incident to keep its impact history, got %+v", cached)
+	}
+
+	if recorder := getIncidents(s, "includeImpactHistory=maybe"); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for an invalid includeImpactHistory, got %d", recorder.Code)
+	}
+}
+
+func TestIncidentsSelectsFields(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	incident := newTestIncident("outage", api.ImpactMajor, start)
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{incident}})
+
+	recorder := getIncidents(s, "fields=deepLink,impact,startTime")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	var response struct {
+		Incidents  []map[string]interface{} `json:"incidents"`
+		IsIndexed  bool                     `json:"isIndexed"`
+		TotalCount int                      `json:"totalCount"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if !response.IsIndexed || response.TotalCount != 1 || len(response.Incidents) != 1 {
+		t.Fatalf("expected one indexed incident, got %s", recorder.Body.String())
+	}
+	expected := map[string]interface{}{"deepLink": incident.DeepLink, "impact": string(api.ImpactMajor), "startTime": start.Format(time.RFC3339)}
+	if !reflect.DeepEqual(response.Incidents[0], expected) {
+		t.Fatalf("expected %v, got %v", expected, response.Incidents[0])
+	}
+
+	if recorder := getIncidents(s, "fields=deepLink,severity"); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for an unknown field, got %d", recorder.Code)
+	}
+}
+
+func TestIncidentsFlagsStaleIncidents(t *testing.T) {
+	now := time.Now()
+	threshold := 24 * time.Hour
+	resolvedAt := now.Add(-time.Hour)
+	stale := newTestIncident("stale", api.ImpactMajor, now.Add(-threshold-time.Minute))
+	active := newTestIncident("active", api.ImpactMajor, now.Add(-threshold+time.Minute))
+	resolved := newTestIncident("resolved", api.ImpactMajor, now.Add(-2*threshold))
+	resolved.EndTime = &resolvedAt
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{stale, active, resolved}})
+	s.config.Incidents.StaleThreshold = threshold
+
+	recorder := getIncidents(s, "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	var response IncidentsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	flags := make(map[string]bool)
+	for _, incident := range response.Incidents {
+		flags[incident.Title] = incident.Stale
+	}
+	expected := map[string]bool{"stale": true, "active": false, "resolved": false}
+	if !reflect.DeepEqual(flags, expected) {
+		t.Fatalf("expected the stale flags %v, got %v", expected, flags)
+	}
+	// The incident became stale after the others last changed
+	if lastModified := recorder.Header().Get("Last-Modified"); lastModified != stale.StartTime.Add(threshold).UTC().Format(http.TimeFormat) {
+		t.Fatalf("expected a Last-Modified of when the incident became stale, got %q", lastModified)
+	}
+
+	// Flagging the incidents must not modify the cached incidents
+	cached, _, _ := s.getIncidentsFromCache(context.Background(), testStatusPageUrl, impactFilter{})
+	for _, incident := range cached {
+		if incident.Stale {
+			t.Fatalf("expected the cached incidents not to be flagged, got %+v", incident)
+		}
+	}
+
+	s.config.Incidents.StaleThreshold = -1
+	if err := json.Unmarshal(getIncidents(s, "").Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	for _, incident := range response.Incidents {
+		if incident.Stale {
+			t.Fatalf("expected no incidents to be stale with a negative threshold, got %+v", incident)
+		}
+	}
+}
+
+func TestIncidentsFlagsSlaBreaches(t *testing.T) {
+	now := time.Now()
+	breachedEnd := now.Add(-time.Hour)
+	breached := newTestIncident("breached", api.ImpactCritical, breachedEnd.Add(-2*time.Hour))
+	breached.EndTime = &breachedEnd
+	withinEnd := now.Add(-time.Hour)
+	within := newTestIncident("within", api.ImpactCritical, withinEnd.Add(-30*time.Minute))
+	within.EndTime = &withinEnd
+	ongoing := newTestIncident("ongoing", api.ImpactMajor, now.Add(-5*time.Hour))
+	untargeted := newTestIncident("untargeted", api.ImpactMinor, now.Add(-5*time.Hour))
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{breached, within, ongoing, untargeted}})
+	s.config.Incidents.SlaTargets = map[string]time.Duration{"critical": time.Hour, "major": 4 * time.Hour}
+
+	recorder := getIncidents(s, "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	var response IncidentsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	flags := make(map[string]bool)
+	for _, incident := range response.Incidents {
+		flags[incident.Title] = incident.SlaBreached
+	}
+	expected := map[string]bool{"breached": true, "within": false, "ongoing": true, "untargeted": false}
+	if !reflect.DeepEqual(flags, expected) {
+		t.Fatalf("expected the sla breached flags %v, got %v", expected, flags)
+	}
+	// The ongoing incident breached its sla after the others last changed
+	if lastModified := recorder.Header().Get("Last-Modified"); lastModified != ongoing.StartTime.Add(4*time.Hour).UTC().Format(http.TimeFormat) {
+		t.Fatalf("expected a Last-Modified of when the ongoing incident breached its sla, got %q", lastModified)
+	}
+}
+
+func TestIncidentsConvertsTimestampsToTimeZone(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	incident := newTestIncident("outage", api.ImpactMajor, start)
+	incident.EndTime = &end
+	incident.Events = api.IncidentEventArray{api.NewIncidentEvent("Resolved", "It is fixed", end)}
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{incident}})
+
+	tests := []struct {
+		query             string
+		expectedStartTime string
+		expectedEndTime   string
+	}{
+		{query: "", expectedStartTime: "2024-03-01T14:00:00Z", expectedEndTime: "2024-03-01T16:00:00Z"},
+		{query: "tz=America/New_York", expectedStartTime: "2024-03-01T09:00:00-05:00", expectedEndTime: "2024-03-01T11:00:00-05:00"},
+		{query: "tz=Asia/Kolkata", expectedStartTime: "2024-03-01T19:30:00+05:30", expectedEndTime: "2024-03-01T21:30:00+05:30"},
+	}
+	for _, test := range tests {
+		recorder := getIncidents(s, test.query)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", test.query, recorder.Code)
+		}
+		var response struct {
+			Incidents []struct {
+				StartTime string `json:"startTime"`
+				EndTime   string `json:"endTime"`
+				Events    []struct {
+					Time string `json:"time"`
+				} `json:"events"`
+			} `json:"incidents"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if len(response.Incidents) != 1 {
+			t.Fatalf("%s: expected 1 incident, got %+v", test.query, response.Incidents)
+		}
+		returned := response.Incidents[0]
+		if returned.StartTime != test.expectedStartTime || returned.EndTime != test.expectedEndTime || returned.Events[0].Time != test.expectedEndTime {
+			t.Fatalf("%s: unexpected timestamps %+v", test.query, returned)
+		}
+	}
+
+	// Converting the timestamps must not modify the cached incidents
+	cached, found, err := s.getIncidentsFromCache(context.Background(), testStatusPageUrl, impactFilter{})
+	if err != nil || !found || cached[0].StartTime.Location() != time.UTC || cached[0].EndTime.Location() != time.UTC {
+		t.Fatalf("expected the cached incident to stay in UTC, got %+v", cached)
+	}
+
+	for _, tz := range []string{"Mars/Olympus_Mons", "Local"} {
+		if recorder := getIncidents(s, "tz="+tz); recorder.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400 for tz %s, got %d", tz, recorder.Code)
+		}
+	}
+}
+
+func TestIncidentsReadsOnlyPageFromDatabaseOnColdCache(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	resolved := newTestIncident("b", api.ImpactMajor, start.Add(time.Hour))
+	resolved.EndTime = &end
+	dbClient := &fakeDbClient{incidents: []api.Incident{
+		newTestIncident("a", api.ImpactMajor, start),
+		resolved,
+		newTestIncident("c", api.ImpactMaintenance, start.Add(2*time.Hour)),
+		newTestIncident("d", api.ImpactCritical, start.Add(3*time.Hour)),
+	}}
+	s := newTestServerWithDb(t, dbClient)
+	query := "excludeImpact=maintenance&limit=2&to=" + url.QueryEscape(start.Add(3*time.Hour).Format(time.RFC3339))
+
+	cold := getIncidents(s, query)
+	if cold.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", cold.Code)
+	}
+	if calls := dbClient.queryIncidentsCalls.Load(); calls != 1 {
+		t.Fatalf("expected a page to be queried from the database, got %d queries", calls)
+	}
+	var coldResponse IncidentsResponse
+	if err := json.Unmarshal(cold.Body.Bytes(), &coldResponse); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(coldResponse.Incidents) != 2 || coldResponse.Incidents[0].Title != "d" || coldResponse.Incidents[1].Title != "b" {
+		t.Fatalf("expected the 2 most recent incidents that aren't maintenance, got %+v", coldResponse.Incidents)
+	}
+	if coldResponse.TotalCount != 3 || coldResponse.OngoingCount != 2 || coldResponse.NextCursor == "" || cold.Header().Get("X-Total-Count") != "3" {
+		t.Fatalf("expected the counts of all 3 matching incidents and a next cursor, got %+v", coldResponse)
+	}
+
+	// All the incidents are cached in the background, after which the same request is served from the cache with the same response
+	for {
+		if _, found, _ := s.getIncidentsFromCache(context.Background(), testStatusPageUrl, impactFilter{}); found {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	warm := getIncidents(s, query)
+	if dbClient.queryIncidentsCalls.Load() != 1 {
+		t.Fatalf("expected the warm request to be served from the cache")
+	}
+	if warm.Body.String() != cold.Body.String() {
+		t.Fatalf("expected the same response from the cache as from the database, got %s and %s", warm.Body.String(), cold.Body.String())
+	}
+
+	// The next page continues from the cursor returned by the database
+	next := getIncidents(s, query+"&cursor="+coldResponse.NextCursor)
+	var nextResponse IncidentsResponse
+	if err := json.Unmarshal(next.Body.Bytes(), &nextResponse); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(nextResponse.Incidents) != 1 || nextResponse.Incidents[0].Title != "a" || nextResponse.NextCursor != "" {
+		t.Fatalf("expected the last matching incident, got %+v", nextResponse)
+	}
+}
+
+func TestIncidentsIncludesDeletedIncidentsForAdmins(t *testing.T) {
+	now := time.Now()
+	deletedAt := now.Add(-time.Minute)
+	deleted := newTestIncident("deleted", api.ImpactMajor, now)
+	deleted.DeletedAt = &deletedAt
+	dbClient := &fakeDbClient{incidents: []api.Incident{newTestIncident("kept", api.ImpactMajor, now.Add(-time.Hour)), deleted}}
+	s := newTestServerWithDb(t, dbClient)
+	s.config.AdminApiKey = "secret"
+
+	getIncidentsAsAdmin := func(query string, apiKey string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		testContext, _ := gin.CreateTestContext(recorder)
+		testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents?statusPageUrl="+url.QueryEscape(testStatusPageUrl)+"&"+query, nil)
+		testContext.Request.Header.Set("Authorization", "Bearer "+apiKey)
+		s.incidents(testContext)
+		return recorder
+	}
+	deepLinks := func(recorder *httptest.ResponseRecorder) []string {
+		t.Helper()
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", recorder.Code)
+		}
+		var response IncidentsResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		var links []string
+		for _, incident := range response.Incidents {
+			links = append(links, incident.DeepLink)
+		}
+		return links
+	}
+
+	if links := deepLinks(getIncidents(s, "")); len(links) != 1 || links[0] != testStatusPageUrl+"/incidents/kept" {
+		t.Fatalf("expected the deleted incident to be excluded, got %v", links)
+	}
+	if links := deepLinks(getIncidentsAsAdmin("includeDeleted=true", "secret")); len(links) != 2 || links[0] != testStatusPageUrl+"/incidents/deleted" {
+		t.Fatalf("expected the deleted incident to be included for admins, got %v", links)
+	}
+	if strings.Contains(getIncidents(s, "").Body.String(), "deletedAt") {
+		t.Fatalf("expected incidents that aren't deleted to have no deletedAt")
+	}
+	if links := deepLinks(getIncidentsAsAdmin("includeDeleted=true&impact=minor", "secret")); len(links) != 0 {
+		t.Fatalf("expected the impact filter to apply to deleted incidents, got %v", links)
+	}
+
+	if recorder := getIncidents(s, "includeDeleted=true"); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 without the admin api key, got %d", recorder.Code)
+	}
+	if recorder := getIncidentsAsAdmin("includeDeleted=true", "wrong"); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 with the wrong api key, got %d", recorder.Code)
+	}
+	if recorder := getIncidents(s, "includeDeleted=maybe"); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for an invalid includeDeleted, got %d", recorder.Code)
+	}
+}
+
+func TestIncidentsCanonicalizesStatusPageUrl(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{newTestIncident("a", api.ImpactMajor, time.Now())}})
+
+	for _, statusPageUrl := range []string{"https://Status.Example.com/", "HTTPS://STATUS.EXAMPLE.COM", "https://status.example.com//"} {
+		recorder := httptest.NewRecorder()
+		testContext, _ := gin.CreateTestContext(recorder)
+		testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents?statusPageUrl="+url.QueryEscape(statusPageUrl), nil)
+		s.incidents(testContext)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", statusPageUrl, recorder.Code)
+		}
+		var response IncidentsResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: failed to unmarshal response: %v", statusPageUrl, err)
+		}
+		if len(response.Incidents) != 1 || response.Incidents[0].StatusPageUrl != testStatusPageUrl {
+			t.Fatalf("%s: expected the incidents of %s, got %+v", statusPageUrl, testStatusPageUrl, response.Incidents)
+		}
+	}
+}
+
+func TestIncidentsClampsLimit(t *testing.T) {
+	now := time.Now()
+	dbClient := &fakeDbClient{}
+	for i := 0; i < 10; i++ {
+		dbClient.incidents = append(dbClient.incidents, newTestIncident(strconv.Itoa(i), api.ImpactMinor, now.Add(-time.Duration(i)*time.Minute)))
+	}
+	s := newTestServerWithDb(t, dbClient)
+	// The incidents of requests without a limit aren't streamed, as streamed incidents aren't limited
+	s.config.Incidents = config.IncidentsConfig{MaxLimit: 5, DefaultLimit: 3, StreamThreshold: -1}
+	incidentsResponse := func(query string) IncidentsResponse {
+		t.Helper()
+		recorder := getIncidents(s, query)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", query, recorder.Code)
+		}
+		var response IncidentsResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: failed to unmarshal response: %v", query, err)
+		}
+		return response
+	}
+
+	if response := incidentsResponse(""); len(response.Incidents) != 3 || response.NextCursor == "" || response.TotalCount != 10 {
+		t.Fatalf("expected the default limit of 3 incidents with a cursor to the rest, got %d incidents", len(response.Incidents))
+	}
+	if response := incidentsResponse("limit=4"); len(response.Incidents) != 4 {
+		t.Fatalf("expected a limit under the max to be used, got %d incidents", len(response.Incidents))
+	}
+	response := incidentsResponse("limit=1000000")
+	if len(response.Incidents) != 5 || response.NextCursor == "" {
+		t.Fatalf("expected the limit to be clamped to 5 with a cursor to the rest, got %d incidents", len(response.Incidents))
+	}
+	if next := incidentsResponse("limit=1000000&cursor=" + url.QueryEscape(response.NextCursor)); len(next.Incidents) != 5 || next.NextCursor != "" {
+		t.Fatalf("expected the rest of the incidents on the next page, got %d incidents", len(next.Incidents))
+	}
+
+	if recorder := getIncidents(s, "limit=-1"); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for a negative limit, got %d", recorder.Code)
+	}
+}
+
+func TestIncidentsDefaultLimitReturnsEverythingUpToTheMax(t *testing.T) {
+	now := time.Now()
+	dbClient := &fakeDbClient{}
+	for i := 0; i < 10; i++ {
+		dbClient.incidents = append(dbClient.incidents, newTestIncident(strconv.Itoa(i), api.ImpactMinor, now.Add(-time.Duration(i)*time.Minute)))
+	}
+	s := newTestServerWithDb(t, dbClient)
+	if s.config.Incidents.DefaultLimit != s.config.Incidents.MaxLimit || s.config.Incidents.MaxLimit <= 0 {
+		t.Fatalf("expected the default limit to default to the max limit, got %+v", s.config.Incidents)
+	}
+
+	var response IncidentsResponse
+	if err := json.Unmarshal(getIncidents(s, "").Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(response.Incidents) != 10 || response.NextCursor != "" {
+		t.Fatalf("expected every incident without a cursor, got %d incidents", len(response.Incidents))
+	}
+}
+
+func TestIncidentsReturnsThePagination(t *testing.T) {
+	now := time.Now()
+	s := newTestServerWithDb(t, &fakeDbClient{})
+	_ = s.incidentCache.Set(context.Background(), testStatusPageUrl, []api.Incident{
+		newTestIncident("a", api.ImpactMajor, now),
+		newTestIncident("b", api.ImpactMinor, now.Add(-time.Hour)),
+		newTestIncident("c", api.ImpactCritical, now.Add(-2*time.Hour)),
+	}, time.Hour)
+	getPagination := func(query string) (IncidentsResponse, Pagination) {
+		t.Helper()
+		var response IncidentsResponse
+		if err := json.Unmarshal(getIncidents(s, query).Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		return response, response.Pagination
+	}
+
+	response, pagination := getPagination("limit=2")
+	if pagination.NextCursor == "" || pagination.NextCursor != response.NextCursor || pagination.TotalCount != 3 || pagination.Limit == nil || *pagination.Limit != 2 {
+		t.Fatalf("unexpected pagination of the first page: %+v", pagination)
+	}
+	_, pagination = getPagination("limit=2&cursor=" + url.QueryEscape(pagination.NextCursor))
+	if pagination.NextCursor != "" || pagination.TotalCount != 3 {
+		t.Fatalf("unexpected pagination of the last page: %+v", pagination)
+	}
+	// Limits above the max limit are clamped to it, which is the limit that is returned
+	_, pagination = getPagination("limit=5000")
+	if pagination.Limit == nil || *pagination.Limit != s.config.Incidents.MaxLimit {
+		t.Fatalf("expected the limit to be clamped to the max limit, got %+v", pagination)
+	}
+}
+
+func TestIncidentsCursorPagesThroughTheFilteredIncidents(t *testing.T) {
+	now := time.Now()
+	s := newTestServerWithDb(t, &fakeDbClient{})
+	_ = s.incidentCache.Set(context.Background(), testStatusPageUrl, []api.Incident{
+		newTestIncident("a", api.ImpactMajor, now),
+		newTestIncident("b", api.ImpactMinor, now.Add(-time.Hour)),
+		newTestIncident("c", api.ImpactMajor, now.Add(-2*time.Hour)),
+		newTestIncident("d", api.ImpactMinor, now.Add(-3*time.Hour)),
+		newTestIncident("e", api.ImpactMajor, now.Add(-4*time.Hour)),
+	}, time.Hour)
+	getTitles := func(query string) ([]string, string) {
+		t.Helper()
+		recorder := getIncidents(s, query)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", query, recorder.Code, recorder.Body.String())
+		}
+		var response IncidentsResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: failed to unmarshal response: %v", query, err)
+		}
+		var titles []string
+		for _, incident := range response.Incidents {
+			titles = append(titles, incident.Title)
+		}
+		return titles, response.NextCursor
+	}
+
+	titles, cursor := getTitles("impact=major&limit=2")
+	if strings.Join(titles, ",") != "a,c" || cursor == "" {
+		t.Fatalf("expected the first two major incidents with a cursor, got %v", titles)
+	}
+	titles, cursor = getTitles("impact=major&limit=2&cursor=" + url.QueryEscape(cursor))
+	if strings.Join(titles, ",") != "e" || cursor != "" {
+		t.Fatalf("expected the remaining major incident without a cursor, got %v", titles)
+	}
+}
+
+func TestIncidentsRejectsAnInvalidCursor(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{})
+	recorder := getIncidents(s, "cursor=invalid")
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", recorder.Code)
+	}
+	var response ErrorResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response.Code != ErrorCodeInvalidCursor {
+		t.Fatalf("expected error code %s, got %s", ErrorCodeInvalidCursor, response.Code)
+	}
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// parseWaitQuery parses the optional wait query parameter, a duration e.g. 30s, waits longer than the configured max wait are clamped to it
+// It returns zero if there is no wait, if the wait is invalid it writes an error response and returns false
+func (s *Server) parseWaitQuery(context *gin.Context) (time.Duration, bool) {
+	waitStr := context.Query("wait")
+	if waitStr == "" {
+		return 0, true
+	}
+	wait, err := time.ParseDuration(waitStr)
+	if err != nil || wait < 0 {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidWait, "wait must be a non-negative duration e.g. 30s")
+		return 0, false
+	}
+	return min(wait, s.config.Incidents.MaxWait), true
+}
+
+// longPoll runs the handler, and while its response is a 304 waits up to wait for the incidents of the status page to change and runs it again
+// It returns the 304 if the incidents didn't change in time, the client went away or the server is shutting down,
+// the handler only gives a 304 to requests with validators
+func (s *Server) longPoll(context *gin.Context, statusPageUrl string, wait time.Duration, handler gin.HandlerFunc) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	writer := &bufferedResponseWriter{ResponseWriter: context.Writer, initialHeader: context.Writer.Header().Clone()}
+	context.Writer = writer
+	defer func() {
+		context.Writer = writer.ResponseWriter
+		writer.flush()
+	}()
+	for {
+		changed := s.changeBroadcaster.wait(statusPageUrl)
+		writer.reset()
+		handler(context)
+		if writer.status != http.StatusNotModified {
+			return
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return
+		case <-context.Request.Context().Done():
+			return
+		case <-s.shuttingDown.Done():
+			return
+		}
+	}
+}
+
+// bufferedResponseWriter holds the status and body of a response until it is flushed, so that a response can be replaced before it is sent
+// The headers are set on the underlying writer, so they're put back to the initial headers when the response is replaced
+type bufferedResponseWriter struct {
+	gin.ResponseWriter
+	initialHeader http.Header
+	status        int
+	body          bytes.Buffer
+}
+
+// reset discards the response so that it can be replaced
+func (w *bufferedResponseWriter) reset() {
+	header := w.ResponseWriter.Header()
+	for key := range header {
+		delete(header, key)
+	}
+	for key, values := range w.initialHeader {
+		header[key] = values
+	}
+	w.status = 0
+	w.body.Reset()
+}
+
+// flush writes the status and body to the underlying writer
+func (w *bufferedResponseWriter) flush() {
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.body.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedResponseWriter) WriteHeaderNow() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+}
+
+func (w *bufferedResponseWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	return w.body.Write(data)
+}
+
+func (w *bufferedResponseWriter) WriteString(data string) (int, error) {
+	w.WriteHeaderNow()
+	return w.body.WriteString(data)
+}
+
+func (w *bufferedResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *bufferedResponseWriter) Size() int {
+	if w.status == 0 {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedResponseWriter) Written() bool {
+	return w.status != 0
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/apiserver/internal/cache"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// getIncidentsIfNoneMatch calls the incidents handler with the given query string and If-None-Match header
+func getIncidentsIfNoneMatch(s *Server, query string, etag string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/incidents?statusPageUrl="+url.QueryEscape(testStatusPageUrl)+"&"+query, nil)
+	testContext.Request.Header.Set("If-None-Match", etag)
+	s.incidents(testContext)
+	return recorder
+}
+
+// waitForWaiter waits until a request is waiting for the incidents of the status page to change
+func waitForWaiter(t *testing.T, s *Server, statusPageUrl string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.changeBroadcaster.mu.Lock()
+		_, waiting := s.changeBroadcaster.changed[statusPageUrl]
+		s.changeBroadcaster.mu.Unlock()
+		if waiting {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected a request to wait for %s", statusPageUrl)
+}
+
+func TestIncidentsWaitReturnsNotModifiedIfNothingChanges(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{newTestIncident("a", api.ImpactMajor, time.Now())}})
+	etag := getIncidents(s, "").Header().Get("ETag")
+
+	start := time.Now()
+	recorder := getIncidentsIfNoneMatch(s, "wait=50ms", etag)
+	if recorder.Code != http.StatusNotModified || recorder.Body.Len() != 0 {
+		t.Fatalf("expected a 304 without a body, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("expected the request to wait, it returned after %s", elapsed)
+	}
+	if recorder.Header().Get("ETag") != etag {
+		t.Fatalf("expected the ETag %s, got %s", etag, recorder.Header().Get("ETag"))
+	}
+
+	// Waits are clamped to the max wait
+	s.config.Incidents.MaxWait = 50 * time.Millisecond
+	start = time.Now()
+	if recorder := getIncidentsIfNoneMatch(s, "wait=1h", etag); recorder.Code != http.StatusNotModified {
+		t.Fatalf("expected a 304, got %d", recorder.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the wait to be clamped, it returned after %s", elapsed)
+	}
+}
+
+func TestIncidentsWaitReturnsChangedIncidentsStraightAway(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{newTestIncident("a", api.ImpactMajor, time.Now())}})
+	etag := getIncidents(s, "").Header().Get("ETag")
+
+	// A request that would get a 200 doesn't wait
+	start := time.Now()
+	if recorder := getIncidentsIfNoneMatch(s, "wait=10s", `W/"other"`); recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the request not to wait, it returned after %s", elapsed)
+	}
+
+	responses := make(chan *httptest.ResponseRecorder)
+	go func() {
+		responses <- getIncidentsIfNoneMatch(s, "wait=10s", etag)
+	}()
+	waitForWaiter(t, s, testStatusPageUrl)
+	_ = s.incidentCache.Set(context.Background(), testStatusPageUrl, []api.Incident{newTestIncident("b", api.ImpactCritical, time.Now())}, cache.DefaultExpiration)
+	s.changeBroadcaster.publish(testStatusPageUrl)
+
+	select {
+	case recorder := <-responses:
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", recorder.Code)
+		}
+		var response IncidentsResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if len(response.Incidents) != 1 || response.Incidents[0].Title != "b" {
+			t.Fatalf("expected the changed incidents, got %+v", response.Incidents)
+		}
+		if recorder.Header().Get("ETag") == etag {
+			t.Fatalf("expected the ETag of the changed incidents")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the request to return once the incidents changed")
+	}
+}
+
+func TestIncidentChangeNotificationsInvalidateTheCacheAndWakeWaiters(t *testing.T) {
+	dbClient := &fakeDbClient{incidentChanges: make(chan string)}
+	s := newTestServerWithDb(t, dbClient)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.listenForIncidentChanges(ctx)
+	_ = s.incidentCache.Set(ctx, testStatusPageUrl, []api.Incident{newTestIncident("a", api.ImpactMajor, time.Now())}, cache.DefaultExpiration)
+
+	changed := s.changeBroadcaster.wait(testStatusPageUrl)
+	unchanged := s.changeBroadcaster.wait("https://status.other.com")
+	dbClient.incidentChanges <- testStatusPageUrl
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the waiters of the changed status page to be woken")
+	}
+	select {
+	case <-unchanged:
+		t.Fatalf("expected the waiters of other status pages not to be woken")
+	default:
+	}
+	if _, found, _ := s.incidentCache.Get(ctx, testStatusPageUrl); found {
+		t.Fatalf("expected the changed incidents to be removed from the cache")
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"strconv"
+	"time"
+)
+
+const (
+	cacheResultHit   = "hit"
+	cacheResultMiss  = "miss"
+	cacheResultError = "error"
+)
+
+var (
+	cacheLookupsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "statusphere",
+		Subsystem: "apiserver",
+		Name:      "cache_lookups_total",
+		Help:      "The number of cache lookups by cache and result (hit, miss or error)",
+	}, []string{"cache", "result"})
+
+	requestDurationSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "statusphere",
+		Subsystem: "apiserver",
+		Name:      "request_duration_seconds",
+		Help:      "The latency of api requests by route and status code",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "status"})
+)
+
+// recordCacheLookup records the result of a cache lookup in the cache_lookups_total metric
+func recordCacheLookup(cache string, found bool, err error) {
+	result := cacheResultMiss
+	if err != nil {
+		result = cacheResultError
+	} else if found {
+		result = cacheResultHit
+	}
+	cacheLookupsTotal.WithLabelValues(cache, result).Inc()
+}
+
+// observeRequestDuration is a middleware that records the latency of each request in the request_duration_seconds metric
+// The route is the registered path rather than the request path so that the number of label values is bounded
+func observeRequestDuration() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		requestDurationSeconds.WithLabelValues(c.FullPath(), strconv.Itoa(c.Writer.Status())).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3gen"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// buildOpenApiSpec is only run once as the spec doesn't change while the server is running
+var buildOpenApiSpec = sync.OnceValues(newOpenApiSpec)
+
+// openApi is a handler for the /openapi.json endpoint.
+// It returns an OpenAPI 3 document describing the /api/v1/incidents endpoint
+func (s *Server) openApi(context *gin.Context) {
+	spec, err := buildOpenApiSpec()
+	if err != nil {
+		utils.GetLogger(context.Request.Context(), s.logger).Error("failed to build the openapi spec", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to build the openapi spec")
+		return
+	}
+	writeJSONWithETag(context, spec)
+}
+
+// newOpenApiSpec builds the OpenAPI 3 document of the api
+// The schemas of the responses are generated from the structs that the handlers return so that they stay in sync with them,
+// the query parameters are described by hand from the doc comments of the handlers
+func newOpenApiSpec() (*openapi3.T, error) {
+	schemas := openapi3.Schemas{}
+	generator := openapi3gen.NewGenerator(openapi3gen.SchemaCustomizer(customizeOpenApiSchema))
+	for name, value := range map[string]interface{}{"IncidentsResponse": IncidentsResponse{}, "ErrorResponse": ErrorResponse{}} {
+		schemaRef, err := generator.NewSchemaRefForValue(value, schemas)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to generate the schema of %s", name)
+		}
+		schemas[name] = schemaRef
+	}
+
+	errorResponse := func(description string) *openapi3.ResponseRef {
+		return &openapi3.ResponseRef{Value: openapi3.NewResponse().
+			WithDescription(description).
+			WithJSONSchemaRef(openapi3.NewSchemaRef("#/components/schemas/ErrorResponse", nil))}
+	}
+	responses := openapi3.NewResponses(
+		openapi3.WithStatus(http.StatusOK, &openapi3.ResponseRef{Value: openapi3.NewResponse().
+			WithDescription("The incidents of the status page matching the filters").
+			WithJSONSchemaRef(openapi3.NewSchemaRef("#/components/schemas/IncidentsResponse", nil))}),
+		openapi3.WithStatus(http.StatusNotModified, &openapi3.ResponseRef{Value: openapi3.NewResponse().
+			WithDescription("The incidents haven't changed since the ETag in the If-None-Match header")}),
+		openapi3.WithStatus(http.StatusBadRequest, errorResponse("A query parameter is invalid")),
+		openapi3.WithStatus(http.StatusUnauthorized, errorResponse("includeDeleted was given without the admin api key")),
+		openapi3.WithStatus(http.StatusNotFound, errorResponse("The status page is not known to statusphere")),
+		openapi3.WithStatus(http.StatusTooManyRequests, errorResponse("The client has made too many requests")),
+		openapi3.WithStatus(http.StatusInternalServerError, errorResponse("The incidents couldn't be read")),
+	)
+	responses.Value("200").Value.Headers = openapi3.Headers{
+		"X-Total-Count": &openapi3.HeaderRef{Value: &openapi3.Header{Parameter: openapi3.Parameter{
+			Description: "The number of incidents matching the filters, before the cursor and limit are applied",
+			Schema:      openapi3.NewIntegerSchema().NewRef(),
+		}}},
+		"ETag": &openapi3.HeaderRef{Value: &openapi3.Header{Parameter: openapi3.Parameter{
+			Description: "Identifies the response, pass it in the If-None-Match header to get a 304 if it hasn't changed",
+			Schema:      openapi3.NewStringSchema().NewRef(),
+		}}},
+		"Last-Modified": &openapi3.HeaderRef{Value: &openapi3.Header{Parameter: openapi3.Parameter{
+			Description: "The newest time that the incidents matching the filters changed, pass it in the If-Modified-Since header to get a 304 if they haven't changed since",
+			Schema:      openapi3.NewStringSchema().NewRef(),
+		}}},
+	}
+
+	impactsSchema := openapi3.NewStringSchema().WithPattern(`^[A-Za-z_ -]+(,[A-Za-z_ -]+)*$`)
+	incidents := &openapi3.Operation{
+		OperationID: "getIncidents",
+		Summary:     "Get the incidents of a status page",
+		Parameters: openapi3.Parameters{
+			{Value: openapi3.NewQueryParameter("statusPageUrl").WithDescription("The url of the status page").WithSchema(openapi3.NewStringSchema()).WithRequired(true)},
+			openApiQueryParameter("impact", "A comma separated list of impacts, only incidents with one of them are returned", impactsSchema),
+			openApiQueryParameter("excludeImpact", "A comma separated list of impacts, incidents with them aren't returned, it can't be used with impact", impactsSchema),
+			openApiQueryParameter("type", "Only return scheduled maintenance or only the other incidents, it can't be used with impact or excludeImpact", openapi3.NewStringSchema().WithEnum(string(incidentTypeMaintenance), string(incidentTypeIncident))),
+			openApiQueryParameter("order", "The order of the incidents by start time, most recent first by default", openapi3.NewStringSchema().WithEnum(string(sortOrderAscending), string(sortOrderDescending)).WithDefault(string(sortOrderDescending))),
+			openApiQueryParameter("limit", "The maximum number of incidents to return, it defaults to and is clamped to limits configured by the server", openapi3.NewIntegerSchema().WithMin(0)),
+			openApiQueryParameter("cursor", "The nextCursor of a previous response, to get the next page of incidents", openapi3.NewStringSchema()),
+			openApiQueryParameter("from", "Only incidents that started at or after this time are returned", openapi3.NewDateTimeSchema()),
+			openApiQueryParameter("to", "Only incidents that started at or before this time are returned", openapi3.NewDateTimeSchema()),
+			openApiQueryParameter("includeOngoing", "Also return the incidents that started before from but haven't ended", openapi3.NewBoolSchema().WithDefault(false)),
+			openApiQueryParameter("at", "Only incidents that were active at this time are returned, i.e. that started at or before it and ended after it or haven't ended", openapi3.NewDateTimeSchema()),
+			openApiQueryParameter("ongoing", "Only return the incidents that haven't ended", openapi3.NewBoolSchema().WithDefault(false)),
+			openApiQueryParameter("q", "Only return the incidents whose title or description contains q case insensitively", openapi3.NewStringSchema()),
+			openApiQueryParameter("component", "Only return the incidents that affected the component", openapi3.NewStringSchema()),
+			openApiQueryParameter("tag", "Only return the incidents with the tag, e.g. network or database, compared case insensitively", openapi3.NewStringSchema()),
+			openApiQueryParameter("componentMatch", "How the component is compared case insensitively", openapi3.NewStringSchema().WithEnum(string(componentMatchExact), string(componentMatchSubstring)).WithDefault(string(componentMatchExact))),
+			openApiQueryParameter("includeUpdates", "Whether the updates posted to each incident are returned", openapi3.NewBoolSchema().WithDefault(true)),
+			openApiQueryParameter("includeEvents", "An alias of includeUpdates", openapi3.NewBoolSchema().WithDefault(true)),
+			openApiQueryParameter("includeImpactHistory", "Whether the impacts each incident had and when they changed are returned", openapi3.NewBoolSchema().WithDefault(false)),
+			openApiQueryParameter("fields", "A comma separated list of the fields of the incidents to return, all of them by default", openapi3.NewStringSchema()),
+			openApiQueryParameter("tz", "An IANA time zone name that the returned timestamps are converted to, UTC by default", openapi3.NewStringSchema()),
+			openApiQueryParameter("includeDeleted", "Also return the incidents that have been removed from the status page, it needs the admin api key", openapi3.NewBoolSchema().WithDefault(false)),
+			openApiQueryParameter("wait", "A duration e.g. 30s to wait for the incidents to change if the response would be a 304, it is clamped to a max configured by the server", openapi3.NewStringSchema()),
+		},
+		Responses: responses,
+	}
+
+	spec := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:       "Statusphere",
+			Description: "The incidents of the status pages indexed by statusphere",
+			Version:     "v1",
+		},
+		Paths:      openapi3.NewPaths(openapi3.WithPath("/api/v1/incidents", &openapi3.PathItem{Get: incidents})),
+		Components: &openapi3.Components{Schemas: schemas},
+	}
+	return spec, nil
+}
+
+func openApiQueryParameter(name string, description string, schema *openapi3.Schema) *openapi3.ParameterRef {
+	return &openapi3.ParameterRef{Value: openapi3.NewQueryParameter(name).WithDescription(description).WithSchema(schema)}
+}
+
+// customizeOpenApiSchema adds what can't be derived from the types of the fields to the generated schemas
+func customizeOpenApiSchema(name string, t reflect.Type, tag reflect.StructTag, schema *openapi3.Schema) error {
+	if t == reflect.TypeOf(api.Impact("")) {
+		for _, impact := range api.Impacts {
+			schema.Enum = append(schema.Enum, string(impact))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenApiSpecIsValid(t *testing.T) {
+	s := newTestServer(t)
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	s.openApi(testContext)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+
+	// Loading the served document resolves its references, validating it checks it against the OpenAPI 3 specification
+	spec, err := openapi3.NewLoader().LoadFromData(recorder.Body.Bytes())
+	if err != nil {
+		t.Fatalf("failed to load the openapi spec: %v", err)
+	}
+	if err := spec.Validate(context.Background()); err != nil {
+		t.Fatalf("expected a valid openapi spec, got %v\n%s", err, recorder.Body.String())
+	}
+
+	incidents := spec.Paths.Find("/api/v1/incidents")
+	if incidents == nil || incidents.Get == nil {
+		t.Fatalf("expected the incidents endpoint to be described")
+	}
+	if parameter := incidents.Get.Parameters.GetByInAndName(openapi3.ParameterInQuery, "statusPageUrl"); parameter == nil || !parameter.Required {
+		t.Fatalf("expected statusPageUrl to be a required query parameter")
+	}
+
+	// The schemas are generated from the structs, so they have their json field names
+	response := spec.Components.Schemas["IncidentsResponse"].Value
+	for _, property := range []string{"incidents", "isIndexed", "nextCursor", "ongoingCount", "totalCount", "matchCount"} {
+		if _, ok := response.Properties[property]; !ok {
+			t.Fatalf("expected IncidentsResponse to have %s, got %v", property, response.Properties)
+		}
+	}
+	incident := response.Properties["incidents"].Value.Items.Value
+	for _, property := range []string{"title", "startTime", "endTime", "deepLink", "impact", "statusPageUrl", "events"} {
+		if _, ok := incident.Properties[property]; !ok {
+			t.Fatalf("expected Incident to have %s, got %v", property, incident.Properties)
+		}
+	}
+	if _, ok := incident.Properties["DedupKey"]; ok {
+		t.Fatalf("expected fields that aren't serialized to be left out")
+	}
+	if len(incident.Properties["impact"].Value.Enum) != 5 {
+		t.Fatalf("expected the impacts to be enumerated, got %v", incident.Properties["impact"].Value.Enum)
+	}
+}
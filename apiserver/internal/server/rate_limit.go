@@ -0,0 +1,44 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/apiserver/internal/ratelimit"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// rateLimit is a middleware that rejects requests with a 429 once a client has exceeded its rate
+// Clients are identified by their api key if they send an Authorization: Bearer <key> header, otherwise by their ip, see clientIP
+// If the limiter fails then the request is allowed, so that an unavailable limiter doesn't take down the api
+func rateLimit(logger *zap.Logger, limiter ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, retryAfter, err := limiter.Allow(c.Request.Context(), rateLimitKey(c))
+		if err != nil {
+			utils.GetLogger(c.Request.Context(), logger).Warn("failed to check rate limit, allowing request", zap.Error(err))
+			c.Next()
+			return
+		}
+		if !allowed {
+			// Retry-After is in whole seconds, round up so that the client doesn't retry before it has a token
+			c.Header("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(retryAfter.Seconds())))))
+			abortWithError(c, http.StatusTooManyRequests, ErrorCodeRateLimited, "rate limit exceeded")
+			return
+		}
+		c.Next()
+	}
+}
+
+func rateLimitKey(c *gin.Context) string {
+	if apiKey, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); found && apiKey != "" {
+		// Hash the key so that it isn't kept in memory or sent to a shared limiter in plain text
+		hash := sha256.Sum256([]byte(apiKey))
+		return "key:" + hex.EncodeToString(hash[:])
+	}
+	return "ip:" + clientIP(c)
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/apiserver/internal/ratelimit"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimitReturnsRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(rateLimit(zap.NewNop(), ratelimit.NewInMemoryLimiter(1, 1)))
+	r.GET("/api/v1/incidents", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	get := func(authorization string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/incidents", nil)
+		request.Header.Set("Authorization", authorization)
+		r.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	if recorder := get(""); recorder.Code != http.StatusOK {
+		t.Fatalf("expected the first request to be allowed, got %d", recorder.Code)
+	}
+	limited := get("")
+	if limited.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", limited.Code)
+	}
+	if retryAfter := limited.Header().Get("Retry-After"); retryAfter != "60" {
+		t.Fatalf("expected to retry after 60 seconds at 1 request per minute, got %q", retryAfter)
+	}
+
+	// Clients with an api key have their own limit rather than sharing the limit of their ip
+	if recorder := get("Bearer some-key"); recorder.Code != http.StatusOK {
+		t.Fatalf("expected a request with an api key to be allowed, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/utils"
+)
+
+const requestIdHeader = "X-Request-Id"
+
+// maxRequestIdLength limits the length of request ids sent by clients so that they can't flood the logs
+const maxRequestIdLength = 128
+
+// addRequestId gives each request an id that is added to the logs of the request and returned in the X-Request-Id header
+// If the client or a proxy in front of the api server already sent an id in the header then that id is used instead
+func addRequestId() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestId := c.GetHeader(requestIdHeader)
+		if requestId == "" || len(requestId) > maxRequestIdLength || !isPrintableAscii(requestId) {
+			// Reading from crypto/rand does not fail on supported platforms
+			requestId, _ = randomHex(16)
+		}
+		c.Writer.Header().Set(requestIdHeader, requestId)
+		ctx := utils.UpdateContextMdc(c.Request.Context(), map[string]string{"requestId": requestId})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+func isPrintableAscii(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < ' ' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
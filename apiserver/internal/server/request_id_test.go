@@ -0,0 +1,60 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIdIsAddedToLogsAndResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+	r := gin.New()
+	r.Use(addRequestId())
+	r.Use(ginZap(logger))
+	r.GET("/api/v1/incidents", func(c *gin.Context) {
+		utils.GetLogger(c.Request.Context(), logger).Error("failed to get incidents from database")
+		c.Status(http.StatusInternalServerError)
+	})
+
+	tests := []struct {
+		name              string
+		incomingRequestId string
+		expectGenerated   bool
+	}{
+		{name: "no request id", incomingRequestId: "", expectGenerated: true},
+		{name: "request id from a proxy", incomingRequestId: "abc-123", expectGenerated: false},
+		{name: "request id that is too long", incomingRequestId: strings.Repeat("a", maxRequestIdLength+1), expectGenerated: true},
+		{name: "request id with control characters", incomingRequestId: "abc\x01", expectGenerated: true},
+	}
+	for _, test := range tests {
+		logs.TakeAll()
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/incidents", nil)
+		if test.incomingRequestId != "" {
+			request.Header.Set(requestIdHeader, test.incomingRequestId)
+		}
+		r.ServeHTTP(recorder, request)
+
+		requestId := recorder.Header().Get(requestIdHeader)
+		if requestId == "" || (test.expectGenerated && requestId == test.incomingRequestId) || (!test.expectGenerated && requestId != test.incomingRequestId) {
+			t.Fatalf("%s: unexpected request id %q", test.name, requestId)
+		}
+		// Both the handler log and the request log should have the request id
+		entries := logs.TakeAll()
+		if len(entries) != 2 {
+			t.Fatalf("%s: expected 2 log entries, got %d", test.name, len(entries))
+		}
+		for _, entry := range entries {
+			if entry.ContextMap()["requestId"] != requestId {
+				t.Fatalf("%s: expected the log %q to have the request id, got %v", test.name, entry.Message, entry.ContextMap())
+			}
+		}
+	}
+}
@@ -1,64 +1,243 @@
 package server
 
 import (
+	"context"
 	"github.com/gin-contrib/cors"
-	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
-	"github.com/metoro-io/statusphere/common/db"
+	"github.com/metoro-io/statusphere/apiserver/internal/cache"
+	"github.com/metoro-io/statusphere/apiserver/internal/config"
+	"github.com/metoro-io/statusphere/apiserver/internal/ratelimit"
+	"github.com/metoro-io/statusphere/common/api"
 	"github.com/metoro-io/statusphere/common/utils"
-	"github.com/patrickmn/go-cache"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+	"net"
+	"net/http"
+	"sync/atomic"
 	"time"
 )
 
 type Server struct {
-	logger               *zap.Logger
-	dbClient             *db.DbClient
-	statusPageCache      *cache.Cache
-	incidentCache        *cache.Cache
-	currentIncidentCache *cache.Cache
+	logger   *zap.Logger
+	dbClient DbClient
+	config   config.Config
+	// statusPageCache is a full copy of the status pages table that is refreshed periodically
+	statusPageCache      cache.Cache[api.StatusPage]
+	incidentCache        cache.Cache[[]api.Incident]
+	currentIncidentCache cache.Cache[[]api.Incident]
+	// allStatusPagesCache caches every status page under a single key for the requests that need all of them, see getCachedStatusPages
+	allStatusPagesCache cache.Cache[map[string]api.StatusPage]
+	// incidentStatusPageCache caches the url of the status page of each incident by the id of the incident,
+	// so that the incident can be found in the cached incidents of its status page, see lookupIncident
+	incidentStatusPageCache cache.Cache[string]
+	// affectedStatusPagesCache caches the status pages with ongoing incidents by the impacts they were filtered by
+	affectedStatusPagesCache cache.Cache[[]AffectedStatusPage]
+	// recentIncidentsCache caches the most recent incidents across every status page by the impacts they were filtered by
+	recentIncidentsCache cache.Cache[[]api.Incident]
+	// recentIncidentCountsCache caches the number of recent incidents of every status page under a single key
+	recentIncidentCountsCache cache.Cache[map[string]int]
+	// idempotentSubscriptionsCache caches the subscriptions created for each idempotency key of each client, see createSubscription
+	idempotentSubscriptionsCache cache.Cache[idempotentSubscription]
+	// streams counts the incident streams each client has open
+	streams *streamLimiter
+	// shuttingDown is done once the server starts shutting down, so that long running requests like streams end instead of holding up the shutdown
+	shuttingDown       context.Context
+	cancelShuttingDown context.CancelFunc
+	// changeBroadcaster wakes the long polling requests when the scraper changes the incidents of their status page
+	changeBroadcaster *incidentChangeBroadcaster
+	// incidentDatabaseFetches coalesces concurrent database fetches of the incidents for the same status page
+	incidentDatabaseFetches singleflight.Group
+	// idempotentSubscriptionCreations coalesces concurrent requests to create a subscription with the same idempotency key
+	idempotentSubscriptionCreations singleflight.Group
+	// statusPageChecker checks that a submitted status page is reachable, it is a field so that it can be faked in tests
+	statusPageChecker func(ctx context.Context, statusPageUrl string) error
+	// rateLimiter limits the requests each client makes to the incidents endpoints, it is nil if there is no rate limit
+	rateLimiter ratelimit.Limiter
+	// redisClient is nil if the caches are process local
+	redisClient redis.UniversalClient
+	// statusPageCacheInitialized is set once the status page cache has been loaded from the database
+	statusPageCacheInitialized atomic.Bool
+	tracer                     trace.Tracer
+	// httpServer serves the api, it is created with the server so that it can be shut down before Serve is called
+	httpServer *http.Server
 }
 
-func NewServer(logger *zap.Logger, dbClient *db.DbClient) *Server {
-	return &Server{
-		logger:               logger,
-		dbClient:             dbClient,
-		statusPageCache:      cache.New(15*time.Minute, 15*time.Minute),
-		incidentCache:        cache.New(1*time.Minute, 1*time.Minute),
-		currentIncidentCache: cache.New(1*time.Minute, 1*time.Minute),
+// NewServer creates a new api server
+// If redisClient is nil then the caches are process local, otherwise they are stored in redis and shared between replicas
+func NewServer(logger *zap.Logger, dbClient DbClient, redisClient redis.UniversalClient, config config.Config) *Server {
+	config.Cache = config.Cache.WithDefaults()
+	config.Incidents = config.Incidents.WithDefaults()
+	s := &Server{
+		logger:            logger,
+		dbClient:          dbClient,
+		config:            config,
+		statusPageChecker: newStatusPageChecker(),
+		changeBroadcaster: newIncidentChangeBroadcaster(),
+		streams:           newStreamLimiter(config.Incidents.MaxStreamsPerClient),
+		redisClient:       redisClient,
+		tracer:            otel.Tracer(tracerName),
+		httpServer:        &http.Server{Addr: ":80"},
 	}
+	s.shuttingDown, s.cancelShuttingDown = context.WithCancel(context.Background())
+	s.httpServer.RegisterOnShutdown(s.cancelShuttingDown)
+	if redisClient != nil {
+		s.statusPageCache = cache.NewRedisCache[api.StatusPage](redisClient, "status_pages", config.Cache.StatusPageTTL)
+		s.allStatusPagesCache = cache.NewRedisCache[map[string]api.StatusPage](redisClient, "all_status_pages", config.Cache.StatusPageTTL)
+		s.incidentCache = cache.NewRedisCache[[]api.Incident](redisClient, "incidents", config.Cache.IncidentTTL)
+		s.currentIncidentCache = cache.NewRedisCache[[]api.Incident](redisClient, "current_incidents", config.Cache.IncidentTTL)
+		s.incidentStatusPageCache = cache.NewRedisCache[string](redisClient, "incident_status_page", config.Cache.IncidentTTL)
+		s.affectedStatusPagesCache = cache.NewRedisCache[[]AffectedStatusPage](redisClient, "affected_status_pages", config.Cache.AffectedStatusPagesTTL)
+		s.recentIncidentsCache = cache.NewRedisCache[[]api.Incident](redisClient, "recent_incidents", config.Cache.RecentIncidentsTTL)
+		s.recentIncidentCountsCache = cache.NewRedisCache[map[string]int](redisClient, "recent_incident_counts", config.Cache.RecentIncidentCountsTTL)
+		s.idempotentSubscriptionsCache = cache.NewRedisCache[idempotentSubscription](redisClient, "idempotent_subscriptions", config.Cache.IdempotencyKeyTTL)
+	} else {
+		s.statusPageCache = cache.NewInMemoryCache[api.StatusPage](config.Cache.StatusPageTTL, config.Cache.CleanupInterval)
+		s.allStatusPagesCache = cache.NewInMemoryCache[map[string]api.StatusPage](config.Cache.StatusPageTTL, config.Cache.CleanupInterval)
+		s.incidentCache = cache.NewInMemoryCache[[]api.Incident](config.Cache.IncidentTTL, config.Cache.CleanupInterval)
+		s.currentIncidentCache = cache.NewInMemoryCache[[]api.Incident](config.Cache.IncidentTTL, config.Cache.CleanupInterval)
+		s.incidentStatusPageCache = cache.NewInMemoryCache[string](config.Cache.IncidentTTL, config.Cache.CleanupInterval)
+		s.affectedStatusPagesCache = cache.NewInMemoryCache[[]AffectedStatusPage](config.Cache.AffectedStatusPagesTTL, config.Cache.CleanupInterval)
+		s.recentIncidentsCache = cache.NewInMemoryCache[[]api.Incident](config.Cache.RecentIncidentsTTL, config.Cache.CleanupInterval)
+		s.recentIncidentCountsCache = cache.NewInMemoryCache[map[string]int](config.Cache.RecentIncidentCountsTTL, config.Cache.CleanupInterval)
+		s.idempotentSubscriptionsCache = cache.NewInMemoryCache[idempotentSubscription](config.Cache.IdempotencyKeyTTL, config.Cache.CleanupInterval)
+	}
+	if config.RateLimit.RequestsPerMinute > 0 {
+		burst := config.RateLimit.Burst
+		if burst <= 0 {
+			burst = config.RateLimit.RequestsPerMinute
+		}
+		s.rateLimiter = ratelimit.NewInMemoryLimiter(config.RateLimit.RequestsPerMinute, burst)
+	}
+	return s
 }
 
+// Serve serves the api on port 80, it blocks until the server is shut down when it returns http.ErrServerClosed
 func (s *Server) Serve() error {
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return errors.Wrap(err, "Failed to start server")
+	}
+	return s.serve(listener)
+}
+
+// serve serves the api on the listener, it is separate from Serve so that tests can listen on any port
+func (s *Server) serve(listener net.Listener) error {
+	s.httpServer.Handler = s.router().Handler()
+	err := s.httpServer.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return errors.Wrap(err, "Failed to serve")
+}
+
+// Shutdown stops the server accepting new requests and waits for the in flight requests to complete
+// If the context is done before they complete, it returns the context's error
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
+
+func (s *Server) router() *gin.Engine {
 	r := gin.New()
 	r.UseH2C = true
+	// Route on the escaped path so that path parameters can contain escaped slashes, e.g. incident ids which are urls
+	r.UseRawPath = true
 	r.Use(gin.Recovery())
+	// gin trusts every proxy by default, so it is told about the trusted ones for anything that uses gin.Context.ClientIP
+	trustedProxies := make([]string, 0, len(s.config.TrustedProxies))
+	for _, prefix := range s.config.TrustedProxies {
+		trustedProxies = append(trustedProxies, prefix.String())
+	}
+	// The prefixes were already parsed, so they are valid
+	_ = r.SetTrustedProxies(trustedProxies)
 
-	corsHandler := handleCors()
-	r.Use(corsHandler)
-	r.Use(gzip.Gzip(gzip.BestSpeed))
+	// Without any allowed origins there is no cors middleware, so browsers only allow same origin requests
+	if len(s.config.Cors.AllowedOrigins) > 0 {
+		r.Use(handleCors(s.config.Cors))
+	}
 
+	// The request id is added before requests are logged so that the request logs include it
+	r.Use(addRequestId())
+	r.Use(addClientIP(s.config.TrustedProxies))
 	r.Use(ginZap(s.logger))
 
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	r.GET("/healthz", s.healthz)
+	r.GET("/readyz", s.readyz)
+	r.GET("/openapi.json", s.openApi)
+
 	apiV1 := r.Group("/api/v1")
 	{
+		apiV1.Use(traceRequests(s.tracer))
 		apiV1.Use(addNoIndexHeader())
-		apiV1.GET("/incidents", s.incidents)
+		apiV1.Use(compressResponses(s.config.CompressionMinSize, s.config.CompressionEncodings))
+		apiV1.Use(cacheControlResponses(s.config.CacheControl, s.config.RequireApiKeyForReads || len(s.apiKeys()) > 0))
+		apiV1.Use(observeRequestDuration())
+		apiV1.Use(s.requireApiKey(config.ApiKeyScopeRead))
+
+		// The incidents endpoints can hit the database on a cache miss, submitting a status page makes a request to it and subscriptions are stored in the database, so they are rate limited
+		rateLimited := apiV1.Group("")
+		if s.rateLimiter != nil {
+			rateLimited.Use(rateLimit(s.logger, s.rateLimiter))
+		}
+		rateLimited.GET("/incidents", s.incidents)
+		rateLimited.GET("/incidents.csv", s.incidentsCsv)
+		rateLimited.GET("/incidents/feed.rss", s.incidentsRss)
+		rateLimited.GET("/incidents/feed.atom", s.incidentsAtom)
+		rateLimited.GET("/incidents/maintenance.ics", s.maintenanceCalendar)
+		rateLimited.GET("/incidents/grouped", s.incidentsGrouped)
+		rateLimited.GET("/incidents/changes", s.incidentChanges)
+		rateLimited.GET("/incidents/all", s.recentIncidents)
+		rateLimited.POST("/incidents/batch", s.incidentsBatch)
+		rateLimited.GET("/incidents/merged", s.mergedIncidents)
+		rateLimited.GET("/incidents/stream", s.incidentsStream)
+		rateLimited.GET("/incidents/counts", s.incidentCounts)
+		rateLimited.GET("/incidents/search", s.searchAllIncidents)
+		rateLimited.GET("/incidents/navigate", s.navigateIncidents)
+		rateLimited.GET("/incidents/:id", s.incident)
+		rateLimited.GET("/incidents/:id/related", s.relatedIncidents)
+		rateLimited.POST("/statusPages", s.requireApiKey(config.ApiKeyScopeWrite), s.createStatusPage)
+		// Subscriptions are only known to the clients that created them, so their responses aren't cached
+		rateLimited.POST("/subscriptions", noStore(), s.requireApiKey(config.ApiKeyScopeWrite), s.createSubscription)
+		rateLimited.DELETE("/subscriptions/:id", noStore(), s.requireApiKey(config.ApiKeyScopeWrite), s.deleteSubscription)
+		rateLimited.GET("/subscriptions/:id/deliveries", noStore(), s.requireApiKey(config.ApiKeyScopeWrite), s.subscriptionDeliveries)
+		rateLimited.POST("/subscriptions/:id/deliveries/:deliveryId/redeliver", noStore(), s.requireApiKey(config.ApiKeyScopeWrite), s.redeliverSubscriptionDelivery)
+
 		apiV1.GET("/currentStatus", s.currentStatus)
 		apiV1.GET("/statusPage", s.statusPage)
 		apiV1.GET("/statusPages", s.statusPages)
 		apiV1.GET("/statusPages/search", s.statusPageSearch)
 		apiV1.GET("/statusPages/count", s.statusPageCount)
+		apiV1.GET("/statusPages/stats", s.statusPageStats)
+		apiV1.GET("/statusPages/status", s.statusPageIndexingStatus)
+		apiV1.GET("/statusPages/current", s.statusPageCurrent)
+		apiV1.GET("/statusPages/affected", s.affectedStatusPages)
 		apiV1.GET("/sitemap.xml", s.siteMap)
+
+		admin := apiV1.Group("/admin")
+		admin.Use(noStore(), s.requireApiKey(config.ApiKeyScopeAdmin))
+		admin.POST("/cache/invalidate", s.invalidateCache)
+		admin.POST("/cache/warm", s.warmCache)
+		admin.POST("/statusPages/reindex", s.reindexStatusPage)
+		admin.POST("/statusPages/pause", s.pauseStatusPageIndexing)
+		admin.GET("/statusPages/snapshot", s.statusPageSnapshot)
 	}
-	return errors.Wrap(r.Run(":80"), "Failed to start server")
+	return r
 }
 
-func handleCors() gin.HandlerFunc {
+// handleCors answers preflight requests and adds the cors headers for the allowed origins
+// Preflight requests are aborted by the middleware so they never reach the handlers
+func handleCors(allowed config.CorsConfig) gin.HandlerFunc {
 	corsConfig := cors.DefaultConfig()
-	// Development cors
-	corsConfig.AllowOrigins = []string{"http://localhost:3000", "https://metoro.io"}
+	corsConfig.AllowOrigins = allowed.AllowedOrigins
+	corsConfig.AllowMethods = allowed.AllowedMethods
+	corsConfig.AllowHeaders = allowed.AllowedHeaders
+	// Browsers only let clients read the headers that are exposed
+	corsConfig.ExposeHeaders = []string{"X-Total-Count", "ETag", requestIdHeader}
 	handlerFunc := cors.New(corsConfig)
 	return handlerFunc
 }
@@ -66,7 +245,6 @@ func handleCors() gin.HandlerFunc {
 // Middleware to make Gin log using Zap
 func ginZap(logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx := c.Request.Context()
 		// Start timer
 		start := time.Now()
 		path := c.Request.URL.Path
@@ -74,11 +252,13 @@ func ginZap(logger *zap.Logger) gin.HandlerFunc {
 
 		// Process request
 		c.Next()
+		// The context is read after the request is processed, so that the logs include the mdc that the handlers added, e.g. the id of the api key
+		ctx := c.Request.Context()
 
 		// Collect log fields
 		end := time.Now()
 		latency := end.Sub(start)
-		clientIP := c.ClientIP()
+		clientIP := clientIP(c)
 		method := c.Request.Method
 		statusCode := c.Writer.Status()
 		if len(c.Errors) > 0 {
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/metoro-io/statusphere/apiserver/internal/config"
+	"github.com/metoro-io/statusphere/common/api"
+	"go.uber.org/zap"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestNewServerDefaultsCacheConfig(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, config.Config{})
+	if s.config.Cache.IncidentTTL != time.Minute {
+		t.Fatalf("expected the default incident ttl of 1m, got %v", s.config.Cache.IncidentTTL)
+	}
+	if s.config.Cache.StatusPageTTL != 15*time.Minute {
+		t.Fatalf("expected the default status page ttl of 15m, got %v", s.config.Cache.StatusPageTTL)
+	}
+}
+
+func TestIncidentCacheExpiresAfterConfiguredTTL(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(zap.NewNop(), nil, nil, config.Config{Cache: config.CacheConfig{IncidentTTL: 50 * time.Millisecond}})
+	_ = s.incidentCache.Set(ctx, testStatusPageUrl, []api.Incident{newTestIncident("a", api.ImpactMinor, time.Now())}, s.config.Cache.IncidentTTL)
+
+	if _, found, _ := s.getIncidentsFromCache(ctx, testStatusPageUrl, impactFilter{}); !found {
+		t.Fatalf("expected incidents to be cached before the ttl")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, found, _ := s.getIncidentsFromCache(ctx, testStatusPageUrl, impactFilter{}); found {
+		t.Fatalf("expected incidents to have expired after the ttl")
+	}
+}
+
+func TestShutdownCompletesInFlightRequests(t *testing.T) {
+	dbClient := &fakeDbClient{
+		incidents:         []api.Incident{newTestIncident("a", api.ImpactMinor, time.Now())},
+		getIncidentsDelay: 200 * time.Millisecond,
+	}
+	s := newTestServerWithDb(t, dbClient)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.serve(listener) }()
+
+	// The cache is cold so the request is in flight until the slow database fetch completes
+	responses := make(chan *http.Response, 1)
+	requestErrs := make(chan error, 1)
+	go func() {
+		response, err := http.Get("http://" + listener.Addr().String() + "/api/v1/incidents?statusPageUrl=" + url.QueryEscape(testStatusPageUrl))
+		if err != nil {
+			requestErrs <- err
+			return
+		}
+		_ = response.Body.Close()
+		responses <- response
+	}()
+	for dbClient.getIncidentsCalls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("expected the server to shut down gracefully, got %v", err)
+	}
+
+	select {
+	case response := <-responses:
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("expected the in flight request to complete with status 200, got %d", response.StatusCode)
+		}
+	case err := <-requestErrs:
+		t.Fatalf("expected the in flight request to complete, got %v", err)
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		t.Fatalf("expected serving to stop with http.ErrServerClosed, got %v", err)
+	}
+	if _, err := http.Get("http://" + listener.Addr().String() + "/healthz"); err == nil {
+		t.Fatalf("expected requests after shutdown to be refused")
+	}
+}
@@ -3,7 +3,8 @@ package server
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/ikeikeikeike/go-sitemap-generator/v2/stm"
-	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
 	"net/http"
 	"net/url"
 )
@@ -11,19 +12,18 @@ import (
 func (s *Server) siteMap(context *gin.Context) {
 	sm := stm.NewSitemap(1)
 	sm.Create()
-	pages := s.statusPageCache.Items()
+	pages, err := s.getCachedStatusPages(context.Request.Context())
+	if err != nil {
+		utils.GetLogger(context.Request.Context(), s.logger).Error("failed to get status pages from cache", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get status pages from cache")
+		return
+	}
 	if len(pages) == 0 {
-		s.logger.Warn("no status pages found")
-		context.JSON(http.StatusInternalServerError, "no status pages found")
+		utils.GetLogger(context.Request.Context(), s.logger).Warn("no status pages found")
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "no status pages found")
 		return
 	}
-	for _, statusPage := range pages {
-		page, ok := statusPage.Object.(api.StatusPage)
-		if !ok {
-			s.logger.Error("failed to cast status page")
-			context.JSON(http.StatusInternalServerError, "failed to cast status page")
-			return
-		}
+	for _, page := range pages {
 		escapeString := url.QueryEscape(page.Name)
 		sm.Add(stm.URL{{"loc", "https://metoro.io/statusphere/status/" + escapeString}, {"changefreq", "always"}, {"mobile", true}, {"priority", 0.1}})
 	}
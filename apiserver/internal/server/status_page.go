@@ -3,6 +3,8 @@ package server
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
 	"net/http"
 	"strings"
 )
@@ -14,36 +16,54 @@ type StatusPageResponse struct {
 // StatusPage is a handler for the /status-page endpoint.
 // It has a required query parameter of statusPageUrl XOR statusPageName
 func (s *Server) statusPage(context *gin.Context) {
+	ctx := context.Request.Context()
 	statusPageUrl := context.Query("statusPageUrl")
 	statusPageName := strings.ToLower(context.Query("statusPageName"))
 
 	if statusPageUrl == "" && statusPageName == "" {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "statusPageUrl or statusPageName is required"})
+		writeError(context, http.StatusBadRequest, ErrorCodeStatusPageRequired, "statusPageUrl or statusPageName is required")
 		return
 	}
 
 	if statusPageUrl != "" && statusPageName != "" {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "statusPageUrl and statusPageName are mutually exclusive"})
+		writeError(context, http.StatusBadRequest, ErrorCodeConflictingStatusPageFilters, "statusPageUrl and statusPageName are mutually exclusive")
 		return
 	}
 
 	if statusPageUrl != "" {
-		statusPage, found := s.statusPageCache.Get(statusPageUrl)
+		canonicalUrl, err := api.CanonicalStatusPageUrl(statusPageUrl)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidStatusPageUrl, "statusPageUrl is invalid, "+err.Error())
+			return
+		}
+		statusPageUrl = canonicalUrl
+		statusPage, found, err := s.statusPageCache.Get(ctx, statusPageUrl)
+		if err != nil {
+			utils.GetLogger(ctx, s.logger).Error("failed to get status page from cache", zap.Error(err))
+			writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get status page from cache")
+			return
+		}
 		if !found {
-			context.JSON(http.StatusNotFound, gin.H{"error": "status page not known to statusphere"})
+			writeError(context, http.StatusNotFound, ErrorCodeStatusPageNotKnown, "status page not known to statusphere")
 			return
 		}
-		context.JSON(http.StatusOK, StatusPageResponse{StatusPage: statusPage.(api.StatusPage)})
+		context.JSON(http.StatusOK, StatusPageResponse{StatusPage: statusPage})
 		return
 	}
 
 	if statusPageName != "" {
-		for _, statusPage := range s.statusPageCache.Items() {
-			if strings.ToLower(statusPage.Object.(api.StatusPage).Name) == statusPageName {
-				context.JSON(http.StatusOK, StatusPageResponse{StatusPage: statusPage.Object.(api.StatusPage)})
+		statusPages, err := s.getCachedStatusPages(ctx)
+		if err != nil {
+			utils.GetLogger(ctx, s.logger).Error("failed to get status pages from cache", zap.Error(err))
+			writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get status pages from cache")
+			return
+		}
+		for _, statusPage := range statusPages {
+			if strings.ToLower(statusPage.Name) == statusPageName {
+				context.JSON(http.StatusOK, StatusPageResponse{StatusPage: statusPage})
 				return
 			}
 		}
-		context.JSON(http.StatusNotFound, gin.H{"error": "status page not known to statusphere"})
+		writeError(context, http.StatusNotFound, ErrorCodeStatusPageNotKnown, "status page not known to statusphere")
 	}
 }
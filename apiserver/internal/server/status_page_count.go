@@ -2,6 +2,8 @@ package server
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
 	"net/http"
 )
 
@@ -11,5 +13,12 @@ type StatusPageCountResponse struct {
 
 // statusPageCount is a handler for the /statusPages/count endpoint.
 func (s *Server) statusPageCount(context *gin.Context) {
-	context.JSON(http.StatusOK, StatusPageCountResponse{StatusPageCount: s.statusPageCache.ItemCount()})
+	ctx := context.Request.Context()
+	statusPages, err := s.getCachedStatusPages(ctx)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to get status pages from cache", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get status pages from cache")
+		return
+	}
+	context.JSON(http.StatusOK, StatusPageCountResponse{StatusPageCount: len(statusPages)})
 }
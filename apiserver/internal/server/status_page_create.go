@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/db"
+	"github.com/metoro-io/statusphere/common/utils"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const statusPageCheckTimeout = 10 * time.Second
+
+type CreateStatusPageRequest struct {
+	URL string `json:"url" binding:"required"`
+	// Name defaults to the host of the url
+	Name string `json:"name"`
+}
+
+// createStatusPage is a handler for the POST /statusPages endpoint.
+// It takes a json body with a required field of url and an optional field of name
+// It checks that the status page is reachable and then adds it to statusphere unindexed, the scraper will index it the next time it runs.
+// It returns a 202 with the created status page, a 409 if the status page is already known or a 400 if the url is not a reachable http(s) url.
+// It returns a 403 if the host of the status page isn't allowed to be indexed, see hosts.Config.
+func (s *Server) createStatusPage(context *gin.Context) {
+	ctx := context.Request.Context()
+	var request CreateStatusPageRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidBody, "url is required")
+		return
+	}
+
+	statusPageUrl, err := normalizeStatusPageUrl(request.URL)
+	if err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidStatusPageUrl, err.Error())
+		return
+	}
+
+	if !s.config.Hosts.AllowedUrl(statusPageUrl) {
+		writeError(context, http.StatusForbidden, ErrorCodeStatusPageHostNotAllowed, "status pages of the host are not allowed to be indexed")
+		return
+	}
+
+	_, found, err := s.statusPageCache.Get(ctx, statusPageUrl)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to get status page from cache", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get status page from cache")
+		return
+	}
+	if found {
+		writeError(context, http.StatusConflict, ErrorCodeStatusPageAlreadyKnown, "status page already known to statusphere")
+		return
+	}
+
+	if err := s.statusPageChecker(ctx, statusPageUrl); err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeStatusPageNotReachable, "status page is not reachable")
+		return
+	}
+
+	name := request.Name
+	if name == "" {
+		parsed, _ := url.Parse(statusPageUrl)
+		name = parsed.Host
+	}
+	statusPage := api.StatusPage{Name: name, URL: statusPageUrl, IsIndexed: false}
+	if err := s.dbClient.CreateStatusPage(ctx, statusPage); err != nil {
+		if errors.Is(err, db.ErrStatusPageExists) {
+			writeError(context, http.StatusConflict, ErrorCodeStatusPageAlreadyKnown, "status page already known to statusphere")
+			return
+		}
+		utils.GetLogger(ctx, s.logger).Error("failed to create status page", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to create status page")
+		return
+	}
+	// Cache the status page straight away rather than waiting for the next refresh
+	if err := s.setCachedStatusPage(ctx, statusPage); err != nil {
+		utils.GetLogger(ctx, s.logger).Warn("failed to set status page in cache", zap.Error(err))
+	}
+
+	context.JSON(http.StatusAccepted, statusPage)
+}
+
+// normalizeStatusPageUrl checks that the url is an absolute http(s) url and removes anything that isn't part of the status page url
+// The urls of status pages are stored in their canonical form, see api.CanonicalStatusPageUrl
+func normalizeStatusPageUrl(rawUrl string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(rawUrl))
+	if err != nil {
+		return "", errors.New("url is invalid")
+	}
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+	return api.CanonicalStatusPageUrl(parsed.String())
+}
+
+// newStatusPageChecker returns a function that checks that a status page responds successfully to a get request
+// It refuses to connect to private addresses so that it can't be used to probe the network the api server runs in
+func newStatusPageChecker() func(ctx context.Context, statusPageUrl string) error {
+	client := utils.NewPublicHttpClient(statusPageCheckTimeout)
+	return func(ctx context.Context, statusPageUrl string) error {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, statusPageUrl, nil)
+		if err != nil {
+			return errors.Wrap(err, "failed to create request")
+		}
+		response, err := client.Do(request)
+		if err != nil {
+			return errors.Wrap(err, "failed to get status page")
+		}
+		defer response.Body.Close()
+		if response.StatusCode < 200 || response.StatusCode >= 300 {
+			return errors.Errorf("status page responded with %d", response.StatusCode)
+		}
+		return nil
+	}
+}
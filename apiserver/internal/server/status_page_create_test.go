@@ -0,0 +1,106 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/hosts"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func postStatusPage(s *Server, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodPost, "/api/v1/statusPages", bytes.NewBufferString(body))
+	testContext.Request.Header.Set("Content-Type", "application/json")
+	s.createStatusPage(testContext)
+	return recorder
+}
+
+func TestCreateStatusPage(t *testing.T) {
+	dbClient := &fakeDbClient{}
+	s := newTestServerWithDb(t, dbClient)
+	s.statusPageChecker = func(ctx context.Context, statusPageUrl string) error {
+		if statusPageUrl == "https://unreachable.example.com" {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	recorder := postStatusPage(s, `{"url": "https://New.example.com/?utm_source=x"}`)
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var created api.StatusPage
+	if err := json.Unmarshal(recorder.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if created.URL != "https://new.example.com" || created.Name != "new.example.com" || created.IsIndexed {
+		t.Fatalf("unexpected created status page %+v", created)
+	}
+	if len(dbClient.statusPages) != 2 {
+		t.Fatalf("expected the status page to be stored in the database")
+	}
+
+	tests := []struct {
+		body               string
+		expectedStatusCode int
+	}{
+		{body: `{"url": "https://new.example.com/"}`, expectedStatusCode: http.StatusConflict},
+		{body: `{"url": "` + testStatusPageUrl + `"}`, expectedStatusCode: http.StatusConflict},
+		{body: `{"url": "ftp://files.example.com"}`, expectedStatusCode: http.StatusBadRequest},
+		{body: `{"url": "not a url"}`, expectedStatusCode: http.StatusBadRequest},
+		{body: `{}`, expectedStatusCode: http.StatusBadRequest},
+		{body: `{"url": "https://unreachable.example.com"}`, expectedStatusCode: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		if recorder := postStatusPage(s, test.body); recorder.Code != test.expectedStatusCode {
+			t.Errorf("%s: expected status %d, got %d", test.body, test.expectedStatusCode, recorder.Code)
+		}
+	}
+}
+
+func TestCreateStatusPageRejectsHostsThatAreNotAllowed(t *testing.T) {
+	dbClient := &fakeDbClient{}
+	s := newTestServerWithDb(t, dbClient)
+	s.statusPageChecker = func(ctx context.Context, statusPageUrl string) error { return nil }
+	s.config.Hosts = hosts.Config{Allow: []hosts.Pattern{"*.example.com"}, Deny: []hosts.Pattern{"internal.example.com"}}
+
+	tests := []struct {
+		body               string
+		expectedStatusCode int
+		expectedCode       ErrorCode
+	}{
+		{body: `{"url": "https://new.example.com"}`, expectedStatusCode: http.StatusAccepted},
+		{body: `{"url": "https://Internal.example.com/"}`, expectedStatusCode: http.StatusForbidden, expectedCode: ErrorCodeStatusPageHostNotAllowed},
+		{body: `{"url": "https://status.unlisted.com"}`, expectedStatusCode: http.StatusForbidden, expectedCode: ErrorCodeStatusPageHostNotAllowed},
+	}
+	for _, test := range tests {
+		recorder := postStatusPage(s, test.body)
+		if recorder.Code != test.expectedStatusCode {
+			t.Errorf("%s: expected status %d, got %d", test.body, test.expectedStatusCode, recorder.Code)
+			continue
+		}
+		var response ErrorResponse
+		if test.expectedCode != "" && (json.Unmarshal(recorder.Body.Bytes(), &response) != nil || response.Code != test.expectedCode) {
+			t.Errorf("%s: expected the error code %s, got %s", test.body, test.expectedCode, recorder.Body.String())
+		}
+	}
+	if len(dbClient.statusPages) != 2 {
+		t.Fatalf("expected only the allowed status page to be stored, got %+v", dbClient.statusPages)
+	}
+}
+
+func TestStatusPageCheckerRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	if err := newStatusPageChecker()(context.Background(), server.URL); err == nil {
+		t.Fatalf("expected the checker to refuse to connect to a loopback address")
+	}
+}
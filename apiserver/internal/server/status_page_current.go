@@ -0,0 +1,73 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+)
+
+type OverallStatus string
+
+const (
+	OverallStatusOperational OverallStatus = "operational"
+	OverallStatusMaintenance OverallStatus = "maintenance"
+	OverallStatusDegraded    OverallStatus = "degraded"
+	OverallStatusMajorOutage OverallStatus = "major_outage"
+	// OverallStatusUnknown is the status of status pages that aren't indexed, as their incidents aren't known
+	OverallStatusUnknown OverallStatus = "unknown"
+)
+
+type StatusPageCurrentResponse struct {
+	StatusPageUrl string        `json:"statusPageUrl"`
+	Status        OverallStatus `json:"status"`
+	// WorstImpact is the most severe impact of the ongoing incidents, it is omitted when nothing is ongoing
+	WorstImpact          *api.Impact `json:"worstImpact,omitempty"`
+	OngoingIncidentCount int         `json:"ongoingIncidentCount"`
+	IsIndexed            bool        `json:"isIndexed"`
+}
+
+// statusPageCurrent is a handler for the /statusPages/current endpoint.
+// It has a required query parameter of statusPageUrl
+// It returns a single verdict for the status page derived from the worst impact of its ongoing incidents, see overallStatus
+// It returns operational when nothing is ongoing and unknown if the status page is not indexed
+func (s *Server) statusPageCurrent(context *gin.Context) {
+	statusPageUrl, ok := parseStatusPageUrlQuery(context)
+	if !ok {
+		return
+	}
+
+	statusPage, incidents, ok := s.getStatusPageIncidents(context, statusPageUrl, impactFilter{})
+	if !ok {
+		return
+	}
+	if !statusPage.IsIndexed {
+		writeJSONWithETag(context, StatusPageCurrentResponse{StatusPageUrl: statusPageUrl, Status: OverallStatusUnknown, IsIndexed: false})
+		return
+	}
+
+	ongoing := filterOngoingIncidents(incidents)
+	response := StatusPageCurrentResponse{StatusPageUrl: statusPageUrl, Status: OverallStatusOperational, OngoingIncidentCount: len(ongoing), IsIndexed: true}
+	for _, incident := range ongoing {
+		if response.WorstImpact == nil || impactSeverity[incident.Impact] > impactSeverity[*response.WorstImpact] {
+			impact := incident.Impact
+			response.WorstImpact = &impact
+		}
+	}
+	if response.WorstImpact != nil {
+		response.Status = overallStatus(*response.WorstImpact)
+	}
+	writeJSONWithETag(context, response)
+}
+
+// overallStatus maps the worst impact of the ongoing incidents of a status page to its overall status
+func overallStatus(worstImpact api.Impact) OverallStatus {
+	switch worstImpact {
+	case api.ImpactCritical:
+		return OverallStatusMajorOutage
+	case api.ImpactMajor, api.ImpactMinor:
+		return OverallStatusDegraded
+	case api.ImpactMaintenance:
+		return OverallStatusMaintenance
+	default:
+		return OverallStatusOperational
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func getStatusPageCurrent(t *testing.T, s *Server, statusPageUrl string) StatusPageCurrentResponse {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/statusPages/current?statusPageUrl="+url.QueryEscape(statusPageUrl), nil)
+	s.statusPageCurrent(testContext)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("%s: expected status 200, got %d", statusPageUrl, recorder.Code)
+	}
+	var response StatusPageCurrentResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	return response
+}
+
+func TestStatusPageCurrent(t *testing.T) {
+	now := time.Now()
+	resolvedAt := now.Add(-time.Minute)
+	tests := []struct {
+		name           string
+		impacts        []api.Impact
+		resolved       bool
+		expectedStatus OverallStatus
+		expectedCount  int
+	}{
+		{name: "nothing ongoing", expectedStatus: OverallStatusOperational},
+		{name: "resolved outage", impacts: []api.Impact{api.ImpactCritical}, resolved: true, expectedStatus: OverallStatusOperational},
+		{name: "maintenance", impacts: []api.Impact{api.ImpactMaintenance}, expectedStatus: OverallStatusMaintenance, expectedCount: 1},
+		{name: "minor and maintenance", impacts: []api.Impact{api.ImpactMaintenance, api.ImpactMinor}, expectedStatus: OverallStatusDegraded, expectedCount: 2},
+		{name: "major", impacts: []api.Impact{api.ImpactMajor}, expectedStatus: OverallStatusDegraded, expectedCount: 1},
+		{name: "critical and minor", impacts: []api.Impact{api.ImpactMinor, api.ImpactCritical}, expectedStatus: OverallStatusMajorOutage, expectedCount: 2},
+	}
+	for _, test := range tests {
+		dbClient := &fakeDbClient{}
+		for i, impact := range test.impacts {
+			incident := newTestIncident(test.name+string(impact), impact, now.Add(-time.Duration(i)*time.Hour))
+			if test.resolved {
+				incident.EndTime = &resolvedAt
+			}
+			dbClient.incidents = append(dbClient.incidents, incident)
+		}
+		s := newTestServerWithDb(t, dbClient)
+
+		response := getStatusPageCurrent(t, s, testStatusPageUrl)
+		if response.Status != test.expectedStatus || response.OngoingIncidentCount != test.expectedCount || !response.IsIndexed {
+			t.Fatalf("%s: expected %s with %d ongoing incidents, got %+v", test.name, test.expectedStatus, test.expectedCount, response)
+		}
+		if (response.WorstImpact == nil) != (test.expectedCount == 0) {
+			t.Fatalf("%s: expected a worst impact only when incidents are ongoing, got %+v", test.name, response)
+		}
+	}
+}
+
+func TestStatusPageCurrentOfUnindexedStatusPage(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{})
+	unindexedUrl := "https://status.unindexed.com"
+	s.setCachedStatusPage(context.Background(), api.StatusPage{URL: unindexedUrl})
+
+	if response := getStatusPageCurrent(t, s, unindexedUrl); response.Status != OverallStatusUnknown || response.IsIndexed {
+		t.Fatalf("expected an unknown status, got %+v", response)
+	}
+
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/statusPages/current?statusPageUrl="+url.QueryEscape("https://status.unknown.com"), nil)
+	s.statusPageCurrent(testContext)
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for an unknown status page, got %d", recorder.Code)
+	}
+}
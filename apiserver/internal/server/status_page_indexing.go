@@ -0,0 +1,83 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"time"
+)
+
+type IndexingState string
+
+const (
+	// IndexingStateNeverIndexed means the status page hasn't been scraped yet
+	IndexingStateNeverIndexed IndexingState = "NEVER_INDEXED"
+	// IndexingStateIndexFailed means the status page has been scraped but never successfully
+	IndexingStateIndexFailed IndexingState = "INDEX_FAILED"
+	// IndexingStateIndexed means the last scrape of the status page succeeded
+	IndexingStateIndexed IndexingState = "INDEXED"
+	// IndexingStateLastAttemptFailed means the status page has been indexed but the last scrape failed, so its incidents may be out of date
+	IndexingStateLastAttemptFailed IndexingState = "LAST_ATTEMPT_FAILED"
+)
+
+type IndexingStatusResponse struct {
+	StatusPageUrl string        `json:"statusPageUrl"`
+	State         IndexingState `json:"state"`
+	IsIndexed     bool          `json:"isIndexed"`
+	// LastIndexedAt is when the status page was last scraped successfully, it is null if that isn't known
+	LastIndexedAt *time.Time `json:"lastIndexedAt"`
+	// LastAttemptAt is when the status page was last scraped, it is null if it has never been scraped
+	LastAttemptAt *time.Time `json:"lastAttemptAt"`
+	// LastError is the error of the last scrape, it is null if the last scrape succeeded
+	LastError *string `json:"lastError"`
+	// IndexingPaused is true if the scraping of the status page has been paused by an admin, see /admin/statusPages/pause
+	IndexingPaused bool `json:"indexingPaused"`
+}
+
+// statusPageIndexingStatus is a handler for the /statusPages/status endpoint.
+// It has a required query parameter of statusPageUrl
+// It returns whether the status page has been indexed, when it was last scraped, the error of the last scrape if it failed
+// and whether its indexing has been paused.
+// If the status page is not known to statusphere, it returns a 404.
+func (s *Server) statusPageIndexingStatus(context *gin.Context) {
+	statusPageUrl, ok := parseStatusPageUrlQuery(context)
+	if !ok {
+		return
+	}
+
+	statusPage, lookupErr := s.lookupStatusPage(context.Request.Context(), statusPageUrl)
+	if lookupErr != nil {
+		lookupErr.write(context)
+		return
+	}
+
+	context.JSON(http.StatusOK, indexingStatus(statusPage))
+}
+
+func indexingStatus(statusPage api.StatusPage) IndexingStatusResponse {
+	response := IndexingStatusResponse{StatusPageUrl: statusPage.URL, IsIndexed: statusPage.IsIndexed, IndexingPaused: statusPage.IndexingPaused}
+	if !statusPage.LastSuccessfullyScraped.IsZero() {
+		lastIndexedAt := statusPage.LastSuccessfullyScraped
+		response.LastIndexedAt = &lastIndexedAt
+	}
+	if !statusPage.LastCurrentlyScraped.IsZero() {
+		lastAttemptAt := statusPage.LastCurrentlyScraped
+		response.LastAttemptAt = &lastAttemptAt
+	}
+	if statusPage.LastScrapeError != "" {
+		lastError := statusPage.LastScrapeError
+		response.LastError = &lastError
+	}
+
+	switch {
+	case response.LastError != nil && statusPage.IsIndexed:
+		response.State = IndexingStateLastAttemptFailed
+	case response.LastError != nil:
+		response.State = IndexingStateIndexFailed
+	case statusPage.IsIndexed:
+		response.State = IndexingStateIndexed
+	default:
+		response.State = IndexingStateNeverIndexed
+	}
+	return response
+}
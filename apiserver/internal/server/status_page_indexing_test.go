@@ -0,0 +1,35 @@
+package server
+
+import (
+	"github.com/metoro-io/statusphere/common/api"
+	"testing"
+	"time"
+)
+
+func TestIndexingStatusStates(t *testing.T) {
+	attempted := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	indexed := attempted.Add(-time.Hour)
+
+	tests := []struct {
+		name          string
+		statusPage    api.StatusPage
+		expectedState IndexingState
+	}{
+		{name: "never scraped", statusPage: api.StatusPage{}, expectedState: IndexingStateNeverIndexed},
+		{name: "never scraped successfully", statusPage: api.StatusPage{LastCurrentlyScraped: attempted, LastScrapeError: "failed"}, expectedState: IndexingStateIndexFailed},
+		{name: "last scrape succeeded", statusPage: api.StatusPage{IsIndexed: true, LastCurrentlyScraped: attempted, LastSuccessfullyScraped: attempted}, expectedState: IndexingStateIndexed},
+		{name: "last scrape failed", statusPage: api.StatusPage{IsIndexed: true, LastCurrentlyScraped: attempted, LastSuccessfullyScraped: indexed, LastScrapeError: "failed"}, expectedState: IndexingStateLastAttemptFailed},
+	}
+	for _, test := range tests {
+		status := indexingStatus(test.statusPage)
+		if status.State != test.expectedState {
+			t.Errorf("%s: expected state %s, got %s", test.name, test.expectedState, status.State)
+		}
+		if (status.LastAttemptAt == nil) != test.statusPage.LastCurrentlyScraped.IsZero() || (status.LastIndexedAt == nil) != test.statusPage.LastSuccessfullyScraped.IsZero() {
+			t.Errorf("%s: unexpected timestamps %+v", test.name, status)
+		}
+		if (status.LastError == nil) != (test.statusPage.LastScrapeError == "") {
+			t.Errorf("%s: unexpected error %v", test.name, status.LastError)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/lithammer/fuzzysearch/fuzzy"
 	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
 	"math"
 	"net/http"
 	"sort"
@@ -26,7 +28,7 @@ type statusPageRanked struct {
 func (s *Server) statusPageSearch(context *gin.Context) {
 	query := context.Query("query")
 	if query == "" {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "query is required"})
+		writeError(context, http.StatusBadRequest, ErrorCodeQueryRequired, "query is required")
 		return
 	}
 
@@ -34,10 +36,17 @@ func (s *Server) statusPageSearch(context *gin.Context) {
 
 	var statusPagesRanked []statusPageRanked
 
-	for _, statusPage := range s.statusPageCache.Items() {
+	ctx := context.Request.Context()
+	cachedStatusPages, err := s.getCachedStatusPages(ctx)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to get status pages from cache", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get status pages from cache")
+		return
+	}
+	for _, statusPage := range cachedStatusPages {
 		score := math.MaxInt
-		nameMatch := fuzzy.RankMatch(query, strings.ToLower(statusPage.Object.(api.StatusPage).Name))
-		urlMatch := fuzzy.RankMatch(query, strings.ToLower(statusPage.Object.(api.StatusPage).URL))
+		nameMatch := fuzzy.RankMatch(query, strings.ToLower(statusPage.Name))
+		urlMatch := fuzzy.RankMatch(query, strings.ToLower(statusPage.URL))
 		if nameMatch != -1 {
 			score = nameMatch
 		}
@@ -48,7 +57,7 @@ func (s *Server) statusPageSearch(context *gin.Context) {
 		}
 
 		if score != math.MaxInt {
-			statusPagesRanked = append(statusPagesRanked, statusPageRanked{StatusPage: statusPage.Object.(api.StatusPage), Score: score})
+			statusPagesRanked = append(statusPagesRanked, statusPageRanked{StatusPage: statusPage, Score: score})
 		}
 	}
 
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const defaultStatsDays = 30
+
+type StatsResponse struct {
+	StatusPageUrl string `json:"statusPageUrl"`
+	IsIndexed     bool   `json:"isIndexed"`
+	Days          int    `json:"days"`
+	IncidentCount int    `json:"incidentCount"`
+	// ImpactCounts has an entry for every impact, even if there are no incidents with that impact
+	ImpactCounts map[api.Impact]int `json:"impactCounts"`
+	// TotalDowntimeSeconds is the time within the window covered by incidents that are not maintenance, ongoing incidents count up to now
+	TotalDowntimeSeconds int64 `json:"totalDowntimeSeconds"`
+	// MeanTimeToResolutionSeconds is the mean duration of the resolved incidents that are not maintenance, it is null if there are none
+	MeanTimeToResolutionSeconds *int64 `json:"meanTimeToResolutionSeconds"`
+	// ImpactDurationSeconds is the time within the window spent at each of the minor, major and critical impacts
+	// When incidents overlap, only the worst impact at each instant counts, so the durations never add up to more than the window
+	ImpactDurationSeconds map[api.Impact]int64 `json:"impactDurationSeconds"`
+	// SlaBreachCount is the number of incidents in the window that took longer to resolve than the sla target of their impact,
+	// ongoing incidents that are already past their target are counted
+	SlaBreachCount int `json:"slaBreachCount"`
+	// SlaBreachCounts has an entry for every impact with an sla target, even if none of its incidents breached it
+	SlaBreachCounts map[api.Impact]int `json:"slaBreachCounts"`
+}
+
+// statusPageStats is a handler for the /statusPages/stats endpoint.
+// It has a required query parameter of statusPageUrl
+// It has an optional query parameter of days (default is 30), which is the number of days before now to aggregate incidents over
+// It returns the number of incidents per impact, the total downtime, the mean time to resolution of the incidents in the window
+// and how long the status page spent at each impact, see impactDurations.
+// It also returns how many of the incidents breached the sla target of their impact, see api.Incident.IsSlaBreachedAt.
+// Incidents that started before the window but are still ongoing are included.
+// If the status page is not known to statusphere, it returns a 404.
+func (s *Server) statusPageStats(context *gin.Context) {
+	statusPageUrl, ok := parseStatusPageUrlQuery(context)
+	if !ok {
+		return
+	}
+
+	days := defaultStatsDays
+	if daysStr := context.Query("days"); daysStr != "" {
+		daysInt, err := strconv.Atoi(daysStr)
+		if err != nil || daysInt <= 0 {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidDays, "days must be a positive integer")
+			return
+		}
+		days = daysInt
+	}
+
+	statusPage, incidents, ok := s.getStatusPageIncidents(context, statusPageUrl, impactFilter{})
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	stats := computeIncidentStats(incidents, now.AddDate(0, 0, -days), now, s.config.Incidents.SlaTargets)
+	stats.StatusPageUrl = statusPage.URL
+	stats.IsIndexed = statusPage.IsIndexed
+	stats.Days = days
+	context.JSON(http.StatusOK, stats)
+}
+
+// computeIncidentStats aggregates the incidents that overlap the window from windowStart to now.
+// The sla targets are the target resolution times of the impacts, incidents of impacts without one never breach it
+func computeIncidentStats(incidents []api.Incident, windowStart time.Time, now time.Time, slaTargets map[string]time.Duration) StatsResponse {
+	stats := StatsResponse{
+		ImpactCounts: map[api.Impact]int{
+			api.ImpactNone:        0,
+			api.ImpactMinor:       0,
+			api.ImpactMajor:       0,
+			api.ImpactCritical:    0,
+			api.ImpactMaintenance: 0,
+		},
+		SlaBreachCounts: map[api.Impact]int{},
+	}
+	for _, impact := range api.Impacts {
+		if slaTargets[string(impact)] > 0 {
+			stats.SlaBreachCounts[impact] = 0
+		}
+	}
+
+	timeRange := incidentTimeRange{from: &windowStart, to: &now, includeOngoing: true}
+	var totalDowntime time.Duration
+	var totalTimeToResolution time.Duration
+	resolvedCount := 0
+	for _, incident := range incidents {
+		if !timeRange.contains(incident) {
+			continue
+		}
+		stats.IncidentCount++
+		stats.ImpactCounts[incident.Impact]++
+		if incident.IsSlaBreachedAt(now, slaTargets[string(incident.Impact)]) {
+			stats.SlaBreachCount++
+			stats.SlaBreachCounts[incident.Impact]++
+		}
+		if incident.Impact == api.ImpactMaintenance {
+			continue
+		}
+
+		end := now
+		if !incident.IsOngoing() {
+			end = *incident.EndTime
+			totalTimeToResolution += end.Sub(incident.StartTime)
+			resolvedCount++
+		}
+		// Only count the downtime within the window
+		start := incident.StartTime
+		if start.Before(windowStart) {
+			start = windowStart
+		}
+		if end.After(start) {
+			totalDowntime += end.Sub(start)
+		}
+	}
+
+	stats.TotalDowntimeSeconds = int64(totalDowntime.Seconds())
+	stats.ImpactDurationSeconds = map[api.Impact]int64{}
+	for impact, duration := range impactDurations(incidents, windowStart, now) {
+		stats.ImpactDurationSeconds[impact] = int64(duration.Seconds())
+	}
+	if resolvedCount > 0 {
+		meanTimeToResolution := int64((totalTimeToResolution / time.Duration(resolvedCount)).Seconds())
+		stats.MeanTimeToResolutionSeconds = &meanTimeToResolution
+	}
+	return stats
+}
+
+// impactDurations returns how long the worst impact of the incidents ongoing at each instant was minor, major and critical
+// between windowStart and now, ongoing incidents last until now
+// Overlapping incidents aren't double counted, e.g. a major incident during a critical one only counts the time outside of the critical one
+func impactDurations(incidents []api.Incident, windowStart time.Time, now time.Time) map[api.Impact]time.Duration {
+	durations := map[api.Impact]time.Duration{
+		api.ImpactMinor:    0,
+		api.ImpactMajor:    0,
+		api.ImpactCritical: 0,
+	}
+
+	// Each incident starts and ends an interval of its impact, clamped to the window
+	type boundary struct {
+		at     time.Time
+		impact api.Impact
+		delta  int
+	}
+	var boundaries []boundary
+	for _, incident := range incidents {
+		if _, tracked := durations[incident.Impact]; !tracked {
+			continue
+		}
+		start, end := incident.StartTime, now
+		if !incident.IsOngoing() {
+			end = *incident.EndTime
+		}
+		if start.Before(windowStart) {
+			start = windowStart
+		}
+		if end.After(now) {
+			end = now
+		}
+		if !end.After(start) {
+			continue
+		}
+		boundaries = append(boundaries, boundary{at: start, impact: incident.Impact, delta: 1}, boundary{at: end, impact: incident.Impact, delta: -1})
+	}
+	sort.Slice(boundaries, func(i, j int) bool {
+		return boundaries[i].at.Before(boundaries[j].at)
+	})
+
+	// Sweep through the boundaries, between two boundaries the worst impact with an incident ongoing accrues the time
+	ongoing := map[api.Impact]int{}
+	for i, b := range boundaries {
+		ongoing[b.impact] += b.delta
+		if i+1 == len(boundaries) {
+			break
+		}
+		elapsed := boundaries[i+1].at.Sub(b.at)
+		if elapsed <= 0 {
+			continue
+		}
+		var worst api.Impact
+		for impact, count := range ongoing {
+			if count > 0 && (worst == "" || impactSeverity[impact] > impactSeverity[worst]) {
+				worst = impact
+			}
+		}
+		if worst != "" {
+			durations[worst] += elapsed
+		}
+	}
+	return durations
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestComputeIncidentStats(t *testing.T) {
+	now := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	windowStart := now.AddDate(0, 0, -30)
+
+	resolvedEnd := now.Add(-10 * 24 * time.Hour)
+	resolved := newTestIncident("resolved", api.ImpactMajor, resolvedEnd.Add(-3*time.Hour))
+	resolved.EndTime = &resolvedEnd
+	// Started before the window and is still ongoing, so only the time within the window is downtime
+	ongoing := newTestIncident("ongoing", api.ImpactCritical, windowStart.Add(-time.Hour))
+	maintenanceEnd := now.Add(-2 * 24 * time.Hour)
+	maintenance := newTestIncident("maintenance", api.ImpactMaintenance, maintenanceEnd.Add(-8*time.Hour))
+	maintenance.EndTime = &maintenanceEnd
+	outsideEnd := windowStart.Add(-time.Hour)
+	outside := newTestIncident("outside", api.ImpactMinor, outsideEnd.Add(-time.Hour))
+	outside.EndTime = &outsideEnd
+
+	stats := computeIncidentStats([]api.Incident{resolved, ongoing, maintenance, outside}, windowStart, now, nil)
+
+	if stats.IncidentCount != 3 {
+		t.Fatalf("expected 3 incidents, got %d", stats.IncidentCount)
+	}
+	if stats.ImpactCounts[api.ImpactMajor] != 1 || stats.ImpactCounts[api.ImpactCritical] != 1 || stats.ImpactCounts[api.ImpactMaintenance] != 1 || stats.ImpactCounts[api.ImpactMinor] != 0 {
+		t.Fatalf("unexpected impact counts %v", stats.ImpactCounts)
+	}
+	if expected := int64((3*time.Hour + now.Sub(windowStart)).Seconds()); stats.TotalDowntimeSeconds != expected {
+		t.Fatalf("expected %ds of downtime, got %ds", expected, stats.TotalDowntimeSeconds)
+	}
+	if stats.MeanTimeToResolutionSeconds == nil || *stats.MeanTimeToResolutionSeconds != int64((3*time.Hour).Seconds()) {
+		t.Fatalf("expected a mean time to resolution of 3h, got %v", stats.MeanTimeToResolutionSeconds)
+	}
+}
+
+func TestImpactDurationsOfOverlappingIncidents(t *testing.T) {
+	now := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	windowStart := now.Add(-24 * time.Hour)
+	at := func(hours int) time.Time { return windowStart.Add(time.Duration(hours) * time.Hour) }
+	incident := func(impact api.Impact, start int, end int) api.Incident {
+		incident := newTestIncident(string(impact)+strconv.Itoa(start), impact, at(start))
+		endTime := at(end)
+		incident.EndTime = &endTime
+		return incident
+	}
+
+	tests := []struct {
+		name      string
+		incidents []api.Incident
+		expected  map[api.Impact]time.Duration
+	}{
+		{
+			name:      "no incidents",
+			incidents: nil,
+			expected:  map[api.Impact]time.Duration{},
+		},
+		{
+			// major from 2 to 10, critical from 4 to 6 within it
+			name:      "critical during major",
+			incidents: []api.Incident{incident(api.ImpactMajor, 2, 10), incident(api.ImpactCritical, 4, 6)},
+			expected:  map[api.Impact]time.Duration{api.ImpactMajor: 6 * time.Hour, api.ImpactCritical: 2 * time.Hour},
+		},
+		{
+			// Two minor incidents overlapping from 2 to 4 only count once
+			name:      "overlapping incidents of the same impact",
+			incidents: []api.Incident{incident(api.ImpactMinor, 1, 4), incident(api.ImpactMinor, 2, 6)},
+			expected:  map[api.Impact]time.Duration{api.ImpactMinor: 5 * time.Hour},
+		},
+		{
+			// Minor from 0 to 12, major from 3 to 8 and critical from 6 to 9 overlapping both
+			name:      "staggered incidents",
+			incidents: []api.Incident{incident(api.ImpactMinor, 0, 12), incident(api.ImpactMajor, 3, 8), incident(api.ImpactCritical, 6, 9)},
+			expected:  map[api.Impact]time.Duration{api.ImpactMinor: 6 * time.Hour, api.ImpactMajor: 3 * time.Hour, api.ImpactCritical: 3 * time.Hour},
+		},
+		{
+			// Maintenance doesn't count, and a gap between incidents doesn't count for either
+			name:      "maintenance and gaps",
+			incidents: []api.Incident{incident(api.ImpactMaintenance, 0, 24), incident(api.ImpactMajor, 1, 2), incident(api.ImpactMajor, 5, 7)},
+			expected:  map[api.Impact]time.Duration{api.ImpactMajor: 3 * time.Hour},
+		},
+		{
+			// Clamped to the window on both sides, the ongoing incident lasts until now
+			name:      "clamped to the window",
+			incidents: []api.Incident{incident(api.ImpactMajor, -5, 2), newTestIncident("ongoing", api.ImpactCritical, at(20))},
+			expected:  map[api.Impact]time.Duration{api.ImpactMajor: 2 * time.Hour, api.ImpactCritical: 4 * time.Hour},
+		},
+	}
+	for _, test := range tests {
+		durations := impactDurations(test.incidents, windowStart, now)
+		for _, impact := range []api.Impact{api.ImpactMinor, api.ImpactMajor, api.ImpactCritical} {
+			if durations[impact] != test.expected[impact] {
+				t.Fatalf("%s: expected %s at %s, got %s", test.name, test.expected[impact], impact, durations[impact])
+			}
+		}
+	}
+}
+
+func TestComputeIncidentStatsCountsSlaBreaches(t *testing.T) {
+	now := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	windowStart := now.AddDate(0, 0, -30)
+	slaTargets := map[string]time.Duration{"critical": time.Hour, "major": 4 * time.Hour}
+
+	within := newTestIncident("within", api.ImpactCritical, now.Add(-48*time.Hour))
+	withinEnd := within.StartTime.Add(30 * time.Minute)
+	within.EndTime = &withinEnd
+	breached := newTestIncident("breached", api.ImpactMajor, now.Add(-48*time.Hour))
+	breachedEnd := breached.StartTime.Add(5 * time.Hour)
+	breached.EndTime = &breachedEnd
+	// Ongoing incidents count as soon as they're past their target
+	ongoing := newTestIncident("ongoing", api.ImpactCritical, now.Add(-2*time.Hour))
+	// Impacts without a target are never breached
+	minor := newTestIncident("minor", api.ImpactMinor, now.Add(-48*time.Hour))
+	// Incidents outside the window aren't counted
+	outside := newTestIncident("outside", api.ImpactMajor, windowStart.Add(-48*time.Hour))
+	outsideEnd := outside.StartTime.Add(24 * time.Hour)
+	outside.EndTime = &outsideEnd
+
+	stats := computeIncidentStats([]api.Incident{within, breached, ongoing, minor, outside}, windowStart, now, slaTargets)
+	if stats.SlaBreachCount != 2 {
+		t.Fatalf("expected 2 sla breaches, got %d", stats.SlaBreachCount)
+	}
+	expected := map[api.Impact]int{api.ImpactCritical: 1, api.ImpactMajor: 1}
+	if !reflect.DeepEqual(stats.SlaBreachCounts, expected) {
+		t.Fatalf("expected breach counts %v, got %v", expected, stats.SlaBreachCounts)
+	}
+}
+
+func TestStatusPageStatsWithNoIncidents(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{})
+
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/statusPages/stats?days=7&statusPageUrl="+url.QueryEscape(testStatusPageUrl), nil)
+	s.statusPageStats(testContext)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response StatsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response.Days != 7 || response.IncidentCount != 0 || response.TotalDowntimeSeconds != 0 || response.MeanTimeToResolutionSeconds != nil {
+		t.Fatalf("unexpected stats for a status page with no incidents: %+v", response)
+	}
+	if len(response.ImpactCounts) != 5 {
+		t.Fatalf("expected a count for every impact, got %v", response.ImpactCounts)
+	}
+}
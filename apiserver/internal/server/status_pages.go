@@ -1,27 +1,253 @@
 package server
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type StatusPagesResponse struct {
 	StatusPages []api.StatusPage `json:"statusPages"`
+	// NextCursor is the cursor to pass to get the next page of status pages, it is empty if there are no more status pages
+	NextCursor string `json:"nextCursor"`
+	// IndexedCount and UnindexedCount are the number of status pages matching the search that have and haven't been indexed,
+	// before indexedOnly, the cursor and the limit are applied
+	IndexedCount   int `json:"indexedCount"`
+	UnindexedCount int `json:"unindexedCount"`
 }
 
+// statusPagesOrder is the order that status pages are listed in, status pages with the same name are ordered by url so that the order is deterministic
+type statusPagesOrder string
+
+const (
+	statusPagesOrderName statusPagesOrder = "name"
+	statusPagesOrderUrl  statusPagesOrder = "url"
+)
+
+func parseStatusPagesOrder(order string) (statusPagesOrder, error) {
+	switch statusPagesOrder(order) {
+	case statusPagesOrderName, statusPagesOrderUrl:
+		return statusPagesOrder(order), nil
+	default:
+		return "", errors.New("invalid status pages order")
+	}
+}
+
+// isStatusPageBefore returns true if a should be listed before b in the given order
+func isStatusPageBefore(a api.StatusPage, b api.StatusPage, order statusPagesOrder) bool {
+	if order == statusPagesOrderName {
+		aName, bName := strings.ToLower(a.Name), strings.ToLower(b.Name)
+		if aName != bName {
+			return aName < bName
+		}
+	}
+	return a.URL < b.URL
+}
+
+// statusPagesCursor marks the position of the last status page returned in a page of status pages.
+// It is handed to clients as an opaque base64 string, like incidentsCursor.
+type statusPagesCursor struct {
+	Name string `json:"n"`
+	// URL is the primary key of a status page, it is used as a tiebreaker for status pages with the same name
+	URL string `json:"u"`
+}
+
+func encodeStatusPagesCursor(statusPage api.StatusPage) string {
+	// Marshalling a struct of strings cannot fail
+	marshalled, _ := json.Marshal(statusPagesCursor{Name: statusPage.Name, URL: statusPage.URL})
+	return base64.RawURLEncoding.EncodeToString(marshalled)
+}
+
+func decodeStatusPagesCursor(cursor string) (*statusPagesCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode cursor")
+	}
+	var decodedCursor statusPagesCursor
+	if err := json.Unmarshal(decoded, &decodedCursor); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal cursor")
+	}
+	if decodedCursor.URL == "" {
+		return nil, errors.New("cursor is missing the status page")
+	}
+	return &decodedCursor, nil
+}
+
+// statusPages is a handler for the /statusPages endpoint.
+// It returns the status pages known to statusphere
+// It has an optional query parameter of indexedOnly (default is false), which only returns status pages that have been indexed
+// It has an optional query parameter of search, which only returns status pages whose name or url contains the search string
+// It has an optional query parameter of order (default is name), which is either name (alphabetically a to z) or url, status pages with the same name are ordered by url
+// It has an optional query parameter of limit, which is the maximum number of status pages to return
+// It has an optional query parameter of cursor, which is the nextCursor returned by a previous request, used to get the next page of status pages
+// It has an optional query parameter of offset, which skips that many status pages, it can't be used with cursor
+// It has an optional query parameter of includeCounts (default is false), which sets the recentIncidentCount of each status page,
+// the number of its incidents that started in the last recentIncidentCountWindow
+// The number of indexed and unindexed status pages matching the search is returned as indexedCount and unindexedCount
 func (s *Server) statusPages(context *gin.Context) {
-	var statusPages []api.StatusPage
-	for _, statusPage := range s.statusPageCache.Items() {
-		statusPages = append(statusPages, statusPage.Object.(api.StatusPage))
+	indexedOnly := false
+	if indexedOnlyStr := context.Query("indexedOnly"); indexedOnlyStr != "" {
+		indexedOnlyBool, err := strconv.ParseBool(indexedOnlyStr)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidBoolean, "indexedOnly must be a boolean")
+			return
+		}
+		indexedOnly = indexedOnlyBool
+	}
+
+	search := strings.ToLower(context.Query("search"))
+
+	order := statusPagesOrderName
+	if orderStr := context.Query("order"); orderStr != "" {
+		parsedOrder, err := parseStatusPagesOrder(orderStr)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidOrder, "order must be name or url")
+			return
+		}
+		order = parsedOrder
+	}
+
+	var limit *int = nil
+	if limitStr := context.Query("limit"); limitStr != "" {
+		limitInt, err := strconv.Atoi(limitStr)
+		if err != nil || limitInt < 0 {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidLimit, "limit must be a non-negative integer")
+			return
+		}
+		limit = &limitInt
 	}
 
-	// Sort the status pages by name alphabetically a to z
+	offset := 0
+	if offsetStr := context.Query("offset"); offsetStr != "" {
+		offsetInt, err := strconv.Atoi(offsetStr)
+		if err != nil || offsetInt < 0 {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidOffset, "offset must be a non-negative integer")
+			return
+		}
+		offset = offsetInt
+	}
+
+	includeCounts := false
+	if includeCountsStr := context.Query("includeCounts"); includeCountsStr != "" {
+		includeCountsBool, err := strconv.ParseBool(includeCountsStr)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidBoolean, "includeCounts must be a boolean")
+			return
+		}
+		includeCounts = includeCountsBool
+	}
+
+	var cursor *statusPagesCursor = nil
+	if cursorStr := context.Query("cursor"); cursorStr != "" {
+		if offset != 0 {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidCursor, "cursor and offset can't be used together")
+			return
+		}
+		decodedCursor, err := decodeStatusPagesCursor(cursorStr)
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidCursor, "invalid cursor")
+			return
+		}
+		cursor = decodedCursor
+	}
+
+	cachedStatusPages, err := s.getCachedStatusPages(context.Request.Context())
+	if err != nil {
+		utils.GetLogger(context.Request.Context(), s.logger).Error("failed to get status pages from cache", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get status pages from cache")
+		return
+	}
+	response := StatusPagesResponse{StatusPages: []api.StatusPage{}}
+	for _, statusPageCasted := range cachedStatusPages {
+		if search != "" && !strings.Contains(strings.ToLower(statusPageCasted.Name), search) && !strings.Contains(strings.ToLower(statusPageCasted.URL), search) {
+			continue
+		}
+		if statusPageCasted.IsIndexed {
+			response.IndexedCount++
+		} else {
+			response.UnindexedCount++
+		}
+		if indexedOnly && !statusPageCasted.IsIndexed {
+			continue
+		}
+		if cursor != nil && !isStatusPageBefore(api.StatusPage{Name: cursor.Name, URL: cursor.URL}, statusPageCasted, order) {
+			continue
+		}
+		response.StatusPages = append(response.StatusPages, statusPageCasted)
+	}
+
+	statusPages := response.StatusPages
 	sort.Slice(statusPages, func(i, j int) bool {
-		return strings.ToLower(statusPages[i].Name) < strings.ToLower(statusPages[j].Name)
+		return isStatusPageBefore(statusPages[i], statusPages[j], order)
 	})
 
-	context.JSON(http.StatusOK, StatusPagesResponse{StatusPages: statusPages})
+	if offset > len(statusPages) {
+		offset = len(statusPages)
+	}
+	statusPages = statusPages[offset:]
+	if limit != nil && len(statusPages) > *limit {
+		statusPages = statusPages[:*limit]
+		if len(statusPages) > 0 {
+			response.NextCursor = encodeStatusPagesCursor(statusPages[len(statusPages)-1])
+		}
+	}
+	response.StatusPages = statusPages
+
+	if includeCounts {
+		counts, err := s.getRecentIncidentCounts(context.Request.Context())
+		if err != nil {
+			utils.GetLogger(context.Request.Context(), s.logger).Error("failed to count the recent incidents of the status pages", zap.Error(err))
+			writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to count the recent incidents of the status pages")
+			return
+		}
+		for i := range response.StatusPages {
+			// The status pages are copies of the cached ones, so setting their counts doesn't modify the cache
+			count := counts[response.StatusPages[i].URL]
+			response.StatusPages[i].RecentIncidentCount = &count
+		}
+	}
+
+	context.JSON(http.StatusOK, response)
+}
+
+// recentIncidentCountWindow is how far back the incidents counted by includeCounts started
+const recentIncidentCountWindow = 30 * 24 * time.Hour
+
+// getRecentIncidentCounts returns the number of incidents of every status page that started in the last recentIncidentCountWindow,
+// status pages without any recent incidents aren't in the result
+// The counts are found with a single query for every status page and cached for a short time, as they're the same for every listing
+func (s *Server) getRecentIncidentCounts(ctx context.Context) (map[string]int, error) {
+	const cacheKey = "all"
+	counts, found, err := s.recentIncidentCountsCache.Get(ctx, cacheKey)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Warn("failed to get recent incident counts from cache", zap.Error(err))
+	}
+	if found {
+		return counts, nil
+	}
+
+	// Coalesce concurrent requests so that only one of them counts the incidents when the cache expires
+	// The count is shared so it shouldn't be cancelled if the request that started it goes away, it's still bounded by the query timeout
+	ctx = context.WithoutCancel(ctx)
+	result, err, _ := s.incidentDatabaseFetches.Do("recentIncidentCounts", func() (interface{}, error) {
+		return s.dbClient.CountIncidentsByStatusPage(ctx, time.Now().Add(-recentIncidentCountWindow))
+	})
+	if err != nil {
+		return nil, err
+	}
+	counts = result.(map[string]int)
+	if err := s.recentIncidentCountsCache.Set(ctx, cacheKey, counts, s.config.Cache.RecentIncidentCountsTTL); err != nil {
+		utils.GetLogger(ctx, s.logger).Warn("failed to set recent incident counts in cache", zap.Error(err))
+	}
+	return counts, nil
 }
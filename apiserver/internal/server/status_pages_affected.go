@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type AffectedStatusPage struct {
+	StatusPageUrl string `json:"statusPageUrl"`
+	Name          string `json:"name"`
+	// WorstImpact is the most severe impact of the ongoing incidents matching the filter
+	WorstImpact          api.Impact `json:"worstImpact"`
+	OngoingIncidentCount int        `json:"ongoingIncidentCount"`
+}
+
+type AffectedStatusPagesResponse struct {
+	StatusPages []AffectedStatusPage `json:"statusPages"`
+}
+
+// impactSeverity orders the impacts from least to most severe
+var impactSeverity = map[api.Impact]int{
+	api.ImpactNone:        0,
+	api.ImpactMaintenance: 1,
+	api.ImpactMinor:       2,
+	api.ImpactMajor:       3,
+	api.ImpactCritical:    4,
+}
+
+// affectedStatusPages is a handler for the /statusPages/affected endpoint.
+// It has an optional query parameter of impact (default is all), which is an array of impacts e.g. impact=critical,major
+// It has an optional query parameter of excludeImpact, which is an array of impacts to exclude e.g. excludeImpact=maintenance, it can't be used with impact
+// It returns the indexed status pages that have ongoing incidents with one of the impacts, along with the worst impact of those incidents
+// The status pages are sorted by their worst impact, most severe first, then by name
+// The result is cached for a short time as finding it reads the current incidents of every status page
+func (s *Server) affectedStatusPages(context *gin.Context) {
+	ctx := context.Request.Context()
+	impacts, ok := parseImpactsQuery(context)
+	if !ok {
+		return
+	}
+
+	cacheKey := impactFilterCacheKey(impacts)
+	affected, found, err := s.affectedStatusPagesCache.Get(ctx, cacheKey)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Warn("failed to get affected status pages from cache", zap.Error(err))
+	}
+	if !found {
+		// Coalesce concurrent requests so that only one of them reads the current incidents when the cache expires
+		result, err, _ := s.incidentDatabaseFetches.Do("affected:"+cacheKey, func() (interface{}, error) {
+			return s.findAffectedStatusPages(ctx, impacts)
+		})
+		if err != nil {
+			utils.GetLogger(ctx, s.logger).Error("failed to get current incidents from database", zap.Error(err))
+			writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get current incidents from database")
+			return
+		}
+		affected = result.([]AffectedStatusPage)
+		if err := s.affectedStatusPagesCache.Set(ctx, cacheKey, affected, s.config.Cache.AffectedStatusPagesTTL); err != nil {
+			utils.GetLogger(ctx, s.logger).Warn("failed to set affected status pages in cache", zap.Error(err))
+		}
+	}
+
+	context.JSON(http.StatusOK, AffectedStatusPagesResponse{StatusPages: affected})
+}
+
+func (s *Server) findAffectedStatusPages(ctx context.Context, impacts impactFilter) ([]AffectedStatusPage, error) {
+	incidents, err := s.dbClient.GetAllCurrentIncidents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	incidents = filterIncidentsByImpacts(filterOngoingIncidents(incidents), impacts)
+
+	affectedByUrl := make(map[string]*AffectedStatusPage)
+	for _, incident := range incidents {
+		affected, ok := affectedByUrl[incident.StatusPageUrl]
+		if !ok {
+			statusPage, found, err := s.statusPageCache.Get(ctx, incident.StatusPageUrl)
+			if err != nil {
+				return nil, err
+			}
+			if !found || !statusPage.IsIndexed {
+				continue
+			}
+			affected = &AffectedStatusPage{
+				StatusPageUrl: incident.StatusPageUrl,
+				Name:          statusPage.Name,
+				WorstImpact:   incident.Impact,
+			}
+			affectedByUrl[incident.StatusPageUrl] = affected
+		}
+		affected.OngoingIncidentCount++
+		if impactSeverity[incident.Impact] > impactSeverity[affected.WorstImpact] {
+			affected.WorstImpact = incident.Impact
+		}
+	}
+
+	affectedStatusPages := []AffectedStatusPage{}
+	for _, affected := range affectedByUrl {
+		affectedStatusPages = append(affectedStatusPages, *affected)
+	}
+	sort.Slice(affectedStatusPages, func(i, j int) bool {
+		if impactSeverity[affectedStatusPages[i].WorstImpact] != impactSeverity[affectedStatusPages[j].WorstImpact] {
+			return impactSeverity[affectedStatusPages[i].WorstImpact] > impactSeverity[affectedStatusPages[j].WorstImpact]
+		}
+		return strings.ToLower(affectedStatusPages[i].Name) < strings.ToLower(affectedStatusPages[j].Name)
+	})
+	return affectedStatusPages, nil
+}
+
+// impactFilterCacheKey returns the same key for the same impact filter, regardless of the order or repetition of its impacts
+func impactFilterCacheKey(filter impactFilter) string {
+	if len(filter.impacts) == 0 {
+		return "all"
+	}
+	impactSet := make(map[string]struct{}, len(filter.impacts))
+	for _, impact := range filter.impacts {
+		impactSet[string(impact)] = struct{}{}
+	}
+	var keys []string
+	for impact := range impactSet {
+		keys = append(keys, impact)
+	}
+	sort.Strings(keys)
+	if filter.exclude {
+		return "exclude:" + strings.Join(keys, ",")
+	}
+	return strings.Join(keys, ",")
+}
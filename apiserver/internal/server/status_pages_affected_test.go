@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func getAffectedStatusPages(s *Server, query string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/statusPages/affected?"+query, nil)
+	s.affectedStatusPages(testContext)
+	return recorder
+}
+
+func TestAffectedStatusPages(t *testing.T) {
+	now := time.Now()
+	resolvedAt := now.Add(-time.Minute)
+	otherUrl := "https://status.other.com"
+	unindexedUrl := "https://status.unindexed.com"
+
+	minor := newTestIncident("minor", api.ImpactMinor, now.Add(-time.Hour))
+	critical := newTestIncident("critical", api.ImpactCritical, now.Add(-2*time.Hour))
+	resolved := newTestIncident("resolved", api.ImpactCritical, now.Add(-3*time.Hour))
+	resolved.EndTime = &resolvedAt
+	other := api.NewIncident("other", nil, nil, now, nil, nil, otherUrl+"/incidents/other", api.ImpactMajor, otherUrl)
+	unindexed := api.NewIncident("unindexed", nil, nil, now, nil, nil, unindexedUrl+"/incidents/unindexed", api.ImpactCritical, unindexedUrl)
+
+	dbClient := &fakeDbClient{incidents: []api.Incident{minor, critical, resolved, other, unindexed}}
+	s := newTestServerWithDb(t, dbClient)
+	s.setCachedStatusPage(context.Background(), api.StatusPage{URL: testStatusPageUrl, Name: "Example", IsIndexed: true})
+	s.setCachedStatusPage(context.Background(), api.StatusPage{URL: otherUrl, Name: "Other", IsIndexed: true})
+	s.setCachedStatusPage(context.Background(), api.StatusPage{URL: unindexedUrl, Name: "Unindexed"})
+
+	tests := []struct {
+		query    string
+		expected []AffectedStatusPage
+	}{
+		{query: "", expected: []AffectedStatusPage{
+			{StatusPageUrl: testStatusPageUrl, Name: "Example", WorstImpact: api.ImpactCritical, OngoingIncidentCount: 2},
+			{StatusPageUrl: otherUrl, Name: "Other", WorstImpact: api.ImpactMajor, OngoingIncidentCount: 1},
+		}},
+		{query: "impact=critical", expected: []AffectedStatusPage{
+			{StatusPageUrl: testStatusPageUrl, Name: "Example", WorstImpact: api.ImpactCritical, OngoingIncidentCount: 1},
+		}},
+		{query: "impact=minor,major", expected: []AffectedStatusPage{
+			{StatusPageUrl: otherUrl, Name: "Other", WorstImpact: api.ImpactMajor, OngoingIncidentCount: 1},
+			{StatusPageUrl: testStatusPageUrl, Name: "Example", WorstImpact: api.ImpactMinor, OngoingIncidentCount: 1},
+		}},
+		{query: "impact=maintenance", expected: []AffectedStatusPage{}},
+	}
+	for _, test := range tests {
+		recorder := getAffectedStatusPages(s, test.query)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", test.query, recorder.Code)
+		}
+		var response AffectedStatusPagesResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if len(response.StatusPages) != len(test.expected) {
+			t.Fatalf("%s: expected %+v, got %+v", test.query, test.expected, response.StatusPages)
+		}
+		for i := range test.expected {
+			if response.StatusPages[i] != test.expected[i] {
+				t.Fatalf("%s: expected %+v, got %+v", test.query, test.expected, response.StatusPages)
+			}
+		}
+	}
+
+	if recorder := getAffectedStatusPages(s, "impact=sev1"); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for an invalid impact, got %d", recorder.Code)
+	}
+}
+
+func TestAffectedStatusPagesAreCached(t *testing.T) {
+	dbClient := &fakeDbClient{incidents: []api.Incident{newTestIncident("critical", api.ImpactCritical, time.Now())}}
+	s := newTestServerWithDb(t, dbClient)
+
+	// The same impacts in a different order share a cache entry
+	for _, query := range []string{"impact=critical,major", "impact=major,critical", "impact=major,critical,major"} {
+		if recorder := getAffectedStatusPages(s, query); recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", query, recorder.Code)
+		}
+	}
+	if calls := dbClient.getAllCurrentIncidentsCalls.Load(); calls != 1 {
+		t.Fatalf("expected the current incidents to be read once, got %d", calls)
+	}
+}
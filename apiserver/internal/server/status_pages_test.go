@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func getStatusPages(t *testing.T, s *Server, query string) StatusPagesResponse {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/statusPages?"+query, nil)
+	s.statusPages(testContext)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("%s: expected status 200, got %d", query, recorder.Code)
+	}
+	var response StatusPagesResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	return response
+}
+
+func TestStatusPagesPagesWithCursor(t *testing.T) {
+	s := newTestServer(t)
+	// Two of the status pages have the same name so they are ordered by url
+	for _, statusPage := range []api.StatusPage{
+		{Name: "Beta", URL: "https://status.beta.com", IsIndexed: true},
+		{Name: "Alpha", URL: "https://status.alpha.com/b", IsIndexed: true},
+		{Name: "alpha", URL: "https://status.alpha.com/a"},
+		{Name: "Gamma", URL: "https://gamma.com/status", IsIndexed: true},
+	} {
+		s.setCachedStatusPage(context.Background(), statusPage)
+	}
+
+	collect := func(query string) []string {
+		var urls []string
+		cursor := ""
+		for pages := 0; ; pages++ {
+			if pages > 10 {
+				t.Fatalf("%s: expected the cursors to reach the last page", query)
+			}
+			response := getStatusPages(t, s, query+"&limit=1&cursor="+url.QueryEscape(cursor))
+			if len(response.StatusPages) > 1 {
+				t.Fatalf("%s: expected at most one status page per page, got %+v", query, response.StatusPages)
+			}
+			for _, statusPage := range response.StatusPages {
+				urls = append(urls, statusPage.URL)
+			}
+			if response.NextCursor == "" {
+				return urls
+			}
+			cursor = response.NextCursor
+		}
+	}
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{query: "", expected: []string{"https://status.alpha.com/a", "https://status.alpha.com/b", "https://status.beta.com", "https://gamma.com/status"}},
+		{query: "order=url", expected: []string{"https://gamma.com/status", "https://status.alpha.com/a", "https://status.alpha.com/b", "https://status.beta.com"}},
+		{query: "indexedOnly=true", expected: []string{"https://status.alpha.com/b", "https://status.beta.com", "https://gamma.com/status"}},
+	}
+	for _, test := range tests {
+		urls := collect(test.query)
+		if len(urls) != len(test.expected) {
+			t.Fatalf("%s: expected %v, got %v", test.query, test.expected, urls)
+		}
+		for i := range urls {
+			if urls[i] != test.expected[i] {
+				t.Fatalf("%s: expected %v, got %v", test.query, test.expected, urls)
+			}
+		}
+	}
+
+	response := getStatusPages(t, s, "indexedOnly=true&search=alpha&limit=1")
+	if response.IndexedCount != 1 || response.UnindexedCount != 1 {
+		t.Fatalf("expected the counts of the status pages matching the search, got %+v", response)
+	}
+	if response := getStatusPages(t, s, "limit=10"); response.NextCursor != "" || len(response.StatusPages) != 4 {
+		t.Fatalf("expected no cursor on the last page, got %+v", response)
+	}
+}
+
+func TestStatusPagesRejectsInvalidParameters(t *testing.T) {
+	s := newTestServer(t)
+	for _, query := range []string{"order=created", "cursor=invalid", "cursor=eyJ1IjoiYSJ9&offset=1", "limit=-1", "includeCounts=maybe"} {
+		recorder := httptest.NewRecorder()
+		testContext, _ := gin.CreateTestContext(recorder)
+		testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/statusPages?"+query, nil)
+		s.statusPages(testContext)
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", query, recorder.Code)
+		}
+	}
+}
+
+func TestStatusPagesIncludeRecentIncidentCounts(t *testing.T) {
+	now := time.Now()
+	other := api.StatusPage{Name: "Other", URL: "https://status.other.com", IsIndexed: true}
+	deletedAt := now
+	deleted := newTestIncident("deleted", api.ImpactMajor, now.Add(-time.Hour))
+	deleted.DeletedAt = &deletedAt
+	dbClient := &fakeDbClient{incidents: []api.Incident{
+		newTestIncident("a", api.ImpactMajor, now.Add(-time.Hour)),
+		newTestIncident("b", api.ImpactMinor, now.Add(-recentIncidentCountWindow+time.Hour)),
+		newTestIncident("old", api.ImpactMajor, now.Add(-recentIncidentCountWindow-time.Hour)),
+		deleted,
+	}}
+	s := newTestServerWithDb(t, dbClient)
+	s.setCachedStatusPage(context.Background(), other)
+
+	// The counts aren't read unless they're asked for
+	for _, statusPage := range getStatusPages(t, s, "").StatusPages {
+		if statusPage.RecentIncidentCount != nil {
+			t.Fatalf("expected no recent incident count by default, got %+v", statusPage)
+		}
+	}
+	if calls := dbClient.countIncidentsByStatusPageCalls.Load(); calls != 0 {
+		t.Fatalf("expected the incidents not to be counted by default, got %d calls", calls)
+	}
+
+	expected := map[string]int{testStatusPageUrl: 2, other.URL: 0}
+	for i := 0; i < 2; i++ {
+		response := getStatusPages(t, s, "includeCounts=true")
+		if len(response.StatusPages) != len(expected) {
+			t.Fatalf("expected %d status pages, got %+v", len(expected), response.StatusPages)
+		}
+		for _, statusPage := range response.StatusPages {
+			if statusPage.RecentIncidentCount == nil || *statusPage.RecentIncidentCount != expected[statusPage.URL] {
+				t.Fatalf("expected %s to have %d recent incidents, got %v", statusPage.URL, expected[statusPage.URL], statusPage.RecentIncidentCount)
+			}
+		}
+	}
+	// The second listing uses the cached counts
+	if calls := dbClient.countIncidentsByStatusPageCalls.Load(); calls != 1 {
+		t.Fatalf("expected the incidents to be counted once, got %d calls", calls)
+	}
+}
+
+func TestRecentIncidentCountsAreNotCancelledWithTheRequestThatCountsThem(t *testing.T) {
+	dbClient := &fakeDbClient{incidents: []api.Incident{newTestIncident("a", api.ImpactMajor, time.Now().Add(-time.Hour))}}
+	s := newTestServerWithDb(t, dbClient)
+
+	// The count is shared with the concurrent requests, so the request that starts it going away mustn't fail the others
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	counts, err := s.getRecentIncidentCounts(ctx)
+	if err != nil {
+		t.Fatalf("expected the incidents to be counted, got %v", err)
+	}
+	if counts[testStatusPageUrl] != 1 {
+		t.Fatalf("expected one recent incident, got %v", counts)
+	}
+}
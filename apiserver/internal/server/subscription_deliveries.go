@@ -0,0 +1,64 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/db"
+	"github.com/metoro-io/statusphere/common/utils"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+type SubscriptionDeliveriesResponse struct {
+	// Deliveries are the latest attempts to send the webhooks of the subscription, latest first
+	Deliveries []api.SubscriptionDelivery `json:"deliveries"`
+}
+
+// subscriptionDeliveries is a handler for the GET /subscriptions/:id/deliveries endpoint.
+// It returns the latest deliveries of the subscription with their status codes and errors, up to db.SubscriptionDeliveriesRetained of them.
+// If the subscription does not exist, it returns a 404.
+func (s *Server) subscriptionDeliveries(context *gin.Context) {
+	ctx := context.Request.Context()
+	id := context.Param("id")
+	subscription, err := s.dbClient.GetSubscription(ctx, id)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to get subscription", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get subscription deliveries")
+		return
+	}
+	if subscription == nil {
+		writeError(context, http.StatusNotFound, ErrorCodeSubscriptionNotKnown, "subscription not found")
+		return
+	}
+
+	deliveries, err := s.dbClient.GetSubscriptionDeliveries(ctx, id)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to get subscription deliveries", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to get subscription deliveries")
+		return
+	}
+	if deliveries == nil {
+		deliveries = []api.SubscriptionDelivery{}
+	}
+	context.JSON(http.StatusOK, SubscriptionDeliveriesResponse{Deliveries: deliveries})
+}
+
+// redeliverSubscriptionDelivery is a handler for the POST /subscriptions/:id/deliveries/:deliveryId/redeliver endpoint.
+// It requests the incident of the delivery to be sent to the subscription again, the jobrunner starts the redelivery the next time it polls.
+// The redelivery is recorded as a new delivery of the subscription.
+// It returns a 202 once the redelivery has been requested, or a 404 if the subscription has no delivery with the id.
+func (s *Server) redeliverSubscriptionDelivery(context *gin.Context) {
+	ctx := context.Request.Context()
+	err := s.dbClient.RequestSubscriptionRedelivery(ctx, context.Param("id"), context.Param("deliveryId"))
+	if err != nil {
+		if errors.Is(err, db.ErrSubscriptionDeliveryNotFound) {
+			writeError(context, http.StatusNotFound, ErrorCodeSubscriptionDeliveryNotKnown, "subscription delivery not found")
+			return
+		}
+		utils.GetLogger(ctx, s.logger).Error("failed to request subscription redelivery", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to request subscription redelivery")
+		return
+	}
+	context.Status(http.StatusAccepted)
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getSubscriptionDeliveries(s *Server, id string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodGet, "/api/v1/subscriptions/"+id+"/deliveries", nil)
+	testContext.Params = gin.Params{{Key: "id", Value: id}}
+	s.subscriptionDeliveries(testContext)
+	return recorder
+}
+
+func redeliver(s *Server, id string, deliveryId string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodPost, "/api/v1/subscriptions/"+id+"/deliveries/"+deliveryId+"/redeliver", nil)
+	testContext.Params = gin.Params{{Key: "id", Value: id}, {Key: "deliveryId", Value: deliveryId}}
+	s.redeliverSubscriptionDelivery(testContext)
+	// The status is only written to the recorder once the handler writes a body or the header is flushed
+	testContext.Writer.WriteHeaderNow()
+	return recorder
+}
+
+func TestSubscriptionDeliveriesAreListedLatestFirst(t *testing.T) {
+	attemptedAt := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	dbClient := &fakeDbClient{
+		subscriptions: []api.Subscription{{ID: "sub", StatusPageUrl: testStatusPageUrl}, {ID: "other", StatusPageUrl: testStatusPageUrl}},
+		deliveries: []api.SubscriptionDelivery{
+			{ID: "1-1", SubscriptionID: "sub", IncidentID: "incident", StatusCode: http.StatusInternalServerError, Error: "expected a 2xx status code, got 500", AttemptedAt: attemptedAt},
+			{ID: "1-2", SubscriptionID: "sub", IncidentID: "incident", StatusCode: http.StatusOK, AttemptedAt: attemptedAt.Add(time.Minute)},
+			{ID: "2-1", SubscriptionID: "other", IncidentID: "incident", StatusCode: http.StatusOK, AttemptedAt: attemptedAt},
+		},
+	}
+	s := newTestServerWithDb(t, dbClient)
+
+	recorder := getSubscriptionDeliveries(s, "sub")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response SubscriptionDeliveriesResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(response.Deliveries) != 2 || response.Deliveries[0].ID != "1-2" || response.Deliveries[1].ID != "1-1" {
+		t.Fatalf("expected the deliveries of the subscription latest first, got %+v", response.Deliveries)
+	}
+	if response.Deliveries[1].StatusCode != http.StatusInternalServerError || response.Deliveries[1].Error == "" {
+		t.Fatalf("expected the failed delivery to have its status code and error, got %+v", response.Deliveries[1])
+	}
+
+	if recorder := getSubscriptionDeliveries(s, "unknown"); recorder.Code != http.StatusNotFound || !strings.Contains(recorder.Body.String(), string(ErrorCodeSubscriptionNotKnown)) {
+		t.Fatalf("expected status 404 for an unknown subscription, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestRedeliverRequestsTheDeliveryToBeSentAgain(t *testing.T) {
+	dbClient := &fakeDbClient{
+		subscriptions: []api.Subscription{{ID: "sub", StatusPageUrl: testStatusPageUrl}},
+		deliveries:    []api.SubscriptionDelivery{{ID: "1-1", SubscriptionID: "sub", IncidentID: "incident", AttemptedAt: time.Now()}},
+	}
+	s := newTestServerWithDb(t, dbClient)
+
+	if recorder := redeliver(s, "sub", "1-1"); recorder.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if dbClient.deliveries[0].RedeliveryRequestedAt == nil {
+		t.Fatalf("expected the redelivery to be requested")
+	}
+	if recorder := redeliver(s, "other", "1-1"); recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for a delivery of another subscription, got %d", recorder.Code)
+	}
+	if recorder := redeliver(s, "sub", "unknown"); recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for an unknown delivery, got %d", recorder.Code)
+	}
+}
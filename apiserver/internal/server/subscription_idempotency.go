@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	// idempotencyKeyHeader is the header that clients retrying POST /subscriptions send so that only one subscription is created
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader is set on the responses that return the subscription created by an earlier request with the same key
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// idempotentSubscription is the subscription created for an idempotency key along with a hash of the request that created it,
+// so that the key being sent again with a different request can be rejected
+type idempotentSubscription struct {
+	RequestHash  string
+	Subscription api.Subscription
+}
+
+// newIdempotentSubscription returns the subscription created for the key, creating it from the request if there isn't one
+// replayed is true if the subscription was created by an earlier request, concurrent requests with the same key share one creation
+// The caller has to check that the RequestHash is the one of its request before returning the subscription
+func (s *Server) newIdempotentSubscription(ctx context.Context, key string, request CreateSubscriptionRequest) (idempotentSubscription, bool, error) {
+	if created, found, err := s.idempotentSubscriptionsCache.Get(ctx, key); err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to get the subscription for the idempotency key from the cache", zap.Error(err))
+		return idempotentSubscription{}, false, err
+	} else if found {
+		return created, true, nil
+	}
+
+	result, err, shared := s.idempotentSubscriptionCreations.Do(key, func() (interface{}, error) {
+		// Another request may have finished creating the subscription between the cache lookup and joining the group
+		if created, found, err := s.idempotentSubscriptionsCache.Get(ctx, key); err == nil && found {
+			return created, nil
+		}
+		subscription, err := s.newSubscription(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		created := idempotentSubscription{RequestHash: hashSubscriptionRequest(request), Subscription: subscription}
+		if err := s.idempotentSubscriptionsCache.Set(ctx, key, created, s.config.Cache.IdempotencyKeyTTL); err != nil {
+			// The subscription has been created so it is still returned, a retry with the key would create another one though
+			utils.GetLogger(ctx, s.logger).Error("failed to cache the subscription for the idempotency key", zap.Error(err))
+		}
+		return created, nil
+	})
+	if err != nil {
+		return idempotentSubscription{}, false, err
+	}
+	return result.(idempotentSubscription), shared, nil
+}
+
+// hashSubscriptionRequest returns a hash of the request, which has already been normalized by createSubscription
+func hashSubscriptionRequest(request CreateSubscriptionRequest) string {
+	// Marshalling a struct can't fail and the fields are always marshalled in the same order
+	body, _ := json.Marshal(request)
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/db"
+	"github.com/metoro-io/statusphere/common/utils"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type CreateSubscriptionRequest struct {
+	StatusPageUrl string `json:"statusPageUrl" binding:"required"`
+	TargetUrl     string `json:"targetUrl" binding:"required"`
+	// Impact filters the incidents that are sent, all incidents are sent if it is empty
+	Impact []api.Impact `json:"impact"`
+	// Format is either json (the default) to send the incident or slack to send a slack message
+	Format string `json:"format"`
+}
+
+// createSubscription is a handler for the POST /subscriptions endpoint.
+// It takes a json body with required fields of statusPageUrl and targetUrl and optional fields of impact and format
+// New incidents of the status page are sent to the target url as a POST with the incident as the body, or a slack message if the format is slack.
+// The body is signed with the secret of the subscription, which is only returned by this endpoint, see subscription_webhook.Sign.
+// It returns a 201 with the created subscription, a 404 if the status page is not known or a 400 if the request is invalid.
+// It has an optional Idempotency-Key header, a retry of the request with the same key returns the subscription that was created for it
+// with the Idempotent-Replayed header rather than creating another one, until the key expires. The keys of each client are separate,
+// clients are identified as they are by the rate limit. Sending the key with a different request returns a 422.
+func (s *Server) createSubscription(context *gin.Context) {
+	ctx := context.Request.Context()
+	var request CreateSubscriptionRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidBody, "statusPageUrl and targetUrl are required")
+		return
+	}
+
+	for i, impact := range request.Impact {
+		parsedImpact, err := api.ParseImpact(string(impact))
+		if err != nil {
+			writeError(context, http.StatusBadRequest, ErrorCodeInvalidImpact, "invalid impact")
+			return
+		}
+		// Synonyms are replaced by the impact they map to so that they match the impacts of incidents
+		request.Impact[i] = parsedImpact
+	}
+
+	format, err := api.ParseSubscriptionFormat(request.Format)
+	if err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidFormat, "format must be json or slack")
+		return
+	}
+
+	if err := validateTargetUrl(request.TargetUrl); err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidTargetUrl, err.Error())
+		return
+	}
+
+	statusPageUrl, err := api.CanonicalStatusPageUrl(request.StatusPageUrl)
+	if err != nil {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidStatusPageUrl, "statusPageUrl is invalid, "+err.Error())
+		return
+	}
+	// The canonical url is stored so that the subscription matches the incidents of the status page
+	request.StatusPageUrl = statusPageUrl
+
+	if _, lookupErr := s.lookupStatusPage(ctx, request.StatusPageUrl); lookupErr != nil {
+		lookupErr.write(context)
+		return
+	}
+	request.Format = string(format)
+
+	idempotencyKey := context.GetHeader(idempotencyKeyHeader)
+	if idempotencyKey == "" {
+		subscription, err := s.newSubscription(ctx, request)
+		if err != nil {
+			writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to create subscription")
+			return
+		}
+		context.JSON(http.StatusCreated, subscription)
+		return
+	}
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		writeError(context, http.StatusBadRequest, ErrorCodeInvalidIdempotencyKey, "Idempotency-Key must be at most 255 characters")
+		return
+	}
+
+	created, replayed, err := s.newIdempotentSubscription(ctx, rateLimitKey(context)+"|"+idempotencyKey, request)
+	if err != nil {
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to create subscription")
+		return
+	}
+	if created.RequestHash != hashSubscriptionRequest(request) {
+		writeError(context, http.StatusUnprocessableEntity, ErrorCodeIdempotencyKeyReused, "Idempotency-Key was already used for a different request")
+		return
+	}
+	if replayed {
+		context.Header(idempotentReplayedHeader, "true")
+	}
+	context.JSON(http.StatusCreated, created.Subscription)
+}
+
+// newSubscription creates and stores a subscription for the request, which has already been validated and normalized
+func (s *Server) newSubscription(ctx context.Context, request CreateSubscriptionRequest) (api.Subscription, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to generate subscription id", zap.Error(err))
+		return api.Subscription{}, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to generate subscription secret", zap.Error(err))
+		return api.Subscription{}, err
+	}
+
+	subscription := api.Subscription{
+		ID:            id,
+		StatusPageUrl: request.StatusPageUrl,
+		TargetUrl:     request.TargetUrl,
+		Impacts:       request.Impact,
+		Format:        api.SubscriptionFormat(request.Format),
+		Secret:        secret,
+		CreatedAt:     time.Now().UTC(),
+	}
+	if err := s.dbClient.CreateSubscription(ctx, subscription); err != nil {
+		utils.GetLogger(ctx, s.logger).Error("failed to create subscription", zap.Error(err))
+		return api.Subscription{}, err
+	}
+	return subscription, nil
+}
+
+// deleteSubscription is a handler for the DELETE /subscriptions/:id endpoint.
+// It returns a 204 once the subscription is deleted or a 404 if there is no subscription with the id
+func (s *Server) deleteSubscription(context *gin.Context) {
+	ctx := context.Request.Context()
+	id := context.Param("id")
+	if err := s.dbClient.DeleteSubscription(ctx, id); err != nil {
+		if errors.Is(err, db.ErrSubscriptionNotFound) {
+			writeError(context, http.StatusNotFound, ErrorCodeSubscriptionNotKnown, "subscription not found")
+			return
+		}
+		utils.GetLogger(ctx, s.logger).Error("failed to delete subscription", zap.Error(err))
+		writeError(context, http.StatusInternalServerError, ErrorCodeInternal, "failed to delete subscription")
+		return
+	}
+	context.Status(http.StatusNoContent)
+}
+
+// validateTargetUrl checks that the url webhooks are sent to is an absolute http(s) url
+// Webhooks to private addresses are refused when they are sent as the url can resolve to a different address by then
+func validateTargetUrl(targetUrl string) error {
+	parsed, err := url.Parse(targetUrl)
+	if err != nil {
+		return errors.New("targetUrl is invalid")
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return errors.New("targetUrl must be an http or https url")
+	}
+	if parsed.Hostname() == "" {
+		return errors.New("targetUrl must have a host")
+	}
+	return nil
+}
+
+func randomHex(numBytes int) (string, error) {
+	randomBytes := make([]byte, numBytes)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(randomBytes), nil
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func postSubscription(s *Server, body string) *httptest.ResponseRecorder {
+	return postSubscriptionWithIdempotencyKey(s, body, "")
+}
+
+func postSubscriptionWithIdempotencyKey(s *Server, body string, idempotencyKey string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodPost, "/api/v1/subscriptions", bytes.NewBufferString(body))
+	testContext.Request.Header.Set("Content-Type", "application/json")
+	if idempotencyKey != "" {
+		testContext.Request.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	s.createSubscription(testContext)
+	return recorder
+}
+
+func deleteSubscription(s *Server, id string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	testContext, _ := gin.CreateTestContext(recorder)
+	testContext.Request = httptest.NewRequest(http.MethodDelete, "/api/v1/subscriptions/"+id, nil)
+	testContext.Params = gin.Params{{Key: "id", Value: id}}
+	s.deleteSubscription(testContext)
+	// The status is only written to the recorder once the handler writes a body or the header is flushed
+	testContext.Writer.WriteHeaderNow()
+	return recorder
+}
+
+func TestCreateAndDeleteSubscription(t *testing.T) {
+	dbClient := &fakeDbClient{}
+	s := newTestServerWithDb(t, dbClient)
+
+	recorder := postSubscription(s, `{"statusPageUrl": "`+testStatusPageUrl+`", "targetUrl": "https://hooks.example.com/statusphere", "impact": ["major", "critical"]}`)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var created api.Subscription
+	if err := json.Unmarshal(recorder.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if created.ID == "" || created.Secret == "" || created.StatusPageUrl != testStatusPageUrl || len(created.Impacts) != 2 {
+		t.Fatalf("unexpected created subscription %+v", created)
+	}
+	if created.Format != api.SubscriptionFormatJson {
+		t.Fatalf("expected the format to default to json, got %s", created.Format)
+	}
+	if len(dbClient.subscriptions) != 1 || dbClient.subscriptions[0].Secret != created.Secret {
+		t.Fatalf("expected the subscription to be stored in the database")
+	}
+
+	if recorder := deleteSubscription(s, created.ID); recorder.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", recorder.Code)
+	}
+	if len(dbClient.subscriptions) != 0 {
+		t.Fatalf("expected the subscription to be deleted from the database")
+	}
+	if recorder := deleteSubscription(s, created.ID); recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for a deleted subscription, got %d", recorder.Code)
+	}
+}
+
+func TestCreateSubscriptionWithIdempotencyKey(t *testing.T) {
+	dbClient := &fakeDbClient{}
+	s := newTestServerWithDb(t, dbClient)
+	body := `{"statusPageUrl": "` + testStatusPageUrl + `", "targetUrl": "https://hooks.example.com/statusphere"}`
+
+	first := postSubscriptionWithIdempotencyKey(s, body, "retry-1")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", first.Code, first.Body.String())
+	}
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("expected the first request not to be a replay")
+	}
+	second := postSubscriptionWithIdempotencyKey(s, body, "retry-1")
+	if second.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", second.Code, second.Body.String())
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected the second request to be a replay")
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Fatalf("expected the original subscription to be returned, got %s and %s", first.Body.String(), second.Body.String())
+	}
+	if len(dbClient.subscriptions) != 1 {
+		t.Fatalf("expected one subscription to be created, got %d", len(dbClient.subscriptions))
+	}
+
+	if recorder := postSubscriptionWithIdempotencyKey(s, body, "retry-2"); recorder.Code != http.StatusCreated {
+		t.Fatalf("expected status 201 for a new key, got %d", recorder.Code)
+	}
+	if len(dbClient.subscriptions) != 2 {
+		t.Fatalf("expected a new key to create another subscription, got %d", len(dbClient.subscriptions))
+	}
+
+	otherBody := `{"statusPageUrl": "` + testStatusPageUrl + `", "targetUrl": "https://hooks.example.com/other"}`
+	if recorder := postSubscriptionWithIdempotencyKey(s, otherBody, "retry-1"); recorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422 for a key reused with a different request, got %d", recorder.Code)
+	}
+	if recorder := postSubscriptionWithIdempotencyKey(s, body, strings.Repeat("k", 256)); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for a key that is too long, got %d", recorder.Code)
+	}
+	if len(dbClient.subscriptions) != 2 {
+		t.Fatalf("expected rejected requests not to create subscriptions, got %d", len(dbClient.subscriptions))
+	}
+}
+
+func TestConcurrentRequestsWithTheSameIdempotencyKeyCreateOneSubscription(t *testing.T) {
+	dbClient := &fakeDbClient{}
+	s := newTestServerWithDb(t, dbClient)
+	body := `{"statusPageUrl": "` + testStatusPageUrl + `", "targetUrl": "https://hooks.example.com/statusphere"}`
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if recorder := postSubscriptionWithIdempotencyKey(s, body, "concurrent"); recorder.Code != http.StatusCreated {
+				t.Errorf("expected status 201, got %d", recorder.Code)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(dbClient.subscriptions) != 1 {
+		t.Fatalf("expected one subscription to be created, got %d", len(dbClient.subscriptions))
+	}
+}
+
+func TestCreateSubscriptionValidation(t *testing.T) {
+	s := newTestServerWithDb(t, &fakeDbClient{})
+
+	tests := []struct {
+		body               string
+		expectedStatusCode int
+	}{
+		{body: `{"statusPageUrl": "https://unknown.example.com", "targetUrl": "https://hooks.example.com"}`, expectedStatusCode: http.StatusNotFound},
+		{body: `{"statusPageUrl": "` + testStatusPageUrl + `", "targetUrl": "ftp://hooks.example.com"}`, expectedStatusCode: http.StatusBadRequest},
+		{body: `{"statusPageUrl": "` + testStatusPageUrl + `", "targetUrl": "/relative"}`, expectedStatusCode: http.StatusBadRequest},
+		{body: `{"statusPageUrl": "` + testStatusPageUrl + `", "targetUrl": "https://hooks.example.com", "impact": ["sev1"]}`, expectedStatusCode: http.StatusBadRequest},
+		{body: `{"statusPageUrl": "` + testStatusPageUrl + `"}`, expectedStatusCode: http.StatusBadRequest},
+		{body: `{"statusPageUrl": "` + testStatusPageUrl + `", "targetUrl": "https://hooks.slack.com/services/x", "format": "teams"}`, expectedStatusCode: http.StatusBadRequest},
+		{body: `{"statusPageUrl": "` + testStatusPageUrl + `", "targetUrl": "https://hooks.slack.com/services/x", "format": "slack"}`, expectedStatusCode: http.StatusCreated},
+	}
+	for _, test := range tests {
+		if recorder := postSubscription(s, test.body); recorder.Code != test.expectedStatusCode {
+			t.Errorf("%s: expected status %d, got %d", test.body, test.expectedStatusCode, recorder.Code)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/metoro-io/statusphere/common/api"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attributes := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attributes[kv.Key] = kv.Value
+	}
+	return attributes
+}
+
+func TestIncidentsSpansOnCacheMiss(t *testing.T) {
+	now := time.Now()
+	s := newTestServerWithDb(t, &fakeDbClient{incidents: []api.Incident{
+		newTestIncident("a", api.ImpactMajor, now),
+		newTestIncident("b", api.ImpactMinor, now.Add(-time.Hour)),
+	}})
+	recorder := tracetest.NewSpanRecorder()
+	s.tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer(tracerName)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	r := gin.New()
+	r.Use(traceRequests(s.tracer))
+	r.GET("/api/v1/incidents", s.incidents)
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/incidents?statusPageUrl="+url.QueryEscape(testStatusPageUrl), nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	response := httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", response.Code)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Fatalf("expected span %s to continue the incoming trace, got trace %s", span.Name(), span.SpanContext().TraceID())
+		}
+		spans[span.Name()] = span
+	}
+	for _, name := range []string{"GET /api/v1/incidents", "lookupStatusPage", "getIncidentsFromCache", "getIncidentsFromDatabase"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("expected a %s span, got %v", name, spans)
+		}
+		if name != "GET /api/v1/incidents" && span.Parent().SpanID() != spans["GET /api/v1/incidents"].SpanContext().SpanID() {
+			t.Fatalf("expected span %s to be a child of the request span", name)
+		}
+	}
+
+	if attributes := spanAttributes(spans["lookupStatusPage"]); !attributes["cache.hit"].AsBool() || attributes["statusPageUrl"].AsString() != testStatusPageUrl {
+		t.Fatalf("unexpected status page lookup attributes %v", attributes)
+	}
+	if attributes := spanAttributes(spans["getIncidentsFromCache"]); attributes["cache.hit"].AsBool() {
+		t.Fatalf("expected a cache miss, got %v", attributes)
+	}
+	if attributes := spanAttributes(spans["getIncidentsFromDatabase"]); attributes["incident.count"].AsInt64() != 2 {
+		t.Fatalf("expected the database to return 2 incidents, got %v", attributes)
+	}
+}
@@ -0,0 +1,36 @@
+package tracing
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const serviceName = "statusphere-apiserver"
+
+// Setup sets the global tracer provider to one that exports spans over otlp/http to the endpoint, e.g. http://otel-collector:4318
+// If the endpoint is empty then tracing is disabled and spans are dropped.
+// The returned function flushes any spans that haven't been exported yet, it should be called before the process exits.
+func Setup(ctx context.Context, otlpEndpoint string) (func(ctx context.Context) error, error) {
+	// The trace context of incoming requests is read from the w3c trace context headers
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if otlpEndpoint == "" {
+		return func(ctx context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(otlpEndpoint))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create otlp exporter")
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
@@ -3,44 +3,92 @@ package main
 import (
 	"context"
 	"errors"
+	"github.com/metoro-io/statusphere/apiserver/internal/config"
 	"github.com/metoro-io/statusphere/apiserver/internal/server"
+	"github.com/metoro-io/statusphere/apiserver/internal/tracing"
 	"github.com/metoro-io/statusphere/common/db"
 	"github.com/metoro-io/statusphere/common/utils"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	// Embed the time zone database as the runtime image doesn't have one, it's needed for the tz query parameter
+	_ "time/tzdata"
 )
 
 func main() {
 	ctx, cancelCtx := context.WithCancel(context.Background())
 	defer cancelCtx()
 
-	logger, err := zap.NewProduction()
+	cfg, err := config.GetConfigFromEnvironment()
 	if err != nil {
 		panic(err)
 	}
 
+	logLevel, err := zapcore.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		panic(err)
+	}
+	loggerConfig := zap.NewProductionConfig()
+	loggerConfig.Level = zap.NewAtomicLevelAt(logLevel)
+	// The logs are sampled as configured rather than by the production defaults
+	loggerConfig.Sampling = nil
+	logger, err := loggerConfig.Build(cfg.LogSampling.Option())
+	if err != nil {
+		panic(err)
+	}
+	// Flush any buffered logs on exit
+	defer func() { _ = logger.Sync() }()
+
 	dbClient, err := db.NewDbClientFromEnvironment(logger)
 	if err != nil {
 		panic(err)
 	}
 
-	s := server.NewServer(logger, dbClient)
+	var redisClient redis.UniversalClient
+	if cfg.CacheBackend == config.CacheBackendRedis {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddress,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDatabase,
+		})
+	}
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing.OtlpEndpoint)
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error("failed to shut down tracing", zap.Error(err))
+		}
+	}()
+
+	s := server.NewServer(logger, dbClient, redisClient, cfg)
 	s.StartCaches(ctx)
 
 	go func() {
+		logger.Info("Server started")
 		if err := s.Serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			utils.GetLogger(ctx, logger).Fatal("Failed to start server", zap.Error(err))
 		}
-		logger.Info("Server started")
 	}()
 
 	// Listen for shutdown signal, then cancel the context
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	sig := <-quit
-	logger.Info("Shutting down", zap.String("signal", sig.String()))
+	logger.Info("Shutting down", zap.String("signal", sig.String()), zap.Duration("gracePeriod", cfg.ShutdownGracePeriod))
+	cancelCtx()
 
+	// The in flight requests are given the grace period to complete, new requests are refused
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownGracePeriod)
+	defer cancelShutdown()
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		logger.Error("failed to shut down the server gracefully", zap.Error(err))
+	}
+	logger.Info("Server stopped")
 }
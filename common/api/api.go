@@ -1,9 +1,14 @@
 package api
 
 import (
+	"bytes"
+	"compress/gzip"
 	"database/sql/driver"
 	"encoding/json"
 	"github.com/pkg/errors"
+	"io"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -17,23 +22,50 @@ const (
 	ImpactNone        Impact = "none"
 )
 
+// Impacts are all the impacts that an incident can have
+var Impacts = []Impact{ImpactCritical, ImpactMajor, ImpactMinor, ImpactMaintenance, ImpactNone}
+
 var ErrInvalidImpact = errors.New("invalid impact")
 
+// impactSynonyms maps the impacts used by status page providers to our impacts
+// The keys are normalized by normalizeImpact, so they're lower case and use underscores between words
+var impactSynonyms = map[string]Impact{
+	"minor":                 ImpactMinor,
+	"degraded":              ImpactMinor,
+	"degraded_performance":  ImpactMinor,
+	"performance_issues":    ImpactMinor,
+	"elevated_error_rates":  ImpactMinor,
+	"major":                 ImpactMajor,
+	"partial_outage":        ImpactMajor,
+	"service_disruption":    ImpactMajor,
+	"critical":              ImpactCritical,
+	"outage":                ImpactCritical,
+	"major_outage":          ImpactCritical,
+	"full_outage":           ImpactCritical,
+	"service_outage":        ImpactCritical,
+	"maintenance":           ImpactMaintenance,
+	"under_maintenance":     ImpactMaintenance,
+	"scheduled_maintenance": ImpactMaintenance,
+	"planned_maintenance":   ImpactMaintenance,
+	"none":                  ImpactNone,
+	"operational":           ImpactNone,
+	"informational":         ImpactNone,
+}
+
+// ParseImpact parses one of our impacts or a synonym used by a status page provider, see impactSynonyms
+// It is case insensitive and treats spaces and hyphens the same as underscores, e.g. "Partial Outage" is ImpactMajor
 func ParseImpact(impact string) (Impact, error) {
-	switch impact {
-	case "minor":
-		return ImpactMinor, nil
-	case "major":
-		return ImpactMajor, nil
-	case "critical":
-		return ImpactCritical, nil
-	case "maintenance":
-		return ImpactMaintenance, nil
-	case "none":
-		return ImpactNone, nil
-	default:
-		return "", ErrInvalidImpact
+	if parsed, ok := impactSynonyms[normalizeImpact(impact)]; ok {
+		return parsed, nil
 	}
+	return "", ErrInvalidImpact
+}
+
+func normalizeImpact(impact string) string {
+	impact = strings.ToLower(strings.TrimSpace(impact))
+	return strings.Join(strings.FieldsFunc(impact, func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_'
+	}), "_")
 }
 
 type IncidentEventArray []IncidentEvent
@@ -47,6 +79,27 @@ func (sla IncidentEventArray) Value() (driver.Value, error) {
 	return string(val), err
 }
 
+type ImpactChangeArray []ImpactChange
+
+func (ica *ImpactChangeArray) Scan(src interface{}) error {
+	if src == nil {
+		*ica = nil
+		return nil
+	}
+	return json.Unmarshal(src.([]byte), &ica)
+}
+
+func (ica ImpactChangeArray) Value() (driver.Value, error) {
+	val, err := json.Marshal(ica)
+	return string(val), err
+}
+
+// ImpactChange is an impact that an incident had from the time until the next change
+type ImpactChange struct {
+	Impact Impact    `json:"impact"`
+	Time   time.Time `json:"time"`
+}
+
 type IncidentEvent struct {
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
@@ -61,17 +114,45 @@ func NewIncidentEvent(title string, description string, time time.Time) Incident
 	}
 }
 
+// Incident is indexed on (status_page_url, start_time) to read the incidents of a status page in order,
+// on (end_time, start_time) to find the ongoing incidents and on (status_page_url, updated_at) to find the incidents that changed
 type Incident struct {
 	Title                   string             `json:"title"`
 	Components              []string           `gorm:"column:components;type:jsonb" json:"components"`
 	Events                  IncidentEventArray `gorm:"column:events;type:jsonb" json:"events"`
-	StartTime               time.Time          `gorm:"column:start_time;secondarykey" json:"startTime"`
-	EndTime                 *time.Time         `gorm:"column:end_time;secondarykey" json:"endTime"`
+	StartTime               time.Time          `gorm:"column:start_time;secondarykey;index:idx_incidents_status_page_url_start_time,priority:2;index:idx_incidents_end_time_start_time,priority:2" json:"startTime"`
+	EndTime                 *time.Time         `gorm:"column:end_time;secondarykey;index:idx_incidents_end_time_start_time,priority:1" json:"endTime"`
 	Description             *string            `gorm:"column:description" json:"description"`
 	DeepLink                string             `gorm:"column:deep_link;primarykey" json:"deepLink"`
 	Impact                  Impact             `gorm:"column:impact;secondarykey" json:"impact"`
-	StatusPageUrl           string             `gorm:"column:status_page_url;secondarykey" json:"statusPageUrl"`
+	StatusPageUrl           string             `gorm:"column:status_page_url;secondarykey;index:idx_incidents_status_page_url_start_time,priority:1;index:idx_incidents_status_page_url_updated_at,priority:1" json:"statusPageUrl"`
 	NotificationJobsStarted bool               `gorm:"column:notification_jobs_started;secondarykey" json:"notificationJobsStarted"`
+	// ProviderIncidentID is the id that the status page provider gives the incident, e.g. the code of an Atlassian incident
+	// It is empty if the provider doesn't give its incidents ids
+	ProviderIncidentID string `gorm:"column:provider_incident_id" json:"providerIncidentId"`
+	// SourceURL is the page of the incident on the status page, it is empty if the status page doesn't have a page for each incident
+	// Unlike the deep link it is never generated, so it can always be linked to
+	SourceURL string `gorm:"column:source_url" json:"sourceUrl"`
+	// DedupKey identifies the incident across scrapes, see IncidentDedupKey
+	DedupKey string `gorm:"column:dedup_key;uniqueIndex" json:"-"`
+	// Tags categorize the incident, e.g. network or database, they're derived by the scraper from the keywords in its title and description
+	Tags []string `gorm:"column:tags;type:jsonb" json:"tags"`
+	// ImpactHistory is the impact that the incident started with followed by every change to it that a current scrape found, oldest first,
+	// so that clients can show how the incident escalated. Impact is always the latest impact
+	// Incidents stored before the history was tracked start their history with the impact they had when they were next scraped
+	ImpactHistory ImpactChangeArray `gorm:"column:impact_history;type:jsonb" json:"impactHistory,omitempty"`
+	// DeletedAt is when the incident was found to have been removed from the status page, e.g. because it was posted by mistake
+	// Deleted incidents are kept but only returned when they're asked for
+	DeletedAt *time.Time `gorm:"column:deleted_at" json:"deletedAt,omitempty"`
+	// UpdatedAt is when the incident was first stored, or when a scrape last changed it or found it deleted
+	// Incidents stored before it was tracked have the time it was added at
+	UpdatedAt time.Time `gorm:"column:updated_at;not null;default:now();index:idx_incidents_status_page_url_updated_at,priority:2" json:"updatedAt"`
+	// Stale is true if the incident has been ongoing for longer than the stale threshold of the api, which usually means that
+	// the status page forgot to resolve it. It is computed when the incident is returned by the api, so it isn't stored
+	Stale bool `gorm:"-" json:"stale"`
+	// SlaBreached is true if the incident took, or has so far taken, longer to resolve than the target resolution time of its impact
+	// It is computed when the incident is returned by the api from the targets it is configured with, so it isn't stored
+	SlaBreached bool `gorm:"-" json:"slaBreached"`
 }
 
 func NewIncident(title string, components []string, events []IncidentEvent, startTime time.Time, endTime *time.Time, description *string, deepLink string, impact Impact, statusPageUrl string) Incident {
@@ -88,14 +169,138 @@ func NewIncident(title string, components []string, events []IncidentEvent, star
 	}
 }
 
+// IncidentDedupKey returns the key used to recognise an incident when the status page is scraped again
+// The id the provider gives the incident is preferred, then its deep link, and if it has neither then the title and start time are used instead
+// Incidents stored before provider ids were scraped have the key without the provider id, which is the key given by an empty providerIncidentID
+func IncidentDedupKey(statusPageUrl string, providerIncidentID string, deepLink string, title string, startTime time.Time) string {
+	if providerIncidentID != "" {
+		return statusPageUrl + "|id:" + providerIncidentID
+	}
+	if deepLink != "" {
+		return statusPageUrl + "|link:" + deepLink
+	}
+	return statusPageUrl + "|title:" + title + "|" + startTime.UTC().Format(time.RFC3339)
+}
+
+// IsOngoing returns true if the incident has not been resolved yet
+// An incident is ongoing if it has no end time, some providers report a zero end time rather than none so that is also treated as ongoing
+func (i Incident) IsOngoing() bool {
+	return i.EndTime == nil || i.EndTime.IsZero()
+}
+
+// IsActiveAt returns true if the instant is within [StartTime, EndTime), ongoing incidents are active at any instant from their start
+func (i Incident) IsActiveAt(at time.Time) bool {
+	if at.Before(i.StartTime) {
+		return false
+	}
+	return i.IsOngoing() || at.Before(*i.EndTime)
+}
+
+// IsStaleAt returns true if the incident is ongoing at now and started more than threshold before it
+// Incidents are never stale if the threshold isn't positive
+func (i Incident) IsStaleAt(now time.Time, threshold time.Duration) bool {
+	return threshold > 0 && i.IsOngoing() && now.Sub(i.StartTime) > threshold
+}
+
+// IsSlaBreachedAt returns true if the incident took longer than the target to resolve, or is ongoing at now and started more than target before it
+// Incidents never breach a target that isn't positive, which is the target of impacts without one
+func (i Incident) IsSlaBreachedAt(now time.Time, target time.Duration) bool {
+	if target <= 0 {
+		return false
+	}
+	end := now
+	if !i.IsOngoing() {
+		end = *i.EndTime
+	}
+	return end.Sub(i.StartTime) > target
+}
+
 type StatusPage struct {
 	Name string `gorm:"secondarykey" json:"name"`
 	URL  string `gorm:"primarykey" json:"url"`
 	// Used to determine if we should run a scrape for this status page
 	LastHistoricallyScraped time.Time `json:"lastHistoricallyScraped"`
 	LastCurrentlyScraped    time.Time `json:"lastCurrentlyScraped"`
+	// LastSuccessfullyScraped is when the last successful current scrape of the status page finished, it is zero if there hasn't been one
+	LastSuccessfullyScraped time.Time `json:"lastSuccessfullyScraped"`
+	// LastScrapeError is the error of the last current scrape of the status page, it is empty if the last scrape succeeded
+	LastScrapeError string `json:"lastScrapeError"`
 	// IsIndexed is used to determine if the status page has ever been indexed in the search engine successfully
 	IsIndexed bool `json:"isIndexed"`
+	// IndexingPaused stops the scraper scraping the status page, e.g. while it breaks a provider, its incidents are kept
+	IndexingPaused bool `json:"indexingPaused"`
+	// RecentIncidentCount is the number of incidents that started in the last 30 days, it is only set when the api is asked for it
+	// so it isn't stored
+	RecentIncidentCount *int `gorm:"-" json:"recentIncidentCount,omitempty"`
+}
+
+// StatusPageSnapshot is the response of the status page to its last current scrape, which is kept to diagnose scrapes that find the wrong incidents
+type StatusPageSnapshot struct {
+	StatusPageUrl string `gorm:"column:status_page_url;primarykey" json:"statusPageUrl"`
+	// Provider is the name of the provider that scraped the status page, it is empty if no provider detected the page
+	Provider string `gorm:"column:provider" json:"provider"`
+	// ScrapedAt is when the status page was fetched and parsed by the provider
+	ScrapedAt   time.Time `gorm:"column:scraped_at" json:"scrapedAt"`
+	StatusCode  int       `gorm:"column:status_code" json:"statusCode"`
+	ContentType string    `gorm:"column:content_type" json:"contentType"`
+	// Error is why the provider failed to scrape the status page, it is empty if the scrape succeeded
+	Error string `gorm:"column:error" json:"error,omitempty"`
+	// Truncated is true if the body was larger than the most that is kept, in which case only the start of it is kept
+	Truncated bool `gorm:"column:truncated" json:"truncated"`
+	// CompressedBody is the gzipped body of the response, see SetBody and Body
+	CompressedBody []byte `gorm:"column:compressed_body" json:"-"`
+}
+
+// SetBody stores the body of the response gzipped, as status pages are mostly html or json that compresses well
+func (s *StatusPageSnapshot) SetBody(body []byte) error {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(body); err != nil {
+		return errors.Wrap(err, "failed to compress the body")
+	}
+	if err := writer.Close(); err != nil {
+		return errors.Wrap(err, "failed to compress the body")
+	}
+	s.CompressedBody = compressed.Bytes()
+	return nil
+}
+
+// Body returns the body of the response that was stored by SetBody
+func (s StatusPageSnapshot) Body() ([]byte, error) {
+	if len(s.CompressedBody) == 0 {
+		return nil, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(s.CompressedBody))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress the body")
+	}
+	defer reader.Close()
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress the body")
+	}
+	return body, nil
+}
+
+// CanonicalStatusPageUrl returns the form that status page urls are stored and looked up in, so that urls that only differ
+// by the case of their host or a trailing slash are the same status page, e.g. https://Status.Example.com/ is https://status.example.com
+// The query and fragment are kept as some status pages need them
+// It returns an error describing what is wrong with the url if it isn't an absolute http(s) url
+func CanonicalStatusPageUrl(rawUrl string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(rawUrl))
+	if err != nil {
+		return "", errors.New("url is invalid")
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", errors.New("url must be an http or https url")
+	}
+	if parsed.Hostname() == "" || parsed.User != nil {
+		return "", errors.New("url must have a host and no credentials")
+	}
+	parsed.Host = strings.ToLower(parsed.Host)
+	parsed.Path = strings.TrimRight(parsed.Path, "/")
+	parsed.RawPath = strings.TrimRight(parsed.RawPath, "/")
+	return parsed.String(), nil
 }
 
 func NewStatusPage(name string, url string) StatusPage {
@@ -106,3 +311,90 @@ func NewStatusPage(name string, url string) StatusPage {
 		LastCurrentlyScraped:    time.Time{},
 	}
 }
+
+type ImpactArray []Impact
+
+func (ia *ImpactArray) Scan(src interface{}) error {
+	if src == nil {
+		*ia = nil
+		return nil
+	}
+	return json.Unmarshal(src.([]byte), &ia)
+}
+
+func (ia ImpactArray) Value() (driver.Value, error) {
+	val, err := json.Marshal(ia)
+	return string(val), err
+}
+
+type SubscriptionFormat string
+
+const (
+	// SubscriptionFormatJson sends the incident as json
+	SubscriptionFormatJson SubscriptionFormat = "json"
+	// SubscriptionFormatSlack sends a message that can be posted to a slack incoming webhook
+	SubscriptionFormatSlack SubscriptionFormat = "slack"
+)
+
+var ErrInvalidSubscriptionFormat = errors.New("invalid subscription format")
+
+// ParseSubscriptionFormat parses the format of a subscription, an empty format is SubscriptionFormatJson
+func ParseSubscriptionFormat(format string) (SubscriptionFormat, error) {
+	switch format {
+	case "", "json":
+		return SubscriptionFormatJson, nil
+	case "slack":
+		return SubscriptionFormatSlack, nil
+	default:
+		return "", ErrInvalidSubscriptionFormat
+	}
+}
+
+// Subscription is a webhook that is sent the new incidents of a status page
+type Subscription struct {
+	ID            string `gorm:"column:id;primarykey" json:"id"`
+	StatusPageUrl string `gorm:"column:status_page_url;secondarykey" json:"statusPageUrl"`
+	TargetUrl     string `gorm:"column:target_url" json:"targetUrl"`
+	// Impacts are the impacts of the incidents that are sent, all incidents are sent if it is empty
+	Impacts ImpactArray `gorm:"column:impacts;type:jsonb" json:"impacts"`
+	// Format is the format of the webhook body, subscriptions created before formats were added have an empty format which is json
+	Format SubscriptionFormat `gorm:"column:format" json:"format"`
+	// Secret is used to sign the webhooks so that the receiver can check they were sent by statusphere
+	// It is only returned when the subscription is created
+	Secret    string    `gorm:"column:secret" json:"secret,omitempty"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"createdAt"`
+}
+
+// Matches returns true if the incident should be sent to the subscription
+func (s Subscription) Matches(incident Incident) bool {
+	if incident.StatusPageUrl != s.StatusPageUrl {
+		return false
+	}
+	if len(s.Impacts) == 0 {
+		return true
+	}
+	for _, impact := range s.Impacts {
+		if impact == incident.Impact {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscriptionDelivery is an attempt to send the webhook of an incident to a subscription
+// Deliveries are indexed on (subscription_id, attempted_at) to list the latest deliveries of a subscription
+type SubscriptionDelivery struct {
+	ID             string `gorm:"column:id;primarykey" json:"id"`
+	SubscriptionID string `gorm:"column:subscription_id;index:idx_subscription_deliveries_subscription_id_attempted_at,priority:1" json:"subscriptionId"`
+	// IncidentID is the id of the incident that was sent, which is its deep link
+	IncidentID string `gorm:"column:incident_id" json:"incidentId"`
+	// StatusCode is the status code that the target url responded with, it is zero if the request failed without a response
+	StatusCode int `gorm:"column:status_code" json:"statusCode"`
+	// Error is why the delivery failed, it is empty if the target url responded with a 2xx status code
+	Error       string    `gorm:"column:error" json:"error,omitempty"`
+	AttemptedAt time.Time `gorm:"column:attempted_at;index:idx_subscription_deliveries_subscription_id_attempted_at,priority:2" json:"attemptedAt"`
+	// RedeliveryOf is the id of the delivery that this delivery resent, it is empty for the deliveries of new incidents
+	RedeliveryOf string `gorm:"column:redelivery_of" json:"redeliveryOf,omitempty"`
+	// RedeliveryRequestedAt is set when the delivery is requested to be sent again, until the jobrunner starts the redelivery
+	RedeliveryRequestedAt *time.Time `gorm:"column:redelivery_requested_at;index" json:"redeliveryRequestedAt,omitempty"`
+}
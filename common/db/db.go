@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -16,6 +17,7 @@ import (
 	"gorm.io/gorm/logger"
 	"log"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -27,18 +29,54 @@ type Config struct {
 	User     string `envconfig:"POSTGRES_USER"`
 	Password string `envconfig:"POSTGRES_PASSWORD"`
 	Database string `envconfig:"POSTGRES_DATABASE"`
+	// MaxOpenConns is the most connections that are open to the database at once, queries wait for a connection when they're all in use
+	MaxOpenConns int `envconfig:"POSTGRES_MAX_OPEN_CONNS" default:"25"`
+	// MaxIdleConns is the most connections that are kept open while they're not in use, it can't be more than MaxOpenConns
+	MaxIdleConns int `envconfig:"POSTGRES_MAX_IDLE_CONNS" default:"10"`
+	// ConnMaxLifetime is how long a connection is used for before it is closed and replaced, zero means connections are reused forever
+	ConnMaxLifetime time.Duration `envconfig:"POSTGRES_CONN_MAX_LIFETIME" default:"30m"`
+	// QueryTimeout is the deadline of the queries that read incidents and status pages, on top of the deadline of the request they're made for
+	// Zero means that they only have the deadline of the request
+	QueryTimeout time.Duration `envconfig:"POSTGRES_QUERY_TIMEOUT" default:"5s"`
+}
+
+// Validate returns an error if the connection pool or timeouts of the config don't make sense
+func (c Config) Validate() error {
+	if c.MaxOpenConns <= 0 {
+		return errors.Errorf("the max open connections must be positive, got %d", c.MaxOpenConns)
+	}
+	if c.MaxIdleConns < 0 {
+		return errors.Errorf("the max idle connections must not be negative, got %d", c.MaxIdleConns)
+	}
+	if c.MaxIdleConns > c.MaxOpenConns {
+		return errors.Errorf("the max idle connections (%d) must not be more than the max open connections (%d)", c.MaxIdleConns, c.MaxOpenConns)
+	}
+	if c.ConnMaxLifetime < 0 {
+		return errors.Errorf("the connection max lifetime must not be negative, got %s", c.ConnMaxLifetime)
+	}
+	if c.QueryTimeout < 0 {
+		return errors.Errorf("the query timeout must not be negative, got %s", c.QueryTimeout)
+	}
+	return nil
 }
 
 func getConfigFromEnvironment() (Config, error) {
 	var config Config
-	err := envconfig.Process("STATUSPHERE", &config)
-	return config, err
+	if err := envconfig.Process("STATUSPHERE", &config); err != nil {
+		return config, err
+	}
+	if err := config.Validate(); err != nil {
+		return config, errors.Wrap(err, "invalid database config")
+	}
+	return config, nil
 }
 
 type DbClient struct {
 	PgxPool *pgxpool.Pool
 	db      *gorm.DB
 	logger  *zap.Logger
+	// queryTimeout is the deadline of the queries made by withQueryTimeout, see Config.QueryTimeout
+	queryTimeout time.Duration
 }
 
 func NewDbClientFromEnvironment(lg *zap.Logger) (*DbClient, error) {
@@ -90,17 +128,57 @@ func NewDbClientFromEnvironment(lg *zap.Logger) (*DbClient, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to connect to postgres")
 	}
+	sqlDb, err := db.DB()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the database connection pool")
+	}
+	sqlDb.SetMaxOpenConns(config.MaxOpenConns)
+	sqlDb.SetMaxIdleConns(config.MaxIdleConns)
+	sqlDb.SetConnMaxLifetime(config.ConnMaxLifetime)
 
-	pgxPool, err := pgxpool.New(context.Background(), dsn)
+	pgxConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the pgx pool config")
+	}
+	pgxConfig.MaxConns = int32(config.MaxOpenConns)
+	if config.ConnMaxLifetime > 0 {
+		pgxConfig.MaxConnLifetime = config.ConnMaxLifetime
+	}
+	pgxPool, err := pgxpool.NewWithConfig(context.Background(), pgxConfig)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create pgx pool")
 	}
 
-	return &DbClient{db: db, logger: lg, PgxPool: pgxPool}, nil
+	return &DbClient{db: db, logger: lg, PgxPool: pgxPool, queryTimeout: config.QueryTimeout}, nil
+}
+
+// withQueryTimeout returns the gorm db for a query made with the context, which is cancelled after the query timeout
+// so that slow queries are cancelled rather than holding on to their connection, the cancel function must be called once the query is done
+func (d *DbClient) withQueryTimeout(ctx context.Context) (*gorm.DB, context.CancelFunc) {
+	if d.queryTimeout <= 0 {
+		return d.db.WithContext(ctx), func() {}
+	}
+	ctx, cancel := context.WithTimeout(ctx, d.queryTimeout)
+	return d.db.WithContext(ctx), cancel
+}
+
+// Ping checks that the database can be reached
+func (d *DbClient) Ping(ctx context.Context) error {
+	sqlDb, err := d.db.DB()
+	if err != nil {
+		return errors.Wrap(err, "failed to get the database connection pool")
+	}
+	return sqlDb.PingContext(ctx)
 }
 
 const statusPageTableName = "status_page"
 const incidentsTableName = "incidents"
+const subscriptionsTableName = "subscriptions"
+const subscriptionDeliveriesTableName = "subscription_deliveries"
+const statusPageSnapshotsTableName = "status_page_snapshots"
+
+// SubscriptionDeliveriesRetained is the number of the latest deliveries of each subscription that are kept, older ones are deleted as new ones are recorded
+const SubscriptionDeliveriesRetained = 100
 
 func (d *DbClient) AutoMigrate(ctx context.Context) error {
 	// Create the schema if it does not exist
@@ -111,32 +189,109 @@ func (d *DbClient) AutoMigrate(ctx context.Context) error {
 	if err != nil {
 		return errors.Wrap(err, "failed to auto-migrate status_page table")
 	}
-	err = d.SeedStatusPages()
-	if err != nil {
-		return errors.Wrap(err, "failed to seed status pages")
-	}
 
 	// Create the incidents table
 	err = d.db.Table(fmt.Sprintf(fmt.Sprintf("%s.%s", schemaName, incidentsTableName))).AutoMigrate(&api.Incident{})
 	if err != nil {
 		return errors.Wrap(err, "failed to auto-migrate incidents table")
 	}
+	err = d.backfillIncidentDedupKeys()
+	if err != nil {
+		return errors.Wrap(err, "failed to backfill incident dedup keys")
+	}
+
+	// Create the subscriptions table
+	err = d.db.Table(fmt.Sprintf("%s.%s", schemaName, subscriptionsTableName)).AutoMigrate(&api.Subscription{})
+	if err != nil {
+		return errors.Wrap(err, "failed to auto-migrate subscriptions table")
+	}
+	err = d.db.Table(fmt.Sprintf("%s.%s", schemaName, subscriptionDeliveriesTableName)).AutoMigrate(&api.SubscriptionDelivery{})
+	if err != nil {
+		return errors.Wrap(err, "failed to auto-migrate subscription deliveries table")
+	}
+
+	// Create the status page snapshots table
+	err = d.db.Table(fmt.Sprintf("%s.%s", schemaName, statusPageSnapshotsTableName)).AutoMigrate(&api.StatusPageSnapshot{})
+	if err != nil {
+		return errors.Wrap(err, "failed to auto-migrate status page snapshots table")
+	}
+
+	// The status pages are seeded once all the tables exist, as the urls of the status pages already stored are canonicalized first
+	err = d.canonicalizeStatusPageUrls()
+	if err != nil {
+		return errors.Wrap(err, "failed to canonicalize status page urls")
+	}
+	err = d.SeedStatusPages()
+	if err != nil {
+		return errors.Wrap(err, "failed to seed status pages")
+	}
 
 	return nil
 }
 
+// canonicalizeStatusPageUrls updates the status pages that were stored before their urls were canonicalized, see api.CanonicalStatusPageUrl
+// The incidents and subscriptions of the status page are moved to the canonical url too, along with the dedup keys of the incidents
+// which start with the url of the status page, so that the incidents are still matched when the status page is scraped again
+func (d *DbClient) canonicalizeStatusPageUrls() error {
+	statusPages, err := d.GetAllStatusPages(context.Background())
+	if err != nil {
+		return err
+	}
+	for _, statusPage := range statusPages {
+		canonicalUrl, err := api.CanonicalStatusPageUrl(statusPage.URL)
+		if err != nil || canonicalUrl == statusPage.URL {
+			continue
+		}
+		existing, err := d.GetStatusPage(context.Background(), canonicalUrl)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			d.logger.Warn("not canonicalizing the status page url as the canonical url is already stored", zap.String("url", statusPage.URL), zap.String("canonicalUrl", canonicalUrl))
+			continue
+		}
+		err = d.db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Table(fmt.Sprintf("%s.%s", schemaName, statusPageTableName)).Where("url = ?", statusPage.URL).Update("url", canonicalUrl).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).Where("status_page_url = ?", statusPage.URL).Updates(map[string]interface{}{
+				"status_page_url": canonicalUrl,
+				"dedup_key":       gorm.Expr("? || substr(dedup_key, ?)", canonicalUrl, len(statusPage.URL)+1),
+			}).Error
+			if err != nil {
+				return err
+			}
+			return tx.Table(fmt.Sprintf("%s.%s", schemaName, subscriptionsTableName)).Where("status_page_url = ?", statusPage.URL).Update("status_page_url", canonicalUrl).Error
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to canonicalize the url of %s", statusPage.URL)
+		}
+		d.logger.Info("canonicalized status page url", zap.String("url", statusPage.URL), zap.String("canonicalUrl", canonicalUrl))
+	}
+	return nil
+}
+
+// GetAllStatusPages gets every status page
+// The query is cancelled if it takes longer than the query timeout or ctx is done
 func (d *DbClient) GetAllStatusPages(ctx context.Context) ([]api.StatusPage, error) {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
 	var statusPages []api.StatusPage
-	result := d.db.Table(fmt.Sprintf(fmt.Sprintf("%s.%s", schemaName, statusPageTableName))).Find(&statusPages)
+	result := db.Table(fmt.Sprintf(fmt.Sprintf("%s.%s", schemaName, statusPageTableName))).Find(&statusPages)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return statusPages, nil
 }
 
+// GetStatusPage gets the status page with the url, it returns nil if there isn't one
+// The query is cancelled if it takes longer than the query timeout or ctx is done
 func (d *DbClient) GetStatusPage(ctx context.Context, url string) (*api.StatusPage, error) {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
 	var statusPage api.StatusPage
-	result := d.db.Table(fmt.Sprintf(fmt.Sprintf("%s.%s", schemaName, statusPageTableName))).Where("url = ?", url).First(&statusPage)
+	result := db.Table(fmt.Sprintf(fmt.Sprintf("%s.%s", schemaName, statusPageTableName))).Where("url = ?", url).First(&statusPage)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -154,6 +309,92 @@ func (d *DbClient) UpdateStatusPage(ctx context.Context, statusPage api.StatusPa
 	return nil
 }
 
+// ErrStatusPageExists is returned by CreateStatusPage if there is already a status page with the same url
+var ErrStatusPageExists = errors.New("status page already exists")
+
+// CreateStatusPage inserts a new status page, returning ErrStatusPageExists if its url is already known
+func (d *DbClient) CreateStatusPage(ctx context.Context, statusPage api.StatusPage) error {
+	result := d.db.Table(fmt.Sprintf("%s.%s", schemaName, statusPageTableName)).Create(&statusPage)
+	if result.Error != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(result.Error, &pgErr) && pgErr.Code == "23505" {
+			// This is the code for a unique violation of the url primary key
+			return ErrStatusPageExists
+		}
+		return result.Error
+	}
+	return nil
+}
+
+// RecordStatusPageScrape records the outcome of an attempt to scrape the current incidents of the status page
+// If the scrape succeeded then the status page is marked as indexed
+func (d *DbClient) RecordStatusPageScrape(ctx context.Context, url string, attemptedAt time.Time, scrapeErr error) error {
+	// Updates ignores zero values in structs, so the columns have to be set with a map to clear the error
+	updates := map[string]interface{}{
+		"last_currently_scraped": attemptedAt,
+		"last_scrape_error":      "",
+	}
+	if scrapeErr != nil {
+		updates["last_scrape_error"] = scrapeErr.Error()
+	} else {
+		updates["last_successfully_scraped"] = attemptedAt
+		updates["is_indexed"] = true
+	}
+	result := d.db.Table(fmt.Sprintf("%s.%s", schemaName, statusPageTableName)).Where("url = ?", url).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// MarkStatusPageForRescrape resets the last scraped times of the status page so that the scraper scrapes it again the next time it runs
+func (d *DbClient) MarkStatusPageForRescrape(ctx context.Context, url string) error {
+	// Updates ignores zero values in structs, so the columns have to be set with a map
+	result := d.db.Table(fmt.Sprintf("%s.%s", schemaName, statusPageTableName)).Where("url = ?", url).Updates(map[string]interface{}{
+		"last_historically_scraped": time.Time{},
+		"last_currently_scraped":    time.Time{},
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// SetStatusPageIndexingPaused pauses or resumes the scraping of the status page
+func (d *DbClient) SetStatusPageIndexingPaused(ctx context.Context, url string, paused bool) error {
+	// Updates ignores zero values in structs, so the column has to be set with a map for it to be set to false
+	result := d.db.Table(fmt.Sprintf("%s.%s", schemaName, statusPageTableName)).Where("url = ?", url).Updates(map[string]interface{}{
+		"indexing_paused": paused,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// RecordStatusPageSnapshot stores the snapshot of the status page, replacing its previous snapshot so that only the last one is kept
+func (d *DbClient) RecordStatusPageSnapshot(ctx context.Context, snapshot api.StatusPageSnapshot) error {
+	result := d.db.Table(fmt.Sprintf("%s.%s", schemaName, statusPageSnapshotsTableName)).Clauses(clause.OnConflict{UpdateAll: true}).Create(&snapshot)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// GetStatusPageSnapshot returns the last snapshot of the status page
+// If the status page hasn't been snapshotted, it returns nil
+func (d *DbClient) GetStatusPageSnapshot(ctx context.Context, statusPageUrl string) (*api.StatusPageSnapshot, error) {
+	var snapshot api.StatusPageSnapshot
+	result := d.db.Table(fmt.Sprintf("%s.%s", schemaName, statusPageSnapshotsTableName)).Where("status_page_url = ?", statusPageUrl).First(&snapshot)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &snapshot, nil
+}
+
 func (d *DbClient) InsertStatusPage(ctx context.Context, statusPage api.StatusPage) error {
 	result := d.db.Table(fmt.Sprintf(fmt.Sprintf("%s.%s", schemaName, statusPageTableName))).Create(&statusPage)
 	if result.Error != nil {
@@ -162,20 +403,326 @@ func (d *DbClient) InsertStatusPage(ctx context.Context, statusPage api.StatusPa
 	return nil
 }
 
+// notDeleted is the condition that excludes the incidents that have been removed from their status page, see MarkMissingIncidentsDeleted
+const notDeleted = "deleted_at IS NULL"
+
+// GetIncidents gets the incidents of the status page, excluding the ones that have been deleted
+// The query is cancelled if it takes longer than the query timeout or ctx is done
 func (d *DbClient) GetIncidents(ctx context.Context, statusPageUrl string) ([]api.Incident, error) {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	var incidents []api.Incident
+	result := db.Table(fmt.Sprintf(fmt.Sprintf("%s.%s", schemaName, incidentsTableName))).Where("status_page_url = ?", statusPageUrl).Where(notDeleted).Find(&incidents)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return incidents, nil
+}
+
+// GetIncidentsUpdatedSince gets the incidents of the status page that were stored or changed after since, oldest change first
+// It includes the deleted incidents, as their deletion is a change
+// The query is cancelled if it takes longer than the query timeout or ctx is done
+func (d *DbClient) GetIncidentsUpdatedSince(ctx context.Context, statusPageUrl string, since time.Time) ([]api.Incident, error) {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	var incidents []api.Incident
+	result := db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).Where("status_page_url = ? AND updated_at > ?", statusPageUrl, since).Order("updated_at ASC").Find(&incidents)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return incidents, nil
+}
+
+// GetIncidentsIncludingDeleted gets the incidents of the status page, including the ones that have been deleted
+// The query is cancelled if it takes longer than the query timeout or ctx is done
+func (d *DbClient) GetIncidentsIncludingDeleted(ctx context.Context, statusPageUrl string) ([]api.Incident, error) {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	var incidents []api.Incident
+	result := db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).Where("status_page_url = ?", statusPageUrl).Find(&incidents)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return incidents, nil
+}
+
+// GetIncidentByID returns the incident with the given id, the id of an incident is its deep link
+// If the incident does not exist, it returns nil
+// The query is cancelled if it takes longer than the query timeout or ctx is done
+func (d *DbClient) GetIncidentByID(ctx context.Context, id string) (*api.Incident, error) {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	var incident api.Incident
+	result := db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).Where("deep_link = ?", id).Where(notDeleted).First(&incident)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &incident, nil
+}
+
+// SearchIncidents gets the incidents of the status page whose title or description contains the query case insensitively
+// The query is cancelled if it takes longer than the query timeout or ctx is done
+func (d *DbClient) SearchIncidents(ctx context.Context, statusPageUrl string, query string) ([]api.Incident, error) {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	var incidents []api.Incident
+	pattern := "%" + likePatternEscaper.Replace(query) + "%"
+	result := db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).Where("status_page_url = ? AND (title ILIKE ? OR description ILIKE ?)", statusPageUrl, pattern, pattern).Where(notDeleted).Find(&incidents)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return incidents, nil
+}
+
+// IncidentsSearch searches the incidents of every status page in the database, see SearchAllIncidents
+type IncidentsSearch struct {
+	// Query is matched case insensitively against the title and description of the incidents
+	Query string
+	// From and To are the range of the start times of the incidents searched, they're required so that the search doesn't scan every incident
+	From time.Time
+	To   time.Time
+	// AfterStartTime and AfterDeepLink are the position of the last incident of the previous page, the first page is returned if AfterDeepLink is empty
+	AfterStartTime time.Time
+	AfterDeepLink  string
+	Limit          int
+}
+
+// SearchAllIncidents gets the incidents of every status page whose title or description contains the query case insensitively
+// and that started in the range of the search, most recent first and then by deep link, paged by the position of the last incident of the previous page
+// The query is cancelled if it takes longer than the query timeout or ctx is done
+func (d *DbClient) SearchAllIncidents(ctx context.Context, search IncidentsSearch) ([]api.Incident, error) {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	pattern := "%" + likePatternEscaper.Replace(search.Query) + "%"
+	tx := db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).
+		Where("start_time >= ? AND start_time <= ?", search.From, search.To).
+		Where("title ILIKE ? OR description ILIKE ?", pattern, pattern).
+		Where(notDeleted)
+	if search.AfterDeepLink != "" {
+		tx = tx.Where("(start_time, deep_link) < (?, ?)", search.AfterStartTime, search.AfterDeepLink)
+	}
+	var incidents []api.Incident
+	result := tx.Order("start_time DESC").Order("deep_link DESC").Limit(search.Limit).Find(&incidents)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return incidents, nil
+}
+
+// IncidentsQuery filters the incidents of a status page in the database, see QueryIncidents
+type IncidentsQuery struct {
+	StatusPageUrl string
+	// Impacts are the impacts of the incidents returned, if it is nil then incidents of every impact are returned
+	Impacts []api.Impact
+	// From and To filter the incidents by their start time, either can be nil to leave that side of the range open-ended
+	From *time.Time
+	To   *time.Time
+	// Ascending orders the incidents oldest first rather than most recent first
+	Ascending bool
+	// Limit is the maximum number of incidents returned, if it is nil then every matching incident is returned
+	Limit  *int
+	Offset int
+}
+
+// filterIncidents is the query of the incidents matching the filters of the query, made on db, see withQueryTimeout
+func filterIncidents(db *gorm.DB, query IncidentsQuery) *gorm.DB {
+	tx := db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).Where("status_page_url = ?", query.StatusPageUrl)
+	if query.Impacts != nil {
+		tx = tx.Where("impact IN ?", query.Impacts)
+	}
+	if query.From != nil {
+		tx = tx.Where("start_time >= ?", *query.From)
+	}
+	if query.To != nil {
+		tx = tx.Where("start_time <= ?", *query.To)
+	}
+	return tx.Where(notDeleted)
+}
+
+// QueryIncidents gets the incidents of a status page that match the query, sorted by start time and then deep link
+// The filtering, ordering and paging are done by the database so that only the incidents returned are read into memory
+// The query is cancelled if it takes longer than the query timeout or ctx is done
+func (d *DbClient) QueryIncidents(ctx context.Context, query IncidentsQuery) ([]api.Incident, error) {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	tx := queryIncidents(db, query)
 	var incidents []api.Incident
-	result := d.db.Table(fmt.Sprintf(fmt.Sprintf("%s.%s", schemaName, incidentsTableName))).Where("status_page_url = ?", statusPageUrl).Find(&incidents)
+	result := tx.Find(&incidents)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return incidents, nil
 }
 
+// StreamIncidents sends the incidents of a status page that match the query to the channel in the order of QueryIncidents,
+// reading them from a database cursor one at a time so that they are never all in memory at once
+// The channel is closed once every incident has been sent, or the stream fails or ctx is done, in which case the error is returned
+// The query is cancelled if it hasn't returned its rows within the query timeout, after that the stream is only cancelled when ctx is done
+// as reading the rest of the rows takes as long as the incidents take to be received from the channel, which can be sent to a slow client
+func (d *DbClient) StreamIncidents(ctx context.Context, query IncidentsQuery, incidents chan<- api.Incident) error {
+	defer close(incidents)
+	// The rows are read with the context of the query, so the query timeout cancels it with a cause rather than being its deadline
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	stopQueryTimeout := func() bool { return true }
+	if d.queryTimeout > 0 {
+		stopQueryTimeout = time.AfterFunc(d.queryTimeout, func() { cancel(context.DeadlineExceeded) }).Stop
+	}
+	tx := queryIncidents(d.db.WithContext(ctx), query)
+	rows, err := tx.Rows()
+	if !stopQueryTimeout() {
+		if rows != nil {
+			_ = rows.Close()
+		}
+		return context.Cause(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var incident api.Incident
+		if err := tx.ScanRows(rows, &incident); err != nil {
+			return err
+		}
+		select {
+		case incidents <- incident:
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+	}
+	return rows.Err()
+}
+
+// queryIncidents is the query of the incidents matching the query, sorted by start time and then deep link and paged, made on db
+func queryIncidents(db *gorm.DB, query IncidentsQuery) *gorm.DB {
+	direction := "DESC"
+	if query.Ascending {
+		direction = "ASC"
+	}
+	tx := filterIncidents(db, query).Order("start_time " + direction).Order("deep_link " + direction)
+	if query.Limit != nil {
+		tx = tx.Limit(*query.Limit)
+	}
+	if query.Offset > 0 {
+		tx = tx.Offset(query.Offset)
+	}
+	return tx
+}
+
+// IncidentCounts are the number of incidents that match a query, regardless of its limit and offset
+type IncidentCounts struct {
+	Total   int `gorm:"column:total"`
+	Ongoing int `gorm:"column:ongoing"`
+}
+
+// CountIncidents counts the incidents of a status page that match the query, ignoring its limit and offset
+// Ongoing incidents are the ones without an end time, see api.Incident.IsOngoing
+// The query is cancelled if it takes longer than the query timeout or ctx is done
+func (d *DbClient) CountIncidents(ctx context.Context, query IncidentsQuery) (IncidentCounts, error) {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	var counts IncidentCounts
+	result := filterIncidents(db, query).Select("COUNT(*) AS total, COUNT(*) FILTER (WHERE end_time IS NULL OR end_time = ?) AS ongoing", time.Time{}).Scan(&counts)
+	if result.Error != nil {
+		return IncidentCounts{}, result.Error
+	}
+	return counts, nil
+}
+
+// CountIncidentsByImpact counts the incidents of a status page that match the query by their impact, ignoring its impacts, limit and offset
+// The counts are found with a single grouped query, impacts without any matching incidents aren't in the result
+// The query is cancelled if it takes longer than the query timeout or ctx is done
+func (d *DbClient) CountIncidentsByImpact(ctx context.Context, query IncidentsQuery) (map[api.Impact]int, error) {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	query.Impacts = nil
+	var rows []struct {
+		Impact api.Impact
+		Count  int
+	}
+	result := filterIncidents(db, query).Select("impact, COUNT(*) AS count").Group("impact").Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	counts := make(map[api.Impact]int, len(rows))
+	for _, row := range rows {
+		counts[row.Impact] = row.Count
+	}
+	return counts, nil
+}
+
+// CountIncidentsByStatusPage counts the incidents of every status page that started at or after since, excluding the ones that have been deleted
+// The counts are found with a single grouped query, status pages without any such incidents aren't in the result
+func (d *DbClient) CountIncidentsByStatusPage(ctx context.Context, since time.Time) (map[string]int, error) {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	var rows []struct {
+		StatusPageUrl string
+		Count         int
+	}
+	result := db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).
+		Select("status_page_url, COUNT(*) AS count").
+		Where("start_time >= ?", since).
+		Where(notDeleted).
+		Group("status_page_url").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.StatusPageUrl] = row.Count
+	}
+	return counts, nil
+}
+
+// likePatternEscaper escapes the wildcards of a LIKE pattern, backslash is the default escape character in postgres
+var likePatternEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 // Current incidents are incidents that have not ended and have a start time in the last two weeks
 // The two week cutiff is not ideal but some incidents don't have a specified end time
+// The query is cancelled if it takes longer than the query timeout or ctx is done
 func (d *DbClient) GetCurrentIncidents(ctx context.Context, statusPageUrl string) ([]api.Incident, error) {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	var incidents []api.Incident
+	result := db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).Where("status_page_url = ? AND start_time > ? AND (end_time IS NULL OR end_time = ?)", statusPageUrl, time.Now().Add(-14*24*time.Hour), time.Time{}).Where(notDeleted).Find(&incidents)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return incidents, nil
+}
+
+// GetAllCurrentIncidents gets the current incidents of every status page, see GetCurrentIncidents
+// Some providers report a zero end time for incidents that haven't ended, so those are included too
+// The query is cancelled if it takes longer than the query timeout or ctx is done
+func (d *DbClient) GetAllCurrentIncidents(ctx context.Context) ([]api.Incident, error) {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	var incidents []api.Incident
+	result := db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).Where("start_time > ? AND (end_time IS NULL OR end_time = ?)", time.Now().Add(-14*24*time.Hour), time.Time{}).Where(notDeleted).Find(&incidents)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return incidents, nil
+}
+
+// GetRecentIncidents gets the most recent incidents across every status page, sorted by start time descending
+// If impacts is nil then incidents of every impact are returned, otherwise only the incidents with one of the impacts are
+// The query is cancelled if it takes longer than the query timeout or ctx is done
+func (d *DbClient) GetRecentIncidents(ctx context.Context, limit int, impacts []api.Impact) ([]api.Incident, error) {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
 	var incidents []api.Incident
-	result := d.db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).Where("status_page_url = ? AND start_time > ? AND end_time = NULL", statusPageUrl, time.Now().Add(-14*24*time.Hour)).Find(&incidents)
+	query := db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).Where(notDeleted)
+	if impacts != nil {
+		query = query.Where("impact IN ?", impacts)
+	}
+	result := query.Order("start_time DESC").Order("deep_link DESC").Limit(limit).Find(&incidents)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -184,7 +731,7 @@ func (d *DbClient) GetCurrentIncidents(ctx context.Context, statusPageUrl string
 
 func (d *DbClient) GetIncidentsWithoutJobsStarted(ctx context.Context, limit int) ([]api.Incident, error) {
 	var incidents []api.Incident
-	result := d.db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).Where("notification_jobs_started is distinct from true limit ?", limit).Find(&incidents)
+	result := d.db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).Where("notification_jobs_started is distinct from true").Where(notDeleted).Limit(limit).Find(&incidents)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -208,11 +755,38 @@ func (d *DbClient) SetIncidentNotificationStartedToTrue(ctx context.Context, inc
 
 }
 
+// CreateOrUpdateIncidents inserts the incidents, or updates them if they were inserted by an earlier scrape
+// Incidents are matched on their dedup key so that an incident that has been resolved since the last scrape is updated rather than duplicated
 func (d *DbClient) CreateOrUpdateIncidents(ctx context.Context, incidents []api.Incident) error {
+	if err := d.rekeyIncidentsWithProviderIds(incidents); err != nil {
+		return errors.Wrap(err, "failed to rekey the incidents with provider incident ids")
+	}
+	incidents = prepareIncidentsForUpsert(incidents)
+	if len(incidents) == 0 {
+		return nil
+	}
+	doUpdates := clause.AssignmentColumns([]string{"title", "components", "start_time", "end_time", "description", "impact", "deleted_at", "provider_incident_id", "source_url", "tags"}) // Update the data column, an incident that was deleted is undeleted if it is scraped again
+	// Only some scrapes get the events of incidents, e.g. the historical scrape doesn't, so the events are kept if the scrape didn't find any
+	events := fmt.Sprintf(`COALESCE(NULLIF(NULLIF(excluded.events, 'null'::jsonb), '[]'::jsonb), %s.events)`, incidentsTableName)
+	doUpdates = append(doUpdates, clause.Assignment{
+		Column: clause.Column{Name: "events"},
+		Value:  gorm.Expr(events),
+	})
+	// Only current scrapes track the impact history of the incidents, e.g. the historical scrape doesn't, so the history is kept if the scrape doesn't have one
+	doUpdates = append(doUpdates, clause.Assignment{
+		Column: clause.Column{Name: "impact_history"},
+		Value:  gorm.Expr(fmt.Sprintf(`COALESCE(NULLIF(excluded.impact_history, 'null'::jsonb), %s.impact_history)`, incidentsTableName)),
+	})
+	// The updated time is only moved forward if the scrape changed the incident, so that clients can find the incidents that changed
+	changed := fmt.Sprintf(`(%[1]s.title, %[1]s.components, %[1]s.start_time, %[1]s.end_time, %[1]s.description, %[1]s.impact, %[1]s.deleted_at, %[1]s.provider_incident_id, %[1]s.source_url, %[1]s.tags, %[1]s.events) IS DISTINCT FROM (excluded.title, excluded.components, excluded.start_time, excluded.end_time, excluded.description, excluded.impact, excluded.deleted_at, excluded.provider_incident_id, excluded.source_url, excluded.tags, %[2]s)`, incidentsTableName, events)
+	doUpdates = append(doUpdates, clause.Assignment{
+		Column: clause.Column{Name: "updated_at"},
+		Value:  gorm.Expr(fmt.Sprintf(`CASE WHEN %s THEN excluded.updated_at ELSE %s.updated_at END`, changed, incidentsTableName)),
+	})
 	result := d.db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).Clauses(
 		clause.OnConflict{
-			Columns:   []clause.Column{{Name: "deep_link"}},                                                                                                      // Primary key
-			DoUpdates: clause.AssignmentColumns([]string{"title", "components", "events", "start_time", "end_time", "description", "impact", "status_page_url"}), // Update the data column
+			Columns:   []clause.Column{{Name: "dedup_key"}}, // Unique index
+			DoUpdates: doUpdates,
 		},
 	).Create(&incidents)
 	if result.Error != nil {
@@ -221,8 +795,276 @@ func (d *DbClient) CreateOrUpdateIncidents(ctx context.Context, incidents []api.
 	return nil
 }
 
+// MarkMissingIncidentsDeleted marks the incidents of the status page that started since the given time as deleted if they aren't in the incidents given,
+// which are all the incidents of the status page that started since then, as the incidents that are missing have been removed from the status page
+// Incidents are matched on their dedup key as they are by CreateOrUpdateIncidents, it returns the number of incidents that were marked as deleted
+func (d *DbClient) MarkMissingIncidentsDeleted(ctx context.Context, statusPageUrl string, since time.Time, incidents []api.Incident) (int64, error) {
+	query := d.db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).Where("status_page_url = ? AND start_time >= ?", statusPageUrl, since).Where(notDeleted)
+	var dedupKeys []string
+	for _, incident := range prepareIncidentsForUpsert(incidents) {
+		dedupKeys = append(dedupKeys, incident.DedupKey)
+	}
+	if len(dedupKeys) > 0 {
+		query = query.Where("dedup_key NOT IN ?", dedupKeys)
+	}
+	now := time.Now()
+	result := query.Updates(map[string]interface{}{"deleted_at": now, "updated_at": now})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+// incidentChangesChannel is the channel that NotifyIncidentChanges notifies with the url of the status page whose incidents changed
+const incidentChangesChannel = "incident_changes"
+
+// NotifyIncidentChanges notifies the listeners of ListenForIncidentChanges that the incidents of the status page changed
+// Postgres only delivers the notification to the connections listening when it is sent, so listeners that aren't connected miss it
+func (d *DbClient) NotifyIncidentChanges(ctx context.Context, statusPageUrl string) error {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	return db.Exec("SELECT pg_notify(?, ?)", incidentChangesChannel, statusPageUrl).Error
+}
+
+// ListenForIncidentChanges calls onChange with the url of each status page that NotifyIncidentChanges is called for, until the context is done
+// It holds a connection of the pool for as long as it listens, which isn't returned to the pool. It returns the context's error once it is done,
+// or the error of the connection if it fails, in which case the notifications sent until it is called again are missed
+func (d *DbClient) ListenForIncidentChanges(ctx context.Context, onChange func(statusPageUrl string)) error {
+	pooled, err := d.PgxPool.Acquire(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to acquire a connection to listen on")
+	}
+	// The connection is listening, so it is closed rather than being given to other queries
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(ctx, "LISTEN "+incidentChangesChannel); err != nil {
+		return errors.Wrap(err, "failed to listen for incident changes")
+	}
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		onChange(notification.Payload)
+	}
+}
+
+// rekeyIncidentsWithProviderIds changes the dedup keys of the stored incidents that were keyed without the provider incident id
+// of the incidents given to the keys that prefer it, so that the upsert updates them rather than inserting them again
+func (d *DbClient) rekeyIncidentsWithProviderIds(incidents []api.Incident) error {
+	var legacyKeys []string
+	var cases []string
+	var args []interface{}
+	seen := make(map[string]bool)
+	for _, incident := range incidents {
+		if incident.ProviderIncidentID == "" {
+			continue
+		}
+		legacyKey := api.IncidentDedupKey(incident.StatusPageUrl, "", incident.DeepLink, incident.Title, incident.StartTime)
+		if seen[legacyKey] {
+			continue
+		}
+		seen[legacyKey] = true
+		legacyKeys = append(legacyKeys, legacyKey)
+		cases = append(cases, "WHEN ? THEN ?")
+		args = append(args, legacyKey, api.IncidentDedupKey(incident.StatusPageUrl, incident.ProviderIncidentID, incident.DeepLink, incident.Title, incident.StartTime))
+	}
+	if len(legacyKeys) == 0 {
+		return nil
+	}
+	return d.db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).
+		Where("dedup_key IN ?", legacyKeys).
+		Update("dedup_key", gorm.Expr("CASE dedup_key "+strings.Join(cases, " ")+" END", args...)).Error
+}
+
+// prepareIncidentsForUpsert sets the dedup key of the incidents and removes incidents with the same key
+// Postgres can't update the same row twice in one upsert, so only the last incident with a key is kept
+func prepareIncidentsForUpsert(incidents []api.Incident) []api.Incident {
+	indexByDedupKey := make(map[string]int)
+	var prepared []api.Incident
+	for _, incident := range incidents {
+		incident.DedupKey = api.IncidentDedupKey(incident.StatusPageUrl, incident.ProviderIncidentID, incident.DeepLink, incident.Title, incident.StartTime)
+		if incident.DeepLink == "" {
+			// The deep link is the primary key so incidents without one are given a link to the status page that is unique to them
+			hash := sha256.Sum256([]byte(incident.DedupKey))
+			incident.DeepLink = fmt.Sprintf("%s#incident-%x", incident.StatusPageUrl, hash[:8])
+		}
+		if i, ok := indexByDedupKey[incident.DedupKey]; ok {
+			prepared[i] = incident
+			continue
+		}
+		indexByDedupKey[incident.DedupKey] = len(prepared)
+		prepared = append(prepared, incident)
+	}
+	return prepared
+}
+
+// backfillIncidentDedupKeys sets the dedup key of incidents that were inserted before incidents had one
+func (d *DbClient) backfillIncidentDedupKeys() error {
+	var incidents []api.Incident
+	result := d.db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).Where("dedup_key IS NULL").FindInBatches(&incidents, 1000, func(tx *gorm.DB, batch int) error {
+		for _, incident := range incidents {
+			dedupKey := api.IncidentDedupKey(incident.StatusPageUrl, incident.ProviderIncidentID, incident.DeepLink, incident.Title, incident.StartTime)
+			err := d.db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).Where("deep_link = ?", incident.DeepLink).Update("dedup_key", dedupKey).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return result.Error
+}
+
+// ErrSubscriptionNotFound is returned by DeleteSubscription if there is no subscription with the id
+var ErrSubscriptionNotFound = errors.New("subscription not found")
+
+// CreateSubscription stores the subscription
+// The query is cancelled if it takes longer than the query timeout or ctx is done
+func (d *DbClient) CreateSubscription(ctx context.Context, subscription api.Subscription) error {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	result := db.Table(fmt.Sprintf("%s.%s", schemaName, subscriptionsTableName)).Create(&subscription)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// GetSubscription returns the subscription with the given id
+// If the subscription does not exist, it returns nil
+// The query is cancelled if it takes longer than the query timeout or ctx is done
+func (d *DbClient) GetSubscription(ctx context.Context, id string) (*api.Subscription, error) {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	var subscription api.Subscription
+	result := db.Table(fmt.Sprintf("%s.%s", schemaName, subscriptionsTableName)).Where("id = ?", id).First(&subscription)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &subscription, nil
+}
+
+// GetSubscriptionsForStatusPage gets the subscriptions to the incidents of the status page
+// The query is cancelled if it takes longer than the query timeout or ctx is done
+func (d *DbClient) GetSubscriptionsForStatusPage(ctx context.Context, statusPageUrl string) ([]api.Subscription, error) {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	var subscriptions []api.Subscription
+	result := db.Table(fmt.Sprintf("%s.%s", schemaName, subscriptionsTableName)).Where("status_page_url = ?", statusPageUrl).Find(&subscriptions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return subscriptions, nil
+}
+
+// DeleteSubscription deletes the subscription with the given id along with its deliveries, returning ErrSubscriptionNotFound if it does not exist
+// The query is cancelled if it takes longer than the query timeout or ctx is done
+func (d *DbClient) DeleteSubscription(ctx context.Context, id string) error {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	result := db.Table(fmt.Sprintf("%s.%s", schemaName, subscriptionsTableName)).Where("id = ?", id).Delete(&api.Subscription{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSubscriptionNotFound
+	}
+	result = db.Table(fmt.Sprintf("%s.%s", schemaName, subscriptionDeliveriesTableName)).Where("subscription_id = ?", id).Delete(&api.SubscriptionDelivery{})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "failed to delete the deliveries of the subscription")
+	}
+	return nil
+}
+
+// ErrSubscriptionDeliveryNotFound is returned by RequestSubscriptionRedelivery if the subscription has no delivery with the id
+var ErrSubscriptionDeliveryNotFound = errors.New("subscription delivery not found")
+
+// RecordSubscriptionDelivery stores the delivery, replacing the delivery with the same id if there is one
+// Only the latest SubscriptionDeliveriesRetained deliveries of the subscription are kept
+func (d *DbClient) RecordSubscriptionDelivery(ctx context.Context, delivery api.SubscriptionDelivery) error {
+	table := fmt.Sprintf("%s.%s", schemaName, subscriptionDeliveriesTableName)
+	result := d.db.Table(table).Clauses(clause.OnConflict{UpdateAll: true}).Create(&delivery)
+	if result.Error != nil {
+		return result.Error
+	}
+	result = d.db.Exec(
+		fmt.Sprintf("DELETE FROM %s WHERE subscription_id = ? AND id NOT IN (SELECT id FROM %s WHERE subscription_id = ? ORDER BY attempted_at DESC LIMIT ?)", table, table),
+		delivery.SubscriptionID, delivery.SubscriptionID, SubscriptionDeliveriesRetained,
+	)
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "failed to delete the oldest deliveries of the subscription")
+	}
+	return nil
+}
+
+// GetSubscriptionDeliveries returns the deliveries of the subscription, latest first
+// The query is cancelled if it takes longer than the query timeout or ctx is done
+func (d *DbClient) GetSubscriptionDeliveries(ctx context.Context, subscriptionId string) ([]api.SubscriptionDelivery, error) {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	var deliveries []api.SubscriptionDelivery
+	result := db.Table(fmt.Sprintf("%s.%s", schemaName, subscriptionDeliveriesTableName)).Where("subscription_id = ?", subscriptionId).Order("attempted_at DESC").Find(&deliveries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return deliveries, nil
+}
+
+// RequestSubscriptionRedelivery marks the delivery of the subscription to be sent again, the jobrunner starts the redeliveries that have been requested
+// It returns ErrSubscriptionDeliveryNotFound if the subscription has no delivery with the id
+// The query is cancelled if it takes longer than the query timeout or ctx is done
+func (d *DbClient) RequestSubscriptionRedelivery(ctx context.Context, subscriptionId string, deliveryId string) error {
+	db, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	result := db.Table(fmt.Sprintf("%s.%s", schemaName, subscriptionDeliveriesTableName)).
+		Where("subscription_id = ? AND id = ?", subscriptionId, deliveryId).
+		Update("redelivery_requested_at", time.Now().UTC())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSubscriptionDeliveryNotFound
+	}
+	return nil
+}
+
+// GetRequestedSubscriptionRedeliveries returns up to limit deliveries that have been requested to be sent again, oldest request first
+func (d *DbClient) GetRequestedSubscriptionRedeliveries(ctx context.Context, limit int) ([]api.SubscriptionDelivery, error) {
+	var deliveries []api.SubscriptionDelivery
+	result := d.db.Table(fmt.Sprintf("%s.%s", schemaName, subscriptionDeliveriesTableName)).Where("redelivery_requested_at IS NOT NULL").Order("redelivery_requested_at").Limit(limit).Find(&deliveries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return deliveries, nil
+}
+
+// ClearSubscriptionRedeliveryRequests clears the redelivery requests of the deliveries once their redeliveries have been started
+func (d *DbClient) ClearSubscriptionRedeliveryRequests(ctx context.Context, deliveries []api.SubscriptionDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		ids = append(ids, delivery.ID)
+	}
+	result := d.db.Table(fmt.Sprintf("%s.%s", schemaName, subscriptionDeliveriesTableName)).Where("id IN ?", ids).Update("redelivery_requested_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// SeedStatusPages inserts the status pages in status_pages.StatusPages that aren't stored yet, with their urls canonicalized
 func (d *DbClient) SeedStatusPages() error {
 	for _, statusPage := range status_pages.StatusPages {
+		canonicalUrl, err := api.CanonicalStatusPageUrl(statusPage.URL)
+		if err != nil {
+			return errors.Wrapf(err, "failed to seed status page %s", statusPage.URL)
+		}
+		statusPage.URL = canonicalUrl
 		if page, err := d.GetStatusPage(context.Background(), statusPage.URL); err != nil || page == nil {
 			// Status page already exists
 			err := d.InsertStatusPage(context.Background(), statusPage)
@@ -0,0 +1,645 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/metoro-io/statusphere/common/api"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newDryRunDbClient returns a client that builds statements without a database, the statement of each insert is passed to onCreate
+func newDryRunDbClient(t *testing.T, onCreate func(statement *gorm.Statement)) *DbClient {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatalf("failed to open dry run database: %v", err)
+	}
+	err = db.Callback().Create().After("gorm:create").Register("test:capture", func(tx *gorm.DB) {
+		onCreate(tx.Statement)
+	})
+	if err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+	return &DbClient{db: db, logger: zap.NewNop()}
+}
+
+func TestCreateOrUpdateIncidentsUpdatesResolvedIncident(t *testing.T) {
+	startTime := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	endTime := startTime.Add(time.Hour)
+	statusPageUrl := "https://status.example.com"
+	ongoing := api.NewIncident("API outage", nil, nil, startTime, nil, nil, statusPageUrl+"/incidents/1", api.ImpactMajor, statusPageUrl)
+	resolved := api.NewIncident("API outage", nil, nil, startTime, &endTime, nil, statusPageUrl+"/incidents/1", api.ImpactCritical, statusPageUrl)
+
+	var statements []string
+	var upserted [][]api.Incident
+	client := newDryRunDbClient(t, func(statement *gorm.Statement) {
+		statements = append(statements, statement.SQL.String())
+		upserted = append(upserted, *statement.Dest.(*[]api.Incident))
+	})
+
+	// The incident is ongoing on the first scrape and resolved on the second
+	for _, scrape := range [][]api.Incident{{ongoing}, {resolved}} {
+		if err := client.CreateOrUpdateIncidents(context.Background(), scrape); err != nil {
+			t.Fatalf("failed to create or update incidents: %v", err)
+		}
+	}
+
+	if len(statements) != 2 {
+		t.Fatalf("expected two upserts, got %d", len(statements))
+	}
+	for _, statement := range statements {
+		if !strings.Contains(statement, `ON CONFLICT ("dedup_key") DO UPDATE SET`) || !strings.Contains(statement, `"end_time"="excluded"."end_time"`) || !strings.Contains(statement, `"impact"="excluded"."impact"`) {
+			t.Fatalf("expected an upsert on the dedup key that updates the end time and impact, got %s", statement)
+		}
+		if !strings.Contains(statement, `"events"=COALESCE(NULLIF(NULLIF(excluded.events, 'null'::jsonb), '[]'::jsonb), incidents.events)`) {
+			t.Fatalf("expected the events to be kept when a scrape has none, got %s", statement)
+		}
+		if !strings.Contains(statement, `"tags"="excluded"."tags"`) {
+			t.Fatalf("expected the tags to be updated, got %s", statement)
+		}
+		if !strings.Contains(statement, `"impact_history"=COALESCE(NULLIF(excluded.impact_history, 'null'::jsonb), incidents.impact_history)`) {
+			t.Fatalf("expected the impact history to be kept when a scrape has none, got %s", statement)
+		}
+		if !strings.Contains(statement, `"updated_at"=CASE WHEN (incidents.title,`) || !strings.Contains(statement, `THEN excluded.updated_at ELSE incidents.updated_at END`) {
+			t.Fatalf("expected the updated time to only change when the incident does, got %s", statement)
+		}
+	}
+	first, second := upserted[0][0], upserted[1][0]
+	if first.DedupKey == "" || first.DedupKey != second.DedupKey {
+		t.Fatalf("expected both scrapes to have the same dedup key, got %q and %q", first.DedupKey, second.DedupKey)
+	}
+	if second.EndTime == nil || !second.EndTime.Equal(endTime) || second.Impact != api.ImpactCritical {
+		t.Fatalf("expected the second scrape to resolve the incident, got %+v", second)
+	}
+}
+
+func TestCreateOrUpdateIncidentsRekeysIncidentsStoredWithoutProviderIds(t *testing.T) {
+	startTime := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	statusPageUrl := "https://status.example.com"
+	withId := api.NewIncident("API outage", nil, nil, startTime, nil, nil, statusPageUrl+"/incidents/abc123", api.ImpactMajor, statusPageUrl)
+	withId.ProviderIncidentID = "abc123"
+	withoutId := api.NewIncident("Other outage", nil, nil, startTime, nil, nil, statusPageUrl+"/incidents/2", api.ImpactMajor, statusPageUrl)
+
+	var upserted []api.Incident
+	client := newDryRunDbClient(t, func(statement *gorm.Statement) {
+		upserted = append(upserted, *statement.Dest.(*[]api.Incident)...)
+	})
+	var updates []string
+	err := client.db.Callback().Update().After("gorm:update").Register("test:capture_update", func(tx *gorm.DB) {
+		updates = append(updates, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+	})
+	if err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+
+	if err := client.CreateOrUpdateIncidents(context.Background(), []api.Incident{withId, withoutId}); err != nil {
+		t.Fatalf("failed to create or update incidents: %v", err)
+	}
+	if len(updates) != 1 {
+		t.Fatalf("expected one update, got %v", updates)
+	}
+	legacyKey := statusPageUrl + "|link:" + withId.DeepLink
+	for _, expected := range []string{`SET "dedup_key"=CASE dedup_key WHEN '` + legacyKey + `' THEN '` + statusPageUrl + `|id:abc123' END`, "dedup_key IN ('" + legacyKey + "')"} {
+		if !strings.Contains(updates[0], expected) {
+			t.Fatalf("expected the update to contain %s, got %s", expected, updates[0])
+		}
+	}
+	if len(upserted) != 2 || upserted[0].DedupKey != statusPageUrl+"|id:abc123" || upserted[1].DedupKey != statusPageUrl+"|link:"+withoutId.DeepLink {
+		t.Fatalf("expected the incidents to be upserted with the keys that prefer the provider incident id, got %+v", upserted)
+	}
+
+	// Nothing has to be rekeyed if none of the incidents have a provider incident id
+	updates = nil
+	if err := client.CreateOrUpdateIncidents(context.Background(), []api.Incident{withoutId}); err != nil {
+		t.Fatalf("failed to create or update incidents: %v", err)
+	}
+	if len(updates) != 0 {
+		t.Fatalf("expected no updates, got %v", updates)
+	}
+}
+
+func TestPrepareIncidentsForUpsert(t *testing.T) {
+	startTime := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	endTime := startTime.Add(time.Hour)
+	statusPageUrl := "https://status.example.com"
+
+	// Incidents without a deep link are matched on their title and start time
+	ongoing := prepareIncidentsForUpsert([]api.Incident{api.NewIncident("Degraded performance", nil, nil, startTime, nil, nil, "", api.ImpactMinor, statusPageUrl)})
+	resolved := prepareIncidentsForUpsert([]api.Incident{api.NewIncident("Degraded performance", nil, nil, startTime, &endTime, nil, "", api.ImpactMinor, statusPageUrl)})
+	if ongoing[0].DedupKey != resolved[0].DedupKey || ongoing[0].DeepLink != resolved[0].DeepLink {
+		t.Fatalf("expected the same incident across scrapes, got %+v and %+v", ongoing[0], resolved[0])
+	}
+	if !strings.HasPrefix(ongoing[0].DeepLink, statusPageUrl+"#incident-") {
+		t.Fatalf("expected a deep link to the status page, got %s", ongoing[0].DeepLink)
+	}
+
+	different := prepareIncidentsForUpsert([]api.Incident{api.NewIncident("Degraded performance", nil, nil, startTime.Add(time.Minute), nil, nil, "", api.ImpactMinor, statusPageUrl)})
+	if different[0].DedupKey == ongoing[0].DedupKey || different[0].DeepLink == ongoing[0].DeepLink {
+		t.Fatalf("expected incidents with different start times to be different incidents")
+	}
+
+	// Incidents that appear twice in one scrape are only upserted once, keeping the last
+	prepared := prepareIncidentsForUpsert([]api.Incident{
+		api.NewIncident("API outage", nil, nil, startTime, nil, nil, statusPageUrl+"/incidents/1", api.ImpactMajor, statusPageUrl),
+		api.NewIncident("Other outage", nil, nil, startTime, nil, nil, statusPageUrl+"/incidents/2", api.ImpactMajor, statusPageUrl),
+		api.NewIncident("API outage", nil, nil, startTime, &endTime, nil, statusPageUrl+"/incidents/1", api.ImpactMajor, statusPageUrl),
+	})
+	if len(prepared) != 2 || prepared[0].EndTime == nil || prepared[1].DeepLink != statusPageUrl+"/incidents/2" {
+		t.Fatalf("unexpected prepared incidents %+v", prepared)
+	}
+}
+
+func TestIncidentMissingFromScrapeIsMarkedDeleted(t *testing.T) {
+	startTime := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	statusPageUrl := "https://status.example.com"
+	kept := api.NewIncident("API outage", nil, nil, startTime, nil, nil, statusPageUrl+"/incidents/1", api.ImpactMajor, statusPageUrl)
+	removed := api.NewIncident("Posted by mistake", nil, nil, startTime.Add(time.Hour), nil, nil, statusPageUrl+"/incidents/2", api.ImpactMajor, statusPageUrl)
+
+	var upserts []string
+	client := newDryRunDbClient(t, func(statement *gorm.Statement) {
+		upserts = append(upserts, statement.SQL.String())
+	})
+	var updates []string
+	err := client.db.Callback().Update().After("gorm:update").Register("test:capture_update", func(tx *gorm.DB) {
+		updates = append(updates, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+	})
+	if err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+
+	// Both incidents are on the first scrape, the second incident has been removed from the status page by the second scrape
+	if err := client.CreateOrUpdateIncidents(context.Background(), []api.Incident{kept, removed}); err != nil {
+		t.Fatalf("failed to create or update incidents: %v", err)
+	}
+	if err := client.CreateOrUpdateIncidents(context.Background(), []api.Incident{kept}); err != nil {
+		t.Fatalf("failed to create or update incidents: %v", err)
+	}
+	if _, err := client.MarkMissingIncidentsDeleted(context.Background(), statusPageUrl, startTime, []api.Incident{kept}); err != nil {
+		t.Fatalf("failed to mark missing incidents as deleted: %v", err)
+	}
+
+	for _, upsert := range upserts {
+		if !strings.Contains(upsert, `"deleted_at"="excluded"."deleted_at"`) {
+			t.Fatalf("expected an incident that is scraped again to be undeleted, got %s", upsert)
+		}
+	}
+	if len(updates) != 1 {
+		t.Fatalf("expected one update, got %v", updates)
+	}
+	keptKey := api.IncidentDedupKey(statusPageUrl, kept.ProviderIncidentID, kept.DeepLink, kept.Title, kept.StartTime)
+	removedKey := api.IncidentDedupKey(statusPageUrl, removed.ProviderIncidentID, removed.DeepLink, removed.Title, removed.StartTime)
+	for _, expected := range []string{`SET "deleted_at"=`, `"updated_at"=`, "status_page_url = '" + statusPageUrl + "'", "start_time >= '2024-03-01 14:00:00'", "deleted_at IS NULL", "dedup_key NOT IN ('" + keptKey + "')"} {
+		if !strings.Contains(updates[0], expected) {
+			t.Fatalf("expected the update to contain %s, got %s", expected, updates[0])
+		}
+	}
+	if strings.Contains(updates[0], removedKey) {
+		t.Fatalf("expected the removed incident to be marked as deleted, got %s", updates[0])
+	}
+}
+
+func TestGetIncidentsExcludesDeletedIncidents(t *testing.T) {
+	client := newDryRunDbClient(t, func(statement *gorm.Statement) {})
+	var statements []string
+	err := client.db.Callback().Query().After("gorm:query").Register("test:capture_query", func(tx *gorm.DB) {
+		statements = append(statements, tx.Statement.SQL.String())
+	})
+	if err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+
+	if _, err := client.GetIncidents(context.Background(), "https://status.example.com"); err != nil {
+		t.Fatalf("failed to get incidents: %v", err)
+	}
+	if _, err := client.GetIncidentsIncludingDeleted(context.Background(), "https://status.example.com"); err != nil {
+		t.Fatalf("failed to get incidents: %v", err)
+	}
+	if len(statements) != 2 || !strings.Contains(statements[0], notDeleted) || strings.Contains(statements[1], notDeleted) {
+		t.Fatalf("expected only GetIncidents to exclude deleted incidents, got %v", statements)
+	}
+}
+
+func TestRecordSubscriptionDeliveryKeepsTheLatestDeliveries(t *testing.T) {
+	var inserts []string
+	client := newDryRunDbClient(t, func(statement *gorm.Statement) {
+		inserts = append(inserts, statement.SQL.String())
+	})
+	var deletes []string
+	err := client.db.Callback().Raw().After("gorm:raw").Register("test:capture_raw", func(tx *gorm.DB) {
+		deletes = append(deletes, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+	})
+	if err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+
+	delivery := api.SubscriptionDelivery{ID: "1-1", SubscriptionID: "sub", IncidentID: "https://status.example.com/incidents/1", StatusCode: 500, Error: "expected a 2xx status code, got 500", AttemptedAt: time.Now()}
+	if err := client.RecordSubscriptionDelivery(context.Background(), delivery); err != nil {
+		t.Fatalf("failed to record subscription delivery: %v", err)
+	}
+	if len(inserts) != 1 || !strings.Contains(inserts[0], "ON CONFLICT") {
+		t.Fatalf("expected the delivery to be upserted, got %v", inserts)
+	}
+	if len(deletes) != 1 {
+		t.Fatalf("expected one delete, got %v", deletes)
+	}
+	for _, expected := range []string{"DELETE FROM statusphere.subscription_deliveries WHERE subscription_id = 'sub'", "ORDER BY attempted_at DESC LIMIT 100"} {
+		if !strings.Contains(deletes[0], expected) {
+			t.Fatalf("expected the delete to contain %s, got %s", expected, deletes[0])
+		}
+	}
+}
+
+func TestQueryIncidentsFiltersAndPagesInTheDatabase(t *testing.T) {
+	client := newDryRunDbClient(t, func(statement *gorm.Statement) {})
+	var statements []string
+	err := client.db.Callback().Query().After("gorm:query").Register("test:capture_query", func(tx *gorm.DB) {
+		statements = append(statements, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+	})
+	if err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	limit := 10
+	_, err = client.QueryIncidents(context.Background(), IncidentsQuery{
+		StatusPageUrl: "https://status.example.com",
+		Impacts:       []api.Impact{api.ImpactMajor, api.ImpactCritical},
+		From:          &from,
+		Limit:         &limit,
+		Offset:        20,
+	})
+	if err != nil {
+		t.Fatalf("failed to query incidents: %v", err)
+	}
+	_, err = client.QueryIncidents(context.Background(), IncidentsQuery{StatusPageUrl: "https://status.example.com", Ascending: true})
+	if err != nil {
+		t.Fatalf("failed to query incidents: %v", err)
+	}
+
+	if len(statements) != 2 {
+		t.Fatalf("expected two queries, got %d", len(statements))
+	}
+	expected := `WHERE status_page_url = 'https://status.example.com' AND impact IN ('major','critical') AND start_time >= '2024-03-01 00:00:00' AND deleted_at IS NULL ORDER BY start_time DESC,deep_link DESC LIMIT 10 OFFSET 20`
+	if !strings.Contains(statements[0], expected) {
+		t.Fatalf("expected the filters, order and page to be in the query, got %s", statements[0])
+	}
+	if !strings.HasSuffix(statements[1], `WHERE status_page_url = 'https://status.example.com' AND deleted_at IS NULL ORDER BY start_time ASC,deep_link ASC`) {
+		t.Fatalf("expected an unfiltered ascending query, got %s", statements[1])
+	}
+}
+
+func TestSearchAllIncidentsSearchesTheWindowAcrossStatusPages(t *testing.T) {
+	client := newDryRunDbClient(t, func(statement *gorm.Statement) {})
+	var statements []string
+	err := client.db.Callback().Query().After("gorm:query").Register("test:capture_query", func(tx *gorm.DB) {
+		statements = append(statements, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+	})
+	if err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(7 * 24 * time.Hour)
+	_, err = client.SearchAllIncidents(context.Background(), IncidentsSearch{Query: "dns_%", From: from, To: to, Limit: 11})
+	if err != nil {
+		t.Fatalf("failed to search incidents: %v", err)
+	}
+	_, err = client.SearchAllIncidents(context.Background(), IncidentsSearch{Query: "dns", From: from, To: to, AfterStartTime: from.Add(time.Hour), AfterDeepLink: "https://status.example.com/incidents/1", Limit: 11})
+	if err != nil {
+		t.Fatalf("failed to search incidents: %v", err)
+	}
+
+	if len(statements) != 2 {
+		t.Fatalf("expected two queries, got %d", len(statements))
+	}
+	expected := `WHERE (start_time >= '2024-03-01 00:00:00' AND start_time <= '2024-03-08 00:00:00') AND (title ILIKE '%dns\_\%%' OR description ILIKE '%dns\_\%%') AND deleted_at IS NULL ORDER BY start_time DESC,deep_link DESC LIMIT 11`
+	if !strings.Contains(statements[0], expected) {
+		t.Fatalf("expected the window, query and order to be in the query, got %s", statements[0])
+	}
+	if strings.Contains(statements[0], "status_page_url") {
+		t.Fatalf("expected every status page to be searched, got %s", statements[0])
+	}
+	if !strings.Contains(statements[1], `(start_time, deep_link) < ('2024-03-01 01:00:00', 'https://status.example.com/incidents/1')`) {
+		t.Fatalf("expected the second page to start after the last incident of the first, got %s", statements[1])
+	}
+}
+
+func TestGetCurrentIncidentsIncludesIncidentsWithoutAnEndTime(t *testing.T) {
+	client := newDryRunDbClient(t, func(statement *gorm.Statement) {})
+	var statements []string
+	err := client.db.Callback().Query().After("gorm:query").Register("test:capture_query", func(tx *gorm.DB) {
+		statements = append(statements, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+	})
+	if err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+
+	if _, err := client.GetCurrentIncidents(context.Background(), "https://status.example.com"); err != nil {
+		t.Fatalf("failed to get current incidents: %v", err)
+	}
+	if len(statements) != 1 {
+		t.Fatalf("expected one query, got %d", len(statements))
+	}
+	// end_time = NULL is never true, so the incidents without an end time have to be matched with IS NULL
+	if !strings.Contains(statements[0], "status_page_url = 'https://status.example.com'") || !strings.Contains(statements[0], "(end_time IS NULL OR end_time = '0000-00-00 00:00:00')") {
+		t.Fatalf("expected the ongoing incidents of the status page to be queried, got %s", statements[0])
+	}
+}
+
+func TestCountIncidentsByImpactGroupsInTheDatabase(t *testing.T) {
+	client := newDryRunDbClient(t, func(statement *gorm.Statement) {})
+	var statements []string
+	err := client.db.Callback().Row().After("gorm:row").Register("test:capture_row", func(tx *gorm.DB) {
+		statements = append(statements, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+	})
+	if err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	_, _ = client.CountIncidentsByImpact(context.Background(), IncidentsQuery{StatusPageUrl: "https://status.example.com", Impacts: []api.Impact{api.ImpactMajor}, From: &from, To: &to})
+	if len(statements) != 1 {
+		t.Fatalf("expected one query, got %d", len(statements))
+	}
+	expected := `SELECT impact, COUNT(*) AS count FROM "statusphere"."incidents" WHERE status_page_url = 'https://status.example.com' AND start_time >= '2024-03-01 00:00:00' AND start_time <= '2024-04-01 00:00:00' AND deleted_at IS NULL GROUP BY "impact"`
+	if statements[0] != expected {
+		t.Fatalf("expected the incidents in the range to be counted by impact, got %s", statements[0])
+	}
+}
+
+// newEnvironmentDbClient connects to the database configured in the environment, the test or benchmark is skipped if there isn't one
+func newEnvironmentDbClient(tb testing.TB) *DbClient {
+	if os.Getenv("STATUSPHERE_POSTGRES_HOST") == "" {
+		tb.Skip("STATUSPHERE_POSTGRES_HOST is not set")
+	}
+	client, err := NewDbClientFromEnvironment(zap.NewNop())
+	if err != nil {
+		tb.Fatalf("failed to connect to the database: %v", err)
+	}
+	if err := client.AutoMigrate(context.Background()); err != nil {
+		tb.Fatalf("failed to migrate the database: %v", err)
+	}
+	return client
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := Config{MaxOpenConns: 25, MaxIdleConns: 10, ConnMaxLifetime: 30 * time.Minute, QueryTimeout: 5 * time.Second}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("expected the config to be valid, got %v", err)
+	}
+	tests := []struct {
+		name   string
+		modify func(config *Config)
+	}{
+		{name: "no open connections", modify: func(config *Config) { config.MaxOpenConns = 0 }},
+		{name: "negative idle connections", modify: func(config *Config) { config.MaxIdleConns = -1 }},
+		{name: "more idle than open connections", modify: func(config *Config) { config.MaxIdleConns = 26 }},
+		{name: "negative connection lifetime", modify: func(config *Config) { config.ConnMaxLifetime = -time.Second }},
+		{name: "negative query timeout", modify: func(config *Config) { config.QueryTimeout = -time.Second }},
+	}
+	for _, test := range tests {
+		config := valid
+		test.modify(&config)
+		if err := config.Validate(); err == nil {
+			t.Fatalf("%s: expected the config to be invalid", test.name)
+		}
+	}
+}
+
+func TestQueriesHaveTheQueryTimeout(t *testing.T) {
+	client := newDryRunDbClient(t, func(statement *gorm.Statement) {})
+	client.queryTimeout = time.Second
+
+	db, cancel := client.withQueryTimeout(context.Background())
+	deadline, ok := db.Statement.Context.Deadline()
+	if !ok || time.Until(deadline) > time.Second {
+		t.Fatalf("expected the query to have a deadline within a second, got %v", deadline)
+	}
+	cancel()
+	if db.Statement.Context.Err() == nil {
+		t.Fatalf("expected the query to be cancelled by its cancel function")
+	}
+
+	// A request with a shorter deadline keeps it
+	requestCtx, requestCancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer requestCancel()
+	db, cancel = client.withQueryTimeout(requestCtx)
+	defer cancel()
+	if deadline, _ := db.Statement.Context.Deadline(); time.Until(deadline) > time.Millisecond {
+		t.Fatalf("expected the query to keep the deadline of the request, got %v", deadline)
+	}
+}
+
+func TestRequestQueriesAreCancelledAfterTheQueryTimeout(t *testing.T) {
+	client := newDryRunDbClient(t, func(statement *gorm.Statement) {})
+	client.queryTimeout = time.Nanosecond
+	var errs []error
+	capture := func(tx *gorm.DB) {
+		// The query timeout has passed by the time the statement is built, so the query would be cancelled
+		time.Sleep(time.Millisecond)
+		errs = append(errs, context.Cause(tx.Statement.Context))
+	}
+	if err := client.db.Callback().Query().Before("gorm:query").Register("test:capture_query", capture); err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+	if err := client.db.Callback().Row().Before("gorm:row").Register("test:capture_row", capture); err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+
+	ctx := context.Background()
+	query := IncidentsQuery{StatusPageUrl: "https://status.example.com"}
+	_, _ = client.QueryIncidents(ctx, query)
+	_, _ = client.CountIncidents(ctx, query)
+	_, _ = client.CountIncidentsByImpact(ctx, query)
+	// Streaming isn't supported by a dry run database, but the query is still built
+	_ = client.StreamIncidents(ctx, query, make(chan api.Incident))
+	_, _ = client.GetAllStatusPages(ctx)
+	_, _ = client.GetIncidentsUpdatedSince(ctx, query.StatusPageUrl, time.Now())
+	_, _ = client.GetIncidentsIncludingDeleted(ctx, query.StatusPageUrl)
+	_, _ = client.GetIncidentByID(ctx, query.StatusPageUrl+"/incidents/a")
+	_, _ = client.SearchIncidents(ctx, query.StatusPageUrl, "outage")
+	_, _ = client.GetCurrentIncidents(ctx, query.StatusPageUrl)
+	_, _ = client.GetAllCurrentIncidents(ctx)
+	_, _ = client.GetRecentIncidents(ctx, 10, nil)
+	_, _ = client.GetSubscription(ctx, "subscription")
+	_, _ = client.GetSubscriptionsForStatusPage(ctx, query.StatusPageUrl)
+	_, _ = client.GetSubscriptionDeliveries(ctx, "subscription")
+
+	if len(errs) != 15 {
+		t.Fatalf("expected fifteen queries, got %d", len(errs))
+	}
+	for i, err := range errs {
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected query %d to have exceeded the query timeout, got %v", i, err)
+		}
+	}
+}
+
+func TestIncidentIndexes(t *testing.T) {
+	incidentSchema, err := schema.Parse(&api.Incident{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatalf("failed to parse the incident schema: %v", err)
+	}
+	indexes := incidentSchema.ParseIndexes()
+	expected := map[string][]string{
+		"idx_incidents_status_page_url_start_time": {"status_page_url", "start_time"},
+		"idx_incidents_end_time_start_time":        {"end_time", "start_time"},
+		"idx_incidents_status_page_url_updated_at": {"status_page_url", "updated_at"},
+	}
+	for name, columns := range expected {
+		index, ok := indexes[name]
+		if !ok {
+			t.Fatalf("expected the %s index to be migrated, got %v", name, indexes)
+		}
+		var indexColumns []string
+		for _, field := range index.Fields {
+			indexColumns = append(indexColumns, field.DBName)
+		}
+		if strings.Join(indexColumns, ",") != strings.Join(columns, ",") {
+			t.Fatalf("expected the %s index to be on %v, got %v", name, columns, indexColumns)
+		}
+	}
+}
+
+// TestIncidentQueriesUseIndexes needs a database, sequential scans are disabled as with few incidents they'd be cheaper than any index
+func TestIncidentQueriesUseIndexes(t *testing.T) {
+	client := newEnvironmentDbClient(t)
+	tests := []struct {
+		query    string
+		args     []interface{}
+		expected string
+	}{
+		{
+			query:    "SELECT * FROM statusphere.incidents WHERE status_page_url = ? ORDER BY start_time DESC LIMIT 20",
+			args:     []interface{}{"https://status.example.com"},
+			expected: "idx_incidents_status_page_url_start_time",
+		},
+		{
+			query:    "SELECT * FROM statusphere.incidents WHERE start_time > ? AND end_time IS NULL",
+			args:     []interface{}{time.Now().Add(-14 * 24 * time.Hour)},
+			expected: "idx_incidents_end_time_start_time",
+		},
+	}
+	for _, test := range tests {
+		var plan []string
+		err := client.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("SET LOCAL enable_seqscan = off").Error; err != nil {
+				return err
+			}
+			return tx.Raw("EXPLAIN "+test.query, test.args...).Scan(&plan).Error
+		})
+		if err != nil {
+			t.Fatalf("failed to explain %s: %v", test.query, err)
+		}
+		if !strings.Contains(strings.Join(plan, "\n"), test.expected) {
+			t.Fatalf("expected %s to use %s, got %v", test.query, test.expected, plan)
+		}
+	}
+}
+
+// TestStreamIncidentsSendsTheQueriedIncidents needs a database, the streamed incidents are the ones QueryIncidents returns
+func TestStreamIncidentsSendsTheQueriedIncidents(t *testing.T) {
+	client := newEnvironmentDbClient(t)
+	ctx := context.Background()
+	statusPageUrl := "https://stream.statusphere.invalid"
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var incidents []api.Incident
+	for i := 0; i < 10; i++ {
+		deepLink := fmt.Sprintf("%s/incidents/%d", statusPageUrl, i)
+		incidents = append(incidents, api.NewIncident("Incident", nil, nil, start.Add(time.Duration(i)*time.Hour), nil, nil, deepLink, api.ImpactMinor, statusPageUrl))
+	}
+	if err := client.CreateOrUpdateIncidents(ctx, incidents); err != nil {
+		t.Fatalf("failed to create incidents: %v", err)
+	}
+	t.Cleanup(func() {
+		client.db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).Where("status_page_url = ?", statusPageUrl).Delete(&api.Incident{})
+	})
+
+	limit := 5
+	query := IncidentsQuery{StatusPageUrl: statusPageUrl, Ascending: true, Limit: &limit}
+	expected, err := client.QueryIncidents(ctx, query)
+	if err != nil {
+		t.Fatalf("failed to query incidents: %v", err)
+	}
+	streamed := make(chan api.Incident)
+	streamErr := make(chan error, 1)
+	go func() { streamErr <- client.StreamIncidents(ctx, query, streamed) }()
+	var received []string
+	for incident := range streamed {
+		received = append(received, incident.DeepLink)
+	}
+	if err := <-streamErr; err != nil {
+		t.Fatalf("failed to stream incidents: %v", err)
+	}
+	if len(received) != len(expected) {
+		t.Fatalf("expected %d incidents, got %v", len(expected), received)
+	}
+	for i, incident := range expected {
+		if received[i] != incident.DeepLink {
+			t.Fatalf("expected the incidents in the order of QueryIncidents, got %v", received)
+		}
+	}
+}
+
+// BenchmarkIncidentFiltering compares reading every incident of a status page and filtering them in memory,
+// which is what the api server does to fill its cache, with filtering and paging them in the database
+func BenchmarkIncidentFiltering(b *testing.B) {
+	client := newEnvironmentDbClient(b)
+	ctx := context.Background()
+	statusPageUrl := "https://benchmark.statusphere.invalid"
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	impacts := []api.Impact{api.ImpactMinor, api.ImpactMajor, api.ImpactCritical, api.ImpactMaintenance}
+	var incidents []api.Incident
+	for i := 0; i < 10000; i++ {
+		deepLink := fmt.Sprintf("%s/incidents/%d", statusPageUrl, i)
+		incidents = append(incidents, api.NewIncident("Incident", nil, nil, start.Add(time.Duration(i)*time.Hour), nil, nil, deepLink, impacts[i%len(impacts)], statusPageUrl))
+	}
+	for i := 0; i < len(incidents); i += 1000 {
+		if err := client.CreateOrUpdateIncidents(ctx, incidents[i:i+1000]); err != nil {
+			b.Fatalf("failed to create incidents: %v", err)
+		}
+	}
+	b.Cleanup(func() {
+		client.db.Table(fmt.Sprintf("%s.%s", schemaName, incidentsTableName)).Where("status_page_url = ?", statusPageUrl).Delete(&api.Incident{})
+	})
+
+	from := start.Add(5000 * time.Hour)
+	limit := 20
+	b.Run("in memory", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			all, err := client.GetIncidents(ctx, statusPageUrl)
+			if err != nil {
+				b.Fatalf("failed to get incidents: %v", err)
+			}
+			var matching []api.Incident
+			for _, incident := range all {
+				if incident.Impact == api.ImpactMajor && !incident.StartTime.Before(from) {
+					matching = append(matching, incident)
+				}
+			}
+			sort.Slice(matching, func(i, j int) bool {
+				return matching[i].StartTime.After(matching[j].StartTime)
+			})
+			if len(matching) > limit {
+				matching = matching[:limit]
+			}
+		}
+	})
+	b.Run("sql", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := client.QueryIncidents(ctx, IncidentsQuery{StatusPageUrl: statusPageUrl, Impacts: []api.Impact{api.ImpactMajor}, From: &from, Limit: &limit})
+			if err != nil {
+				b.Fatalf("failed to query incidents: %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package hosts
+
+import (
+	"github.com/pkg/errors"
+	"net/url"
+	"strings"
+)
+
+// Config is which hosts status pages can be indexed from, it is shared by the api server, which rejects status pages
+// of hosts that aren't allowed when they're submitted, and the scraper, which doesn't scrape them
+type Config struct {
+	// Allow are the hosts that status pages can be indexed from, if it is empty every host that isn't denied is allowed
+	Allow []Pattern `envconfig:"ALLOW"`
+	// Deny are the hosts that status pages are never indexed from, they take precedence over Allow
+	Deny []Pattern `envconfig:"DENY"`
+}
+
+// Pattern matches hosts case insensitively, it is either an exact host e.g. status.example.com, a wildcard e.g. *.example.com
+// that matches every subdomain of example.com but not example.com itself, or * which matches every host
+type Pattern string
+
+// UnmarshalText parses the pattern, the only wildcard allowed is a * that is the whole pattern or its first label
+func (p *Pattern) UnmarshalText(text []byte) error {
+	pattern := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(string(text))), ".")
+	rest := strings.TrimPrefix(pattern, "*.")
+	if pattern == "" || (pattern != "*" && (rest == "" || strings.Contains(rest, "*"))) {
+		return errors.Errorf("host pattern %q must be a host, a wildcard such as *.example.com, or *", string(text))
+	}
+	*p = Pattern(pattern)
+	return nil
+}
+
+// Matches returns true if the host matches the pattern
+func (p Pattern) Matches(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	pattern := strings.ToLower(string(p))
+	if pattern == "*" {
+		return true
+	}
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return host == pattern
+}
+
+// Allowed returns true if status pages can be indexed from the host
+func (c Config) Allowed(host string) bool {
+	for _, pattern := range c.Deny {
+		if pattern.Matches(host) {
+			return false
+		}
+	}
+	if len(c.Allow) == 0 {
+		return true
+	}
+	for _, pattern := range c.Allow {
+		if pattern.Matches(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedUrl returns true if the status page at the url can be indexed, see Allowed, urls that can't be parsed aren't allowed
+func (c Config) AllowedUrl(statusPageUrl string) bool {
+	parsed, err := url.Parse(statusPageUrl)
+	if err != nil {
+		return false
+	}
+	return c.Allowed(parsed.Hostname())
+}
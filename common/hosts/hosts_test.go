@@ -0,0 +1,69 @@
+package hosts
+
+import (
+	"github.com/kelseyhightower/envconfig"
+	"testing"
+)
+
+func TestPatternsMatchHosts(t *testing.T) {
+	tests := []struct {
+		pattern  Pattern
+		host     string
+		expected bool
+	}{
+		{pattern: "status.example.com", host: "status.example.com", expected: true},
+		{pattern: "status.example.com", host: "Status.Example.com", expected: true},
+		{pattern: "status.example.com", host: "status.example.com.", expected: true},
+		{pattern: "status.example.com", host: "other.example.com", expected: false},
+		{pattern: "status.example.com", host: "status.example.com.evil.com", expected: false},
+		{pattern: "*.example.com", host: "status.example.com", expected: true},
+		{pattern: "*.example.com", host: "eu.status.example.com", expected: true},
+		{pattern: "*.example.com", host: "example.com", expected: false},
+		{pattern: "*.example.com", host: "notexample.com", expected: false},
+		{pattern: "*.example.com", host: "status.example.com.evil.com", expected: false},
+		{pattern: "*", host: "status.example.com", expected: true},
+	}
+	for _, test := range tests {
+		if actual := test.pattern.Matches(test.host); actual != test.expected {
+			t.Fatalf("expected %s matching %s to be %t", test.pattern, test.host, test.expected)
+		}
+	}
+}
+
+func TestDenyTakesPrecedenceOverAllow(t *testing.T) {
+	config := Config{Allow: []Pattern{"*.example.com", "status.other.com"}, Deny: []Pattern{"internal.example.com"}}
+	tests := map[string]bool{
+		"https://status.example.com":            true,
+		"https://status.other.com/history":      true,
+		"https://internal.example.com":          false,
+		"https://status.unlisted.com":           false,
+		"https://status.example.com:8443/":      true,
+		"https://INTERNAL.example.com/incident": false,
+	}
+	for statusPageUrl, expected := range tests {
+		if actual := config.AllowedUrl(statusPageUrl); actual != expected {
+			t.Fatalf("expected %s to be allowed %t", statusPageUrl, expected)
+		}
+	}
+	if !(Config{Deny: []Pattern{"*.internal.com"}}).Allowed("status.example.com") {
+		t.Fatalf("expected every host that isn't denied to be allowed without an allow list")
+	}
+}
+
+func TestPatternsAreParsedFromTheEnvironment(t *testing.T) {
+	t.Setenv("TEST_ALLOW", "*.Example.com,status.other.com.")
+	var config Config
+	if err := envconfig.Process("TEST", &config); err != nil {
+		t.Fatalf("failed to parse the config: %v", err)
+	}
+	if len(config.Allow) != 2 || config.Allow[0] != "*.example.com" || config.Allow[1] != "status.other.com" {
+		t.Fatalf("unexpected allow list %v", config.Allow)
+	}
+
+	for _, invalid := range []string{"status.*.com", "*example.com", "*.*", "**.example.com", "."} {
+		t.Setenv("TEST_ALLOW", invalid)
+		if err := envconfig.Process("TEST", &config); err == nil {
+			t.Fatalf("expected the pattern %q to be invalid", invalid)
+		}
+	}
+}
@@ -6,18 +6,25 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/metoro-io/statusphere/common/db"
 	"github.com/metoro-io/statusphere/common/jobs/slack_webhook"
+	"github.com/metoro-io/statusphere/common/jobs/subscription_webhook"
 	"github.com/metoro-io/statusphere/common/jobs/twitter_post"
+	"github.com/metoro-io/statusphere/common/utils"
 	"github.com/riverqueue/river"
 	"github.com/riverqueue/river/riverdriver/riverpgxv5"
 	"github.com/riverqueue/river/rivermigrate"
 	"go.uber.org/zap"
 	"net/http"
+	"time"
 )
 
+const subscriptionWebhookTimeout = 30 * time.Second
+
 func spawnWorkers(logger *zap.Logger, client *http.Client, dbClient *db.DbClient) *river.Workers {
 	workers := river.NewWorkers()
 	river.AddWorker(workers, slack_webhook.NewSlackWebhookWorker(logger, client))
 	river.AddWorker(workers, twitter_post.NewTwitterPostWorker(logger, client, dbClient))
+	// Subscription webhooks are sent to urls supplied by users so they can't be sent to private addresses
+	river.AddWorker(workers, subscription_webhook.NewSubscriptionWebhookWorker(logger, utils.NewPublicHttpClient(subscriptionWebhookTimeout), dbClient))
 	return workers
 }
 
@@ -0,0 +1,74 @@
+package subscription_webhook
+
+import (
+	"fmt"
+	"github.com/metoro-io/statusphere/common/api"
+	"strings"
+)
+
+// slackMessage is a message in the format sent to slack incoming webhooks
+// See https://api.slack.com/messaging/webhooks
+type slackMessage struct {
+	// Text is shown in notifications, which don't render attachments
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+// slackAttachment is used rather than putting the blocks in the message so that the message gets a coloured bar
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackColors are the colours of the attachment bar for each impact
+var slackColors = map[api.Impact]string{
+	api.ImpactCritical:    "#d92d20",
+	api.ImpactMajor:       "#f79009",
+	api.ImpactMinor:       "#fdb022",
+	api.ImpactMaintenance: "#98a2b3",
+	api.ImpactNone:        "#98a2b3",
+}
+
+func newSlackMessage(incident api.Incident) slackMessage {
+	color, ok := slackColors[incident.Impact]
+	if !ok {
+		color = slackColors[api.ImpactNone]
+	}
+
+	title := fmt.Sprintf("*<%s|%s>*", incident.DeepLink, escapeSlackText(incident.Title))
+	if incident.Description != nil && *incident.Description != "" {
+		title += "\n" + escapeSlackText(*incident.Description)
+	}
+	// Slack renders the date in the timezone of the reader, falling back to the text after the | if it can't
+	started := fmt.Sprintf("<!date^%d^{date_short_pretty} at {time}|%s>", incident.StartTime.Unix(), incident.StartTime.UTC().Format("2006-01-02 15:04 UTC"))
+	details := fmt.Sprintf("*Impact:* %s | *Started:* %s | %s", incident.Impact, started, incident.StatusPageUrl)
+
+	return slackMessage{
+		Text: fmt.Sprintf("New %s incident: %s", incident.Impact, incident.Title),
+		Attachments: []slackAttachment{{
+			Color: color,
+			Blocks: []slackBlock{
+				{Type: "section", Text: &slackText{Type: "mrkdwn", Text: title}},
+				{Type: "context", Elements: []slackText{{Type: "mrkdwn", Text: details}}},
+			},
+		}},
+	}
+}
+
+// slackTextEscaper escapes the characters that slack uses for formatting links and mentions
+var slackTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func escapeSlackText(text string) string {
+	return slackTextEscaper.Replace(text)
+}
@@ -0,0 +1,60 @@
+package subscription_webhook
+
+import (
+	"encoding/json"
+	"github.com/metoro-io/statusphere/common/api"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlackMessageColorsByImpact(t *testing.T) {
+	tests := []struct {
+		impact        api.Impact
+		expectedColor string
+	}{
+		{impact: api.ImpactCritical, expectedColor: "#d92d20"},
+		{impact: api.ImpactMajor, expectedColor: "#f79009"},
+		{impact: api.ImpactMinor, expectedColor: "#fdb022"},
+		{impact: api.ImpactMaintenance, expectedColor: "#98a2b3"},
+		{impact: api.Impact("unknown"), expectedColor: "#98a2b3"},
+	}
+	for _, test := range tests {
+		incident := api.NewIncident("Outage", nil, nil, time.Now(), nil, nil, "https://status.example.com/incidents/1", test.impact, "https://status.example.com")
+		message := newSlackMessage(incident)
+		if len(message.Attachments) != 1 || message.Attachments[0].Color != test.expectedColor {
+			t.Errorf("%s: expected color %s, got %+v", test.impact, test.expectedColor, message.Attachments)
+		}
+	}
+}
+
+func TestSlackWebhookBody(t *testing.T) {
+	description := "Requests to <api> & the dashboard are failing"
+	incident := api.NewIncident("API outage", nil, nil, time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC), nil, &description, "https://status.example.com/incidents/1", api.ImpactCritical, "https://status.example.com")
+
+	body, err := webhookBody(api.SubscriptionFormatSlack, incident)
+	if err != nil {
+		t.Fatalf("failed to build body: %v", err)
+	}
+	var message slackMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		t.Fatalf("failed to unmarshal slack message: %v", err)
+	}
+	if message.Text == "" || len(message.Attachments[0].Blocks) != 2 {
+		t.Fatalf("unexpected slack message %s", body)
+	}
+	section := message.Attachments[0].Blocks[0].Text.Text
+	if !strings.Contains(section, "<https://status.example.com/incidents/1|API outage>") || !strings.Contains(section, "&lt;api&gt; &amp; the dashboard") {
+		t.Fatalf("unexpected section text %q", section)
+	}
+
+	// Subscriptions without a format are sent the incident
+	body, err = webhookBody("", incident)
+	if err != nil {
+		t.Fatalf("failed to build body: %v", err)
+	}
+	var sent api.Incident
+	if err := json.Unmarshal(body, &sent); err != nil || sent.DeepLink != incident.DeepLink {
+		t.Fatalf("expected the incident to be sent, got %s", body)
+	}
+}
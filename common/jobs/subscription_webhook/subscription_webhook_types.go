@@ -0,0 +1,25 @@
+package subscription_webhook
+
+import (
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/riverqueue/river"
+)
+
+// SubscriptionWebhookArgs only references the subscription so that its secret isn't stored in the job
+// and so that deleting the subscription stops any webhooks that haven't been sent yet
+type SubscriptionWebhookArgs struct {
+	SubscriptionId string       `json:"subscription_id"`
+	Incident       api.Incident `json:"incident"`
+	// RedeliveryOf is the id of the delivery that is being sent again, it is empty for new incidents
+	RedeliveryOf string `json:"redelivery_of,omitempty"`
+}
+
+func (SubscriptionWebhookArgs) Kind() string {
+	return "subscription_webhook"
+}
+
+func (SubscriptionWebhookArgs) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{
+		MaxAttempts: 10,
+	}
+}
@@ -0,0 +1,129 @@
+package subscription_webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/pkg/errors"
+	"github.com/riverqueue/river"
+	"go.uber.org/zap"
+	"math"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader is the header containing the signature of the webhook body, see Sign
+const SignatureHeader = "X-Statusphere-Signature"
+
+// SubscriptionIdHeader is the header containing the id of the subscription the webhook was sent for
+const SubscriptionIdHeader = "X-Statusphere-Subscription-Id"
+
+type subscriptionStore interface {
+	GetSubscription(ctx context.Context, id string) (*api.Subscription, error)
+	RecordSubscriptionDelivery(ctx context.Context, delivery api.SubscriptionDelivery) error
+}
+
+type SubscriptionWebhookWorker struct {
+	// An embedded WorkerDefaults sets up default methods to fulfill the rest of
+	// the Worker interface:
+	river.WorkerDefaults[SubscriptionWebhookArgs]
+	logger     *zap.Logger
+	httpClient *http.Client
+	db         subscriptionStore
+}
+
+func NewSubscriptionWebhookWorker(logger *zap.Logger, httpClient *http.Client, dbClient subscriptionStore) *SubscriptionWebhookWorker {
+	return &SubscriptionWebhookWorker{
+		logger:     logger,
+		httpClient: httpClient,
+		db:         dbClient,
+	}
+}
+
+func (w *SubscriptionWebhookWorker) Work(ctx context.Context, job *river.Job[SubscriptionWebhookArgs]) error {
+	subscription, err := w.db.GetSubscription(ctx, job.Args.SubscriptionId)
+	if err != nil {
+		return errors.Wrap(err, "failed to get subscription")
+	}
+	if subscription == nil {
+		w.logger.Info("subscription was deleted, not sending webhook", zap.String("subscriptionId", job.Args.SubscriptionId))
+		return nil
+	}
+
+	w.logger.Info("Sending subscription webhook", zap.String("subscriptionId", subscription.ID), zap.String("incident", job.Args.Incident.DeepLink))
+	body, err := webhookBody(subscription.Format, job.Args.Incident)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal webhook body")
+	}
+	attemptedAt := time.Now().UTC()
+	statusCode, err := w.send(ctx, *subscription, body)
+
+	// Each attempt of the job is a delivery, so a retried attempt is recorded once
+	delivery := api.SubscriptionDelivery{
+		ID:             fmt.Sprintf("%d-%d", job.ID, job.Attempt),
+		SubscriptionID: subscription.ID,
+		IncidentID:     job.Args.Incident.DeepLink,
+		StatusCode:     statusCode,
+		AttemptedAt:    attemptedAt,
+		RedeliveryOf:   job.Args.RedeliveryOf,
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	// Failing to record the delivery doesn't fail the job, as that would send the webhook again
+	if recordErr := w.db.RecordSubscriptionDelivery(ctx, delivery); recordErr != nil {
+		w.logger.Error("failed to record subscription delivery", zap.String("subscriptionId", subscription.ID), zap.Error(recordErr))
+	}
+	return err
+}
+
+// send sends the webhook body to the target url of the subscription, returning the status code that it responded with
+// It returns an error if the request fails or the status code isn't 2xx, in which case the status code is zero if there was no response
+func (w *SubscriptionWebhookWorker) send(ctx context.Context, subscription api.Subscription, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.TargetUrl, bytes.NewBuffer(body))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SubscriptionIdHeader, subscription.ID)
+	req.Header.Set(SignatureHeader, Sign(subscription.Secret, body))
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to send request")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, errors.Errorf("expected a 2xx status code, got %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookBody returns the body of the webhook in the format of the subscription
+func webhookBody(format api.SubscriptionFormat, incident api.Incident) ([]byte, error) {
+	if format == api.SubscriptionFormatSlack {
+		return json.Marshal(newSlackMessage(incident))
+	}
+	return json.Marshal(incident)
+}
+
+// Sign returns the signature of a webhook body, the hex encoded HMAC-SHA256 of the body keyed with the secret of the subscription
+// Receivers can verify a webhook by computing the signature of the body themselves and comparing it to the SignatureHeader
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *SubscriptionWebhookWorker) Timeout(job *river.Job[SubscriptionWebhookArgs]) time.Duration {
+	return time.Second * 30
+}
+
+// NextRetry performs exponential backoff with a maximum of 120 seconds.
+func (w *SubscriptionWebhookWorker) NextRetry(job *river.Job[SubscriptionWebhookArgs]) time.Time {
+	return time.Now().Add(time.Duration(math.Min(math.Pow(2.0, float64(job.Attempt)), 120)) * time.Second)
+}
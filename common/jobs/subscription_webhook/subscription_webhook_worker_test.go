@@ -0,0 +1,117 @@
+package subscription_webhook
+
+import (
+	"context"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeSubscriptionStore struct {
+	subscriptions map[string]api.Subscription
+	deliveries    []api.SubscriptionDelivery
+}
+
+func (f *fakeSubscriptionStore) RecordSubscriptionDelivery(ctx context.Context, delivery api.SubscriptionDelivery) error {
+	f.deliveries = append(f.deliveries, delivery)
+	return nil
+}
+
+func (f *fakeSubscriptionStore) GetSubscription(ctx context.Context, id string) (*api.Subscription, error) {
+	subscription, ok := f.subscriptions[id]
+	if !ok {
+		return nil, nil
+	}
+	return &subscription, nil
+}
+
+func TestWorkSendsSignedIncident(t *testing.T) {
+	var receivedBody []byte
+	var receivedSignature string
+	statusCode := http.StatusInternalServerError
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedBody, _ = io.ReadAll(r.Body)
+		receivedSignature = r.Header.Get(SignatureHeader)
+		w.WriteHeader(statusCode)
+	}))
+	defer receiver.Close()
+
+	store := &fakeSubscriptionStore{subscriptions: map[string]api.Subscription{
+		"sub": {ID: "sub", TargetUrl: receiver.URL, Secret: "secret"},
+	}}
+	worker := NewSubscriptionWebhookWorker(zap.NewNop(), http.DefaultClient, store)
+	job := &river.Job[SubscriptionWebhookArgs]{JobRow: &rivertype.JobRow{ID: 1, Attempt: 1}, Args: SubscriptionWebhookArgs{
+		SubscriptionId: "sub",
+		Incident:       api.NewIncident("Outage", nil, nil, time.Now(), nil, nil, "https://status.example.com/incidents/1", api.ImpactMajor, "https://status.example.com"),
+	}}
+
+	// A failed delivery returns an error so that river retries it
+	if err := worker.Work(context.Background(), job); err == nil {
+		t.Fatalf("expected an error when the receiver fails")
+	}
+
+	statusCode = http.StatusAccepted
+	if err := worker.Work(context.Background(), job); err != nil {
+		t.Fatalf("expected the webhook to be sent, got %v", err)
+	}
+	if receivedSignature != Sign("secret", receivedBody) {
+		t.Fatalf("expected the signature to match the body, got %s", receivedSignature)
+	}
+	if Sign("other secret", receivedBody) == receivedSignature {
+		t.Fatalf("expected the signature to depend on the secret")
+	}
+}
+
+func TestWorkRecordsEachDelivery(t *testing.T) {
+	statusCode := http.StatusInternalServerError
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCode)
+	}))
+	defer receiver.Close()
+
+	store := &fakeSubscriptionStore{subscriptions: map[string]api.Subscription{
+		"sub": {ID: "sub", TargetUrl: receiver.URL, Secret: "secret"},
+	}}
+	worker := NewSubscriptionWebhookWorker(zap.NewNop(), http.DefaultClient, store)
+	job := &river.Job[SubscriptionWebhookArgs]{
+		JobRow: &rivertype.JobRow{ID: 7, Attempt: 1},
+		Args: SubscriptionWebhookArgs{
+			SubscriptionId: "sub",
+			Incident:       api.NewIncident("Outage", nil, nil, time.Now(), nil, nil, "https://status.example.com/incidents/1", api.ImpactMajor, "https://status.example.com"),
+		},
+	}
+	if err := worker.Work(context.Background(), job); err == nil {
+		t.Fatalf("expected an error when the receiver fails")
+	}
+	statusCode = http.StatusOK
+	job.Attempt = 2
+	job.Args.RedeliveryOf = "3-1"
+	if err := worker.Work(context.Background(), job); err != nil {
+		t.Fatalf("expected the webhook to be sent, got %v", err)
+	}
+
+	if len(store.deliveries) != 2 {
+		t.Fatalf("expected two deliveries to be recorded, got %+v", store.deliveries)
+	}
+	failed, succeeded := store.deliveries[0], store.deliveries[1]
+	if failed.ID != "7-1" || failed.SubscriptionID != "sub" || failed.IncidentID != "https://status.example.com/incidents/1" || failed.StatusCode != http.StatusInternalServerError || failed.Error == "" {
+		t.Fatalf("unexpected failed delivery %+v", failed)
+	}
+	if succeeded.ID != "7-2" || succeeded.StatusCode != http.StatusOK || succeeded.Error != "" || succeeded.RedeliveryOf != "3-1" {
+		t.Fatalf("unexpected successful delivery %+v", succeeded)
+	}
+}
+
+func TestWorkSkipsDeletedSubscriptions(t *testing.T) {
+	worker := NewSubscriptionWebhookWorker(zap.NewNop(), http.DefaultClient, &fakeSubscriptionStore{})
+	job := &river.Job[SubscriptionWebhookArgs]{Args: SubscriptionWebhookArgs{SubscriptionId: "deleted"}}
+	if err := worker.Work(context.Background(), job); err != nil {
+		t.Fatalf("expected no error for a deleted subscription, got %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"github.com/pkg/errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// NewPublicHttpClient returns an http client that refuses to connect to private addresses
+// It is used for requests to urls supplied by users so that they can't be used to probe the network statusphere runs in
+func NewPublicHttpClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+				return errors.Errorf("refusing to connect to %s", host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/metoro-io/statusphere/common/api"
 	"github.com/metoro-io/statusphere/common/db"
 	"github.com/metoro-io/statusphere/common/jobs/slack_webhook"
+	"github.com/metoro-io/statusphere/common/jobs/subscription_webhook"
 	"github.com/metoro-io/statusphere/common/jobs/twitter_post"
 	"github.com/pkg/errors"
 	"github.com/riverqueue/river"
@@ -33,27 +34,34 @@ func NewIncidentPoller(db *db.DbClient, logger *zap.Logger, client *river.Client
 	}
 }
 
-func (p *IncidentPoller) Start() {
-	go p.Poll()
+// Start polls in the background until the context is done
+func (p *IncidentPoller) Start(ctx context.Context) {
+	go p.Poll(ctx)
 }
 
-func (p *IncidentPoller) Poll() error {
-	err := p.pollInner()
-	if err != nil {
-		p.logger.Error("failed to poll", zap.Error(err))
-	}
+func (p *IncidentPoller) Poll(ctx context.Context) error {
+	p.pollOnce()
 	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ticker.C:
-			err := p.pollInner()
-			if err != nil {
-				p.logger.Error("failed to poll", zap.Error(err))
-			}
+			p.pollOnce()
+		case <-ctx.Done():
+			return nil
 		}
 	}
 }
 
+func (p *IncidentPoller) pollOnce() {
+	if err := p.pollInner(); err != nil {
+		p.logger.Error("failed to poll", zap.Error(err))
+	}
+	if err := p.pollRedeliveries(); err != nil {
+		p.logger.Error("failed to poll redeliveries", zap.Error(err))
+	}
+}
+
 func (p *IncidentPoller) pollInner() error {
 	// Get the incidents from the database without jobs started
 	p.logger.Info("polling incidents without jobs started")
@@ -70,12 +78,14 @@ func (p *IncidentPoller) pollInner() error {
 	jobArgs := make([]river.InsertManyParams, 0)
 
 	var incidentsToProcess = make([]api.Incident, 0)
+	var newIncidents = make([]api.Incident, 0)
 	for _, incident := range incidents {
 		// We only want to notify about incidents that have started in the last hour
 		// Otherwise, we will be sending notifications for incidents that have already been resolved
 		if incident.StartTime.Before(time.Now().Add(-1 * time.Hour)) {
 			continue
 		}
+		newIncidents = append(newIncidents, incident)
 
 		if incident.Impact == "maintenance" {
 			continue
@@ -106,6 +116,13 @@ func (p *IncidentPoller) pollInner() error {
 		}})
 	}
 
+	// Subscription webhook notifications, subscriptions filter by impact themselves so they can be sent maintenance incidents
+	subscriptionJobArgs, err := p.subscriptionJobArgs(newIncidents)
+	if err != nil {
+		return errors.Wrap(err, "failed to get subscription jobs")
+	}
+	jobArgs = append(jobArgs, subscriptionJobArgs...)
+
 	p.logger.Info("starting to insert jobs", zap.Int("count", len(jobArgs)))
 	// Start the jobs for each incident and update the database
 	if len(jobArgs) != 0 {
@@ -119,3 +136,65 @@ func (p *IncidentPoller) pollInner() error {
 	// Start the jobs for each incident and update the database
 	return p.db.SetIncidentNotificationStartedToTrue(context.Background(), incidents)
 }
+
+func (p *IncidentPoller) subscriptionJobArgs(incidents []api.Incident) ([]river.InsertManyParams, error) {
+	jobArgs := make([]river.InsertManyParams, 0)
+	subscriptionsByStatusPage := make(map[string][]api.Subscription)
+	for _, incident := range incidents {
+		subscriptions, ok := subscriptionsByStatusPage[incident.StatusPageUrl]
+		if !ok {
+			var err error
+			subscriptions, err = p.db.GetSubscriptionsForStatusPage(context.Background(), incident.StatusPageUrl)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to get subscriptions for status page")
+			}
+			subscriptionsByStatusPage[incident.StatusPageUrl] = subscriptions
+		}
+		for _, subscription := range subscriptions {
+			if !subscription.Matches(incident) {
+				continue
+			}
+			jobArgs = append(jobArgs, river.InsertManyParams{Args: subscription_webhook.SubscriptionWebhookArgs{
+				SubscriptionId: subscription.ID,
+				Incident:       incident,
+			}})
+		}
+	}
+	return jobArgs, nil
+}
+
+// pollRedeliveries starts the subscription webhooks of the deliveries that have been requested to be sent again, see db.RequestSubscriptionRedelivery
+// The incident is read again so that the latest version of it is sent
+func (p *IncidentPoller) pollRedeliveries() error {
+	deliveries, err := p.db.GetRequestedSubscriptionRedeliveries(context.Background(), 1000)
+	if err != nil {
+		return errors.Wrap(err, "failed to get requested redeliveries")
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	jobArgs := make([]river.InsertManyParams, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		incident, err := p.db.GetIncidentByID(context.Background(), delivery.IncidentID)
+		if err != nil {
+			return errors.Wrap(err, "failed to get the incident of the delivery")
+		}
+		if incident == nil {
+			p.logger.Info("incident of the delivery was deleted, not redelivering it", zap.String("deliveryId", delivery.ID), zap.String("incident", delivery.IncidentID))
+			continue
+		}
+		jobArgs = append(jobArgs, river.InsertManyParams{Args: subscription_webhook.SubscriptionWebhookArgs{
+			SubscriptionId: delivery.SubscriptionID,
+			Incident:       *incident,
+			RedeliveryOf:   delivery.ID,
+		}})
+	}
+	if len(jobArgs) != 0 {
+		if _, err := p.riverClient.InsertMany(context.Background(), jobArgs); err != nil {
+			return errors.Wrap(err, "failed to insert redeliveries")
+		}
+	}
+	p.logger.Info("started redeliveries", zap.Int("count", len(jobArgs)))
+	return p.db.ClearSubscriptionRedeliveryRequests(context.Background(), deliveries)
+}
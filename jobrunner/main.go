@@ -10,16 +10,25 @@ import (
 	"github.com/riverqueue/river"
 	"go.uber.org/zap"
 	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+// shutdownGracePeriod is how long the jobs that are running are given to complete on shutdown
+const shutdownGracePeriod = 30 * time.Second
+
 func main() {
-	ctx, cancelCtx := context.WithCancel(context.Background())
+	// The context is cancelled on shutdown, which stops the incident poller
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancelCtx()
 
 	logger, err := zap.NewProduction()
 	if err != nil {
 		panic(err)
 	}
+	// Flush any buffered logs on exit
+	defer func() { _ = logger.Sync() }()
 
 	db, err := db.NewDbClientFromEnvironment(logger)
 	if err != nil {
@@ -36,9 +45,14 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	defer func(client *river.Client[pgx.Tx], ctx context.Context) {
-		_ = client.Stop(ctx)
-	}(client, ctx)
+	defer func(client *river.Client[pgx.Tx]) {
+		// The context is already done by the time the client is stopped, so the running jobs get a fresh one to complete within
+		stopCtx, cancelStop := context.WithTimeout(context.Background(), shutdownGracePeriod)
+		defer cancelStop()
+		if err := client.Stop(stopCtx); err != nil {
+			logger.Error("failed to stop the river client gracefully", zap.Error(err))
+		}
+	}(client)
 
 	config, err := config2.GetConfigFromEnvironment()
 	if err != nil {
@@ -46,8 +60,9 @@ func main() {
 	}
 
 	incidentPoller := incidentpoller.NewIncidentPoller(db, logger, client, config.SlackWebhookUrl, config.TwitterWebhookUrl)
-	incidentPoller.Start()
+	incidentPoller.Start(ctx)
 
-	// Work forever
+	// Work until the shutdown signal
 	<-ctx.Done()
+	logger.Info("Shutting down")
 }
@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+)
+
+type DryRunRequest struct {
+	Url string `json:"url"`
+	// Historical is whether the historical incidents are scraped rather than the current ones
+	Historical bool `json:"historical"`
+}
+
+type DryRunResponse struct {
+	Url string `json:"url"`
+	// Provider is the name of the provider that detected the status page, it is empty if none of them did
+	Provider  string         `json:"provider"`
+	Incidents []api.Incident `json:"incidents"`
+	// Error is why the status page couldn't be scraped, it is empty if the scrape succeeded
+	Error string `json:"error,omitempty"`
+	// ErrorClass is the kind of the error, see scraper.ErrorClass
+	ErrorClass scraper.ErrorClass `json:"errorClass,omitempty"`
+}
+
+// NewDryRunHandler returns the handler of the POST /admin/index/dry-run endpoint
+// It takes a json body with a required url and optional historical field, and returns the incidents that the scraper extracts from the
+// status page along with the provider that detected it, without storing them, so that providers can be checked before a status page is added
+// It requires an Authorization: Bearer <adminApiKey> header, if adminApiKey is empty then all requests are rejected as the endpoint is disabled
+// A scrape that fails because no provider detected the page or the provider couldn't parse it responds with a 422, and one that couldn't
+// fetch the status page with a 502, both with the error and its class
+func NewDryRunHandler(dryRunner scraper.DryRunner, adminApiKey string, logger *zap.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "only POST is allowed"})
+			return
+		}
+		if adminApiKey == "" {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "admin endpoints are disabled"})
+			return
+		}
+		apiKey, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(apiKey), []byte(adminApiKey)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid api key"})
+			return
+		}
+
+		var request DryRunRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Url == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "url is required"})
+			return
+		}
+		url, err := api.CanonicalStatusPageUrl(request.Url)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "url is invalid, " + err.Error()})
+			return
+		}
+
+		provider, incidents, err := dryRunner.DryRun(r.Context(), url, request.Historical)
+		response := DryRunResponse{Url: url, Provider: provider, Incidents: incidents}
+		if incidents == nil {
+			response.Incidents = []api.Incident{}
+		}
+		if err != nil {
+			utils.GetLogger(r.Context(), logger).Info("dry run of the status page failed", zap.String("url", url), zap.Error(err))
+			response.Error = err.Error()
+			response.ErrorClass = scraper.ClassOf(err)
+			status := http.StatusBadGateway
+			if response.ErrorClass == scraper.ErrorClassUnsupported || response.ErrorClass == scraper.ErrorClassProvider {
+				status = http.StatusUnprocessableEntity
+			}
+			writeJSON(w, status, response)
+			return
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
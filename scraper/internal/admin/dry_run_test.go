@@ -0,0 +1,95 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper/providers"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeDryRunner struct {
+	provider  string
+	incidents []api.Incident
+	err       error
+	url       string
+}
+
+func (f *fakeDryRunner) DryRun(ctx context.Context, url string, historical bool) (string, []api.Incident, error) {
+	f.url = url
+	return f.provider, f.incidents, f.err
+}
+
+func postDryRun(handler http.Handler, body string, apiKey string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/admin/index/dry-run", strings.NewReader(body))
+	if apiKey != "" {
+		request.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestDryRunReturnsTheParsedIncidents(t *testing.T) {
+	incident := api.NewIncident("Outage", nil, nil, time.Now(), nil, nil, "https://status.example.com/incidents/1", api.ImpactMajor, "https://status.example.com")
+	dryRunner := &fakeDryRunner{provider: "atlassian", incidents: []api.Incident{incident}}
+	handler := NewDryRunHandler(dryRunner, "secret", zap.NewNop())
+
+	recorder := postDryRun(handler, `{"url": "https://Status.example.com/"}`, "secret")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response DryRunResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response.Provider != "atlassian" || len(response.Incidents) != 1 || response.Incidents[0].Title != "Outage" || dryRunner.url != "https://status.example.com" {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+}
+
+func TestDryRunSurfacesTheErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		apiKey         string
+		adminApiKey    string
+		err            error
+		expectedStatus int
+	}{
+		{name: "disabled", body: `{"url": "https://status.example.com"}`, apiKey: "secret", expectedStatus: http.StatusForbidden},
+		{name: "invalid api key", body: `{"url": "https://status.example.com"}`, apiKey: "wrong", adminApiKey: "secret", expectedStatus: http.StatusUnauthorized},
+		{name: "missing url", body: `{}`, apiKey: "secret", adminApiKey: "secret", expectedStatus: http.StatusBadRequest},
+		{name: "invalid url", body: `{"url": "status.example.com"}`, apiKey: "secret", adminApiKey: "secret", expectedStatus: http.StatusBadRequest},
+		{name: "unsupported", body: `{"url": "https://status.example.com"}`, apiKey: "secret", adminApiKey: "secret", err: unsupportedError(t), expectedStatus: http.StatusUnprocessableEntity},
+		{name: "unreachable", body: `{"url": "https://status.example.com"}`, apiKey: "secret", adminApiKey: "secret", err: errors.New("connection refused"), expectedStatus: http.StatusBadGateway},
+	}
+	for _, test := range tests {
+		handler := NewDryRunHandler(&fakeDryRunner{err: test.err}, test.adminApiKey, zap.NewNop())
+		recorder := postDryRun(handler, test.body, test.apiKey)
+		if recorder.Code != test.expectedStatus {
+			t.Fatalf("%s: expected status %d, got %d: %s", test.name, test.expectedStatus, recorder.Code, recorder.Body.String())
+		}
+		if test.err != nil && !strings.Contains(recorder.Body.String(), test.err.Error()) {
+			t.Fatalf("%s: expected the error in the response, got %s", test.name, recorder.Body.String())
+		}
+	}
+}
+
+// unsupportedError returns the error that the dry runner returns for a status page that none of the providers detect
+func unsupportedError(t *testing.T) error {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, _, err := scraper.NewDryRunner(zap.NewNop(), server.Client(), providers.NewRegistry()).DryRun(context.Background(), server.URL, false)
+	if scraper.ClassOf(err) != scraper.ErrorClassUnsupported {
+		t.Fatalf("expected the status page to be unsupported, got %v", err)
+	}
+	return err
+}
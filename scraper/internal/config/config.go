@@ -0,0 +1,45 @@
+package config
+
+import (
+	"github.com/kelseyhightower/envconfig"
+	"github.com/metoro-io/statusphere/common/hosts"
+	"github.com/metoro-io/statusphere/scraper/internal/retry"
+	"github.com/metoro-io/statusphere/scraper/internal/robots"
+	"github.com/metoro-io/statusphere/scraper/internal/sanitize"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper/poller"
+	"github.com/metoro-io/statusphere/scraper/internal/tags"
+	"github.com/metoro-io/statusphere/scraper/internal/useragent"
+)
+
+type Config struct {
+	// UserAgent is how the scraper identifies itself in the http requests it makes
+	UserAgent useragent.Config `envconfig:"HTTP"`
+	// Retry is how the http requests made to status pages are retried when they fail transiently
+	Retry retry.Config `envconfig:"RETRY"`
+	// Robots is how the robots.txt files of the status page hosts are followed and how often each host is requested
+	Robots robots.Config `envconfig:"ROBOTS"`
+	// Tags is how the incidents are tagged with the keywords in them
+	Tags tags.Config `envconfig:"TAGS"`
+	// Descriptions is how the html in the descriptions of the incidents is sanitized before they're stored
+	Descriptions sanitize.Config `envconfig:"DESCRIPTIONS"`
+	// Poller is how many status pages are scraped at once
+	Poller poller.Config `envconfig:"POLLER"`
+	// Hosts are the hosts that status pages are scraped from, the api server reads the same variables so that only they can be submitted
+	Hosts hosts.Config `envconfig:"HOSTS"`
+	// Snapshots is whether the last response of each status page is stored, and how much of it, so that scrapes can be diagnosed
+	Snapshots scraper.SnapshotConfig `envconfig:"SNAPSHOTS"`
+	// MetricsAddress is the address that the prometheus metrics of the scraper are served on at /metrics, they aren't served if it is empty
+	MetricsAddress string `envconfig:"METRICS_ADDRESS" default:":9090"`
+	// AdminAddress is the address that the admin endpoints of the scraper are served on, they aren't served if it is empty
+	// It is separate from the metrics address so that exposing the metrics to a scraper of them doesn't expose the admin endpoints too
+	AdminAddress string `envconfig:"ADMIN_ADDRESS" default:":9091"`
+	// AdminApiKey is the bearer token required to call the admin endpoints of the scraper, if it is empty the admin endpoints are disabled
+	AdminApiKey string `envconfig:"ADMIN_API_KEY"`
+}
+
+func GetConfigFromEnvironment() (Config, error) {
+	var config Config
+	err := envconfig.Process("STATUSPHERE", &config)
+	return config, err
+}
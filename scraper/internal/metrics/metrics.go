@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	ScrapeTypeCurrent    = "current"
+	ScrapeTypeHistorical = "historical"
+)
+
+var (
+	ScrapeAttemptsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "statusphere",
+		Subsystem: "scraper",
+		Name:      "scrape_attempts_total",
+		Help:      "The number of status page scrapes started by type (current or historical)",
+	}, []string{"type"})
+
+	ScrapeSuccessesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "statusphere",
+		Subsystem: "scraper",
+		Name:      "scrape_successes_total",
+		Help:      "The number of status page scrapes that succeeded by type (current or historical)",
+	}, []string{"type"})
+
+	ScrapeFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "statusphere",
+		Subsystem: "scraper",
+		Name:      "scrape_failures_total",
+		Help:      "The number of status page scrapes that failed by type (current or historical) and error class",
+	}, []string{"type", "class"})
+
+	IncidentsDiscoveredTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "statusphere",
+		Subsystem: "scraper",
+		Name:      "incidents_discovered_total",
+		Help:      "The number of incidents that current scrapes found for the first time by impact",
+	}, []string{"impact"})
+
+	StatusPagesPendingIndex = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "statusphere",
+		Subsystem: "scraper",
+		Name:      "status_pages_pending_index",
+		Help:      "The number of status pages that haven't been scraped successfully yet",
+	})
+)
+
+// RecordScrapeAttempt records the start of a scrape in the scrape_attempts_total metric
+func RecordScrapeAttempt(scrapeType string) {
+	ScrapeAttemptsTotal.WithLabelValues(scrapeType).Inc()
+}
+
+// RecordScrapeResult records the outcome of a scrape in the scrape_successes_total or scrape_failures_total metric
+// errorClass is the class of the error of a failed scrape, it is ignored if the scrape succeeded
+func RecordScrapeResult(scrapeType string, err error, errorClass string) {
+	if err == nil {
+		ScrapeSuccessesTotal.WithLabelValues(scrapeType).Inc()
+		return
+	}
+	ScrapeFailuresTotal.WithLabelValues(scrapeType, errorClass).Inc()
+}
+
+// RecordIncidentDiscovered records an incident found for the first time in the incidents_discovered_total metric
+func RecordIncidentDiscovered(impact string) {
+	IncidentsDiscoveredTotal.WithLabelValues(impact).Inc()
+}
+
+// SetStatusPagesPendingIndex sets the status_pages_pending_index metric
+func SetStatusPagesPendingIndex(count int) {
+	StatusPagesPendingIndex.Set(float64(count))
+}
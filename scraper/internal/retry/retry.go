@@ -0,0 +1,115 @@
+package retry
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+)
+
+type Config struct {
+	// MaxAttempts is the most times a request is made, including the first attempt, 1 disables retries
+	MaxAttempts int `envconfig:"MAX_ATTEMPTS" default:"3"`
+	// InitialBackoff is how long is waited before the first retry, it doubles with each retry after that
+	InitialBackoff time.Duration `envconfig:"INITIAL_BACKOFF" default:"500ms"`
+	// MaxBackoff is the longest that is waited between two attempts
+	MaxBackoff time.Duration `envconfig:"MAX_BACKOFF" default:"10s"`
+}
+
+// Transport retries the GET and HEAD requests that fail transiently, see IsRetriable
+// Other requests aren't retried as they may not be safe to make twice
+type Transport struct {
+	next   http.RoundTripper
+	config Config
+	logger *zap.Logger
+	// sleep waits for the backoff, it returns early with an error if the context is done
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewTransport returns a transport that makes the requests with next, retrying them as described by the config
+func NewTransport(next http.RoundTripper, config Config, logger *zap.Logger) *Transport {
+	return &Transport{
+		next:   next,
+		config: config,
+		logger: logger,
+		sleep:  sleepContext,
+	}
+}
+
+func (t *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		return t.next.RoundTrip(request)
+	}
+	for attempt := 1; ; attempt++ {
+		response, err := t.next.RoundTrip(request)
+		if attempt >= t.config.MaxAttempts || !IsRetriable(request.Context(), response, err) {
+			return response, err
+		}
+
+		backoff := Backoff(t.config, attempt)
+		fields := []zap.Field{zap.String("url", request.URL.String()), zap.Int("attempt", attempt), zap.Duration("backoff", backoff)}
+		if err != nil {
+			fields = append(fields, zap.Error(err))
+		} else {
+			fields = append(fields, zap.Int("status", response.StatusCode))
+			// The body is drained so that the connection can be reused by the next attempt
+			_, _ = io.Copy(io.Discard, response.Body)
+			_ = response.Body.Close()
+		}
+		t.logger.Debug("retrying request to status page", fields...)
+
+		if sleepErr := t.sleep(request.Context(), backoff); sleepErr != nil {
+			return nil, errors.Wrap(sleepErr, "request cancelled while waiting to retry")
+		}
+	}
+}
+
+// IsRetriable returns true if the request failed in a way that may succeed if it is made again
+// Server errors, rate limiting, timeouts and other network errors are retriable, client errors such as a 404 or 401 aren't
+// as making the request again would get the same response
+func IsRetriable(ctx context.Context, response *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		// The request was cancelled, e.g. by a shutdown
+		return false
+	}
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return true
+		}
+		// Other errors making the request, e.g. a connection reset, are usually transient too
+		return !errors.Is(err, context.Canceled)
+	}
+	return response.StatusCode >= http.StatusInternalServerError || response.StatusCode == http.StatusTooManyRequests
+}
+
+// Backoff returns how long to wait after the given attempt failed before making the next one
+// It grows exponentially from the initial backoff up to the max backoff, with jitter so that the retries
+// of requests that failed at the same time don't all happen at the same time
+func Backoff(config Config, attempt int) time.Duration {
+	backoff := config.InitialBackoff
+	for i := 1; i < attempt && backoff < config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, config.MaxBackoff)
+	if backoff <= 0 {
+		return 0
+	}
+	// Wait between half and all of the backoff
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,148 @@
+package retry
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testConfig = Config{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond}
+
+// newFlakyServer returns a server that responds with failureStatus until it has been called failures times
+func newFlakyServer(t *testing.T, failures int32, failureStatus int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(failureStatus)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func newTestClient(config Config) *http.Client {
+	return &http.Client{Transport: NewTransport(http.DefaultTransport, config, zap.NewNop())}
+}
+
+func TestTransportRetriesUntilTheRequestSucceeds(t *testing.T) {
+	server, calls := newFlakyServer(t, 2, http.StatusServiceUnavailable)
+
+	response, err := newTestClient(testConfig).Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Fatalf("expected the third attempt to succeed, got %d after %d attempts", response.StatusCode, calls.Load())
+	}
+}
+
+func TestTransportReturnsTheLastResponseWhenTheAttemptsAreExhausted(t *testing.T) {
+	server, calls := newFlakyServer(t, 10, http.StatusBadGateway)
+
+	response, err := newTestClient(testConfig).Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusBadGateway || calls.Load() != 3 {
+		t.Fatalf("expected a 502 after 3 attempts, got %d after %d attempts", response.StatusCode, calls.Load())
+	}
+}
+
+func TestTransportDoesNotRetryClientErrors(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusUnauthorized} {
+		server, calls := newFlakyServer(t, 10, status)
+		response, err := newTestClient(testConfig).Get(server.URL)
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %v", status, err)
+		}
+		response.Body.Close()
+		if response.StatusCode != status || calls.Load() != 1 {
+			t.Fatalf("%d: expected a single attempt, got %d after %d attempts", status, response.StatusCode, calls.Load())
+		}
+	}
+}
+
+func TestTransportDoesNotRetryPosts(t *testing.T) {
+	server, calls := newFlakyServer(t, 10, http.StatusServiceUnavailable)
+	response, err := newTestClient(testConfig).Post(server.URL, "text/plain", strings.NewReader("body"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	if calls.Load() != 1 {
+		t.Fatalf("expected a single attempt, got %d", calls.Load())
+	}
+}
+
+func TestTransportRetriesNetworkErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	var attempts atomic.Int32
+	transport := NewTransport(roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		attempts.Add(1)
+		return http.DefaultTransport.RoundTrip(request)
+	}), testConfig, zap.NewNop())
+	if _, err := (&http.Client{Transport: transport}).Get(url); err == nil {
+		t.Fatalf("expected an error from a closed server")
+	}
+	if attempts.Load() != 3 {
+		t.Fatalf("expected the connection error to be retried, got %d attempts", attempts.Load())
+	}
+}
+
+func TestTransportStopsWaitingWhenTheRequestIsCancelled(t *testing.T) {
+	server, calls := newFlakyServer(t, 10, http.StatusServiceUnavailable)
+	ctx, cancel := context.WithCancel(context.Background())
+	client := newTestClient(Config{MaxAttempts: 3, InitialBackoff: time.Hour, MaxBackoff: time.Hour})
+	client.Transport.(*Transport).sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return sleepContext(ctx, d)
+	}
+
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if _, err := client.Do(request); err == nil {
+		t.Fatalf("expected an error when the request is cancelled")
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected no retry after the request was cancelled, got %d attempts", calls.Load())
+	}
+}
+
+func TestBackoffGrowsExponentiallyWithJitter(t *testing.T) {
+	config := Config{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 1, max: 100 * time.Millisecond},
+		{attempt: 2, max: 200 * time.Millisecond},
+		{attempt: 3, max: 400 * time.Millisecond},
+		{attempt: 5, max: time.Second},
+		{attempt: 50, max: time.Second},
+	}
+	for _, test := range tests {
+		for i := 0; i < 100; i++ {
+			if backoff := Backoff(config, test.attempt); backoff < test.max/2 || backoff > test.max {
+				t.Fatalf("attempt %d: expected a backoff between %s and %s, got %s", test.attempt, test.max/2, test.max, backoff)
+			}
+		}
+	}
+}
+
+type roundTripperFunc func(request *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
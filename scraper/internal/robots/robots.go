@@ -0,0 +1,141 @@
+package robots
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rules are the rules of a robots.txt that apply to the scraper, see https://www.rfc-editor.org/rfc/rfc9309
+type Rules struct {
+	rules []rule
+	// CrawlDelay is the delay between requests that the robots.txt asks for, it is zero if it doesn't ask for one
+	CrawlDelay time.Duration
+}
+
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+// AllowAll is the rules of a host without a robots.txt
+var AllowAll = &Rules{}
+
+// Parse parses the robots.txt, returning the rules of the groups for the userAgent
+// If there is no group for the userAgent then the rules of the * group are returned
+// User agents are compared case insensitively, a group applies to the userAgent if its name is a prefix of it, e.g. Statusphere applies to Statusphere/1.0
+func Parse(robotsTxt io.Reader, userAgent string) (*Rules, error) {
+	userAgent = strings.ToLower(userAgent)
+	matching := &Rules{}
+	wildcard := &Rules{}
+	foundMatching := false
+
+	// The groups that the rules being read belong to, a group starts with one or more user-agent lines
+	var current []*Rules
+	inUserAgents := false
+	scanner := bufio.NewScanner(robotsTxt)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			if !inUserAgents {
+				current = nil
+				inUserAgents = true
+			}
+			name := strings.ToLower(value)
+			switch {
+			case name == "*":
+				current = append(current, wildcard)
+			case name != "" && strings.HasPrefix(userAgent, name):
+				current = append(current, matching)
+				foundMatching = true
+			}
+			continue
+		}
+		inUserAgents = false
+
+		for _, group := range current {
+			switch key {
+			case "allow", "disallow":
+				// An empty disallow allows everything, which is the same as having no rule
+				if value != "" {
+					group.rules = append(group.rules, rule{allow: key == "allow", pattern: value})
+				}
+			case "crawl-delay":
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					group.CrawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if foundMatching {
+		return matching, nil
+	}
+	return wildcard, nil
+}
+
+// Allowed returns true if the rules allow the path, which includes the query if there is one
+// The rule with the longest pattern matching the path applies, allow rules win ties, and the path is allowed if no rule matches
+// /robots.txt is always allowed
+func (r *Rules) Allowed(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+	allowed := true
+	longest := -1
+	for _, rule := range r.rules {
+		if !matches(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			allowed = rule.allow
+			longest = len(rule.pattern)
+		}
+	}
+	return allowed
+}
+
+// matches returns true if the path starts with the pattern, where * in the pattern matches any characters
+// and a $ at the end of the pattern means that the path has to end there
+func matches(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+
+	// The first part has to be at the start of the path, the others can be anywhere after the part before them
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for _, part := range parts[1:] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	if !anchored {
+		return true
+	}
+	if len(parts) > 1 && parts[len(parts)-1] == "" {
+		// The pattern ends with *$, so any remaining characters are matched by the *
+		return true
+	}
+	// The last part has to be at the end of the path, which the search above may not have found if it appears more than once
+	return rest == "" || (len(parts) > 1 && strings.HasSuffix(path, parts[len(parts)-1]))
+}
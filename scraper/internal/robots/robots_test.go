@@ -0,0 +1,89 @@
+package robots
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseFixture(t *testing.T, userAgent string) *Rules {
+	t.Helper()
+	file, err := os.Open("testdata/robots.txt")
+	if err != nil {
+		t.Fatalf("failed to open the fixture: %v", err)
+	}
+	defer file.Close()
+	rules, err := Parse(file, userAgent)
+	if err != nil {
+		t.Fatalf("failed to parse the fixture: %v", err)
+	}
+	return rules
+}
+
+func TestParseUsesTheGroupOfTheUserAgent(t *testing.T) {
+	rules := parseFixture(t, "Statusphere/1.0")
+	if rules.CrawlDelay != 500*time.Millisecond {
+		t.Fatalf("expected the crawl delay of the statusphere group, got %s", rules.CrawlDelay)
+	}
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{path: "/", expected: true},
+		{path: "/incidents/123", expected: true},
+		{path: "/history", expected: false},
+		{path: "/history?page=2", expected: false},
+		{path: "/history?page=1", expected: true},
+		{path: "/private/incidents", expected: false},
+		{path: "/private/feed.atom", expected: true},
+		{path: "/private/feed.atom.bak", expected: false},
+		// The rules of the * group don't apply as there is a group for the user agent
+		{path: "/admin", expected: true},
+		{path: "/robots.txt", expected: true},
+	}
+	for _, test := range tests {
+		if allowed := rules.Allowed(test.path); allowed != test.expected {
+			t.Fatalf("%s: expected allowed to be %t", test.path, test.expected)
+		}
+	}
+}
+
+func TestParseFallsBackToTheWildcardGroup(t *testing.T) {
+	rules := parseFixture(t, "SomeOtherScraper")
+	if rules.CrawlDelay != 2*time.Second {
+		t.Fatalf("expected the crawl delay of the * group, got %s", rules.CrawlDelay)
+	}
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{path: "/history", expected: true},
+		{path: "/admin", expected: false},
+		{path: "/admin/settings", expected: false},
+		{path: "/admin/public", expected: true},
+		{path: "/api/v2/summary.json", expected: false},
+		{path: "/api/v2/summary.json?x=1", expected: true},
+	}
+	for _, test := range tests {
+		if allowed := rules.Allowed(test.path); allowed != test.expected {
+			t.Fatalf("%s: expected allowed to be %t", test.path, test.expected)
+		}
+	}
+
+	if rules := parseFixture(t, "OtherBot"); rules.Allowed("/") || !rules.Allowed("/robots.txt") {
+		t.Fatalf("expected everything but robots.txt to be disallowed for OtherBot")
+	}
+}
+
+func TestParseAllowsEverythingWithoutRules(t *testing.T) {
+	for _, robotsTxt := range []string{"", "User-agent: *\nDisallow:\n", "Sitemap: https://status.example.com/sitemap.xml\n"} {
+		rules, err := Parse(strings.NewReader(robotsTxt), "Statusphere")
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", robotsTxt, err)
+		}
+		if !rules.Allowed("/history") || rules.CrawlDelay != 0 {
+			t.Fatalf("%q: expected everything to be allowed without a crawl delay", robotsTxt)
+		}
+	}
+}
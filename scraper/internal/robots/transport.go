@@ -0,0 +1,163 @@
+package robots
+
+import (
+	"context"
+	"github.com/patrickmn/go-cache"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDisallowed is returned for requests to paths that the robots.txt of the host disallows
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+type Config struct {
+	// DefaultCrawlDelay is the delay between requests to the same host when its robots.txt doesn't ask for one
+	DefaultCrawlDelay time.Duration `envconfig:"DEFAULT_CRAWL_DELAY" default:"1s"`
+	// MaxCrawlDelay caps the crawl delays that robots.txt files ask for, so that a status page can still be scraped every cycle
+	MaxCrawlDelay time.Duration `envconfig:"MAX_CRAWL_DELAY" default:"10s"`
+	// CrawlDelayOverrides are the crawl delays of hosts that are used instead of the ones their robots.txt ask for, e.g. status.example.com:5s
+	CrawlDelayOverrides map[string]time.Duration `envconfig:"CRAWL_DELAY_OVERRIDES"`
+	// CacheTTL is how long the robots.txt of a host is cached for
+	CacheTTL time.Duration `envconfig:"CACHE_TTL" default:"1h"`
+}
+
+// Transport checks that the robots.txt of the host allows a request before it is made, and waits for the crawl delay of
+// the host since the last request to it so that hosts aren't hammered when many of their status pages are scraped at once
+type Transport struct {
+	next      http.RoundTripper
+	config    Config
+	userAgent string
+	logger    *zap.Logger
+	// robotsClient fetches the robots.txt files with next, following redirects as described in section 2.3.1.2 of RFC 9309
+	robotsClient *http.Client
+	robots       *cache.Cache
+	// robotsFetches makes sure only one request for the robots.txt of a host is made at a time
+	robotsFetches sync.Map
+	mu            sync.Mutex
+	// nextRequest is the earliest time that the next request can be made to each host
+	nextRequest map[string]time.Time
+}
+
+// NewTransport returns a transport that makes the requests with next, following the robots.txt rules for the userAgent
+func NewTransport(next http.RoundTripper, config Config, userAgent string, logger *zap.Logger) *Transport {
+	return &Transport{
+		next:         next,
+		config:       config,
+		userAgent:    userAgent,
+		logger:       logger,
+		robotsClient: &http.Client{Transport: next},
+		robots:       cache.New(config.CacheTTL, config.CacheTTL),
+		nextRequest:  make(map[string]time.Time),
+	}
+}
+
+func (t *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	rules := t.getRules(request.Context(), request.URL)
+	path := request.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if request.URL.RawQuery != "" {
+		path += "?" + request.URL.RawQuery
+	}
+	if !rules.Allowed(path) {
+		return nil, errors.Wrapf(ErrDisallowed, "%s is", request.URL.String())
+	}
+	if err := t.wait(request.Context(), request.URL.Host, t.crawlDelay(request.URL.Hostname(), rules)); err != nil {
+		return nil, errors.Wrap(err, "request cancelled while waiting for the crawl delay")
+	}
+	return t.next.RoundTrip(request)
+}
+
+// crawlDelay returns the delay between requests to the host, an override for the host takes precedence over its robots.txt
+func (t *Transport) crawlDelay(hostname string, rules *Rules) time.Duration {
+	if delay, ok := t.config.CrawlDelayOverrides[strings.ToLower(hostname)]; ok {
+		return delay
+	}
+	if rules.CrawlDelay > 0 {
+		return min(rules.CrawlDelay, t.config.MaxCrawlDelay)
+	}
+	return t.config.DefaultCrawlDelay
+}
+
+// wait waits until a request can be made to the host and reserves the next slot after it for the next request
+func (t *Transport) wait(ctx context.Context, host string, delay time.Duration) error {
+	t.mu.Lock()
+	now := time.Now()
+	slot := t.nextRequest[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	t.nextRequest[host] = slot.Add(delay)
+	t.mu.Unlock()
+
+	waitFor := slot.Sub(now)
+	if waitFor <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(waitFor)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// getRules returns the rules of the robots.txt of the host of the url, fetching it if it isn't cached
+func (t *Transport) getRules(ctx context.Context, requestUrl *url.URL) *Rules {
+	origin := requestUrl.Scheme + "://" + requestUrl.Host
+	if rules, found := t.robots.Get(origin); found {
+		return rules.(*Rules)
+	}
+
+	lock, _ := t.robotsFetches.LoadOrStore(origin, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+	if rules, found := t.robots.Get(origin); found {
+		return rules.(*Rules)
+	}
+
+	rules, err := t.fetchRules(ctx, requestUrl, origin)
+	if err != nil {
+		// Status pages are most useful while their hosts are having problems, so a robots.txt that can't be fetched doesn't stop them being scraped
+		// The result isn't cached so that the robots.txt is fetched again by the next request
+		t.logger.Debug("failed to fetch robots.txt, allowing every path", zap.String("origin", origin), zap.Error(err))
+		return AllowAll
+	}
+	t.robots.Set(origin, rules, cache.DefaultExpiration)
+	return rules
+}
+
+// fetchRules fetches and parses the robots.txt of the origin
+// A host without a robots.txt, or that doesn't allow it to be read, allows every path, as described in section 2.3.1.3 of RFC 9309
+func (t *Transport) fetchRules(ctx context.Context, requestUrl *url.URL, origin string) (*Rules, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return nil, err
+	}
+	// The robots.txt is a request to the host too, so it waits for the crawl delay that applies before the robots.txt is known
+	if err := t.wait(ctx, requestUrl.Host, t.crawlDelay(requestUrl.Hostname(), AllowAll)); err != nil {
+		return nil, err
+	}
+	response, err := t.robotsClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	switch {
+	case response.StatusCode >= http.StatusInternalServerError:
+		return nil, errors.Errorf("robots.txt responded with %s", response.Status)
+	case response.StatusCode >= http.StatusBadRequest:
+		return AllowAll, nil
+	}
+	// Section 2.5 of RFC 9309 allows only the first 500 KiB to be parsed
+	return Parse(io.LimitReader(response.Body, 500*1024), t.userAgent)
+}
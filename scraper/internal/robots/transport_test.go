@@ -0,0 +1,162 @@
+package robots
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFixtureServer serves the fixture robots.txt and a 200 for every other path
+func newFixtureServer(t *testing.T) (*httptest.Server, *atomic.Int32, *atomic.Int32) {
+	t.Helper()
+	robotsTxt, err := os.ReadFile("testdata/robots.txt")
+	if err != nil {
+		t.Fatalf("failed to read the fixture: %v", err)
+	}
+	var robotsRequests, pageRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsRequests.Add(1)
+			_, _ = w.Write(robotsTxt)
+			return
+		}
+		pageRequests.Add(1)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &robotsRequests, &pageRequests
+}
+
+func newTestClient(config Config) *http.Client {
+	return &http.Client{Transport: NewTransport(http.DefaultTransport, config, "Statusphere", zap.NewNop())}
+}
+
+func TestTransportSkipsDisallowedPaths(t *testing.T) {
+	server, robotsRequests, pageRequests := newFixtureServer(t)
+	client := newTestClient(Config{CacheTTL: time.Hour})
+
+	for _, path := range []string{"/", "/history?page=1", "/private/feed.atom"} {
+		response, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", path, err)
+		}
+		response.Body.Close()
+	}
+	for _, path := range []string{"/history", "/private/incidents"} {
+		if _, err := client.Get(server.URL + path); !errors.Is(err, ErrDisallowed) {
+			t.Fatalf("%s: expected ErrDisallowed, got %v", path, err)
+		}
+	}
+	if pageRequests.Load() != 3 {
+		t.Fatalf("expected only the allowed paths to be requested, got %d requests", pageRequests.Load())
+	}
+	if robotsRequests.Load() != 1 {
+		t.Fatalf("expected robots.txt to be fetched once and cached, got %d requests", robotsRequests.Load())
+	}
+}
+
+func TestTransportAllowsEverythingWithoutRobotsTxt(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	response, err := newTestClient(Config{CacheTTL: time.Hour}).Get(server.URL + "/history")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+}
+
+func TestTransportAllowsEverythingWhenRobotsTxtFails(t *testing.T) {
+	var robotsRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsRequests.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := newTestClient(Config{CacheTTL: time.Hour})
+	for i := 0; i < 2; i++ {
+		response, err := client.Get(server.URL + "/history")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		response.Body.Close()
+	}
+	if robotsRequests.Load() != 2 {
+		t.Fatalf("expected a robots.txt that failed not to be cached, got %d requests", robotsRequests.Load())
+	}
+}
+
+func TestTransportWaitsForTheCrawlDelay(t *testing.T) {
+	server, _, _ := newFixtureServer(t)
+	host := mustParse(t, server.URL).Hostname()
+	tests := []struct {
+		name     string
+		config   Config
+		expected time.Duration
+	}{
+		{name: "robots.txt crawl delay", config: Config{CacheTTL: time.Hour, DefaultCrawlDelay: time.Millisecond, MaxCrawlDelay: time.Second}, expected: 500 * time.Millisecond},
+		{name: "capped crawl delay", config: Config{CacheTTL: time.Hour, DefaultCrawlDelay: time.Millisecond, MaxCrawlDelay: 100 * time.Millisecond}, expected: 100 * time.Millisecond},
+		{name: "override", config: Config{CacheTTL: time.Hour, MaxCrawlDelay: time.Second, CrawlDelayOverrides: map[string]time.Duration{host: 50 * time.Millisecond}}, expected: 50 * time.Millisecond},
+	}
+	for _, test := range tests {
+		client := newTestClient(test.config)
+		// The first request fetches robots.txt, so only the requests after it are timed
+		response, err := client.Get(server.URL + "/")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		response.Body.Close()
+
+		start := time.Now()
+		for i := 0; i < 2; i++ {
+			response, err := client.Get(server.URL + "/")
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", test.name, err)
+			}
+			response.Body.Close()
+		}
+		if elapsed := time.Since(start); elapsed < test.expected || elapsed > 4*test.expected {
+			t.Fatalf("%s: expected two requests to take about %s, took %s", test.name, 2*test.expected, elapsed)
+		}
+	}
+}
+
+func TestTransportStopsWaitingWhenTheRequestIsCancelled(t *testing.T) {
+	server, _, _ := newFixtureServer(t)
+	client := newTestClient(Config{CacheTTL: time.Hour, CrawlDelayOverrides: map[string]time.Duration{mustParse(t, server.URL).Hostname(): time.Hour}})
+
+	// The robots.txt is fetched straight away, then the page has to wait for the crawl delay after it
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/", nil)
+	if _, err := client.Do(request); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the request to be cancelled while waiting, got %v", err)
+	}
+}
+
+func mustParse(t *testing.T, rawUrl string) *url.URL {
+	t.Helper()
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		t.Fatalf("failed to parse %s: %v", rawUrl, err)
+	}
+	return parsed
+}
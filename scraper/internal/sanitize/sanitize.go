@@ -0,0 +1,221 @@
+package sanitize
+
+import (
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/pkg/errors"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Format is the format that the descriptions of the incidents are stored in
+type Format string
+
+const (
+	// FormatRaw stores the descriptions as the status page returned them
+	FormatRaw Format = "raw"
+	// FormatText strips the html of the descriptions, keeping the urls of the links after their text
+	FormatText Format = "text"
+	// FormatHtml keeps only a safe subset of html in the descriptions, the tags that format text and links without scripts
+	FormatHtml Format = "html"
+)
+
+type Config struct {
+	// Format is how the descriptions of the incidents and their events are sanitized before they're stored, it is raw, text or html
+	Format Format `envconfig:"FORMAT" default:"raw"`
+}
+
+// Sanitizer sanitizes the descriptions of the incidents, as some status pages return html in them which breaks clients expecting plain text
+type Sanitizer struct {
+	format Format
+}
+
+func NewSanitizer(config Config) (*Sanitizer, error) {
+	switch config.Format {
+	case FormatRaw, FormatText, FormatHtml:
+		return &Sanitizer{format: config.Format}, nil
+	case "":
+		return &Sanitizer{format: FormatRaw}, nil
+	}
+	return nil, errors.Errorf("the description format must be raw, text or html, got %s", config.Format)
+}
+
+// Sanitize returns the incidents with their descriptions and the descriptions of their events sanitized
+func (s *Sanitizer) Sanitize(incidents []api.Incident) []api.Incident {
+	if s.format == FormatRaw {
+		return incidents
+	}
+	sanitized := make([]api.Incident, 0, len(incidents))
+	for _, incident := range incidents {
+		if incident.Description != nil {
+			description := s.Description(*incident.Description)
+			incident.Description = &description
+		}
+		if incident.Events != nil {
+			// The events are copied so that the events of the incidents that were passed in aren't changed
+			events := make(api.IncidentEventArray, 0, len(incident.Events))
+			for _, event := range incident.Events {
+				event.Description = s.Description(event.Description)
+				events = append(events, event)
+			}
+			incident.Events = events
+		}
+		sanitized = append(sanitized, incident)
+	}
+	return sanitized
+}
+
+// markup matches the start of an html tag, comment or entity, descriptions without any are plain text and aren't changed
+// so that their line breaks and spacing are kept
+var markup = regexp.MustCompile(`<[a-zA-Z/!]|&(#[0-9]+|#x[0-9a-fA-F]+|[a-zA-Z]+);`)
+
+// Description returns the description sanitized to the format of the sanitizer
+func (s *Sanitizer) Description(description string) string {
+	if s.format == FormatRaw || !markup.MatchString(description) {
+		return description
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(description), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		// The html parser accepts any input, so this only happens if reading the string fails
+		return description
+	}
+	var builder strings.Builder
+	for _, node := range nodes {
+		if s.format == FormatText {
+			writeText(&builder, node)
+		} else {
+			writeSafeHtml(&builder, node)
+		}
+	}
+	if s.format == FormatText {
+		return tidyText(builder.String())
+	}
+	return strings.TrimSpace(builder.String())
+}
+
+// droppedElements are the elements whose contents are never part of the description
+var droppedElements = map[atom.Atom]bool{atom.Script: true, atom.Style: true, atom.Head: true, atom.Title: true, atom.Iframe: true, atom.Object: true, atom.Template: true}
+
+// blockElements are the elements that are written as their own paragraphs when the html is stripped
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Table: true, atom.Blockquote: true, atom.Pre: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true, atom.Hr: true,
+}
+
+// lineElements are the elements that are written on their own lines when the html is stripped, without a blank line between them
+var lineElements = map[atom.Atom]bool{atom.Li: true, atom.Tr: true}
+
+// writeText writes the text of the node, links are written as their text followed by their url in brackets
+func writeText(builder *strings.Builder, node *html.Node) {
+	switch node.Type {
+	case html.TextNode:
+		builder.WriteString(node.Data)
+		return
+	case html.ElementNode, html.DocumentNode:
+	default:
+		return
+	}
+	if droppedElements[node.DataAtom] {
+		return
+	}
+	if node.DataAtom == atom.Br {
+		builder.WriteString("\n")
+		return
+	}
+	if blockElements[node.DataAtom] || lineElements[node.DataAtom] {
+		builder.WriteString("\n")
+	}
+	if node.DataAtom == atom.Li {
+		builder.WriteString("- ")
+	}
+	start := builder.Len()
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		writeText(builder, child)
+	}
+	if node.DataAtom == atom.A {
+		// The url is only added if it isn't already the text of the link, e.g. <a href="https://example.com">https://example.com</a>
+		if href, ok := safeHref(node); ok && strings.TrimSpace(builder.String()[start:]) != href {
+			builder.WriteString(" (" + href + ")")
+		}
+	}
+	if blockElements[node.DataAtom] || node.DataAtom == atom.Ul || node.DataAtom == atom.Ol {
+		builder.WriteString("\n")
+	}
+}
+
+var (
+	spaces       = regexp.MustCompile(`[ \t\r\f\v]+`)
+	blankLines   = regexp.MustCompile(`\n{3,}`)
+	spacedBreaks = regexp.MustCompile(` *\n *`)
+)
+
+// tidyText collapses the whitespace that the html had for its layout, keeping at most one blank line between paragraphs
+func tidyText(text string) string {
+	text = strings.ReplaceAll(text, "\u00a0", " ")
+	text = spaces.ReplaceAllString(text, " ")
+	text = spacedBreaks.ReplaceAllString(text, "\n")
+	text = blankLines.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// safeElements are the elements that are kept when the html is sanitized, other elements are removed but their contents are kept
+var safeElements = map[atom.Atom]bool{
+	atom.A: true, atom.B: true, atom.Strong: true, atom.I: true, atom.Em: true, atom.U: true, atom.Code: true, atom.Pre: true,
+	atom.P: true, atom.Br: true, atom.Ul: true, atom.Ol: true, atom.Li: true, atom.Blockquote: true,
+}
+
+// writeSafeHtml writes the node with only the safe elements, without any of their attributes apart from the urls of links
+func writeSafeHtml(builder *strings.Builder, node *html.Node) {
+	switch node.Type {
+	case html.TextNode:
+		builder.WriteString(html.EscapeString(node.Data))
+		return
+	case html.ElementNode, html.DocumentNode:
+	default:
+		return
+	}
+	if droppedElements[node.DataAtom] {
+		return
+	}
+	safe := safeElements[node.DataAtom]
+	if safe {
+		builder.WriteString("<" + node.Data)
+		if node.DataAtom == atom.A {
+			if href, ok := safeHref(node); ok {
+				builder.WriteString(` href="` + html.EscapeString(href) + `" rel="nofollow noopener"`)
+			}
+		}
+		builder.WriteString(">")
+	}
+	if node.DataAtom == atom.Br {
+		return
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		writeSafeHtml(builder, child)
+	}
+	if safe {
+		builder.WriteString("</" + node.Data + ">")
+	}
+}
+
+// safeHref returns the url of the link if it is an absolute http(s) or mailto url, so that links can't run scripts
+func safeHref(node *html.Node) (string, bool) {
+	for _, attribute := range node.Attr {
+		if attribute.Namespace != "" || attribute.Key != "href" {
+			continue
+		}
+		href := strings.TrimSpace(attribute.Val)
+		parsed, err := url.Parse(href)
+		if err != nil {
+			return "", false
+		}
+		switch strings.ToLower(parsed.Scheme) {
+		case "http", "https", "mailto":
+			return href, true
+		}
+		return "", false
+	}
+	return "", false
+}
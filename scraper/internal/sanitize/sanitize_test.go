@@ -0,0 +1,82 @@
+package sanitize
+
+import (
+	"github.com/metoro-io/statusphere/common/api"
+	"testing"
+	"time"
+)
+
+func TestDescriptionsAreStrippedToText(t *testing.T) {
+	sanitizer, err := NewSanitizer(Config{Format: FormatText})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		description string
+		expected    string
+	}{
+		{description: "Plain text\n\nwith  its spacing", expected: "Plain text\n\nwith  its spacing"},
+		{description: "a < b and c > d", expected: "a < b and c > d"},
+		{description: "<p>We are <strong>investigating</strong> elevated errors.</p><p>Updates to follow.</p>", expected: "We are investigating elevated errors.\n\nUpdates to follow."},
+		{description: "See <a href=\"https://status.example.com/incidents/1\">the incident</a> for details", expected: "See the incident (https://status.example.com/incidents/1) for details"},
+		{description: "<a href=\"https://example.com\">https://example.com</a>", expected: "https://example.com"},
+		{description: "<a href=\"javascript:alert(1)\">click</a>", expected: "click"},
+		{description: "Affected:<ul><li>API</li><li>Dashboard</li></ul>", expected: "Affected:\n- API\n- Dashboard"},
+		{description: "line one<br>line two<br/>line three", expected: "line one\nline two\nline three"},
+		{description: "<script>alert(1)</script><style>p {}</style>Resolved &amp; monitoring&nbsp;now", expected: "Resolved & monitoring now"},
+		{description: "<div><p>Unclosed <b>tags", expected: "Unclosed tags"},
+		{description: "<!-- internal note -->Fixed", expected: "Fixed"},
+	}
+	for _, test := range tests {
+		if actual := sanitizer.Description(test.description); actual != test.expected {
+			t.Fatalf("expected %q to be stripped to %q, got %q", test.description, test.expected, actual)
+		}
+	}
+}
+
+func TestDescriptionsAreSanitizedToSafeHtml(t *testing.T) {
+	sanitizer, err := NewSanitizer(Config{Format: FormatHtml})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		description string
+		expected    string
+	}{
+		{description: "<p onclick=\"steal()\">We are <strong>investigating</strong></p>", expected: "<p>We are <strong>investigating</strong></p>"},
+		{description: "<a href=\"https://example.com/?a=1&b=2\" target=\"_blank\">details</a>", expected: "<a href=\"https://example.com/?a=1&amp;b=2\" rel=\"nofollow noopener\">details</a>"},
+		{description: "<a href=\"javascript:alert(1)\">click</a>", expected: "<a>click</a>"},
+		{description: "<script>alert(1)</script><img src=x onerror=alert(1)>Fixed<br>", expected: "Fixed<br>"},
+		{description: "<span style=\"color: red\">a &lt; b</span>", expected: "a &lt; b"},
+		{description: "<ul><li>API<li>Dashboard</ul>", expected: "<ul><li>API</li><li>Dashboard</li></ul>"},
+	}
+	for _, test := range tests {
+		if actual := sanitizer.Description(test.description); actual != test.expected {
+			t.Fatalf("expected %q to be sanitized to %q, got %q", test.description, test.expected, actual)
+		}
+	}
+}
+
+func TestSanitizeDoesNotChangeTheIncidentsPassedIn(t *testing.T) {
+	sanitizer, err := NewSanitizer(Config{Format: FormatText})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	description := "<p>Outage</p>"
+	events := []api.IncidentEvent{api.NewIncidentEvent("Investigating", "<b>Looking</b> into it", time.Now())}
+	incident := api.NewIncident("Outage", nil, events, time.Now(), nil, &description, "https://status.example.com/incidents/1", api.ImpactMajor, "https://status.example.com")
+
+	sanitized := sanitizer.Sanitize([]api.Incident{incident})
+	if *sanitized[0].Description != "Outage" || sanitized[0].Events[0].Description != "Looking into it" {
+		t.Fatalf("unexpected sanitized incident: %+v", sanitized[0])
+	}
+	if *incident.Description != "<p>Outage</p>" || incident.Events[0].Description != "<b>Looking</b> into it" {
+		t.Fatalf("expected the incident passed in not to change, got %+v", incident)
+	}
+}
+
+func TestInvalidFormatsAreRejected(t *testing.T) {
+	if _, err := NewSanitizer(Config{Format: "markdown"}); err == nil {
+		t.Fatalf("expected the markdown format to be rejected")
+	}
+}
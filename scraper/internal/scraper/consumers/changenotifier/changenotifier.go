@@ -0,0 +1,42 @@
+package changenotifier
+
+import (
+	"context"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper/consumers"
+	"go.uber.org/zap"
+	"time"
+)
+
+// notifyTimeout is how long a notification is given to be sent, so that a slow database doesn't hold up the scrape
+const notifyTimeout = 5 * time.Second
+
+// Notifier sends the notification that the incidents of a status page changed, it is implemented by db.DbClient
+type Notifier interface {
+	NotifyIncidentChanges(ctx context.Context, statusPageUrl string) error
+}
+
+// ChangeNotifier notifies the api servers of the status pages whose incidents a current scrape changed,
+// so that the requests waiting for the incidents of a status page to change return straight away
+type ChangeNotifier struct {
+	logger   *zap.Logger
+	notifier Notifier
+}
+
+func NewChangeNotifier(logger *zap.Logger, notifier Notifier) *ChangeNotifier {
+	return &ChangeNotifier{logger: logger, notifier: notifier}
+}
+
+var _ consumers.IncidentChangeHandler = &ChangeNotifier{}
+
+// HandleIncidentChanges sends a notification for the status page if the scrape created, updated or resolved any of its incidents
+// Failing to send it is only logged, as the waiting requests return once they time out anyway
+func (n *ChangeNotifier) HandleIncidentChanges(statusPageUrl string, result consumers.ScrapeResult) {
+	if result.IsEmpty() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	if err := n.notifier.NotifyIncidentChanges(ctx, statusPageUrl); err != nil {
+		n.logger.Warn("failed to notify the incident changes", zap.String("url", statusPageUrl), zap.Error(err))
+	}
+}
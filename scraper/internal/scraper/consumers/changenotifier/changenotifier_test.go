@@ -0,0 +1,41 @@
+package changenotifier
+
+import (
+	"context"
+	"errors"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper/consumers"
+	"go.uber.org/zap"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeNotifier struct {
+	notified []string
+	err      error
+}
+
+func (f *fakeNotifier) NotifyIncidentChanges(ctx context.Context, statusPageUrl string) error {
+	f.notified = append(f.notified, statusPageUrl)
+	return f.err
+}
+
+func TestChangeNotifierOnlyNotifiesChangedStatusPages(t *testing.T) {
+	notifier := &fakeNotifier{}
+	changeNotifier := NewChangeNotifier(zap.NewNop(), notifier)
+	incident := api.NewIncident("Outage", nil, nil, time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC), nil, nil, "https://status.changed.com/incidents/1", api.ImpactMajor, "https://status.changed.com")
+
+	changeNotifier.HandleIncidentChanges("https://status.unchanged.com", consumers.ScrapeResult{})
+	changeNotifier.HandleIncidentChanges("https://status.changed.com", consumers.ScrapeResult{New: []api.Incident{incident}})
+	if expected := []string{"https://status.changed.com"}; !reflect.DeepEqual(notifier.notified, expected) {
+		t.Fatalf("expected %v to be notified, got %v", expected, notifier.notified)
+	}
+
+	// Failing to notify doesn't panic or retry
+	notifier.err = errors.New("database unavailable")
+	changeNotifier.HandleIncidentChanges("https://status.changed.com", consumers.ScrapeResult{Resolved: []api.Incident{incident}})
+	if len(notifier.notified) != 2 {
+		t.Fatalf("expected a single attempt to notify, got %v", notifier.notified)
+	}
+}
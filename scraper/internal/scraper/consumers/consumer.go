@@ -0,0 +1,14 @@
+package consumers
+
+import (
+	"github.com/metoro-io/statusphere/common/api"
+)
+
+type Consumer interface {
+	// Consume consumes the given incidents
+	Consume(incidents []api.Incident) error
+
+	// ConsumeCurrent consumes the incidents found by a current scrape of the status page
+	// A current scrape finds all the recent incidents of the status page, so the recent incidents missing from it have been removed from the status page
+	ConsumeCurrent(statusPageUrl string, incidents []api.Incident) error
+}
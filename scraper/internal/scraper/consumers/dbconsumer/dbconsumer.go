@@ -0,0 +1,104 @@
+package dbconsumer
+
+import (
+	"context"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/db"
+	"github.com/metoro-io/statusphere/scraper/internal/metrics"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper/consumers"
+	"go.uber.org/zap"
+	"time"
+)
+
+type DbConsumer struct {
+	logger         *zap.Logger
+	dbClient       *db.DbClient
+	changeHandlers []consumers.IncidentChangeHandler
+}
+
+// NewDbConsumer creates a consumer that stores the incidents, the change handlers are notified of the incidents that each current scrape changed
+func NewDbConsumer(logger *zap.Logger, client *db.DbClient, changeHandlers ...consumers.IncidentChangeHandler) *DbConsumer {
+	return &DbConsumer{
+		logger:         logger,
+		dbClient:       client,
+		changeHandlers: changeHandlers,
+	}
+}
+
+func (s *DbConsumer) Consume(incidents []api.Incident) error {
+	err := s.dbClient.CreateOrUpdateIncidents(context.Background(), incidents)
+	if err != nil {
+		s.logger.Error("failed to create or update incidents", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// ConsumeCurrent stores the incidents, then marks the incidents of the status page that are missing from them as deleted
+// Only the incidents that started within the window of time the scrape covered are marked, see currentScrapeWindowStart
+// The impact history of the incidents is updated with the impacts that changed since they were stored, see consumers.WithImpactHistory
+// The incidents that the scrape created, updated or resolved are logged and passed to the change handlers once they're stored
+func (s *DbConsumer) ConsumeCurrent(statusPageUrl string, incidents []api.Incident) error {
+	stored, err := s.dbClient.GetIncidents(context.Background(), statusPageUrl)
+	if err != nil {
+		s.logger.Error("failed to get the stored incidents", zap.Error(err), zap.String("url", statusPageUrl))
+		return err
+	}
+	incidents = consumers.WithImpactHistory(stored, incidents, time.Now())
+	err = s.Consume(incidents)
+	if err != nil {
+		return err
+	}
+	s.handleIncidentChanges(statusPageUrl, consumers.DiffIncidents(stored, incidents))
+	since, ok := currentScrapeWindowStart(incidents)
+	if !ok {
+		return nil
+	}
+	deleted, err := s.dbClient.MarkMissingIncidentsDeleted(context.Background(), statusPageUrl, since, incidents)
+	if err != nil {
+		s.logger.Error("failed to mark missing incidents as deleted", zap.Error(err), zap.String("url", statusPageUrl))
+		return err
+	}
+	if deleted > 0 {
+		s.logger.Info("marked incidents removed from the status page as deleted", zap.String("url", statusPageUrl), zap.Int64("count", deleted))
+	}
+	return nil
+}
+
+// currentScrapeWindowStart returns the start of the window of time that a current scrape found all the incidents of, which is
+// the start time of the oldest incident that has ended. Ongoing incidents are found however long ago they started, so they don't widen the window.
+// If the scrape found no ended incidents then it returns false, as a scrape that found nothing is more likely to have failed to parse
+// the status page than the status page to have removed all its incidents
+func currentScrapeWindowStart(incidents []api.Incident) (time.Time, bool) {
+	var since time.Time
+	found := false
+	for _, incident := range incidents {
+		if incident.IsOngoing() {
+			continue
+		}
+		if !found || incident.StartTime.Before(since) {
+			since = incident.StartTime
+			found = true
+		}
+	}
+	return since, found
+}
+
+func (s *DbConsumer) handleIncidentChanges(statusPageUrl string, result consumers.ScrapeResult) {
+	if result.IsEmpty() {
+		return
+	}
+	for _, incident := range result.New {
+		metrics.RecordIncidentDiscovered(string(incident.Impact))
+		s.logger.Info("incident created", zap.String("url", statusPageUrl), zap.String("deepLink", incident.DeepLink), zap.String("impact", string(incident.Impact)))
+	}
+	for _, update := range result.Updated {
+		s.logger.Info("incident updated", zap.String("url", statusPageUrl), zap.String("deepLink", update.Current.DeepLink), zap.String("previousImpact", string(update.Previous.Impact)), zap.String("impact", string(update.Current.Impact)), zap.Int("newEvents", len(update.NewEvents)))
+	}
+	for _, incident := range result.Resolved {
+		s.logger.Info("incident resolved", zap.String("url", statusPageUrl), zap.String("deepLink", incident.DeepLink), zap.String("impact", string(incident.Impact)))
+	}
+	for _, handler := range s.changeHandlers {
+		handler.HandleIncidentChanges(statusPageUrl, result)
+	}
+}
@@ -0,0 +1,30 @@
+package dbconsumer
+
+import (
+	"github.com/metoro-io/statusphere/common/api"
+	"testing"
+	"time"
+)
+
+func TestCurrentScrapeWindowStart(t *testing.T) {
+	statusPageUrl := "https://status.example.com"
+	now := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	ended := now.Add(-time.Hour)
+	resolved := func(startTime time.Time) api.Incident {
+		return api.NewIncident("Resolved", nil, nil, startTime, &ended, nil, "", api.ImpactMinor, statusPageUrl)
+	}
+	ongoing := func(startTime time.Time) api.Incident {
+		return api.NewIncident("Ongoing", nil, nil, startTime, nil, nil, "", api.ImpactMinor, statusPageUrl)
+	}
+
+	since, ok := currentScrapeWindowStart([]api.Incident{resolved(now.Add(-2 * time.Hour)), resolved(now.Add(-48 * time.Hour)), ongoing(now.Add(-365 * 24 * time.Hour))})
+	if !ok || !since.Equal(now.Add(-48*time.Hour)) {
+		t.Fatalf("expected the window to start at the oldest resolved incident, got %v", since)
+	}
+	if _, ok := currentScrapeWindowStart([]api.Incident{ongoing(now)}); ok {
+		t.Fatalf("expected no window when the scrape found no resolved incidents")
+	}
+	if _, ok := currentScrapeWindowStart(nil); ok {
+		t.Fatalf("expected no window when the scrape found no incidents")
+	}
+}
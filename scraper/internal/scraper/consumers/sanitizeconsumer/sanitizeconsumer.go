@@ -0,0 +1,25 @@
+package sanitizeconsumer
+
+import (
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/scraper/internal/sanitize"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper/consumers"
+)
+
+// SanitizeConsumer sanitizes the descriptions of the incidents before they are consumed by the next consumer, see sanitize.Sanitizer
+type SanitizeConsumer struct {
+	next      consumers.Consumer
+	sanitizer *sanitize.Sanitizer
+}
+
+func NewSanitizeConsumer(next consumers.Consumer, sanitizer *sanitize.Sanitizer) *SanitizeConsumer {
+	return &SanitizeConsumer{next: next, sanitizer: sanitizer}
+}
+
+func (s *SanitizeConsumer) Consume(incidents []api.Incident) error {
+	return s.next.Consume(s.sanitizer.Sanitize(incidents))
+}
+
+func (s *SanitizeConsumer) ConsumeCurrent(statusPageUrl string, incidents []api.Incident) error {
+	return s.next.ConsumeCurrent(statusPageUrl, s.sanitizer.Sanitize(incidents))
+}
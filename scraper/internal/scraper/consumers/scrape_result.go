@@ -0,0 +1,133 @@
+package consumers
+
+import (
+	"github.com/metoro-io/statusphere/common/api"
+	"time"
+)
+
+// ScrapeResult is how the incidents of a status page changed between what was stored and what the latest scrape found
+type ScrapeResult struct {
+	// New are the incidents that weren't stored before the scrape
+	New []api.Incident
+	// Updated are the incidents whose impact changed or that had updates posted to them, without being resolved
+	Updated []IncidentUpdate
+	// Resolved are the incidents that were ongoing before the scrape and have ended since
+	Resolved []api.Incident
+}
+
+// IsEmpty returns true if the scrape didn't change any incidents
+func (r ScrapeResult) IsEmpty() bool {
+	return len(r.New) == 0 && len(r.Updated) == 0 && len(r.Resolved) == 0
+}
+
+type IncidentUpdate struct {
+	Previous api.Incident
+	Current  api.Incident
+	// NewEvents are the updates posted to the incident since it was stored
+	NewEvents []api.IncidentEvent
+}
+
+// ImpactChanged returns true if the impact of the incident is different to the one that was stored
+func (u IncidentUpdate) ImpactChanged() bool {
+	return u.Previous.Impact != u.Current.Impact
+}
+
+// IncidentChangeHandler is notified of the incidents that a current scrape of a status page changed, e.g. to send webhooks or record metrics
+type IncidentChangeHandler interface {
+	HandleIncidentChanges(statusPageUrl string, result ScrapeResult)
+}
+
+// DiffIncidents compares the incidents that a scrape found to the incidents that were stored before it
+// Incidents are matched by their dedup key, see api.IncidentDedupKey, the stored incidents that the scrape didn't find are ignored
+// Stored incidents that were keyed before the provider incident id was scraped are matched by the key without it
+func DiffIncidents(stored []api.Incident, scraped []api.Incident) ScrapeResult {
+	findStored := storedIncidentFinder(stored)
+	var result ScrapeResult
+	for _, incident := range scraped {
+		previous, ok := findStored(incident)
+		if !ok {
+			result.New = append(result.New, incident)
+			continue
+		}
+		if previous.IsOngoing() && !incident.IsOngoing() {
+			result.Resolved = append(result.Resolved, incident)
+			continue
+		}
+		update := IncidentUpdate{Previous: previous, Current: incident, NewEvents: newEvents(previous.Events, incident.Events)}
+		if update.ImpactChanged() || len(update.NewEvents) > 0 {
+			result.Updated = append(result.Updated, update)
+		}
+	}
+	return result
+}
+
+// WithImpactHistory returns copies of the scraped incidents with their impact history, see api.Incident.ImpactHistory
+// The history of an incident that was stored is continued, with the impact it changed to at now added if its impact changed
+// Incidents are matched as they are by DiffIncidents, the history of new incidents and of stored incidents without one starts
+// with the impact they had at their start time
+func WithImpactHistory(stored []api.Incident, scraped []api.Incident, now time.Time) []api.Incident {
+	findStored := storedIncidentFinder(stored)
+	withHistory := make([]api.Incident, 0, len(scraped))
+	for _, incident := range scraped {
+		previous, ok := findStored(incident)
+		if !ok {
+			incident.ImpactHistory = api.ImpactChangeArray{{Impact: incident.Impact, Time: incident.StartTime}}
+			withHistory = append(withHistory, incident)
+			continue
+		}
+		// The stored history is copied so that appending to it doesn't change the stored incident
+		history := append(api.ImpactChangeArray{}, previous.ImpactHistory...)
+		if len(history) == 0 {
+			history = api.ImpactChangeArray{{Impact: previous.Impact, Time: previous.StartTime}}
+		}
+		if previous.Impact != incident.Impact {
+			history = append(history, api.ImpactChange{Impact: incident.Impact, Time: now})
+		}
+		incident.ImpactHistory = history
+		withHistory = append(withHistory, incident)
+	}
+	return withHistory
+}
+
+// storedIncidentFinder returns a function that finds the stored incident that a scraped incident is, by their dedup keys
+// Stored incidents that were keyed before the provider incident id was scraped are found by the key without it
+func storedIncidentFinder(stored []api.Incident) func(scraped api.Incident) (api.Incident, bool) {
+	storedByDedupKey := make(map[string]api.Incident, len(stored))
+	for _, incident := range stored {
+		storedByDedupKey[dedupKey(incident)] = incident
+	}
+	return func(scraped api.Incident) (api.Incident, bool) {
+		previous, ok := storedByDedupKey[dedupKey(scraped)]
+		if !ok && scraped.ProviderIncidentID != "" {
+			previous, ok = storedByDedupKey[api.IncidentDedupKey(scraped.StatusPageUrl, "", scraped.DeepLink, scraped.Title, scraped.StartTime)]
+		}
+		return previous, ok
+	}
+}
+
+// dedupKey returns the dedup key of the incident, the stored incidents have it set but the scraped ones don't yet
+func dedupKey(incident api.Incident) string {
+	if incident.DedupKey != "" {
+		return incident.DedupKey
+	}
+	return api.IncidentDedupKey(incident.StatusPageUrl, incident.ProviderIncidentID, incident.DeepLink, incident.Title, incident.StartTime)
+}
+
+// newEvents returns the events that aren't in the previous events, events are the same if they have the same time and title
+func newEvents(previous []api.IncidentEvent, current []api.IncidentEvent) []api.IncidentEvent {
+	type eventKey struct {
+		title string
+		time  int64
+	}
+	seen := make(map[eventKey]bool, len(previous))
+	for _, event := range previous {
+		seen[eventKey{title: event.Title, time: event.Time.UnixNano()}] = true
+	}
+	var events []api.IncidentEvent
+	for _, event := range current {
+		if !seen[eventKey{title: event.Title, time: event.Time.UnixNano()}] {
+			events = append(events, event)
+		}
+	}
+	return events
+}
@@ -0,0 +1,154 @@
+package consumers
+
+import (
+	"github.com/metoro-io/statusphere/common/api"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testStatusPageUrl = "https://status.example.com"
+
+var testStart = time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+
+func newTestIncident(id string, impact api.Impact, events []api.IncidentEvent, endTime *time.Time) api.Incident {
+	return api.NewIncident(id, nil, events, testStart, endTime, nil, testStatusPageUrl+"/incidents/"+id, impact, testStatusPageUrl)
+}
+
+// stored returns the incident as it is read back from the database, with its dedup key set
+func stored(incident api.Incident) api.Incident {
+	incident.DedupKey = api.IncidentDedupKey(incident.StatusPageUrl, incident.ProviderIncidentID, incident.DeepLink, incident.Title, incident.StartTime)
+	return incident
+}
+
+func TestDiffIncidentsFindsNewIncidents(t *testing.T) {
+	existing := newTestIncident("existing", api.ImpactMinor, nil, nil)
+	created := newTestIncident("created", api.ImpactMajor, nil, nil)
+	withoutLink := api.NewIncident("No link", nil, nil, testStart, nil, nil, "", api.ImpactMinor, testStatusPageUrl)
+	storedWithoutLink := stored(withoutLink)
+	// Incidents without a deep link are stored with a synthesized one
+	storedWithoutLink.DeepLink = testStatusPageUrl + "#incident-1234"
+
+	result := DiffIncidents([]api.Incident{stored(existing), storedWithoutLink}, []api.Incident{existing, created, withoutLink})
+	if len(result.New) != 1 || result.New[0].DeepLink != created.DeepLink {
+		t.Fatalf("expected only the created incident to be new, got %+v", result.New)
+	}
+	if len(result.Updated) != 0 || len(result.Resolved) != 0 {
+		t.Fatalf("expected no updated or resolved incidents, got %+v", result)
+	}
+	if result := DiffIncidents(nil, []api.Incident{existing}); len(result.New) != 1 {
+		t.Fatalf("expected every incident to be new when nothing was stored, got %+v", result)
+	}
+}
+
+func TestDiffIncidentsFindsUpdatedIncidents(t *testing.T) {
+	investigating := api.NewIncidentEvent("Investigating", "We are looking into it", testStart)
+	identified := api.NewIncidentEvent("Identified", "We found the cause", testStart.Add(time.Hour))
+
+	previous := newTestIncident("a", api.ImpactMinor, []api.IncidentEvent{investigating}, nil)
+	escalated := newTestIncident("a", api.ImpactMajor, []api.IncidentEvent{investigating}, nil)
+	posted := newTestIncident("a", api.ImpactMinor, []api.IncidentEvent{identified, investigating}, nil)
+
+	result := DiffIncidents([]api.Incident{stored(previous)}, []api.Incident{escalated})
+	if len(result.Updated) != 1 || !result.Updated[0].ImpactChanged() || len(result.Updated[0].NewEvents) != 0 {
+		t.Fatalf("expected an impact change, got %+v", result.Updated)
+	}
+	result = DiffIncidents([]api.Incident{stored(previous)}, []api.Incident{posted})
+	if len(result.Updated) != 1 || result.Updated[0].ImpactChanged() || len(result.Updated[0].NewEvents) != 1 || result.Updated[0].NewEvents[0].Title != "Identified" {
+		t.Fatalf("expected a new update to be posted, got %+v", result.Updated)
+	}
+	if result := DiffIncidents([]api.Incident{stored(previous)}, []api.Incident{previous}); !result.IsEmpty() {
+		t.Fatalf("expected an unchanged incident not to be in the result, got %+v", result)
+	}
+}
+
+func TestDiffIncidentsFindsResolvedIncidents(t *testing.T) {
+	resolvedAt := testStart.Add(2 * time.Hour)
+	resolvedEvent := api.NewIncidentEvent("Resolved", "It is fixed", resolvedAt)
+	ongoing := newTestIncident("a", api.ImpactMajor, nil, nil)
+	resolved := newTestIncident("a", api.ImpactMajor, []api.IncidentEvent{resolvedEvent}, &resolvedAt)
+
+	result := DiffIncidents([]api.Incident{stored(ongoing)}, []api.Incident{resolved})
+	if len(result.Resolved) != 1 || result.Resolved[0].DeepLink != resolved.DeepLink {
+		t.Fatalf("expected the incident to be resolved, got %+v", result.Resolved)
+	}
+	if len(result.Updated) != 0 || len(result.New) != 0 {
+		t.Fatalf("expected a resolved incident not to also be updated, got %+v", result)
+	}
+	if result := DiffIncidents([]api.Incident{stored(resolved)}, []api.Incident{resolved}); !result.IsEmpty() {
+		t.Fatalf("expected an incident that was already resolved not to be resolved again, got %+v", result)
+	}
+}
+
+func TestDiffIncidentsMatchesIncidentsStoredBeforeTheirProviderIncidentId(t *testing.T) {
+	// The incident was stored before the scraper found its provider incident id, so it has the key without it
+	previous := stored(newTestIncident("a", api.ImpactMajor, nil, nil))
+	scraped := newTestIncident("a", api.ImpactMajor, nil, nil)
+	scraped.ProviderIncidentID = "a"
+
+	if result := DiffIncidents([]api.Incident{previous}, []api.Incident{scraped}); !result.IsEmpty() {
+		t.Fatalf("expected the incident to match the one stored without the provider incident id, got %+v", result)
+	}
+	if result := DiffIncidents([]api.Incident{stored(scraped)}, []api.Incident{scraped}); !result.IsEmpty() {
+		t.Fatalf("expected the incident to match the one stored with the provider incident id, got %+v", result)
+	}
+}
+
+// scrapeImpacts scrapes the incident with each of the impacts in turn an hour apart, storing the result of each scrape, and returns the stored incident
+func scrapeImpacts(incident api.Incident, impacts ...api.Impact) api.Incident {
+	var storedIncidents []api.Incident
+	for i, impact := range impacts {
+		incident.Impact = impact
+		scraped := WithImpactHistory(storedIncidents, []api.Incident{incident}, testStart.Add(time.Duration(i)*time.Hour))
+		storedIncidents = []api.Incident{stored(scraped[0])}
+	}
+	return storedIncidents[0]
+}
+
+func TestWithImpactHistoryRecordsEscalations(t *testing.T) {
+	incident := scrapeImpacts(newTestIncident("escalated", api.ImpactMinor, nil, nil), api.ImpactMinor, api.ImpactMajor, api.ImpactMajor, api.ImpactCritical)
+	expected := api.ImpactChangeArray{
+		{Impact: api.ImpactMinor, Time: testStart},
+		{Impact: api.ImpactMajor, Time: testStart.Add(time.Hour)},
+		{Impact: api.ImpactCritical, Time: testStart.Add(3 * time.Hour)},
+	}
+	if !reflect.DeepEqual(incident.ImpactHistory, expected) {
+		t.Fatalf("expected the history %+v, got %+v", expected, incident.ImpactHistory)
+	}
+	if incident.Impact != api.ImpactCritical {
+		t.Fatalf("expected the impact to be the latest one, got %s", incident.Impact)
+	}
+}
+
+func TestWithImpactHistoryRecordsDeescalations(t *testing.T) {
+	incident := scrapeImpacts(newTestIncident("deescalated", api.ImpactCritical, nil, nil), api.ImpactCritical, api.ImpactMinor, api.ImpactCritical, api.ImpactMinor)
+	expected := api.ImpactChangeArray{
+		{Impact: api.ImpactCritical, Time: testStart},
+		{Impact: api.ImpactMinor, Time: testStart.Add(time.Hour)},
+		{Impact: api.ImpactCritical, Time: testStart.Add(2 * time.Hour)},
+		{Impact: api.ImpactMinor, Time: testStart.Add(3 * time.Hour)},
+	}
+	if !reflect.DeepEqual(incident.ImpactHistory, expected) {
+		t.Fatalf("expected the history %+v, got %+v", expected, incident.ImpactHistory)
+	}
+}
+
+func TestWithImpactHistoryStartsTheHistoryOfIncidentsStoredWithoutOne(t *testing.T) {
+	previous := stored(newTestIncident("legacy", api.ImpactMinor, nil, nil))
+	scraped := newTestIncident("legacy", api.ImpactMajor, nil, nil)
+	now := testStart.Add(time.Hour)
+
+	incident := WithImpactHistory([]api.Incident{previous}, []api.Incident{scraped}, now)[0]
+	expected := api.ImpactChangeArray{{Impact: api.ImpactMinor, Time: testStart}, {Impact: api.ImpactMajor, Time: now}}
+	if !reflect.DeepEqual(incident.ImpactHistory, expected) {
+		t.Fatalf("expected the history %+v, got %+v", expected, incident.ImpactHistory)
+	}
+
+	// Appending to the history doesn't change the stored incident
+	previous.ImpactHistory = make(api.ImpactChangeArray, 1, 2)
+	previous.ImpactHistory[0] = api.ImpactChange{Impact: api.ImpactMinor, Time: testStart}
+	WithImpactHistory([]api.Incident{previous}, []api.Incident{scraped}, now)
+	if extended := previous.ImpactHistory[:2]; extended[1] != (api.ImpactChange{}) {
+		t.Fatalf("expected the stored history not to be modified, got %+v", extended)
+	}
+}
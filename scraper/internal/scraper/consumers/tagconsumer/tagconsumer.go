@@ -0,0 +1,25 @@
+package tagconsumer
+
+import (
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper/consumers"
+	"github.com/metoro-io/statusphere/scraper/internal/tags"
+)
+
+// TagConsumer tags the incidents before they are consumed by the next consumer, see tags.Tagger
+type TagConsumer struct {
+	next   consumers.Consumer
+	tagger *tags.Tagger
+}
+
+func NewTagConsumer(next consumers.Consumer, tagger *tags.Tagger) *TagConsumer {
+	return &TagConsumer{next: next, tagger: tagger}
+}
+
+func (s *TagConsumer) Consume(incidents []api.Incident) error {
+	return s.next.Consume(s.tagger.Tag(incidents))
+}
+
+func (s *TagConsumer) ConsumeCurrent(statusPageUrl string, incidents []api.Incident) error {
+	return s.next.ConsumeCurrent(statusPageUrl, s.tagger.Tag(incidents))
+}
@@ -0,0 +1,50 @@
+package scraper
+
+import (
+	"context"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper/providers"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// DryRunner scrapes status pages to show what would be extracted from them, without storing anything
+type DryRunner interface {
+	// DryRun detects the provider of the status page and scrapes it, the historical incidents are scraped if historical is true
+	// The name of the provider is returned when one detected the status page, even if it then failed to scrape it
+	DryRun(ctx context.Context, url string, historical bool) (string, []api.Incident, error)
+}
+
+// NewDryRunner returns a dry runner that scrapes the status pages with the providers
+// Unlike the scraper it doesn't store the validators or the snapshots of the status pages, so dry runs don't change the next scrape
+func NewDryRunner(logger *zap.Logger, httpClient *http.Client, providers *providers.Registry) DryRunner {
+	return &scraper{
+		logger:     logger,
+		httpClient: httpClient,
+		providers:  providers,
+	}
+}
+
+func (s *scraper) DryRun(ctx context.Context, url string, historical bool) (string, []api.Incident, error) {
+	ctx = utils.UpdateContextMdc(ctx, map[string]string{"url": url})
+	response, err := s.fetchStatusPage(ctx, url, pageValidators{})
+	if err != nil {
+		return "", nil, err
+	}
+	provider, err := s.detectProvider(url, response.Response)
+	if err != nil {
+		return "", nil, err
+	}
+	var incidents []api.Incident
+	if historical {
+		incidents, err = provider.ScrapeStatusPageHistorical(ctx, url)
+	} else {
+		incidents, err = provider.ScrapeStatusPageCurrent(ctx, url)
+	}
+	if err != nil {
+		return provider.Name(), nil, withClass(ErrorClassProvider, errors.Wrapf(err, "failed to scrape the status page using the %s provider", provider.Name()))
+	}
+	return provider.Name(), incidents, nil
+}
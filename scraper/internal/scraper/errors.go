@@ -0,0 +1,50 @@
+package scraper
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrorClass is the kind of failure of a scrape, it lets failures be monitored without matching on their messages
+type ErrorClass string
+
+const (
+	// ErrorClassRobotsDisallowed is the class of scrapes skipped because robots.txt disallows the status page
+	ErrorClassRobotsDisallowed ErrorClass = "robots_disallowed"
+	// ErrorClassUnreachable is the class of scrapes that couldn't reach the status page, e.g. because of a timeout
+	ErrorClassUnreachable ErrorClass = "unreachable"
+	// ErrorClassServerError is the class of scrapes where the status page responded with a server error
+	ErrorClassServerError ErrorClass = "server_error"
+	// ErrorClassUnsupported is the class of scrapes where none of the providers detected the status page
+	ErrorClassUnsupported ErrorClass = "unsupported"
+	// ErrorClassProvider is the class of scrapes where the provider failed to scrape the status page, e.g. because it couldn't be parsed
+	ErrorClassProvider ErrorClass = "provider"
+	// ErrorClassUnknown is the class of errors that weren't classified
+	ErrorClassUnknown ErrorClass = "unknown"
+)
+
+type classifiedError struct {
+	class ErrorClass
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+// withClass returns the error with the class, its message is unchanged
+func withClass(class ErrorClass, err error) error {
+	return &classifiedError{class: class, err: err}
+}
+
+// ClassOf returns the class of an error returned by the scraper, or ErrorClassUnknown if it wasn't classified
+func ClassOf(err error) ErrorClass {
+	var classified *classifiedError
+	if errors.As(err, &classified) {
+		return classified.class
+	}
+	return ErrorClassUnknown
+}
@@ -0,0 +1,103 @@
+package poller
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+type Config struct {
+	// Concurrency is the most status pages that are scraped at once
+	Concurrency int `envconfig:"CONCURRENCY" default:"32"`
+	// PerHostConcurrency is the most status pages of the same host that are scraped at once, so that a host with many status pages isn't overloaded
+	PerHostConcurrency int `envconfig:"PER_HOST_CONCURRENCY" default:"2"`
+}
+
+// WithDefaults returns the config with the limits that aren't set replaced by their defaults
+func (c Config) WithDefaults() Config {
+	if c.Concurrency <= 0 {
+		c.Concurrency = 32
+	}
+	if c.PerHostConcurrency <= 0 {
+		c.PerHostConcurrency = 2
+	}
+	return c
+}
+
+// limiter bounds how many scrapes run at once, both in total and for each host
+type limiter struct {
+	global  chan struct{}
+	perHost int
+	mu      sync.Mutex
+	hosts   map[string]*hostSlots
+}
+
+// hostSlots are the slots of one host, users counts the scrapes holding or waiting for one so that idle hosts can be forgotten
+type hostSlots struct {
+	slots chan struct{}
+	users int
+}
+
+func newLimiter(config Config) *limiter {
+	return &limiter{
+		global:  make(chan struct{}, config.Concurrency),
+		perHost: config.PerHostConcurrency,
+		hosts:   make(map[string]*hostSlots),
+	}
+}
+
+// acquire waits for a slot for a scrape of the status page, it returns a function that releases the slot
+// The slot of the host is acquired first, so that scrapes waiting for a busy host don't hold global slots that other hosts could use
+// It returns an error without a slot if the context is done while waiting
+func (l *limiter) acquire(ctx context.Context, statusPageUrl string) (func(), error) {
+	host := hostOf(statusPageUrl)
+	l.mu.Lock()
+	slots, found := l.hosts[host]
+	if !found {
+		slots = &hostSlots{slots: make(chan struct{}, l.perHost)}
+		l.hosts[host] = slots
+	}
+	slots.users++
+	l.mu.Unlock()
+
+	releaseHost := func() {
+		<-slots.slots
+		l.forget(host, slots)
+	}
+	select {
+	case slots.slots <- struct{}{}:
+	case <-ctx.Done():
+		l.forget(host, slots)
+		return nil, ctx.Err()
+	}
+	select {
+	case l.global <- struct{}{}:
+	case <-ctx.Done():
+		releaseHost()
+		return nil, ctx.Err()
+	}
+	return func() {
+		<-l.global
+		releaseHost()
+	}, nil
+}
+
+// forget stops tracking the host once no scrape holds or waits for one of its slots
+func (l *limiter) forget(host string, slots *hostSlots) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots.users--
+	if slots.users == 0 {
+		delete(l.hosts, host)
+	}
+}
+
+// hostOf returns the host that status pages are limited by, status pages whose url can't be parsed share a host
+func hostOf(statusPageUrl string) string {
+	parsed, err := url.Parse(statusPageUrl)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
@@ -0,0 +1,227 @@
+package poller
+
+import (
+	"context"
+	"github.com/metoro-io/statusphere/scraper/internal/metrics"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper/consumers"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper/urlgetter"
+	"github.com/patrickmn/go-cache"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+type Poller struct {
+	urlGetter                           urlgetter.URLGetter
+	scraper                             scraper.Scraper
+	consumers                           []consumers.Consumer
+	currentlyExecutingScrapes           *cache.Cache
+	currentlyExecutingHistoricalScrapes *cache.Cache
+	logger                              *zap.Logger
+	limiter                             *limiter
+	// statusPageLocks makes sure the current and historical scrapes of a status page don't consume their incidents at the same time
+	statusPageLocks sync.Map
+	// scrapes tracks the scrapes that are queued or running, so that Poll can wait for them on shutdown
+	scrapes sync.WaitGroup
+}
+
+func NewPoller(urlGetter urlgetter.URLGetter, scraper scraper.Scraper, consumers []consumers.Consumer, config Config, logger *zap.Logger) *Poller {
+	return &Poller{
+		urlGetter:                           urlGetter,
+		scraper:                             scraper,
+		consumers:                           consumers,
+		currentlyExecutingScrapes:           cache.New(cache.NoExpiration, cache.NoExpiration),
+		currentlyExecutingHistoricalScrapes: cache.New(cache.NoExpiration, cache.NoExpiration),
+		logger:                              logger,
+		limiter:                             newLimiter(config.WithDefaults()),
+	}
+}
+
+// Poll polls the scraper and sends the incidents to the consumers
+// The status pages are scraped in parallel, bounded by the concurrency limits of the config
+// It blocks until the context is done, which also cancels the scrapes in progress, and returns once they have stopped
+func (p *Poller) Poll(ctx context.Context) error {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			err := p.pollInner(ctx)
+			if err != nil {
+				p.logger.Error("failed to poll", zap.Error(err))
+			}
+			err = p.pollInnerHistorical(ctx)
+			if err != nil {
+				p.logger.Error("failed to poll", zap.Error(err))
+			}
+		case <-ctx.Done():
+			p.scrapes.Wait()
+			return nil
+		}
+	}
+}
+
+func (p *Poller) pollInner(ctx context.Context) error {
+	urlsToScrape, err := p.urlGetter.GetUrlsToScrape()
+	if err != nil {
+		return err
+	}
+
+	var urlsToScrapeWhichAreNotCurrentlyExecuting []string
+	for _, url := range urlsToScrape {
+		if _, found := p.currentlyExecutingScrapes.Get(url); !found {
+			urlsToScrapeWhichAreNotCurrentlyExecuting = append(urlsToScrapeWhichAreNotCurrentlyExecuting, url)
+		}
+	}
+
+	for _, url := range urlsToScrapeWhichAreNotCurrentlyExecuting {
+		// The scrape is marked as executing while it is queued, so that it isn't queued again by the next poll
+		p.currentlyExecutingScrapes.Set(url, true, cache.NoExpiration)
+		p.scrapes.Add(1)
+		go func(url string) {
+			defer p.scrapes.Done()
+			defer p.currentlyExecutingScrapes.Delete(url)
+			release, err := p.acquire(ctx, url)
+			if err != nil {
+				// The poller is shutting down, the scrape is retried on the next start
+				return
+			}
+			defer release()
+			p.logger.Info("scraping", zap.String("url", url))
+			defer p.logger.Info("finished scraping", zap.String("url", url))
+			err = p.executeScrape(ctx, url)
+			defer func(urlGetter urlgetter.URLGetter, url string, time time.Time) {
+				if ctx.Err() != nil {
+					// The scrape was cancelled by a shutdown rather than failing, so it isn't recorded and is retried on the next start
+					return
+				}
+				if updateErr := urlGetter.UpdateLastScrapedTime(url, time, err); updateErr != nil {
+					p.logger.Error("failed to update last scraped time", zap.Error(updateErr), zap.String("url", url))
+				}
+			}(p.urlGetter, url, time.Now())
+			if err != nil {
+				p.logger.Error("failed to scrape", zap.Error(err), zap.String("url", url))
+				return
+			}
+		}(url)
+	}
+	return nil
+}
+
+// acquire waits until the status page can be scraped, it returns a function that is called once the scrape has finished
+// A scrape waits for the other scrape of the same status page to finish, then for a slot of the limiter
+func (p *Poller) acquire(ctx context.Context, url string) (func(), error) {
+	lock, _ := p.statusPageLocks.LoadOrStore(url, make(chan struct{}, 1))
+	statusPageLock := lock.(chan struct{})
+	select {
+	case statusPageLock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release, err := p.limiter.acquire(ctx, url)
+	if err != nil {
+		<-statusPageLock
+		return nil, err
+	}
+	return func() {
+		release()
+		<-statusPageLock
+	}, nil
+}
+
+// errorClassStorage is the class of scrapes whose incidents the consumers failed to store
+const errorClassStorage = "storage"
+
+func (p *Poller) executeScrape(ctx context.Context, url string) error {
+	metrics.RecordScrapeAttempt(metrics.ScrapeTypeCurrent)
+	incidents, err := p.scraper.ScrapeStatusPageCurrent(ctx, url)
+	if errors.Is(err, scraper.ErrNotModified) {
+		// The incidents haven't changed since the last scrape so there is nothing to consume, the scrape is still recorded as a success
+		recordScrapeResult(ctx, metrics.ScrapeTypeCurrent, nil, "")
+		return nil
+	}
+	if err != nil {
+		recordScrapeResult(ctx, metrics.ScrapeTypeCurrent, err, string(scraper.ClassOf(err)))
+		return err
+	}
+	for _, consumer := range p.consumers {
+		err := consumer.ConsumeCurrent(url, incidents)
+		if err != nil {
+			recordScrapeResult(ctx, metrics.ScrapeTypeCurrent, err, errorClassStorage)
+			return err
+		}
+	}
+	recordScrapeResult(ctx, metrics.ScrapeTypeCurrent, nil, "")
+	return nil
+}
+
+// recordScrapeResult records the outcome of a scrape in the scrape metrics, scrapes cancelled by a shutdown aren't recorded as they didn't fail
+func recordScrapeResult(ctx context.Context, scrapeType string, err error, errorClass string) {
+	if ctx.Err() != nil {
+		return
+	}
+	metrics.RecordScrapeResult(scrapeType, err, errorClass)
+}
+
+func (p *Poller) pollInnerHistorical(ctx context.Context) error {
+	urlsToScrape, err := p.urlGetter.GetHistoricalUrlsToScrape()
+	if err != nil {
+		return err
+	}
+
+	var urlsToScrapeWhichAreNotCurrentlyExecuting []string
+	for _, url := range urlsToScrape {
+		if _, found := p.currentlyExecutingHistoricalScrapes.Get(url); !found {
+			urlsToScrapeWhichAreNotCurrentlyExecuting = append(urlsToScrapeWhichAreNotCurrentlyExecuting, url)
+		}
+	}
+
+	for _, url := range urlsToScrapeWhichAreNotCurrentlyExecuting {
+		p.currentlyExecutingHistoricalScrapes.Set(url, true, cache.NoExpiration)
+		p.scrapes.Add(1)
+		go func(url string) {
+			defer p.scrapes.Done()
+			release, err := p.acquire(ctx, url)
+			if err != nil {
+				p.currentlyExecutingHistoricalScrapes.Delete(url)
+				return
+			}
+			defer release()
+			p.logger.Info("scraping historical", zap.String("url", url))
+			defer p.logger.Info("finished scraping historical", zap.String("url", url))
+			defer func(urlGetter urlgetter.URLGetter, url string, time time.Time) {
+				if ctx.Err() != nil {
+					return
+				}
+				_ = urlGetter.UpdateLastScrapedTimeHistorical(url, time)
+			}(p.urlGetter, url, time.Now())
+			defer p.currentlyExecutingHistoricalScrapes.Delete(url)
+			err = p.executeScrapeHistorical(ctx, url)
+			if err != nil {
+				p.logger.Error("failed to scrape historical", zap.Error(err), zap.String("url", url))
+			}
+		}(url)
+	}
+	return nil
+}
+
+func (p *Poller) executeScrapeHistorical(ctx context.Context, url string) error {
+	p.currentlyExecutingHistoricalScrapes.Set(url, struct{}{}, cache.NoExpiration)
+	metrics.RecordScrapeAttempt(metrics.ScrapeTypeHistorical)
+	incidents, err := p.scraper.ScrapeStatusPageHistorical(ctx, url)
+	if err != nil {
+		recordScrapeResult(ctx, metrics.ScrapeTypeHistorical, err, string(scraper.ClassOf(err)))
+		return err
+	}
+	for _, consumer := range p.consumers {
+		err := consumer.Consume(incidents)
+		if err != nil {
+			recordScrapeResult(ctx, metrics.ScrapeTypeHistorical, err, errorClassStorage)
+			return err
+		}
+	}
+	recordScrapeResult(ctx, metrics.ScrapeTypeHistorical, nil, "")
+	return nil
+}
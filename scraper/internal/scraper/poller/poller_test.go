@@ -0,0 +1,208 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/scraper/internal/metrics"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeURLGetter returns each of its urls to be scraped until it has been scraped once
+type fakeURLGetter struct {
+	mu      sync.Mutex
+	urls    []string
+	scraped map[string]bool
+}
+
+func (g *fakeURLGetter) GetUrlsToScrape() ([]string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var urls []string
+	for _, url := range g.urls {
+		if !g.scraped[url] {
+			urls = append(urls, url)
+		}
+	}
+	return urls, nil
+}
+
+func (g *fakeURLGetter) GetHistoricalUrlsToScrape() ([]string, error) { return nil, nil }
+
+func (g *fakeURLGetter) UpdateLastScrapedTime(url string, time time.Time, scrapeErr error) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.scraped[url] = true
+	return nil
+}
+
+func (g *fakeURLGetter) UpdateLastScrapedTimeHistorical(url string, time time.Time) error { return nil }
+
+func (g *fakeURLGetter) scrapedCount() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.scraped)
+}
+
+// concurrencyTrackingScraper records the most scrapes that ran at once, in total and for each host
+type concurrencyTrackingScraper struct {
+	mu             sync.Mutex
+	running        int
+	maxRunning     int
+	runningPerHost map[string]int
+	maxPerHost     int
+}
+
+func (s *concurrencyTrackingScraper) ScrapeStatusPageHistorical(ctx context.Context, url string) ([]api.Incident, error) {
+	return nil, nil
+}
+
+func (s *concurrencyTrackingScraper) ScrapeStatusPageCurrent(ctx context.Context, url string) ([]api.Incident, error) {
+	host := hostOf(url)
+	s.mu.Lock()
+	s.running++
+	s.runningPerHost[host]++
+	s.maxRunning = max(s.maxRunning, s.running)
+	s.maxPerHost = max(s.maxPerHost, s.runningPerHost[host])
+	s.mu.Unlock()
+
+	select {
+	case <-time.After(20 * time.Millisecond):
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	s.running--
+	s.runningPerHost[host]--
+	s.mu.Unlock()
+	return nil, nil
+}
+
+func TestPollScrapesStatusPagesConcurrentlyWithinTheLimits(t *testing.T) {
+	var urls []string
+	for host := 0; host < 10; host++ {
+		for page := 0; page < 5; page++ {
+			urls = append(urls, fmt.Sprintf("https://status%d.example.com/page%d", host, page))
+		}
+	}
+	urlGetter := &fakeURLGetter{urls: urls, scraped: map[string]bool{}}
+	scraper := &concurrencyTrackingScraper{runningPerHost: map[string]int{}}
+	config := Config{Concurrency: 8, PerHostConcurrency: 2}
+	poller := NewPoller(urlGetter, scraper, nil, config, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = poller.Poll(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(10 * time.Second)
+	for urlGetter.scrapedCount() < len(urls) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if count := urlGetter.scrapedCount(); count != len(urls) {
+		t.Fatalf("expected all %d status pages to be scraped, got %d", len(urls), count)
+	}
+	if scraper.maxRunning != config.Concurrency {
+		t.Fatalf("expected %d status pages to be scraped at once, got at most %d", config.Concurrency, scraper.maxRunning)
+	}
+	if scraper.maxPerHost > config.PerHostConcurrency {
+		t.Fatalf("expected at most %d status pages of a host to be scraped at once, got %d", config.PerHostConcurrency, scraper.maxPerHost)
+	}
+}
+
+func TestPollWaitsForTheScrapesOnShutdown(t *testing.T) {
+	urlGetter := &fakeURLGetter{urls: []string{"https://status.example.com", "https://status.example.com/other"}, scraped: map[string]bool{}}
+	scraper := &concurrencyTrackingScraper{runningPerHost: map[string]int{}}
+	poller := NewPoller(urlGetter, scraper, nil, Config{Concurrency: 1, PerHostConcurrency: 1}, zap.NewNop())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1010*time.Millisecond)
+	defer cancel()
+	_ = poller.Poll(ctx)
+
+	scraper.mu.Lock()
+	defer scraper.mu.Unlock()
+	if scraper.running != 0 {
+		t.Fatalf("expected no scrapes to be running once Poll returned, got %d", scraper.running)
+	}
+}
+
+func TestLimiterStopsWaitingWhenTheContextIsDone(t *testing.T) {
+	limiter := newLimiter(Config{Concurrency: 1, PerHostConcurrency: 1})
+	release, err := limiter.acquire(context.Background(), "https://status.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.acquire(ctx, "https://status.other.com"); err == nil {
+		t.Fatalf("expected waiting for the global slot to stop when the context is done")
+	}
+	release()
+
+	if len(limiter.hosts) != 0 {
+		t.Fatalf("expected the idle hosts to be forgotten, got %d", len(limiter.hosts))
+	}
+}
+
+func counterValue(t *testing.T, counter prometheus.Counter) float64 {
+	t.Helper()
+	var metric dto.Metric
+	if err := counter.Write(&metric); err != nil {
+		t.Fatalf("failed to read the counter: %v", err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+// failingScraper fails every current scrape with its error
+type failingScraper struct {
+	err error
+}
+
+func (s *failingScraper) ScrapeStatusPageHistorical(ctx context.Context, url string) ([]api.Incident, error) {
+	return nil, s.err
+}
+
+func (s *failingScraper) ScrapeStatusPageCurrent(ctx context.Context, url string) ([]api.Incident, error) {
+	return nil, s.err
+}
+
+func TestExecuteScrapeRecordsTheOutcomeInTheMetrics(t *testing.T) {
+	tests := []struct {
+		err           error
+		expectedClass string
+	}{
+		{err: nil},
+		{err: scraper.ErrNotModified},
+		{err: errors.New("unclassified"), expectedClass: string(scraper.ErrorClassUnknown)},
+	}
+	for _, test := range tests {
+		poller := NewPoller(&fakeURLGetter{scraped: map[string]bool{}}, &failingScraper{err: test.err}, nil, Config{}, zap.NewNop())
+		attempts := counterValue(t, metrics.ScrapeAttemptsTotal.WithLabelValues(metrics.ScrapeTypeCurrent))
+		successes := counterValue(t, metrics.ScrapeSuccessesTotal.WithLabelValues(metrics.ScrapeTypeCurrent))
+		failures := counterValue(t, metrics.ScrapeFailuresTotal.WithLabelValues(metrics.ScrapeTypeCurrent, test.expectedClass))
+
+		_ = poller.executeScrape(context.Background(), "https://status.example.com")
+
+		if counterValue(t, metrics.ScrapeAttemptsTotal.WithLabelValues(metrics.ScrapeTypeCurrent)) != attempts+1 {
+			t.Fatalf("%v: expected the attempt to be recorded", test.err)
+		}
+		if test.expectedClass == "" && counterValue(t, metrics.ScrapeSuccessesTotal.WithLabelValues(metrics.ScrapeTypeCurrent)) != successes+1 {
+			t.Fatalf("%v: expected the success to be recorded", test.err)
+		}
+		if test.expectedClass != "" && counterValue(t, metrics.ScrapeFailuresTotal.WithLabelValues(metrics.ScrapeTypeCurrent, test.expectedClass)) != failures+1 {
+			t.Fatalf("%v: expected the failure to be recorded with the class %s", test.err, test.expectedClass)
+		}
+	}
+}
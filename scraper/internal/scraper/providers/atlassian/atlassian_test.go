@@ -0,0 +1,46 @@
+package atlassian
+
+import (
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/atlassian/history", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<html><body><div data-react-class="HistoryIndex" data-react-props="{}"></div></body></html>`))
+	})
+	mux.HandleFunc("/other/history", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<html><body><h1>History</h1></body></html>`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	provider := NewAtlassianProvider(zap.NewNop(), server.Client())
+
+	if !provider.Detect(server.URL + "/atlassian") {
+		t.Fatalf("expected the atlassian page to be detected")
+	}
+	if provider.Detect(server.URL + "/other") {
+		t.Fatalf("expected a page without the history index not to be detected")
+	}
+	if provider.Detect("http://127.0.0.1:0") {
+		t.Fatalf("expected a page that can't be fetched not to be detected")
+	}
+}
+
+func TestIncidentCode(t *testing.T) {
+	tests := map[string]string{
+		"/incidents/abc123":       "abc123",
+		"/incidents/":             "",
+		"/incidents/abc123/other": "",
+		"/history":                "",
+		"":                        "",
+	}
+	for path, expected := range tests {
+		if code := incidentCode(path); code != expected {
+			t.Fatalf("expected the code of %q to be %q, got %q", path, expected, code)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package instatus
+
+import (
+	"context"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	neturl "net/url"
+	"strings"
+)
+
+// ErrNotSupported is returned when an instatus page is scraped, as the incidents of instatus pages can't be parsed yet
+var ErrNotSupported = errors.New("scraping instatus pages is not supported yet")
+
+func (s *InstatusProvider) Name() string {
+	return "Instatus"
+}
+
+// InstatusProvider is a stub for the status pages hosted by instatus, it detects them but can't scrape them yet
+// It is registered after the other providers so that instatus pages that they can scrape, e.g. through their feeds, still are
+type InstatusProvider struct {
+	logger *zap.Logger
+}
+
+func NewInstatusProvider(logger *zap.Logger) *InstatusProvider {
+	return &InstatusProvider{
+		logger: logger,
+	}
+}
+
+// Detect returns true if the status page is hosted on an instatus.com subdomain
+// Instatus pages on custom domains aren't detected
+func (s *InstatusProvider) Detect(url string) bool {
+	parsed, err := neturl.Parse(url)
+	if err != nil {
+		return false
+	}
+	return strings.HasSuffix(strings.ToLower(parsed.Hostname()), ".instatus.com")
+}
+
+func (s *InstatusProvider) ScrapeStatusPageHistorical(ctx context.Context, url string) ([]api.Incident, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *InstatusProvider) ScrapeStatusPageCurrent(ctx context.Context, url string) ([]api.Incident, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,38 @@
+package instatus
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	provider := NewInstatusProvider(zap.NewNop())
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{url: "https://example.instatus.com", expected: true},
+		{url: "https://Example.Instatus.com/history", expected: true},
+		{url: "https://instatus.com", expected: false},
+		{url: "https://status.example.com", expected: false},
+		{url: "https://example.instatus.com.evil.com", expected: false},
+		{url: "%zz", expected: false},
+	}
+	for _, test := range tests {
+		if detected := provider.Detect(test.url); detected != test.expected {
+			t.Fatalf("%s: expected %t, got %t", test.url, test.expected, detected)
+		}
+	}
+}
+
+func TestScrapeIsNotSupported(t *testing.T) {
+	provider := NewInstatusProvider(zap.NewNop())
+	if _, err := provider.ScrapeStatusPageCurrent(context.Background(), "https://example.instatus.com"); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+	if _, err := provider.ScrapeStatusPageHistorical(context.Background(), "https://example.instatus.com"); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package providers
+
+import (
+	"context"
+	"github.com/metoro-io/statusphere/common/api"
+)
+
+// Provider scrapes the status pages of one kind of status page, e.g. the pages hosted by Atlassian Statuspage
+// New kinds of status page are supported by adding a Provider to the Registry that the scraper is created with
+type Provider interface {
+	// Detect returns true if the status page at the given URL can be scraped by the provider
+	// It is called before the status page is scraped, the first provider in the registry that detects the page scrapes it
+	Detect(url string) bool
+
+	// ScrapeStatusPageHistorical scrapes the status page at the given URL and returns a list of incidents
+	// The incidents are historical, meaning they are not just the current incidents, this can be expected to return a large number of incidents
+	// And take a long time to run, so we should only run this infrequently, maybe once per week per page
+	ScrapeStatusPageHistorical(ctx context.Context, url string) ([]api.Incident, error)
+
+	// ScrapeStatusPageCurrent scrapes the status page at the given URL and returns a list of incidents
+	// The incidents are current, meaning they are only the recent incidents, this can be expected to return a small number of incidents
+	// And take a short time to run, so we should run this frequently, maybe once per 5 minutes per page
+	ScrapeStatusPageCurrent(ctx context.Context, url string) ([]api.Incident, error)
+	Name() string
+}
@@ -0,0 +1,31 @@
+package providers
+
+// Registry is the ordered list of providers that status pages are scraped with
+// The order matters as a status page is scraped by the first provider that detects it, so more specific providers should be registered first
+type Registry struct {
+	providers []Provider
+}
+
+func NewRegistry(providers ...Provider) *Registry {
+	registry := &Registry{}
+	for _, provider := range providers {
+		registry.Register(provider)
+	}
+	return registry
+}
+
+// Register adds the provider after the providers that are already registered
+func (r *Registry) Register(provider Provider) {
+	r.providers = append(r.providers, provider)
+}
+
+// Detect returns the first registered provider that detects the status page at the given URL
+// It returns false for the second return value if none of them do
+func (r *Registry) Detect(url string) (Provider, bool) {
+	for _, provider := range r.providers {
+		if provider.Detect(url) {
+			return provider, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,62 @@
+package providers
+
+import (
+	"context"
+	"github.com/metoro-io/statusphere/common/api"
+	"strings"
+	"testing"
+)
+
+type fakeProvider struct {
+	name   string
+	detect func(url string) bool
+}
+
+func (p fakeProvider) Detect(url string) bool {
+	return p.detect(url)
+}
+
+func (p fakeProvider) ScrapeStatusPageHistorical(ctx context.Context, url string) ([]api.Incident, error) {
+	return nil, nil
+}
+
+func (p fakeProvider) ScrapeStatusPageCurrent(ctx context.Context, url string) ([]api.Incident, error) {
+	return nil, nil
+}
+
+func (p fakeProvider) Name() string {
+	return p.name
+}
+
+func TestRegistryDetectsTheFirstMatchingProvider(t *testing.T) {
+	registry := NewRegistry(
+		fakeProvider{name: "example", detect: func(url string) bool { return strings.Contains(url, "example") }},
+		fakeProvider{name: "https", detect: func(url string) bool { return strings.HasPrefix(url, "https://") }},
+	)
+	registry.Register(fakeProvider{name: "any", detect: func(url string) bool { return true }})
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{url: "https://status.example.com", expected: "example"},
+		{url: "https://status.other.com", expected: "https"},
+		{url: "http://status.other.com", expected: "any"},
+	}
+	for _, test := range tests {
+		provider, found := registry.Detect(test.url)
+		if !found || provider.Name() != test.expected {
+			t.Fatalf("%s: expected the %s provider, got %v", test.url, test.expected, provider)
+		}
+	}
+}
+
+func TestRegistryDetectsNothingWhenNoProviderMatches(t *testing.T) {
+	if _, found := NewRegistry().Detect("https://status.example.com"); found {
+		t.Fatalf("expected an empty registry to detect nothing")
+	}
+	registry := NewRegistry(fakeProvider{name: "none", detect: func(url string) bool { return false }})
+	if provider, found := registry.Detect("https://status.example.com"); found {
+		t.Fatalf("expected no provider to be detected, got %s", provider.Name())
+	}
+}
@@ -0,0 +1,138 @@
+package scraper
+
+import (
+	"context"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"github.com/metoro-io/statusphere/scraper/internal/robots"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper/providers"
+	"github.com/patrickmn/go-cache"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+)
+
+// ErrNotModified is returned by ScrapeStatusPageCurrent when the status page hasn't changed since its last successful scrape
+var ErrNotModified = errors.New("the status page has not been modified since it was last scraped")
+
+// pageValidators are the validators of the response of a status page, which are sent with the next request for it
+// so that the status page only has to be scraped again if it has changed
+type pageValidators struct {
+	etag         string
+	lastModified string
+}
+
+func (s *scraper) ScrapeStatusPageHistorical(ctx context.Context, url string) ([]api.Incident, error) {
+	ctx = utils.UpdateContextMdc(ctx, map[string]string{"url": url})
+	// Historical scrapes are infrequent and return every incident, so the status page is always scraped in full
+	response, err := s.fetchStatusPage(ctx, url, pageValidators{})
+	if err != nil {
+		return nil, err
+	}
+	provider, err := s.detectProvider(url, response.Response)
+	if err != nil {
+		return nil, err
+	}
+	ctx = utils.UpdateContextMdc(ctx, map[string]string{"provider": provider.Name()})
+	incidents, err := provider.ScrapeStatusPageHistorical(ctx, url)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Info("Failed to scrape the status page using the provider method", zap.Error(err))
+		return nil, withClass(ErrorClassProvider, errors.Wrapf(err, "failed to scrape the status page using the %s provider", provider.Name()))
+	}
+	utils.GetLogger(ctx, s.logger).Info("Successfully scraped the status page using the provider method")
+	return incidents, nil
+}
+
+// ScrapeStatusPageCurrent returns ErrNotModified without scraping the status page if it responds with a 304 to the validators
+// of its last successful scrape, in which case its incidents are the same as they were then
+func (s *scraper) ScrapeStatusPageCurrent(ctx context.Context, url string) ([]api.Incident, error) {
+	ctx = utils.UpdateContextMdc(ctx, map[string]string{"url": url})
+	var validators pageValidators
+	if cached, found := s.validators.Get(url); found {
+		validators = cached.(pageValidators)
+	}
+	response, err := s.fetchStatusPage(ctx, url, validators)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusNotModified {
+		utils.GetLogger(ctx, s.logger).Info("Skipped scraping the status page as it has not been modified")
+		return nil, ErrNotModified
+	}
+	provider, err := s.detectProvider(url, response.Response)
+	if err != nil {
+		s.recordSnapshot(ctx, url, "", response, err)
+		return nil, err
+	}
+	ctx = utils.UpdateContextMdc(ctx, map[string]string{"provider": provider.Name()})
+	incidents, err := provider.ScrapeStatusPageCurrent(ctx, url)
+	s.recordSnapshot(ctx, url, provider.Name(), response, err)
+	if err != nil {
+		utils.GetLogger(ctx, s.logger).Info("Failed to scrape the status page using the provider method", zap.Error(err))
+		return nil, withClass(ErrorClassProvider, errors.Wrapf(err, "failed to scrape the status page using the %s provider", provider.Name()))
+	}
+	utils.GetLogger(ctx, s.logger).Info("Successfully scraped the status page using the provider method")
+	// The validators are only stored once the scrape has succeeded, so that a failed scrape is retried in full
+	received := pageValidators{etag: response.Header.Get("ETag"), lastModified: response.Header.Get("Last-Modified")}
+	if received.etag != "" || received.lastModified != "" {
+		s.validators.Set(url, received, cache.DefaultExpiration)
+	} else {
+		s.validators.Delete(url)
+	}
+	return incidents, nil
+}
+
+// detectProvider returns the provider that the status page is scraped with, see providers.Registry.Detect
+// Different status pages are structured differently, so each provider checks whether it knows how to scrape the page
+// The response is the one fetchStatusPage got for the status page, it is used to explain why no provider detected the page
+func (s *scraper) detectProvider(url string, response *http.Response) (providers.Provider, error) {
+	provider, found := s.providers.Detect(url)
+	if !found {
+		if response.StatusCode >= http.StatusBadRequest {
+			return nil, withClass(ErrorClassUnsupported, errors.Errorf("none of the providers detected the status page, it responded with %s", response.Status))
+		}
+		return nil, withClass(ErrorClassUnsupported, errors.New("none of the providers detected the status page"))
+	}
+	return provider, nil
+}
+
+// fetchStatusPage gets the status page, requests that fail transiently are retried by the transport of the http client
+// The validators are sent as If-None-Match and If-Modified-Since, so the status page may respond with a 304
+// It returns an error if the status page can't be reached or responds with a server error once the retries are exhausted
+// It returns an error if robots.txt disallows the status page, which is recorded as the error of the scrape
+// Client errors don't fail the scrape as the providers scrape other paths of the status page, e.g. /history
+// The start of the body is read if snapshots are stored, up to the max size of a snapshot, the rest of it is discarded
+func (s *scraper) fetchStatusPage(ctx context.Context, url string, validators pageValidators) (*statusPageResponse, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the request to the status page")
+	}
+	if validators.etag != "" {
+		request.Header.Set("If-None-Match", validators.etag)
+	}
+	if validators.lastModified != "" {
+		request.Header.Set("If-Modified-Since", validators.lastModified)
+	}
+	response, err := s.httpClient.Do(request)
+	if errors.Is(err, robots.ErrDisallowed) {
+		return nil, withClass(ErrorClassRobotsDisallowed, errors.New("the status page is disallowed by robots.txt, so it is skipped"))
+	}
+	if err != nil {
+		return nil, withClass(ErrorClassUnreachable, errors.Wrap(err, "failed to get the status page"))
+	}
+	fetched := &statusPageResponse{Response: response}
+	if s.snapshots != nil {
+		fetched.body, _ = io.ReadAll(io.LimitReader(response.Body, int64(s.snapshotConfig.MaxSize)+1))
+		if len(fetched.body) > s.snapshotConfig.MaxSize {
+			fetched.body = fetched.body[:s.snapshotConfig.MaxSize]
+			fetched.truncated = true
+		}
+	}
+	_, _ = io.Copy(io.Discard, response.Body)
+	_ = response.Body.Close()
+	if response.StatusCode >= http.StatusInternalServerError {
+		return nil, withClass(ErrorClassServerError, errors.Errorf("the status page responded with %s", response.Status))
+	}
+	return fetched, nil
+}
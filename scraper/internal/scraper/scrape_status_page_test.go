@@ -0,0 +1,237 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/scraper/internal/retry"
+	"github.com/metoro-io/statusphere/scraper/internal/robots"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper/providers"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScrapeFailsWithTheFinalErrorOfTheStatusPage(t *testing.T) {
+	tests := []struct {
+		status        int
+		expected      string
+		expectedClass ErrorClass
+	}{
+		{status: http.StatusServiceUnavailable, expected: "the status page responded with 503 Service Unavailable", expectedClass: ErrorClassServerError},
+		{status: http.StatusNotFound, expected: "none of the providers detected the status page, it responded with 404 Not Found", expectedClass: ErrorClassUnsupported},
+	}
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+		}))
+		httpClient := &http.Client{Transport: retry.NewTransport(http.DefaultTransport, retry.Config{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}, zap.NewNop())}
+		scraper := NewScraper(zap.NewNop(), httpClient, providers.NewRegistry(), nil, SnapshotConfig{})
+
+		_, err := scraper.ScrapeStatusPageCurrent(context.Background(), server.URL)
+		server.Close()
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Fatalf("%d: expected the error %q, got %v", test.status, test.expected, err)
+		}
+		if class := ClassOf(err); class != test.expectedClass {
+			t.Fatalf("%d: expected the error class %s, got %s", test.status, test.expectedClass, class)
+		}
+	}
+}
+
+func TestScrapeSkipsStatusPagesDisallowedByRobotsTxt(t *testing.T) {
+	requested := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			_, _ = w.Write([]byte("User-agent: Statusphere\nDisallow: /\n"))
+			return
+		}
+		requested = true
+	}))
+	defer server.Close()
+	httpClient := &http.Client{Transport: robots.NewTransport(http.DefaultTransport, robots.Config{CacheTTL: time.Hour}, "Statusphere", zap.NewNop())}
+	scraper := NewScraper(zap.NewNop(), httpClient, providers.NewRegistry(), nil, SnapshotConfig{})
+
+	_, err := scraper.ScrapeStatusPageCurrent(context.Background(), server.URL)
+	if err == nil || !strings.Contains(err.Error(), "the status page is disallowed by robots.txt") || ClassOf(err) != ErrorClassRobotsDisallowed {
+		t.Fatalf("expected the status page to be skipped, got %v", err)
+	}
+	if requested {
+		t.Fatalf("expected the status page not to be requested")
+	}
+}
+
+// countingProvider detects every status page and counts how many times it scrapes them
+type countingProvider struct {
+	scrapes atomic.Int32
+	err     error
+}
+
+func (p *countingProvider) Detect(url string) bool { return true }
+
+func (p *countingProvider) ScrapeStatusPageHistorical(ctx context.Context, url string) ([]api.Incident, error) {
+	p.scrapes.Add(1)
+	return nil, p.err
+}
+
+func (p *countingProvider) ScrapeStatusPageCurrent(ctx context.Context, url string) ([]api.Incident, error) {
+	p.scrapes.Add(1)
+	return []api.Incident{}, p.err
+}
+
+func (p *countingProvider) Name() string { return "counting" }
+
+func TestScrapeSkipsStatusPagesThatHaveNotBeenModified(t *testing.T) {
+	const etag = `"v1"`
+	const lastModified = "Mon, 01 Jan 2024 00:00:00 GMT"
+	tests := []struct {
+		name    string
+		headers map[string]string
+		// notModified returns true if the request has the validators of the status page
+		notModified func(r *http.Request) bool
+	}{
+		{
+			name:        "etag",
+			headers:     map[string]string{"ETag": etag},
+			notModified: func(r *http.Request) bool { return r.Header.Get("If-None-Match") == etag },
+		},
+		{
+			name:        "last modified",
+			headers:     map[string]string{"Last-Modified": lastModified},
+			notModified: func(r *http.Request) bool { return r.Header.Get("If-Modified-Since") == lastModified },
+		},
+	}
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if test.notModified(r) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			for key, value := range test.headers {
+				w.Header().Set(key, value)
+			}
+		}))
+		provider := &countingProvider{}
+		scraper := NewScraper(zap.NewNop(), server.Client(), providers.NewRegistry(provider), nil, SnapshotConfig{})
+
+		if _, err := scraper.ScrapeStatusPageCurrent(context.Background(), server.URL); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if _, err := scraper.ScrapeStatusPageCurrent(context.Background(), server.URL); !errors.Is(err, ErrNotModified) {
+			t.Fatalf("%s: expected ErrNotModified, got %v", test.name, err)
+		}
+		// Historical scrapes don't send the validators, so they always scrape the status page
+		if _, err := scraper.ScrapeStatusPageHistorical(context.Background(), server.URL); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		server.Close()
+		if provider.scrapes.Load() != 2 {
+			t.Fatalf("%s: expected the status page to be scraped twice, got %d scrapes", test.name, provider.scrapes.Load())
+		}
+	}
+}
+
+func TestScrapeDoesNotSkipStatusPagesAfterAFailedScrape(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+	}))
+	defer server.Close()
+	provider := &countingProvider{err: errors.New("failed to parse")}
+	scraper := NewScraper(zap.NewNop(), server.Client(), providers.NewRegistry(provider), nil, SnapshotConfig{})
+
+	for i := 0; i < 2; i++ {
+		if _, err := scraper.ScrapeStatusPageCurrent(context.Background(), server.URL); err == nil || errors.Is(err, ErrNotModified) {
+			t.Fatalf("expected the scrape to fail, got %v", err)
+		}
+	}
+	if provider.scrapes.Load() != 2 {
+		t.Fatalf("expected the status page to be scraped again after the failed scrape, got %d scrapes", provider.scrapes.Load())
+	}
+}
+
+type fakeSnapshotStore struct {
+	snapshots []api.StatusPageSnapshot
+}
+
+func (s *fakeSnapshotStore) RecordStatusPageSnapshot(ctx context.Context, snapshot api.StatusPageSnapshot) error {
+	s.snapshots = append(s.snapshots, snapshot)
+	return nil
+}
+
+func TestScrapeRecordsTheSnapshotOfTheStatusPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html>all systems operational</html>"))
+	}))
+	defer server.Close()
+	store := &fakeSnapshotStore{}
+	provider := &countingProvider{err: errors.New("failed to parse")}
+	scraper := NewScraper(zap.NewNop(), server.Client(), providers.NewRegistry(provider), store, SnapshotConfig{Enabled: true, MaxSize: 12})
+
+	if _, err := scraper.ScrapeStatusPageCurrent(context.Background(), server.URL); err == nil {
+		t.Fatalf("expected the scrape to fail")
+	}
+	if len(store.snapshots) != 1 {
+		t.Fatalf("expected one snapshot, got %d", len(store.snapshots))
+	}
+	snapshot := store.snapshots[0]
+	if snapshot.StatusPageUrl != server.URL || snapshot.Provider != "counting" || snapshot.StatusCode != http.StatusOK || snapshot.ContentType != "text/html" || snapshot.Error != "failed to parse" {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+	body, err := snapshot.Body()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(body) != "<html>all sy" || !snapshot.Truncated {
+		t.Fatalf("expected the body to be truncated to the max size, got %q, truncated %v", body, snapshot.Truncated)
+	}
+}
+
+func TestScrapeRecordsTheSnapshotOfUndetectedStatusPages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("unknown"))
+	}))
+	defer server.Close()
+	store := &fakeSnapshotStore{}
+	scraper := NewScraper(zap.NewNop(), server.Client(), providers.NewRegistry(), store, SnapshotConfig{Enabled: true, MaxSize: 1024})
+
+	if _, err := scraper.ScrapeStatusPageCurrent(context.Background(), server.URL); ClassOf(err) != ErrorClassUnsupported {
+		t.Fatalf("expected the status page to be unsupported, got %v", err)
+	}
+	if len(store.snapshots) != 1 || store.snapshots[0].Provider != "" || store.snapshots[0].Truncated {
+		t.Fatalf("unexpected snapshots: %+v", store.snapshots)
+	}
+	if body, _ := store.snapshots[0].Body(); string(body) != "unknown" {
+		t.Fatalf("expected the whole body to be stored, got %q", body)
+	}
+}
+
+func TestDryRunReturnsTheProviderAndItsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+	}))
+	defer server.Close()
+	provider := &countingProvider{}
+	dryRunner := NewDryRunner(zap.NewNop(), server.Client(), providers.NewRegistry(provider))
+
+	name, incidents, err := dryRunner.DryRun(context.Background(), server.URL, false)
+	if err != nil || name != "counting" || incidents == nil {
+		t.Fatalf("expected the incidents of the counting provider, got %q %v %v", name, incidents, err)
+	}
+	provider.err = errors.New("failed to parse")
+	name, _, err = dryRunner.DryRun(context.Background(), server.URL, true)
+	if name != "counting" || ClassOf(err) != ErrorClassProvider || !strings.Contains(err.Error(), "failed to parse") {
+		t.Fatalf("expected the provider error, got %q %v", name, err)
+	}
+	if provider.scrapes.Load() != 2 {
+		t.Fatalf("expected the status page to be scraped by every dry run, got %d scrapes", provider.scrapes.Load())
+	}
+}
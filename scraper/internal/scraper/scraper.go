@@ -0,0 +1,53 @@
+package scraper
+
+import (
+	"context"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper/providers"
+	"github.com/patrickmn/go-cache"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// validatorsTTL is how long the validators of a status page are kept, so that the validators of removed status pages don't build up
+const validatorsTTL = 24 * time.Hour
+
+type Scraper interface {
+	// ScrapeStatusPageHistorical scrapes the status page at the given URL and returns a list of incidents
+	// The incidents are historical, meaning they are not just the current incidents, this can be expected to return a large number of incidents
+	// And take a long time to run, so we should only run this infrequently, maybe once per week per page
+	ScrapeStatusPageHistorical(ctx context.Context, url string) ([]api.Incident, error)
+
+	// ScrapeStatusPageCurrent scrapes the status page at the given URL and returns a list of incidents
+	// The incidents are current, meaning they are only the recent incidents, this can be expected to return a small number of incidents
+	// And take a short time to run, so we should run this frequently, maybe once per 5 minutes per page
+	// It returns ErrNotModified if the status page hasn't changed since it was last scraped successfully
+	ScrapeStatusPageCurrent(ctx context.Context, url string) ([]api.Incident, error)
+}
+
+type scraper struct {
+	providers  *providers.Registry
+	logger     *zap.Logger
+	httpClient *http.Client
+	// validators are the pageValidators of the last successful current scrape of each status page
+	validators *cache.Cache
+	// snapshots stores the response of each status page to its last current scrape, it is nil if snapshots are disabled
+	snapshots      SnapshotStore
+	snapshotConfig SnapshotConfig
+}
+
+// NewScraper returns a scraper that stores the snapshots of the status pages in snapshots, which can be nil to not store them
+func NewScraper(logger *zap.Logger, httpClient *http.Client, providers *providers.Registry, snapshots SnapshotStore, snapshotConfig SnapshotConfig) Scraper {
+	if !snapshotConfig.Enabled {
+		snapshots = nil
+	}
+	return &scraper{
+		logger:         logger,
+		httpClient:     httpClient,
+		providers:      providers,
+		validators:     cache.New(validatorsTTL, validatorsTTL),
+		snapshots:      snapshots,
+		snapshotConfig: snapshotConfig,
+	}
+}
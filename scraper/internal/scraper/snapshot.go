@@ -0,0 +1,56 @@
+package scraper
+
+import (
+	"context"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/utils"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+type SnapshotConfig struct {
+	// Enabled is whether the response of each status page to its last current scrape is stored, see api.StatusPageSnapshot
+	Enabled bool `envconfig:"ENABLED" default:"true"`
+	// MaxSize is the most bytes of the body of the response that are stored, larger bodies are truncated
+	MaxSize int `envconfig:"MAX_SIZE" default:"262144"`
+}
+
+// SnapshotStore stores the snapshots of the status pages
+type SnapshotStore interface {
+	RecordStatusPageSnapshot(ctx context.Context, snapshot api.StatusPageSnapshot) error
+}
+
+// statusPageResponse is the response fetchStatusPage got for the status page, with the start of its body
+type statusPageResponse struct {
+	*http.Response
+	body      []byte
+	truncated bool
+}
+
+// recordSnapshot stores the response of the status page along with the result of the provider scraping it
+// Only the response to the request for the status page itself is stored, not the responses to the other paths that the provider requests
+// Failing to store the snapshot doesn't fail the scrape, it is only logged
+func (s *scraper) recordSnapshot(ctx context.Context, url string, provider string, response *statusPageResponse, scrapeErr error) {
+	if s.snapshots == nil {
+		return
+	}
+	snapshot := api.StatusPageSnapshot{
+		StatusPageUrl: url,
+		Provider:      provider,
+		ScrapedAt:     time.Now().UTC(),
+		StatusCode:    response.StatusCode,
+		ContentType:   response.Header.Get("Content-Type"),
+		Truncated:     response.truncated,
+	}
+	if scrapeErr != nil {
+		snapshot.Error = scrapeErr.Error()
+	}
+	if err := snapshot.SetBody(response.body); err != nil {
+		utils.GetLogger(ctx, s.logger).Warn("failed to store the snapshot of the status page", zap.Error(err))
+		return
+	}
+	if err := s.snapshots.RecordStatusPageSnapshot(ctx, snapshot); err != nil {
+		utils.GetLogger(ctx, s.logger).Warn("failed to store the snapshot of the status page", zap.Error(err))
+	}
+}
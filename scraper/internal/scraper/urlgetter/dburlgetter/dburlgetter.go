@@ -0,0 +1,150 @@
+package dburlgetter
+
+import (
+	"context"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/metoro-io/statusphere/common/db"
+	"github.com/metoro-io/statusphere/common/hosts"
+	"github.com/metoro-io/statusphere/scraper/internal/metrics"
+	"github.com/patrickmn/go-cache"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"time"
+)
+
+type DBURLGetter struct {
+	logger          *zap.Logger
+	dbClient        *db.DbClient
+	StatusPageCache *cache.Cache
+	// hosts are the hosts that status pages are scraped from, the status pages of other hosts are skipped
+	hosts hosts.Config
+}
+
+func NewDBURLGetter(logger *zap.Logger, client *db.DbClient, hosts hosts.Config) *DBURLGetter {
+	return &DBURLGetter{
+		logger:          logger,
+		dbClient:        client,
+		StatusPageCache: cache.New(time.Minute*20, time.Minute*10),
+		hosts:           hosts,
+	}
+}
+
+func (s *DBURLGetter) UpdateLastScrapedTimeHistorical(url string, time time.Time) error {
+	statusPage, err := s.dbClient.GetStatusPage(context.Background(), url)
+	if err != nil {
+		return errors.Wrap(err, "failed to get status page")
+	}
+	statusPage.LastHistoricallyScraped = time
+	err = s.dbClient.UpdateStatusPage(context.Background(), *statusPage)
+	if err != nil {
+		return errors.Wrap(err, "failed to update status page")
+	}
+	s.StatusPageCache.Set(url, *statusPage, cache.DefaultExpiration)
+	return nil
+}
+
+func (s *DBURLGetter) UpdateLastScrapedTime(url string, time time.Time, scrapeErr error) error {
+	err := s.dbClient.RecordStatusPageScrape(context.Background(), url, time, scrapeErr)
+	if err != nil {
+		return errors.Wrap(err, "failed to record status page scrape")
+	}
+	statusPage, err := s.dbClient.GetStatusPage(context.Background(), url)
+	if err != nil {
+		return errors.Wrap(err, "failed to get status page")
+	}
+	if statusPage == nil {
+		return errors.New("status page not found")
+	}
+	s.StatusPageCache.Set(url, *statusPage, cache.DefaultExpiration)
+	s.recordPendingIndex()
+	return nil
+}
+
+const timeToRescrape = 5 * time.Minute
+
+// GetUrlsToScrape returns the status pages that haven't been scraped within timeToRescrape, except the ones whose indexing is paused
+// or whose hosts aren't allowed to be indexed
+func (s *DBURLGetter) GetUrlsToScrape() ([]string, error) {
+	urlsToUse := []string{}
+	items := s.StatusPageCache.Items()
+	for k, v := range items {
+		statusPage, ok := v.Object.(api.StatusPage)
+		if !ok {
+			s.logger.Error("failed to cast status page")
+			continue
+		}
+		if statusPage.IndexingPaused || !s.hosts.AllowedUrl(statusPage.URL) {
+			continue
+		}
+		if time.Since(statusPage.LastCurrentlyScraped) > timeToRescrape {
+			urlsToUse = append(urlsToUse, k)
+		}
+	}
+	return urlsToUse, nil
+}
+
+const timeToRescrapeHistorical = 24 * time.Hour * 7
+
+// GetHistoricalUrlsToScrape returns the status pages that haven't been scraped historically within timeToRescrapeHistorical, except the ones whose indexing is paused
+// or whose hosts aren't allowed to be indexed
+func (s *DBURLGetter) GetHistoricalUrlsToScrape() ([]string, error) {
+	urlsToUse := []string{}
+	items := s.StatusPageCache.Items()
+	for k, v := range items {
+		statusPage, ok := v.Object.(api.StatusPage)
+		if !ok {
+			s.logger.Error("failed to cast status page")
+			continue
+		}
+		if statusPage.IndexingPaused || !s.hosts.AllowedUrl(statusPage.URL) {
+			continue
+		}
+		if time.Since(statusPage.LastHistoricallyScraped) > timeToRescrapeHistorical {
+			urlsToUse = append(urlsToUse, k)
+		}
+	}
+	return urlsToUse, nil
+}
+
+func (s *DBURLGetter) Start() {
+	s.UpdateStatusPageCache()
+}
+
+func (s *DBURLGetter) UpdateStatusPageCache() {
+	// Update the cache every 1 minute
+	s.updateStatusPageCacheInner()
+	ticker := time.NewTicker(1 * time.Minute)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				s.updateStatusPageCacheInner()
+			}
+		}
+	}()
+}
+
+func (s *DBURLGetter) updateStatusPageCacheInner() {
+	s.logger.Info("updating status page cache")
+	statusPages, err := s.dbClient.GetAllStatusPages(context.Background())
+	if err != nil {
+		s.logger.Error("failed to get status pages", zap.Error(err))
+		return
+	}
+	for _, statusPage := range statusPages {
+		s.StatusPageCache.Set(statusPage.URL, statusPage, cache.DefaultExpiration)
+	}
+	s.recordPendingIndex()
+}
+
+// recordPendingIndex sets the status_pages_pending_index metric to the number of cached status pages that haven't been indexed
+// The status pages whose indexing is paused or whose hosts aren't allowed aren't pending as they won't be scraped
+func (s *DBURLGetter) recordPendingIndex() {
+	pending := 0
+	for _, item := range s.StatusPageCache.Items() {
+		if statusPage, ok := item.Object.(api.StatusPage); ok && !statusPage.IsIndexed && !statusPage.IndexingPaused && s.hosts.AllowedUrl(statusPage.URL) {
+			pending++
+		}
+	}
+	metrics.SetStatusPagesPendingIndex(pending)
+}
@@ -0,0 +1,26 @@
+package dburlgetter
+
+import (
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/patrickmn/go-cache"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func TestPausedStatusPagesAreNotScraped(t *testing.T) {
+	getter := &DBURLGetter{logger: zap.NewNop(), StatusPageCache: cache.New(cache.NoExpiration, cache.NoExpiration)}
+	getter.StatusPageCache.Set("https://status.example.com", api.StatusPage{URL: "https://status.example.com"}, cache.DefaultExpiration)
+	getter.StatusPageCache.Set("https://status.paused.com", api.StatusPage{URL: "https://status.paused.com", IndexingPaused: true}, cache.DefaultExpiration)
+	getter.StatusPageCache.Set("https://status.recent.com", api.StatusPage{URL: "https://status.recent.com", LastCurrentlyScraped: time.Now(), LastHistoricallyScraped: time.Now()}, cache.DefaultExpiration)
+
+	for name, getUrls := range map[string]func() ([]string, error){"current": getter.GetUrlsToScrape, "historical": getter.GetHistoricalUrlsToScrape} {
+		urls, err := getUrls()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if len(urls) != 1 || urls[0] != "https://status.example.com" {
+			t.Fatalf("%s: expected only the status page that isn't paused or recently scraped, got %v", name, urls)
+		}
+	}
+}
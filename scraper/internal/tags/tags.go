@@ -0,0 +1,131 @@
+package tags
+
+import (
+	"encoding/json"
+	"github.com/metoro-io/statusphere/common/api"
+	"github.com/pkg/errors"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type Config struct {
+	// KeywordsFile is a json file of the keywords of each tag, e.g. {"database": ["database", "postgres"]}, which are used instead of DefaultKeywords
+	// so that the tags can be tuned without a release
+	KeywordsFile string `envconfig:"KEYWORDS_FILE"`
+}
+
+// DefaultKeywords are the keywords of each tag that are used when there is no keywords file
+// A keyword can be several words, e.g. packet loss, which have to appear together
+var DefaultKeywords = map[string][]string{
+	"network":  {"network", "networking", "dns", "connectivity", "latency", "packet loss", "cdn", "load balancer"},
+	"database": {"database", "databases", "db", "postgres", "postgresql", "mysql", "redis", "replication"},
+	"auth":     {"auth", "authentication", "login", "logins", "log in", "sign in", "sso", "oauth", "2fa"},
+	"api":      {"api", "apis", "webhook", "webhooks"},
+	"email":    {"email", "emails", "smtp"},
+	"payments": {"payment", "payments", "billing", "checkout"},
+	"storage":  {"storage", "disk", "s3", "uploads"},
+}
+
+// LoadKeywords returns the keywords of the keywords file of the config, or DefaultKeywords if there isn't one
+func LoadKeywords(config Config) (map[string][]string, error) {
+	if config.KeywordsFile == "" {
+		return DefaultKeywords, nil
+	}
+	contents, err := os.ReadFile(config.KeywordsFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the keywords file")
+	}
+	var keywords map[string][]string
+	if err := json.Unmarshal(contents, &keywords); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the keywords file")
+	}
+	return keywords, nil
+}
+
+// Tagger derives the tags of incidents from the keywords in their titles and descriptions
+type Tagger struct {
+	keywords []keyword
+}
+
+type keyword struct {
+	tag   string
+	words []string
+}
+
+// NewTagger returns a tagger that tags incidents with each tag that has one of its keywords in the incident
+// The tags are lower case and keywords are matched case insensitively on whole words, e.g. db matches "DB latency" but not "dbt"
+func NewTagger(keywords map[string][]string) *Tagger {
+	tagger := &Tagger{}
+	for tag, tagKeywords := range keywords {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		for _, tagKeyword := range tagKeywords {
+			if words := splitWords(tagKeyword); len(words) > 0 {
+				tagger.keywords = append(tagger.keywords, keyword{tag: tag, words: words})
+			}
+		}
+	}
+	return tagger
+}
+
+// Tag returns copies of the incidents with their tags, see Tags
+func (t *Tagger) Tag(incidents []api.Incident) []api.Incident {
+	tagged := make([]api.Incident, 0, len(incidents))
+	for _, incident := range incidents {
+		description := ""
+		if incident.Description != nil {
+			description = *incident.Description
+		}
+		incident.Tags = t.Tags(incident.Title, description)
+		tagged = append(tagged, incident)
+	}
+	return tagged
+}
+
+// Tags returns the sorted tags that have a keyword in the title or description, or nil if none of them do
+func (t *Tagger) Tags(title string, description string) []string {
+	words := append(splitWords(title), splitWords(description)...)
+	found := make(map[string]bool)
+	for _, keyword := range t.keywords {
+		if !found[keyword.tag] && containsWords(words, keyword.words) {
+			found[keyword.tag] = true
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	tags := make([]string, 0, len(found))
+	for tag := range found {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// splitWords returns the lower case words of the text, which are separated by anything that isn't a letter or digit
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsWords returns true if the sequence of words appears in the words
+func containsWords(words []string, sequence []string) bool {
+	for i := 0; i+len(sequence) <= len(words); i++ {
+		matches := true
+		for j, word := range sequence {
+			if words[i+j] != word {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
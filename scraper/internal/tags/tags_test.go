@@ -0,0 +1,75 @@
+package tags
+
+import (
+	"github.com/metoro-io/statusphere/common/api"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTagsMatchesKeywordsOnWholeWords(t *testing.T) {
+	tagger := NewTagger(map[string][]string{
+		"network":  {"dns", "packet loss"},
+		"database": {"db", "Postgres"},
+		"auth":     {"login"},
+	})
+	tests := []struct {
+		title       string
+		description string
+		expected    []string
+	}{
+		{title: "Elevated DNS resolution failures", expected: []string{"network"}},
+		{title: "Degraded performance", description: "We are seeing packet loss between our postgres replicas", expected: []string{"database", "network"}},
+		{title: "Login failures for some users", description: "Logins are failing as the DB is unavailable", expected: []string{"auth", "database"}},
+		{title: "dbt jobs are delayed", expected: nil},
+		{title: "Packet delays", description: "Some loss of metrics", expected: nil},
+		{title: "DB/DNS issues", expected: []string{"database", "network"}},
+		{title: "", expected: nil},
+	}
+	for _, test := range tests {
+		if tags := tagger.Tags(test.title, test.description); !reflect.DeepEqual(tags, test.expected) {
+			t.Fatalf("%q %q: expected the tags %v, got %v", test.title, test.description, test.expected, tags)
+		}
+	}
+}
+
+func TestTagSetsTheTagsOfCopiesOfTheIncidents(t *testing.T) {
+	description := "The primary database failed over"
+	incidents := []api.Incident{
+		api.NewIncident("Elevated API latency", nil, nil, time.Now(), nil, &description, "https://status.example.com/incidents/1", api.ImpactMajor, "https://status.example.com"),
+		api.NewIncident("Scheduled maintenance", nil, nil, time.Now(), nil, nil, "https://status.example.com/incidents/2", api.ImpactMaintenance, "https://status.example.com"),
+	}
+	incidents[1].Tags = []string{"stale"}
+	tagged := NewTagger(DefaultKeywords).Tag(incidents)
+	if !reflect.DeepEqual(tagged[0].Tags, []string{"api", "database", "network"}) || tagged[1].Tags != nil {
+		t.Fatalf("unexpected tags %v and %v", tagged[0].Tags, tagged[1].Tags)
+	}
+	if incidents[0].Tags != nil || !reflect.DeepEqual(incidents[1].Tags, []string{"stale"}) {
+		t.Fatalf("expected the incidents passed in to be unchanged")
+	}
+}
+
+func TestLoadKeywordsReadsTheKeywordsFile(t *testing.T) {
+	keywords, err := LoadKeywords(Config{})
+	if err != nil || !reflect.DeepEqual(keywords, DefaultKeywords) {
+		t.Fatalf("expected the default keywords without a keywords file, got %v, %v", keywords, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "keywords.json")
+	if err := os.WriteFile(path, []byte(`{"queues": ["kafka", "message queue"]}`), 0o600); err != nil {
+		t.Fatalf("failed to write the keywords file: %v", err)
+	}
+	keywords, err = LoadKeywords(Config{KeywordsFile: path})
+	if err != nil || !reflect.DeepEqual(keywords, map[string][]string{"queues": {"kafka", "message queue"}}) {
+		t.Fatalf("expected the keywords of the file, got %v, %v", keywords, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`["kafka"]`), 0o600); err != nil {
+		t.Fatalf("failed to write the keywords file: %v", err)
+	}
+	if _, err := LoadKeywords(Config{KeywordsFile: path}); err == nil {
+		t.Fatalf("expected an invalid keywords file to fail")
+	}
+}
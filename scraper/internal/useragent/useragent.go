@@ -0,0 +1,44 @@
+package useragent
+
+import (
+	"net/http"
+)
+
+type Config struct {
+	// UserAgent identifies the scraper to status page hosts, some of which block requests without one
+	// It is also the user agent that the groups of robots.txt files are matched against
+	UserAgent string `envconfig:"USER_AGENT" default:"Statusphere/1.0 (+https://github.com/metoro-io/statusphere)"`
+	// From is an email address that the operators of status page hosts can contact about the scraper, it isn't sent if it is empty
+	From string `envconfig:"FROM"`
+}
+
+// Transport sets the User-Agent and From headers of the requests that don't already have them
+type Transport struct {
+	next   http.RoundTripper
+	config Config
+}
+
+// NewTransport returns a transport that makes the requests with next, identifying the scraper as described by the config
+func NewTransport(next http.RoundTripper, config Config) *Transport {
+	return &Transport{
+		next:   next,
+		config: config,
+	}
+}
+
+func (t *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	setUserAgent := t.config.UserAgent != "" && request.Header.Get("User-Agent") == ""
+	setFrom := t.config.From != "" && request.Header.Get("From") == ""
+	if !setUserAgent && !setFrom {
+		return t.next.RoundTrip(request)
+	}
+	// A RoundTripper mustn't modify the request, so the headers are set on a copy of it
+	request = request.Clone(request.Context())
+	if setUserAgent {
+		request.Header.Set("User-Agent", t.config.UserAgent)
+	}
+	if setFrom {
+		request.Header.Set("From", t.config.From)
+	}
+	return t.next.RoundTrip(request)
+}
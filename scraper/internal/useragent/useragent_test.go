@@ -0,0 +1,63 @@
+package useragent
+
+import (
+	"github.com/metoro-io/statusphere/scraper/internal/robots"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTransportSetsTheHeadersOfEveryRequest(t *testing.T) {
+	var mu sync.Mutex
+	headers := map[string]http.Header{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers[r.URL.Path] = r.Header.Clone()
+		mu.Unlock()
+		if r.URL.Path == "/robots.txt" {
+			_, _ = w.Write([]byte("User-agent: *\nAllow: /\n"))
+		}
+	}))
+	defer server.Close()
+
+	config := Config{UserAgent: "Statusphere/1.0 (+https://statusphere.example.com)", From: "scraper@example.com"}
+	// The robots.txt fetches are made with the transport that the robots transport wraps, so they are identified too
+	client := &http.Client{Transport: robots.NewTransport(NewTransport(http.DefaultTransport, config), robots.Config{CacheTTL: time.Hour}, config.UserAgent, zap.NewNop())}
+	response, err := client.Get(server.URL + "/history")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+
+	for _, path := range []string{"/robots.txt", "/history"} {
+		header, found := headers[path]
+		if !found {
+			t.Fatalf("%s: expected a request", path)
+		}
+		if header.Get("User-Agent") != config.UserAgent || header.Get("From") != config.From {
+			t.Fatalf("%s: expected the configured headers, got User-Agent %q and From %q", path, header.Get("User-Agent"), header.Get("From"))
+		}
+	}
+}
+
+func TestTransportKeepsTheHeadersOfTheRequest(t *testing.T) {
+	var userAgent, from string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent, from = r.UserAgent(), r.Header.Get("From")
+	}))
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	request.Header.Set("User-Agent", "custom")
+	response, err := NewTransport(http.DefaultTransport, Config{UserAgent: "Statusphere/1.0"}).RoundTrip(request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	if userAgent != "custom" || from != "" {
+		t.Fatalf("expected the user agent of the request and no From header, got %q and %q", userAgent, from)
+	}
+}
@@ -3,29 +3,69 @@ package main
 import (
 	"context"
 	"github.com/metoro-io/statusphere/common/db"
+	"github.com/metoro-io/statusphere/scraper/internal/admin"
+	config2 "github.com/metoro-io/statusphere/scraper/internal/config"
+	"github.com/metoro-io/statusphere/scraper/internal/retry"
+	"github.com/metoro-io/statusphere/scraper/internal/robots"
+	"github.com/metoro-io/statusphere/scraper/internal/sanitize"
 	"github.com/metoro-io/statusphere/scraper/internal/scraper"
 	"github.com/metoro-io/statusphere/scraper/internal/scraper/consumers"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper/consumers/changenotifier"
 	"github.com/metoro-io/statusphere/scraper/internal/scraper/consumers/dbconsumer"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper/consumers/sanitizeconsumer"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper/consumers/tagconsumer"
 	"github.com/metoro-io/statusphere/scraper/internal/scraper/dbgroomer"
 	"github.com/metoro-io/statusphere/scraper/internal/scraper/poller"
 	"github.com/metoro-io/statusphere/scraper/internal/scraper/providers"
 	"github.com/metoro-io/statusphere/scraper/internal/scraper/providers/atlassian"
+	"github.com/metoro-io/statusphere/scraper/internal/scraper/providers/instatus"
 	"github.com/metoro-io/statusphere/scraper/internal/scraper/providers/rss"
 	"github.com/metoro-io/statusphere/scraper/internal/scraper/urlgetter/dburlgetter"
+	"github.com/metoro-io/statusphere/scraper/internal/tags"
+	"github.com/metoro-io/statusphere/scraper/internal/useragent"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 	"net/http"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
+	// The context is cancelled on shutdown, which stops the poller and cancels the scrapes in progress
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	logger, err := zap.NewProduction()
 	if err != nil {
 		panic(err)
 	}
+	// Flush any buffered logs on exit
+	defer func() { _ = logger.Sync() }()
 
-	scraper := scraper.NewScraper(logger, http.DefaultClient, []providers.Provider{
-		atlassian.NewAtlassianProvider(logger, http.DefaultClient),
-		rss.NewRssProvider(logger, http.DefaultClient),
-	})
+	config, err := config2.GetConfigFromEnvironment()
+	if err != nil {
+		logger.Error("failed to get config from environment", zap.Error(err))
+		return
+	}
+
+	// Requests to status pages that fail transiently are retried, so that a blip doesn't fail the scrape for a whole cycle
+	// Only the requests that robots.txt allows are made, each of them is retried if it fails transiently
+	// Every request identifies the scraper, including the robots.txt fetches
+	transport := retry.NewTransport(useragent.NewTransport(http.DefaultTransport, config.UserAgent), config.Retry, logger)
+	httpClient := &http.Client{Transport: robots.NewTransport(transport, config.Robots, config.UserAgent.UserAgent, logger)}
+
+	keywords, err := tags.LoadKeywords(config.Tags)
+	if err != nil {
+		logger.Error("failed to load the tag keywords", zap.Error(err))
+		return
+	}
+
+	sanitizer, err := sanitize.NewSanitizer(config.Descriptions)
+	if err != nil {
+		logger.Error("failed to create the description sanitizer", zap.Error(err))
+		return
+	}
 
 	dbClient, err := db.NewDbClientFromEnvironment(logger)
 	if err != nil {
@@ -33,22 +73,70 @@ func main() {
 		return
 	}
 
-	err = dbClient.AutoMigrate(context.Background())
+	err = dbClient.AutoMigrate(ctx)
 	if err != nil {
 		logger.Error("failed to auto migrate", zap.Error(err))
 		return
 	}
 
-	getter := dburlgetter.NewDBURLGetter(logger, dbClient)
+	registry := providers.NewRegistry(
+		atlassian.NewAtlassianProvider(logger, httpClient),
+		rss.NewRssProvider(logger, httpClient),
+		instatus.NewInstatusProvider(logger),
+	)
+	dryRunner := scraper.NewDryRunner(logger, httpClient, registry)
+	scraper := scraper.NewScraper(logger, httpClient, registry, dbClient, config.Snapshots)
+
+	getter := dburlgetter.NewDBURLGetter(logger, dbClient, config.Hosts)
 	getter.Start()
 	dbGroomer := dbgroomer.NewDbGroomer(logger, dbClient)
 	dbGroomer.Groom()
 	poller := poller.NewPoller(getter, scraper, []consumers.Consumer{
-		dbconsumer.NewDbConsumer(logger, dbClient),
-	}, logger)
-	err = poller.Poll()
+		// The incidents are tagged before they're stored, so that the tags are stored and returned with them
+		// Their descriptions are sanitized first so that they're tagged on the text of the descriptions rather than their html
+		sanitizeconsumer.NewSanitizeConsumer(tagconsumer.NewTagConsumer(dbconsumer.NewDbConsumer(logger, dbClient, changenotifier.NewChangeNotifier(logger, dbClient)), tags.NewTagger(keywords)), sanitizer),
+	}, config.Poller, logger)
+	if config.MetricsAddress != "" {
+		go serveMetrics(ctx, config.MetricsAddress, logger)
+	}
+	if config.AdminAddress != "" {
+		go serveAdmin(ctx, config.AdminAddress, admin.NewDryRunHandler(dryRunner, config.AdminApiKey, logger), logger)
+	}
+	err = poller.Poll(ctx)
 	if err != nil {
 		logger.Error("failed to poll", zap.Error(err))
 		return
 	}
+	logger.Info("Scraper stopped")
+}
+
+// serveMetrics serves the prometheus metrics at /metrics on the address until the context is done
+func serveMetrics(ctx context.Context, address string, logger *zap.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	if err := serveUntilDone(ctx, address, mux); err != nil {
+		logger.Error("failed to serve metrics", zap.Error(err))
+	}
+}
+
+// serveAdmin serves the dry run of indexing a status page at POST /admin/index/dry-run on the address until the context is done
+func serveAdmin(ctx context.Context, address string, dryRun http.Handler, logger *zap.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/admin/index/dry-run", dryRun)
+	if err := serveUntilDone(ctx, address, mux); err != nil {
+		logger.Error("failed to serve admin endpoints", zap.Error(err))
+	}
+}
+
+// serveUntilDone serves the handler on the address until the context is done, it returns nil once the server is closed
+func serveUntilDone(ctx context.Context, address string, handler http.Handler) error {
+	server := &http.Server{Addr: address, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }